@@ -0,0 +1,184 @@
+// eval 评测知识检索和问答效果
+//
+// 读取JSONL格式的评测集（问题、期望的回答、相关文档ID），对每个问题检索知识并对话，
+// 计算recall@k、MRR、回答相似度和模型评审的忠实度，输出JSON结果和Markdown报告。
+// 可以评测运行中的服务（-server），也可以按配置在进程内创建服务；
+// 配置使用fake模型和内存向量存储时无需任何外部服务。
+//
+//	go run ./cmd/eval -config configs/eval.yaml -corpus configs/eval/corpus.jsonl -dataset configs/eval/dataset.jsonl
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"eino/internal/agent"
+	"eino/internal/config"
+	"eino/internal/model"
+)
+
+// Case 评测集中的一个问题
+type Case struct {
+	ID             string   `json:"id"`
+	Question       string   `json:"question"`
+	Answer         string   `json:"answer"`                    // 期望的回答
+	RelevantIDs    []string `json:"relevant_ids"`              // 相关文档ID：语料中的id、服务中的document_id、来源或分块ID
+	KnowledgeBases []string `json:"knowledge_bases,omitempty"` // 为空时使用-kb
+}
+
+// Document 评测前导入的语料
+type Document struct {
+	ID            string `json:"id"`
+	KnowledgeBase string `json:"knowledge_base,omitempty"` // 为空时写入默认知识库
+	Title         string `json:"title,omitempty"`
+	Content       string `json:"content"`
+}
+
+func main() {
+	configPath := flag.String("config", "", "配置文件，默认为环境变量CONFIG_PATH或configs/config.yaml")
+	datasetPath := flag.String("dataset", "", "评测集（JSONL）")
+	corpusPath := flag.String("corpus", "", "评测前导入的语料（JSONL），可选")
+	serverURL := flag.String("server", "", "评测运行中的服务，如 http://localhost:8080；为空时在进程内创建服务")
+	chatbotID := flag.String("chatbot", "", "对话使用的聊天机器人，为空时每个问题新建一个（避免对话历史互相影响）")
+	k := flag.Int("k", 5, "检索条数，即recall@k的k")
+	mode := flag.String("mode", "", "检索方式：hybrid, vector, keyword；为空时使用服务的配置")
+	kbs := flag.String("kb", "", "检索的知识库，逗号分隔，默认default")
+	judgeModel := flag.String("judge-model", "", "评审忠实度的模型，为空时使用model.model")
+	skipChat := flag.Bool("skip-chat", false, "只评测检索，不对话")
+	outPath := flag.String("out", "eval.json", "JSON结果文件")
+	reportPath := flag.String("report", "eval.md", "Markdown报告文件")
+	flag.Parse()
+
+	if *datasetPath == "" {
+		log.Fatal("-dataset is required")
+	}
+	if err := model.ValidateSearchMode(*mode); err != nil {
+		log.Fatal(err)
+	}
+
+	path := *configPath
+	if path == "" {
+		path = os.Getenv("CONFIG_PATH")
+	}
+	if path == "" {
+		path = "configs/config.yaml"
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	cases, err := readJSONL[Case](*datasetPath)
+	if err != nil {
+		log.Fatalf("Failed to read dataset: %v", err)
+	}
+	var corpus []Document
+	if *corpusPath != "" {
+		if corpus, err = readJSONL[Document](*corpusPath); err != nil {
+			log.Fatalf("Failed to read corpus: %v", err)
+		}
+	}
+
+	ctx := context.Background()
+	var t target
+	if *serverURL != "" {
+		t = newHTTPTarget(*serverURL, time.Duration(cfg.Model.Timeout+cfg.RAG.EmbedTimeout)*time.Second)
+	} else {
+		if t, err = newLocalTarget(cfg); err != nil {
+			log.Fatalf("Failed to create service: %v", err)
+		}
+	}
+	defer t.Close()
+
+	judge, err := agent.NewChatModel(ctx, cfg.Model, *judgeModel)
+	if err != nil {
+		log.Fatalf("Failed to create judge model: %v", err)
+	}
+
+	e := &evaluator{
+		target:         t,
+		judge:          judge,
+		judgeTimeout:   cfg.GetModelTimeout(),
+		k:              *k,
+		mode:           *mode,
+		knowledgeBases: splitList(*kbs),
+		chatbotID:      *chatbotID,
+		skipChat:       *skipChat,
+		documents:      make(map[string]string),
+		chunks:         make(map[int64]*model.KnowledgeChunk),
+	}
+	if err := e.loadCorpus(ctx, corpus); err != nil {
+		log.Fatalf("Failed to load corpus: %v", err)
+	}
+
+	report := e.run(ctx, cases)
+	report.Dataset = *datasetPath
+	report.Target = "in-process"
+	if *serverURL != "" {
+		report.Target = *serverURL
+	}
+
+	if err := writeJSON(*outPath, report); err != nil {
+		log.Fatalf("Failed to write results: %v", err)
+	}
+	if err := os.WriteFile(*reportPath, []byte(report.Markdown()), 0o644); err != nil {
+		log.Fatalf("Failed to write report: %v", err)
+	}
+	log.Printf("Evaluated %d cases: recall@%d=%s MRR=%s, results in %s and %s",
+		report.Summary.Cases, report.K, formatMetric(report.Summary.Recall), formatMetric(report.Summary.MRR), *outPath, *reportPath)
+}
+
+// readJSONL 逐行解析JSONL文件，跳过空行
+func readJSONL[T any](path string) ([]T, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var items []T
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var item T
+		if err := json.Unmarshal([]byte(text), &item); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		items = append(items, item)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// writeJSON 把v格式化写入文件
+func writeJSON(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// splitList 按逗号拆分，去掉空白和空项
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"eino/internal/llmjson"
+	"eino/internal/model"
+	"eino/internal/storage/keyword"
+
+	einomodel "github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// judgePrompt 评审回答忠实度使用的系统提示词
+const judgePrompt = `你负责评审回答是否忠实于给出的资料：回答中的每个说法都能在资料中找到依据时为10分，完全没有依据或与资料矛盾时为0分。
+只输出JSON，不要输出其他内容：{"score": 0到10的数字, "reason": "简述原因"}`
+
+// citationMarker 回答中的引用编号，计算相似度前去掉
+var citationMarker = regexp.MustCompile(`\[\d+(?:\s*[,，、]\s*\d+)*\]`)
+
+// evaluator 逐个问题检索、对话并计算指标
+type evaluator struct {
+	target         target
+	judge          einomodel.BaseChatModel
+	judgeTimeout   time.Duration
+	k              int
+	mode           string
+	knowledgeBases []string
+	chatbotID      string
+	skipChat       bool
+
+	documents map[string]string               // 服务中的document_id -> 语料中的id
+	chunks    map[int64]*model.KnowledgeChunk // 已查询的分块
+}
+
+// loadCorpus 导入语料，记录服务分配的document_id对应的语料id
+func (e *evaluator) loadCorpus(ctx context.Context, corpus []Document) error {
+	created := make(map[string]bool)
+	for i, doc := range corpus {
+		if doc.KnowledgeBase != "" && !created[doc.KnowledgeBase] {
+			if err := e.target.EnsureKnowledgeBase(ctx, doc.KnowledgeBase); err != nil {
+				return fmt.Errorf("create knowledge base %s: %w", doc.KnowledgeBase, err)
+			}
+			created[doc.KnowledgeBase] = true
+		}
+
+		content := doc.Content
+		if doc.Title != "" {
+			content = "# " + doc.Title + "\n\n" + content
+		}
+		documentID, err := e.target.AddKnowledge(ctx, doc.KnowledgeBase, content)
+		if err != nil {
+			return fmt.Errorf("add document %d (%s): %w", i+1, doc.ID, err)
+		}
+		if doc.ID != "" {
+			e.documents[documentID] = doc.ID
+		}
+	}
+	if len(corpus) > 0 {
+		log.Printf("Loaded %d documents", len(corpus))
+	}
+	return nil
+}
+
+// run 评测所有问题；单个问题出错时记录在结果中，继续评测其他问题
+func (e *evaluator) run(ctx context.Context, cases []Case) *Report {
+	report := &Report{
+		K:         e.k,
+		Mode:      e.mode,
+		StartedAt: time.Now(),
+		Results:   make([]*CaseResult, 0, len(cases)),
+	}
+	for i, c := range cases {
+		if c.ID == "" {
+			c.ID = strconv.Itoa(i + 1)
+		}
+		result := e.evaluate(ctx, c)
+		if result.Error != "" {
+			log.Printf("Case %s: %s", c.ID, result.Error)
+		}
+		report.Results = append(report.Results, result)
+	}
+	report.DurationMS = time.Since(report.StartedAt).Milliseconds()
+	report.Summary = summarize(report.Results)
+	return report
+}
+
+// evaluate 评测一个问题
+func (e *evaluator) evaluate(ctx context.Context, c Case) *CaseResult {
+	result := &CaseResult{ID: c.ID, Question: c.Question, Expected: c.Answer, RelevantIDs: c.RelevantIDs}
+	kbs := c.KnowledgeBases
+	if len(kbs) == 0 {
+		kbs = e.knowledgeBases
+	}
+
+	start := time.Now()
+	hits, err := e.target.SearchKnowledge(ctx, c.Question, model.SearchOptions{KnowledgeBases: kbs, TopK: e.k, Mode: e.mode})
+	result.SearchMS = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Error = fmt.Sprintf("search: %v", err)
+		return result
+	}
+	for _, hit := range hits {
+		result.Retrieved = append(result.Retrieved, e.hitID(ctx, hit))
+	}
+	if len(c.RelevantIDs) > 0 {
+		recall, rank := e.matchRelevant(ctx, hits, c.RelevantIDs)
+		result.Recall = &recall
+		mrr := 0.0
+		if rank > 0 {
+			mrr = 1 / float64(rank)
+		}
+		result.MRR = &mrr
+	}
+
+	if e.skipChat {
+		return result
+	}
+	start = time.Now()
+	resp, err := e.chat(ctx, c.Question, kbs)
+	result.ChatMS = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Error = fmt.Sprintf("chat: %v", err)
+		return result
+	}
+	result.Answer = resp.Message
+	result.RetrievalUsed = resp.RetrievalUsed
+	for _, source := range resp.Sources {
+		result.Sources = append(result.Sources, source.ChunkID)
+	}
+	if c.Answer != "" {
+		similarity := answerSimilarity(resp.Message, c.Answer)
+		result.AnswerSimilarity = &similarity
+	}
+
+	faithfulness, reason, err := e.judgeFaithfulness(ctx, hits, resp.Message)
+	if err != nil {
+		result.Error = fmt.Sprintf("judge: %v", err)
+		return result
+	}
+	result.Faithfulness = &faithfulness
+	result.JudgeReason = reason
+	return result
+}
+
+// chat 向指定的聊天机器人提问；未指定时新建一个绑定kbs的机器人，问完删除，避免问题之间共享对话历史
+func (e *evaluator) chat(ctx context.Context, question string, kbs []string) (*model.ChatResponse, error) {
+	if e.chatbotID != "" {
+		return e.target.Chat(ctx, e.chatbotID, question)
+	}
+
+	chatbotID, err := e.target.CreateChatbot(ctx, &model.CreateChatbotRequest{
+		Name:        "eval",
+		Personality: "严谨、简洁",
+		Background:  "根据知识库中的资料回答问题的助手",
+		Options: model.GenerationOptions{
+			Retrieval: &model.RetrievalOptions{KnowledgeBases: kbs, Mode: e.mode, RAG: model.RAGModeAlways},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create chatbot: %w", err)
+	}
+	defer func() {
+		if err := e.target.DeleteChatbot(ctx, chatbotID); err != nil {
+			log.Printf("Warning: failed to delete chatbot %s: %v", chatbotID, err)
+		}
+	}()
+	return e.target.Chat(ctx, chatbotID, question)
+}
+
+// chunk 查询检索结果对应的分块，结果缓存；查询失败时返回nil
+func (e *evaluator) chunk(ctx context.Context, id int64) *model.KnowledgeChunk {
+	if chunk, ok := e.chunks[id]; ok {
+		return chunk
+	}
+	chunk, err := e.target.GetKnowledge(ctx, id)
+	if err != nil {
+		log.Printf("Warning: failed to get knowledge %d: %v", id, err)
+		chunk = nil
+	}
+	e.chunks[id] = chunk
+	return chunk
+}
+
+// hitIDs 检索结果可以与relevant_ids匹配的标识：分块ID、来源、document_id和语料id
+func (e *evaluator) hitIDs(ctx context.Context, hit *model.KnowledgeHit) []string {
+	ids := []string{strconv.FormatInt(hit.ID, 10)}
+	if hit.Source != "" {
+		ids = append(ids, hit.Source)
+	}
+	if chunk := e.chunk(ctx, hit.ID); chunk != nil && chunk.DocumentID != "" {
+		ids = append(ids, chunk.DocumentID)
+		if id, ok := e.documents[chunk.DocumentID]; ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// hitID 报告中展示的检索结果标识，优先使用语料id
+func (e *evaluator) hitID(ctx context.Context, hit *model.KnowledgeHit) string {
+	ids := e.hitIDs(ctx, hit)
+	return ids[len(ids)-1]
+}
+
+// matchRelevant 返回前k条结果覆盖的相关文档比例，以及第一条相关结果的名次（没有时为0）
+func (e *evaluator) matchRelevant(ctx context.Context, hits []*model.KnowledgeHit, relevantIDs []string) (float64, int) {
+	relevant := make(map[string]bool, len(relevantIDs))
+	for _, id := range relevantIDs {
+		relevant[id] = true
+	}
+
+	found := make(map[string]bool)
+	firstRank := 0
+	for i, hit := range hits {
+		if i >= e.k {
+			break
+		}
+		for _, id := range e.hitIDs(ctx, hit) {
+			if !relevant[id] {
+				continue
+			}
+			found[id] = true
+			if firstRank == 0 {
+				firstRank = i + 1
+			}
+		}
+	}
+	return float64(len(found)) / float64(len(relevant)), firstRank
+}
+
+// answerSimilarity 回答与期望回答的词级F1，去掉引用编号
+func answerSimilarity(answer, expected string) float64 {
+	answerTerms := termCounts(citationMarker.ReplaceAllString(answer, ""))
+	expectedTerms := termCounts(expected)
+	var answerTotal, expectedTotal, common int
+	for term, n := range answerTerms {
+		answerTotal += n
+		common += min(n, expectedTerms[term])
+	}
+	for _, n := range expectedTerms {
+		expectedTotal += n
+	}
+	if common == 0 {
+		return 0
+	}
+	precision := float64(common) / float64(answerTotal)
+	recall := float64(common) / float64(expectedTotal)
+	return 2 * precision * recall / (precision + recall)
+}
+
+// termCounts 按关键词索引的分词统计词频
+func termCounts(text string) map[string]int {
+	counts := make(map[string]int)
+	for _, term := range keyword.Tokenize(text) {
+		counts[term]++
+	}
+	return counts
+}
+
+// judgeFaithfulness 让模型按检索到的资料给回答打分，返回0到1的忠实度和理由；没有资料时回答没有依据，为0
+func (e *evaluator) judgeFaithfulness(ctx context.Context, hits []*model.KnowledgeHit, answer string) (float64, string, error) {
+	if len(hits) == 0 {
+		return 0, "no retrieved context", nil
+	}
+
+	var b strings.Builder
+	b.WriteString("资料：\n")
+	for i, hit := range hits {
+		fmt.Fprintf(&b, "[%d] %s\n\n", i+1, hit.Content)
+	}
+	fmt.Fprintf(&b, "回答：\n%s", answer)
+
+	ctx, cancel := context.WithTimeout(ctx, e.judgeTimeout)
+	defer cancel()
+	response, err := e.judge.Generate(ctx, []*schema.Message{
+		schema.SystemMessage(judgePrompt),
+		schema.UserMessage(b.String()),
+	}, einomodel.WithTemperature(0))
+	if err != nil {
+		return 0, "", err
+	}
+
+	var result struct {
+		Score  *float64 `json:"score"`
+		Reason string   `json:"reason"`
+	}
+	if err := llmjson.Unmarshal(response.Content, &result); err != nil {
+		return 0, "", fmt.Errorf("parse judge result %q: %w", response.Content, err)
+	}
+	if result.Score == nil {
+		return 0, "", fmt.Errorf("judge result %q has no score", response.Content)
+	}
+	return min(max(*result.Score/10, 0), 1), result.Reason, nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"eino/internal/model"
+)
+
+// stubTarget 返回固定检索结果的评测对象，只实现计算检索指标用到的方法
+type stubTarget struct {
+	target
+	hits      []*model.KnowledgeHit
+	documents map[int64]string // 分块ID -> document_id
+}
+
+func (t *stubTarget) SearchKnowledge(context.Context, string, model.SearchOptions) ([]*model.KnowledgeHit, error) {
+	return t.hits, nil
+}
+
+func (t *stubTarget) GetKnowledge(_ context.Context, id int64) (*model.KnowledgeChunk, error) {
+	documentID, ok := t.documents[id]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return &model.KnowledgeChunk{ID: id, DocumentID: documentID}, nil
+}
+
+func approx(v *float64, want float64) bool {
+	return v != nil && math.Abs(*v-want) < 1e-9
+}
+
+func TestRetrievalMetrics(t *testing.T) {
+	// 分块1、2属于语料refund，3属于leave，4来自wifi.md，5查询失败
+	stub := &stubTarget{
+		hits: []*model.KnowledgeHit{
+			{ID: 3}, {ID: 1}, {ID: 4, Source: "wifi.md"}, {ID: 2}, {ID: 5},
+		},
+		documents: map[int64]string{1: "doc-1", 2: "doc-1", 3: "doc-3", 4: "doc-4"},
+	}
+
+	tests := []struct {
+		name     string
+		k        int
+		relevant []string
+		recall   float64
+		mrr      float64
+	}{
+		{"FirstHit", 3, []string{"leave"}, 1, 1},
+		{"SecondHit", 3, []string{"refund"}, 1, 0.5},
+		{"PartialRecall", 3, []string{"refund", "missing"}, 0.5, 0.5},
+		{"BySource", 3, []string{"wifi.md", "leave"}, 1, 1},
+		{"ByChunkID", 5, []string{"2"}, 1, 0.25},
+		{"ByDocumentID", 3, []string{"doc-4"}, 1, 1.0 / 3},
+		{"BeyondK", 3, []string{"2"}, 0, 0},
+		{"DuplicateChunks", 5, []string{"refund", "leave"}, 1, 1},
+		{"NoMatch", 5, []string{"missing"}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &evaluator{
+				target:    stub,
+				k:         tt.k,
+				skipChat:  true,
+				documents: map[string]string{"doc-1": "refund", "doc-3": "leave"},
+				chunks:    make(map[int64]*model.KnowledgeChunk),
+			}
+			result := e.evaluate(context.Background(), Case{ID: "1", Question: "q", RelevantIDs: tt.relevant})
+			if result.Error != "" {
+				t.Fatalf("evaluate: %s", result.Error)
+			}
+			if !approx(result.Recall, tt.recall) || !approx(result.MRR, tt.mrr) {
+				t.Fatalf("recall@%d = %s, MRR = %s, want %v, %v",
+					tt.k, formatMetric(result.Recall), formatMetric(result.MRR), tt.recall, tt.mrr)
+			}
+		})
+	}
+
+	t.Run("NoRelevantIDs", func(t *testing.T) {
+		e := &evaluator{target: stub, k: 3, skipChat: true, documents: map[string]string{}, chunks: make(map[int64]*model.KnowledgeChunk)}
+		result := e.evaluate(context.Background(), Case{ID: "1", Question: "q"})
+		if result.Recall != nil || result.MRR != nil {
+			t.Fatalf("recall = %s, MRR = %s, want none", formatMetric(result.Recall), formatMetric(result.MRR))
+		}
+	})
+}
+
+func TestSummarize(t *testing.T) {
+	one, half, zero := 1.0, 0.5, 0.0
+	summary := summarize([]*CaseResult{
+		{Recall: &one, MRR: &one, SearchMS: 10},
+		{Recall: &zero, MRR: &zero, SearchMS: 20, Error: "chat: timeout"},
+		{Recall: &half, MRR: &half, SearchMS: 30},
+		{SearchMS: 40}, // 没有相关文档ID，不计入检索指标
+	})
+	if summary.Cases != 4 || summary.Errors != 1 || summary.AvgSearchMS != 25 {
+		t.Fatalf("summary = %+v", summary)
+	}
+	if !approx(summary.Recall, 0.5) || !approx(summary.MRR, 0.5) {
+		t.Fatalf("recall = %s, MRR = %s, want 0.5, 0.5", formatMetric(summary.Recall), formatMetric(summary.MRR))
+	}
+	if summary.AnswerSimilarity != nil || summary.Faithfulness != nil {
+		t.Fatal("answer metrics without any answered case")
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Report 评测结果
+type Report struct {
+	Target     string        `json:"target"` // 服务地址，进程内评测时为in-process
+	Dataset    string        `json:"dataset"`
+	K          int           `json:"k"`
+	Mode       string        `json:"mode,omitempty"`
+	StartedAt  time.Time     `json:"started_at"`
+	DurationMS int64         `json:"duration_ms"`
+	Summary    Summary       `json:"summary"`
+	Results    []*CaseResult `json:"results"`
+}
+
+// Summary 各指标在有该指标的问题上的平均值，没有问题有该指标时为空
+type Summary struct {
+	Cases            int      `json:"cases"`
+	Errors           int      `json:"errors"`
+	Recall           *float64 `json:"recall"`
+	MRR              *float64 `json:"mrr"`
+	AnswerSimilarity *float64 `json:"answer_similarity"`
+	Faithfulness     *float64 `json:"faithfulness"`
+	AvgSearchMS      int64    `json:"avg_search_ms"`
+	AvgChatMS        int64    `json:"avg_chat_ms"`
+}
+
+// CaseResult 一个问题的评测结果；没有相关文档ID、期望回答或未对话时对应的指标为空
+type CaseResult struct {
+	ID               string   `json:"id"`
+	Question         string   `json:"question"`
+	Expected         string   `json:"expected,omitempty"`
+	Answer           string   `json:"answer,omitempty"`
+	RelevantIDs      []string `json:"relevant_ids,omitempty"`
+	Retrieved        []string `json:"retrieved"`         // 检索结果的标识，优先使用语料id
+	Sources          []int64  `json:"sources,omitempty"` // 回答引用的分块ID
+	RetrievalUsed    bool     `json:"retrieval_used"`
+	Recall           *float64 `json:"recall,omitempty"`
+	MRR              *float64 `json:"mrr,omitempty"`
+	AnswerSimilarity *float64 `json:"answer_similarity,omitempty"`
+	Faithfulness     *float64 `json:"faithfulness,omitempty"`
+	JudgeReason      string   `json:"judge_reason,omitempty"`
+	SearchMS         int64    `json:"search_ms"`
+	ChatMS           int64    `json:"chat_ms,omitempty"`
+	Error            string   `json:"error,omitempty"`
+}
+
+// summarize 汇总各问题的指标
+func summarize(results []*CaseResult) Summary {
+	summary := Summary{Cases: len(results)}
+	var recall, mrr, similarity, faithfulness []float64
+	var searchMS, chatMS, chats int64
+	for _, r := range results {
+		if r.Error != "" {
+			summary.Errors++
+		}
+		recall = appendMetric(recall, r.Recall)
+		mrr = appendMetric(mrr, r.MRR)
+		similarity = appendMetric(similarity, r.AnswerSimilarity)
+		faithfulness = appendMetric(faithfulness, r.Faithfulness)
+		searchMS += r.SearchMS
+		if r.ChatMS > 0 {
+			chatMS += r.ChatMS
+			chats++
+		}
+	}
+	summary.Recall = mean(recall)
+	summary.MRR = mean(mrr)
+	summary.AnswerSimilarity = mean(similarity)
+	summary.Faithfulness = mean(faithfulness)
+	if len(results) > 0 {
+		summary.AvgSearchMS = searchMS / int64(len(results))
+	}
+	if chats > 0 {
+		summary.AvgChatMS = chatMS / chats
+	}
+	return summary
+}
+
+func appendMetric(values []float64, v *float64) []float64 {
+	if v == nil {
+		return values
+	}
+	return append(values, *v)
+}
+
+func mean(values []float64) *float64 {
+	if len(values) == 0 {
+		return nil
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	avg := sum / float64(len(values))
+	return &avg
+}
+
+// formatMetric 保留三位小数，没有该指标时为 -
+func formatMetric(v *float64) string {
+	if v == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.3f", *v)
+}
+
+// Markdown 生成评测报告：汇总指标和每个问题的结果
+func (r *Report) Markdown() string {
+	var b strings.Builder
+	b.WriteString("# RAG评测报告\n\n")
+	fmt.Fprintf(&b, "- 服务：%s\n", r.Target)
+	fmt.Fprintf(&b, "- 评测集：%s（%d个问题，%d个出错）\n", r.Dataset, r.Summary.Cases, r.Summary.Errors)
+	mode := r.Mode
+	if mode == "" {
+		mode = "服务配置"
+	}
+	fmt.Fprintf(&b, "- 检索：top %d，%s\n", r.K, mode)
+	fmt.Fprintf(&b, "- 时间：%s，耗时 %d ms\n\n", r.StartedAt.Format(time.RFC3339), r.DurationMS)
+
+	b.WriteString("## 汇总\n\n")
+	b.WriteString("| 指标 | 值 |\n| --- | --- |\n")
+	fmt.Fprintf(&b, "| recall@%d | %s |\n", r.K, formatMetric(r.Summary.Recall))
+	fmt.Fprintf(&b, "| MRR | %s |\n", formatMetric(r.Summary.MRR))
+	fmt.Fprintf(&b, "| 回答相似度 | %s |\n", formatMetric(r.Summary.AnswerSimilarity))
+	fmt.Fprintf(&b, "| 忠实度 | %s |\n", formatMetric(r.Summary.Faithfulness))
+	fmt.Fprintf(&b, "| 平均检索耗时 | %d ms |\n", r.Summary.AvgSearchMS)
+	fmt.Fprintf(&b, "| 平均对话耗时 | %d ms |\n\n", r.Summary.AvgChatMS)
+
+	b.WriteString("## 问题\n\n")
+	fmt.Fprintf(&b, "| ID | 问题 | recall@%d | MRR | 相似度 | 忠实度 | 检索结果 | 回答 |\n", r.K)
+	b.WriteString("| --- | --- | --- | --- | --- | --- | --- | --- |\n")
+	for _, c := range r.Results {
+		answer := c.Answer
+		if c.Error != "" {
+			answer = "**错误**：" + c.Error
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s | %s | %s |\n",
+			escapeCell(c.ID), escapeCell(c.Question),
+			formatMetric(c.Recall), formatMetric(c.MRR), formatMetric(c.AnswerSimilarity), formatMetric(c.Faithfulness),
+			escapeCell(strings.Join(c.Retrieved, ", ")), escapeCell(answer))
+	}
+	return b.String()
+}
+
+// escapeCell 转义表格单元格中的竖线和换行
+func escapeCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "<br>"), "\n", "<br>")
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"eino/internal/agent"
+	"eino/internal/config"
+	"eino/internal/model"
+	"eino/internal/service"
+	"eino/internal/storage"
+)
+
+// target 被评测的服务：进程内的ChatService或运行中的HTTP服务
+type target interface {
+	// EnsureKnowledgeBase 创建知识库，已存在时忽略
+	EnsureKnowledgeBase(ctx context.Context, name string) error
+	// AddKnowledge 添加知识，返回文档ID
+	AddKnowledge(ctx context.Context, knowledgeBase, content string) (string, error)
+	SearchKnowledge(ctx context.Context, query string, opts model.SearchOptions) ([]*model.KnowledgeHit, error)
+	GetKnowledge(ctx context.Context, id int64) (*model.KnowledgeChunk, error)
+	CreateChatbot(ctx context.Context, req *model.CreateChatbotRequest) (string, error)
+	DeleteChatbot(ctx context.Context, id string) error
+	Chat(ctx context.Context, chatbotID, message string) (*model.ChatResponse, error)
+	Close() error
+}
+
+// localTarget 按配置在进程内创建的服务，需要启用RAG
+type localTarget struct {
+	chat    *agent.ChatService
+	storage storage.Storage
+	rag     *service.RAGService
+}
+
+func newLocalTarget(cfg *config.Config) (*localTarget, error) {
+	if !cfg.RAG.Enabled {
+		return nil, errors.New("rag.enabled must be true to evaluate retrieval")
+	}
+	store, err := storage.NewStorage(cfg.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("init storage: %w", err)
+	}
+	chatService, err := agent.NewChatService(cfg, store)
+	if err != nil {
+		store.Close()
+		return nil, fmt.Errorf("init chat service: %w", err)
+	}
+	ragService, err := service.NewRAGService(cfg.RAG, cfg.Storage, store)
+	if err != nil {
+		store.Close()
+		return nil, fmt.Errorf("init rag service: %w", err)
+	}
+	chatService.SetRAGService(ragService)
+	return &localTarget{chat: chatService, storage: store, rag: ragService}, nil
+}
+
+func (t *localTarget) EnsureKnowledgeBase(ctx context.Context, name string) error {
+	_, err := t.chat.CreateKnowledgeBase(ctx, &model.CreateKnowledgeBaseRequest{Name: name})
+	if errors.Is(err, agent.ErrKnowledgeBaseExists) {
+		return nil
+	}
+	return err
+}
+
+func (t *localTarget) AddKnowledge(ctx context.Context, knowledgeBase, content string) (string, error) {
+	return t.chat.AddKnowledge(ctx, knowledgeBase, content)
+}
+
+func (t *localTarget) SearchKnowledge(ctx context.Context, query string, opts model.SearchOptions) ([]*model.KnowledgeHit, error) {
+	result, err := t.chat.SearchKnowledge(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	return result.Hits, nil
+}
+
+func (t *localTarget) GetKnowledge(ctx context.Context, id int64) (*model.KnowledgeChunk, error) {
+	return t.chat.GetKnowledge(ctx, id)
+}
+
+func (t *localTarget) CreateChatbot(ctx context.Context, req *model.CreateChatbotRequest) (string, error) {
+	chatbot, err := t.chat.CreateChatbot(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	return chatbot.ID, nil
+}
+
+func (t *localTarget) DeleteChatbot(ctx context.Context, id string) error {
+	return t.chat.DeleteChatbot(ctx, id)
+}
+
+func (t *localTarget) Chat(ctx context.Context, chatbotID, message string) (*model.ChatResponse, error) {
+	return t.chat.Chat(ctx, chatbotID, &model.ChatRequest{Message: message})
+}
+
+func (t *localTarget) Close() error {
+	t.rag.Close()
+	return t.storage.Close()
+}
+
+// httpTarget 通过 /api/v1 接口评测运行中的服务
+type httpTarget struct {
+	baseURL string
+	client  *http.Client
+}
+
+func newHTTPTarget(baseURL string, timeout time.Duration) *httpTarget {
+	return &httpTarget{
+		baseURL: strings.TrimRight(baseURL, "/") + "/api/v1",
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// do 发送请求，body不为空时编码为JSON，响应解码到out；状态码不是2xx时返回错误
+func (t *httpTarget) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, t.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		return &httpError{Method: method, Path: path, StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(data))}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("decode %s %s response: %w", method, path, err)
+	}
+	return nil
+}
+
+// httpError 服务返回的错误状态码
+type httpError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Body       string
+}
+
+func (e *httpError) Error() string {
+	return fmt.Sprintf("%s %s: status %d: %s", e.Method, e.Path, e.StatusCode, e.Body)
+}
+
+func (t *httpTarget) EnsureKnowledgeBase(ctx context.Context, name string) error {
+	err := t.do(ctx, http.MethodPost, "/knowledge/bases", model.CreateKnowledgeBaseRequest{Name: name}, nil)
+	var httpErr *httpError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusConflict {
+		return nil
+	}
+	return err
+}
+
+func (t *httpTarget) AddKnowledge(ctx context.Context, knowledgeBase, content string) (string, error) {
+	var resp struct {
+		DocumentID string `json:"document_id"`
+	}
+	req := model.KnowledgeRequest{KnowledgeBase: knowledgeBase, Content: content}
+	if err := t.do(ctx, http.MethodPost, "/knowledge", req, &resp); err != nil {
+		return "", err
+	}
+	return resp.DocumentID, nil
+}
+
+func (t *httpTarget) SearchKnowledge(ctx context.Context, query string, opts model.SearchOptions) ([]*model.KnowledgeHit, error) {
+	params := url.Values{"q": {query}}
+	if opts.TopK > 0 {
+		params.Set("top_k", strconv.Itoa(opts.TopK))
+	}
+	if opts.Mode != "" {
+		params.Set("mode", opts.Mode)
+	}
+	if len(opts.KnowledgeBases) > 0 {
+		params.Set("kb", strings.Join(opts.KnowledgeBases, ","))
+	}
+
+	var resp struct {
+		Hits []*model.KnowledgeHit `json:"hits"`
+	}
+	if err := t.do(ctx, http.MethodGet, "/knowledge/search?"+params.Encode(), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Hits, nil
+}
+
+func (t *httpTarget) GetKnowledge(ctx context.Context, id int64) (*model.KnowledgeChunk, error) {
+	var chunk model.KnowledgeChunk
+	if err := t.do(ctx, http.MethodGet, "/knowledge/items/"+strconv.FormatInt(id, 10), nil, &chunk); err != nil {
+		return nil, err
+	}
+	return &chunk, nil
+}
+
+func (t *httpTarget) CreateChatbot(ctx context.Context, req *model.CreateChatbotRequest) (string, error) {
+	var chatbot model.Chatbot
+	if err := t.do(ctx, http.MethodPost, "/chatbots", req, &chatbot); err != nil {
+		return "", err
+	}
+	return chatbot.ID, nil
+}
+
+func (t *httpTarget) DeleteChatbot(ctx context.Context, id string) error {
+	return t.do(ctx, http.MethodDelete, "/chatbots/"+url.PathEscape(id), nil, nil)
+}
+
+func (t *httpTarget) Chat(ctx context.Context, chatbotID, message string) (*model.ChatResponse, error) {
+	var resp model.ChatResponse
+	if err := t.do(ctx, http.MethodPost, "/chatbots/"+url.PathEscape(chatbotID)+"/chat", model.ChatRequest{Message: message}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (t *httpTarget) Close() error {
+	return nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
 	"os"
@@ -32,6 +33,14 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
+	// 数据库迁移子命令：server migrate [up|down [n]|status]
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(cfg.Storage, os.Args[2:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
 	// 初始化存储
 	storageService, err := storage.NewStorage(cfg.Storage)
 	if err != nil {
@@ -39,6 +48,14 @@ func main() {
 	}
 	defer storageService.Close()
 
+	// 重新嵌入子命令：server reembed
+	if len(os.Args) > 1 && os.Args[1] == "reembed" {
+		if err := runReembed(cfg.RAG, cfg.Storage, storageService); err != nil {
+			log.Fatalf("Re-embedding failed: %v", err)
+		}
+		return
+	}
+
 	// 初始化聊天机器人服务
 	chatService, err := agent.NewChatService(cfg, storageService)
 	if err != nil {
@@ -47,19 +64,30 @@ func main() {
 
 	// 初始化RAG服务（如果启用）
 	if cfg.RAG.Enabled {
-		ragService, err := service.NewRAGService(
-			cfg.Storage.Milvus.Host,
-			cfg.Storage.Milvus.Port,
-			cfg.RAG.OllamaURL,
-		)
+		ragService, err := service.NewRAGService(cfg.RAG, cfg.Storage, storageService)
+		if errors.Is(err, service.ErrEmbeddingMismatch) {
+			// 继续启动会把不同模型的向量混在一起，检索结果没有意义
+			log.Fatalf("Failed to initialize RAG service: %v", err)
+		}
 		if err != nil {
 			log.Printf("Warning: Failed to initialize RAG service: %v", err)
 		} else {
+			defer ragService.Close()
 			chatService.SetRAGService(ragService)
 			log.Println("RAG service enabled")
 		}
 	}
 
+	// 导入人设包目录（如果配置）
+	if cfg.Agent.PersonaDir != "" {
+		n, err := chatService.LoadPersonaDir(context.Background(), cfg.Agent.PersonaDir)
+		if err != nil {
+			log.Printf("Warning: Failed to load personas from %s: %v", cfg.Agent.PersonaDir, err)
+		} else {
+			log.Printf("Loaded %d personas from %s", n, cfg.Agent.PersonaDir)
+		}
+	}
+
 	// 初始化HTTP处理器
 	router := gin.Default()
 
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	"eino/internal/config"
+	"eino/internal/storage"
+)
+
+// runMigrate 执行数据库迁移子命令
+//
+// 用法：
+//
+//	server migrate [up]       执行所有未执行的迁移
+//	server migrate down [n]   回滚最近n个迁移（默认1）
+//	server migrate status     查看迁移状态
+func runMigrate(cfg config.StorageConfig, args []string) error {
+	m, err := storage.NewMigrator(cfg)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	ctx := context.Background()
+	cmd := "up"
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+
+	switch cmd {
+	case "up":
+		applied, err := m.Up(ctx)
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			log.Println("Database is up to date")
+		}
+		for _, mig := range applied {
+			log.Printf("Applied migration %d_%s", mig.Version, mig.Name)
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid step count: %s", args[1])
+			}
+		}
+		reverted, err := m.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		for _, mig := range reverted {
+			log.Printf("Reverted migration %d_%s", mig.Version, mig.Name)
+		}
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, st := range statuses {
+			applied := "pending"
+			if st.Applied {
+				applied = "applied at " + st.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%03d_%s\t%s\n", st.Version, st.Name, applied)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command: %s (want up, down or status)", cmd)
+	}
+
+	return nil
+}
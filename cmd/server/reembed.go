@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"eino/internal/config"
+	"eino/internal/service"
+	"eino/internal/storage"
+)
+
+// runReembed 用rag.embedding_model重新嵌入知识库中的全部知识
+//
+// 用法：
+//
+//	server reembed   迁移早期版本创建的集合，或在服务停止时前台重建索引
+func runReembed(cfg config.RAGConfig, storageCfg config.StorageConfig, store storage.Storage) error {
+	log.Printf("Re-embedding knowledge with %s", cfg.EmbeddingModel)
+	n, err := service.Reembed(context.Background(), cfg, storageCfg, store, func(done, total int) {
+		log.Printf("Re-embedded %d/%d chunks", done, total)
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("Re-embedded %d chunks with %s", n, cfg.EmbeddingModel)
+	return nil
+}
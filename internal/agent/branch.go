@@ -0,0 +1,77 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	"eino/internal/model"
+	"eino/internal/storage"
+)
+
+// Regenerate 重新生成当前分支最后一轮的回复
+//
+// 新回复与原回复是同一父节点下的兄弟分支，并成为当前分支，原回复仍可通过分支列表查看。
+func (s *ChatService) Regenerate(ctx context.Context, chatbotID string, req *model.RegenerateRequest) (*model.ChatResponse, error) {
+	chatbot, err := s.storage.GetChatbot(ctx, chatbotID)
+	if err != nil {
+		return nil, fmt.Errorf("get chatbot: %w", err)
+	}
+
+	// 多取一轮：最后一轮是要重新生成的对话，之前的作为上下文
+	history, err := s.storage.GetConversationHistory(ctx, chatbotID, max(s.config.Agent.MaxHistory, 0)+1)
+	if err != nil {
+		return nil, fmt.Errorf("get conversation history: %w", err)
+	}
+	if len(history) == 0 {
+		return nil, fmt.Errorf("no conversation to regenerate: %w", storage.ErrNotFound)
+	}
+
+	last := history[len(history)-1]
+	return s.reply(ctx, chatbot, history[:len(history)-1], last.ParentID, &model.ChatRequest{
+		Message:  last.UserMessage,
+		UserName: req.UserName,
+	})
+}
+
+// EditMessage 编辑历史对话中的用户消息
+//
+// 原记录不会被修改：以原记录之前的对话为上下文生成回复，作为原记录的兄弟分支保存并成为当前分支。
+func (s *ChatService) EditMessage(ctx context.Context, conversationID int64, req *model.ChatRequest) (*model.ChatResponse, error) {
+	conv, err := s.storage.GetConversation(ctx, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("get conversation: %w", err)
+	}
+
+	chatbot, err := s.storage.GetChatbot(ctx, conv.ChatbotID)
+	if err != nil {
+		return nil, fmt.Errorf("get chatbot: %w", err)
+	}
+
+	var history []*model.Conversation
+	if conv.ParentID != 0 && s.config.Agent.MaxHistory > 0 {
+		history, err = s.storage.GetConversationPath(ctx, conv.ParentID, s.config.Agent.MaxHistory)
+		if err != nil {
+			return nil, fmt.Errorf("get conversation path: %w", err)
+		}
+	}
+
+	return s.reply(ctx, chatbot, history, conv.ParentID, req)
+}
+
+// GetConversation 获取对话记录
+func (s *ChatService) GetConversation(ctx context.Context, conversationID int64) (*model.Conversation, error) {
+	conv, err := s.storage.GetConversation(ctx, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("get conversation: %w", err)
+	}
+	return conv, nil
+}
+
+// GetConversationBranches 获取与指定对话同一父节点的全部分支，按创建顺序排列
+func (s *ChatService) GetConversationBranches(ctx context.Context, conversationID int64) ([]*model.Conversation, error) {
+	branches, err := s.storage.GetConversationBranches(ctx, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("get conversation branches: %w", err)
+	}
+	return branches, nil
+}
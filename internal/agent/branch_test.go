@@ -0,0 +1,122 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"eino/internal/model"
+	"eino/internal/storage"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// userMessages 输入中的用户消息，最后一条是本轮消息，之前的来自上下文
+func userMessages(input []*schema.Message) []string {
+	var messages []string
+	for _, msg := range input {
+		if msg.Role == schema.User {
+			messages = append(messages, msg.Content)
+		}
+	}
+	return messages
+}
+
+func TestBranchParentSelection(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestChatService(t)
+	scripted := &scriptedModel{}
+	s.model = scripted
+
+	chatbot, err := s.CreateChatbot(ctx, &model.CreateChatbotRequest{Name: "bot", Personality: "p", Background: "b"})
+	if err != nil {
+		t.Fatalf("CreateChatbot: %v", err)
+	}
+	if _, err := s.Regenerate(ctx, chatbot.ID, &model.RegenerateRequest{}); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("Regenerate without conversations error = %v, want ErrNotFound", err)
+	}
+
+	chat := func(message string) *model.ChatResponse {
+		t.Helper()
+		resp, err := s.Chat(ctx, chatbot.ID, &model.ChatRequest{Message: message})
+		if err != nil {
+			t.Fatalf("Chat(%s): %v", message, err)
+		}
+		return resp
+	}
+	// check 最近一次生成的父节点和输入的用户消息
+	check := func(step string, resp *model.ChatResponse, parentID int64, messages ...string) {
+		t.Helper()
+		if resp.ParentID != parentID {
+			t.Fatalf("%s: parent = %d, want %d", step, resp.ParentID, parentID)
+		}
+		conv, err := s.GetConversation(ctx, resp.ConversationID)
+		if err != nil || conv.ParentID != parentID {
+			t.Fatalf("%s: saved conversation = %+v, %v, want parent %d", step, conv, err, parentID)
+		}
+		if got := userMessages(scripted.inputs[len(scripted.inputs)-1]); !slices.Equal(got, messages) {
+			t.Fatalf("%s: model input = %q, want %q", step, got, messages)
+		}
+	}
+	branches := func(conversationID int64, want ...int64) {
+		t.Helper()
+		got, err := s.GetConversationBranches(ctx, conversationID)
+		if err != nil {
+			t.Fatalf("GetConversationBranches: %v", err)
+		}
+		ids := make([]int64, len(got))
+		for i, conv := range got {
+			ids[i] = conv.ID
+		}
+		if !slices.Equal(ids, want) {
+			t.Fatalf("branches of %d = %v, want %v", conversationID, ids, want)
+		}
+	}
+
+	c1 := chat("q1")
+	c2 := chat("q2")
+	c3 := chat("q3")
+	check("chat", c3, c2.ConversationID, "q1", "q2", "q3")
+
+	// 重新生成最后一轮：与原回复同一父节点，上下文不含原回复
+	r3, err := s.Regenerate(ctx, chatbot.ID, &model.RegenerateRequest{})
+	if err != nil {
+		t.Fatalf("Regenerate: %v", err)
+	}
+	check("regenerate", r3, c2.ConversationID, "q1", "q2", "q3")
+	branches(c3.ConversationID, c3.ConversationID, r3.ConversationID)
+
+	// 新的一轮接在重新生成的回复之后
+	c4 := chat("q4")
+	check("chat after regenerate", c4, r3.ConversationID, "q1", "q2", "q3", "q4")
+
+	// 再次重新生成的是当前分支的最后一轮
+	r4, err := s.Regenerate(ctx, chatbot.ID, &model.RegenerateRequest{})
+	if err != nil {
+		t.Fatalf("Regenerate: %v", err)
+	}
+	check("regenerate again", r4, r3.ConversationID, "q1", "q2", "q3", "q4")
+
+	// 编辑中间的消息：作为原记录的兄弟分支，上下文只到原记录的父节点
+	e2, err := s.EditMessage(ctx, c2.ConversationID, &model.ChatRequest{Message: "q2-edited"})
+	if err != nil {
+		t.Fatalf("EditMessage: %v", err)
+	}
+	check("edit", e2, c1.ConversationID, "q1", "q2-edited")
+	branches(c2.ConversationID, c2.ConversationID, e2.ConversationID)
+
+	c5 := chat("q5")
+	check("chat after edit", c5, e2.ConversationID, "q1", "q2-edited", "q5")
+
+	// 编辑第一轮：新的根节点，没有上下文
+	e1, err := s.EditMessage(ctx, c1.ConversationID, &model.ChatRequest{Message: "q1-edited"})
+	if err != nil {
+		t.Fatalf("EditMessage: %v", err)
+	}
+	check("edit root", e1, 0, "q1-edited")
+
+	if _, err := s.EditMessage(ctx, c5.ConversationID+100, &model.ChatRequest{Message: "x"}); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("EditMessage(missing) error = %v, want ErrNotFound", err)
+	}
+}
@@ -6,58 +6,111 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"sync"
 	"time"
 
 	"eino/internal/config"
+	"eino/internal/fake"
 	"eino/internal/model"
 	"eino/internal/storage"
 
 	"github.com/cloudwego/eino-ext/components/model/ollama"
+	einomodel "github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/schema"
 	"github.com/google/uuid"
 )
 
 // ChatService 聊天服务
 type ChatService struct {
-	model      *ollama.ChatModel
-	config     *config.Config
-	storage    storage.Storage
+	model einomodel.BaseChatModel
+	// classifier 判断是否需要检索的模型，为nil时使用model
+	classifier einomodel.BaseChatModel
+	// rewriter 检索前改写问题的模型，为nil时使用model
+	rewriter einomodel.BaseChatModel
+	config   *config.Config
+	storage  storage.Storage
+	// defaultPrompt 未设置模板的聊天机器人使用的系统提示词模板
+	defaultPrompt *promptTemplate
+	// prompts 聊天机器人ID -> *cachedPrompt，见promptTemplateFor
+	prompts    sync.Map
 	ragService interface { // RAG服务接口（可选）
-		EnhanceMessages(ctx context.Context, userMessage string, messages []*schema.Message) ([]*schema.Message, error)
+		EnhanceMessages(ctx context.Context, opts model.SearchOptions, userMessage string, messages []*schema.Message) ([]*schema.Message, *model.RetrievalTrace, error)
 	}
 }
 
-// NewChatService 创建聊天服务
-func NewChatService(cfg *config.Config, storage storage.Storage) (*ChatService, error) {
-	ctx := context.Background()
-
-	var chatModel *ollama.ChatModel
-	var err error
+// NewChatModel 按model配置创建对话模型，name为空时使用model.model
+func NewChatModel(ctx context.Context, cfg config.ModelConfig, name string) (einomodel.BaseChatModel, error) {
+	if name == "" {
+		name = cfg.Model
+	}
 
-	switch cfg.Model.Provider {
+	switch cfg.Provider {
 	case "ollama":
-		chatModel, err = ollama.NewChatModel(ctx, &ollama.ChatModelConfig{
-			BaseURL: cfg.Model.BaseURL,
-			Model:   cfg.Model.Model,
+		chatModel, err := ollama.NewChatModel(ctx, &ollama.ChatModelConfig{
+			BaseURL: cfg.BaseURL,
+			Model:   name,
 		})
 		if err != nil {
 			return nil, fmt.Errorf("create ollama model: %w", err)
 		}
+		return chatModel, nil
+	case "fake":
+		return fake.NewChatModel(), nil
 	default:
-		return nil, fmt.Errorf("unsupported model provider: %s", cfg.Model.Provider)
+		return nil, fmt.Errorf("unsupported model provider: %s", cfg.Provider)
+	}
+}
+
+// NewChatService 创建聊天服务
+func NewChatService(cfg *config.Config, storage storage.Storage) (*ChatService, error) {
+	ctx := context.Background()
+
+	chatModel, err := NewChatModel(ctx, cfg.Model, "")
+	if err != nil {
+		return nil, err
+	}
+	var classifier, rewriter einomodel.BaseChatModel
+	if name := cfg.RAG.Retrieval.ClassifierModel; name != "" {
+		if classifier, err = NewChatModel(ctx, cfg.Model, name); err != nil {
+			return nil, fmt.Errorf("create classifier model: %w", err)
+		}
+	}
+	if name := cfg.RAG.QueryRewrite.Model; name != "" {
+		if rewriter, err = NewChatModel(ctx, cfg.Model, name); err != nil {
+			return nil, fmt.Errorf("create query rewrite model: %w", err)
+		}
+	}
+
+	if err := model.ValidateRAGMode(cfg.RAG.Retrieval.RAGMode); err != nil {
+		return nil, fmt.Errorf("rag.retrieval.rag_mode: %w", err)
+	}
+	if err := model.ValidateRAGClassifier(cfg.RAG.Retrieval.Classifier); err != nil {
+		return nil, fmt.Errorf("rag.retrieval.classifier: %w", err)
+	}
+
+	promptText := cfg.Agent.PromptTemplate
+	if promptText == "" {
+		promptText = defaultPromptTemplate
+	}
+	defaultPrompt, err := parsePromptTemplate(cfg.Agent.PromptFormat, promptText)
+	if err != nil {
+		return nil, fmt.Errorf("agent.prompt_template: %w", err)
 	}
 
 	return &ChatService{
-		model:      chatModel,
-		config:     cfg,
-		storage:    storage,
-		ragService: nil, // 可选，通过SetRAGService设置
+		model:         chatModel,
+		classifier:    classifier,
+		rewriter:      rewriter,
+		config:        cfg,
+		storage:       storage,
+		defaultPrompt: defaultPrompt,
+		ragService:    nil, // 可选，通过SetRAGService设置
 	}, nil
 }
 
 // SetRAGService 设置RAG服务（可选）
 func (s *ChatService) SetRAGService(ragService interface {
-	EnhanceMessages(ctx context.Context, userMessage string, messages []*schema.Message) ([]*schema.Message, error)
+	EnhanceMessages(ctx context.Context, opts model.SearchOptions, userMessage string, messages []*schema.Message) ([]*schema.Message, *model.RetrievalTrace, error)
 }) {
 	s.ragService = ragService
 }
@@ -65,69 +118,104 @@ func (s *ChatService) SetRAGService(ragService interface {
 // CreateChatbot 创建聊天机器人实例
 func (s *ChatService) CreateChatbot(ctx context.Context, req *model.CreateChatbotRequest) (*model.Chatbot, error) {
 	chatbot := &model.Chatbot{
-		ID:          uuid.New().String(),
-		Name:        req.Name,
-		Personality: req.Personality,
-		Background:  req.Background,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+		ID:             uuid.New().String(),
+		Name:           req.Name,
+		Personality:    req.Personality,
+		Background:     req.Background,
+		PromptTemplate: req.PromptTemplate,
+		PromptFormat:   req.PromptFormat,
+		Options:        req.Options,
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
 	}
 
-	// 构建系统提示词
-	systemPrompt := s.buildSystemPrompt(req.Personality, req.Background)
+	// 校验模板并构建系统提示词
+	if err := chatbot.Options.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPersona, err)
+	}
+	systemPrompt, err := s.renderSystemPrompt(chatbot)
+	if err != nil {
+		return nil, err
+	}
 	chatbot.SystemPrompt = systemPrompt
 
-	// 保存到存储
-	if err := s.storage.SaveChatbot(ctx, chatbot); err != nil {
-		return nil, fmt.Errorf("save chatbot: %w", err)
+	// 保存到存储，并记录初始人设版本
+	if err := s.savePersona(ctx, chatbot, "initial"); err != nil {
+		return nil, err
 	}
 
 	return chatbot, nil
 }
 
-// Chat 进行对话
-func (s *ChatService) Chat(ctx context.Context, chatbotID string, userMessage string) (*model.ChatResponse, error) {
+// Chat 进行对话，在当前分支末尾追加一轮
+func (s *ChatService) Chat(ctx context.Context, chatbotID string, req *model.ChatRequest) (*model.ChatResponse, error) {
 	// 获取聊天机器人配置
 	chatbot, err := s.storage.GetChatbot(ctx, chatbotID)
 	if err != nil {
 		return nil, fmt.Errorf("get chatbot: %w", err)
 	}
 
-	// 获取对话历史
-	history, err := s.storage.GetConversationHistory(ctx, chatbotID, s.config.Agent.MaxHistory)
+	// 获取当前分支的对话历史
+	history, parentID, err := s.activeHistory(ctx, chatbotID)
 	if err != nil {
-		return nil, fmt.Errorf("get conversation history: %w", err)
+		return nil, err
 	}
 
-	// 构建消息列表
-	messages := s.buildMessages(chatbot.SystemPrompt, history, userMessage)
+	return s.reply(ctx, chatbot, history, parentID, req)
+}
 
-	// RAG增强（如果启用）
-	if s.ragService != nil {
-		enhanced, err := s.ragService.EnhanceMessages(ctx, userMessage, messages)
-		if err == nil && len(enhanced) > 0 {
-			messages = enhanced
-		}
+// activeHistory 获取当前分支用作上下文的对话历史，以及新一轮对话的父节点ID
+//
+// 即使不使用历史（max_history为0），也需要读取最新一条记录来接续分支。
+func (s *ChatService) activeHistory(ctx context.Context, chatbotID string) ([]*model.Conversation, int64, error) {
+	history, err := s.storage.GetConversationHistory(ctx, chatbotID, max(s.config.Agent.MaxHistory, 1))
+	if err != nil {
+		return nil, 0, fmt.Errorf("get conversation history: %w", err)
 	}
 
-	// 设置超时上下文
-	modelCtx, cancel := context.WithTimeout(ctx, s.config.GetModelTimeout())
-	defer cancel()
+	var parentID int64
+	if len(history) > 0 {
+		parentID = history[len(history)-1].ID
+	}
+	if s.config.Agent.MaxHistory <= 0 {
+		history = nil
+	}
+
+	return history, parentID, nil
+}
+
+// reply 以history为上下文生成回复，并作为parentID的子节点保存
+func (s *ChatService) reply(ctx context.Context, chatbot *model.Chatbot, history []*model.Conversation, parentID int64, req *model.ChatRequest) (*model.ChatResponse, error) {
+	// 构建消息列表（含RAG增强）
+	messages, retrieval, err := s.buildChatMessages(ctx, chatbot, history, parentID, req)
+	if err != nil {
+		return nil, err
+	}
 
 	// 生成回复
 	startTime := time.Now()
-	response, err := s.model.Generate(modelCtx, messages)
+	response, err := s.generate(ctx, messages, s.generateOptions(chatbot)...)
 	if err != nil {
 		return nil, fmt.Errorf("generate response: %w", err)
 	}
+
+	// 人设一致性检查（如果启用）
+	reply, verdict, err := s.guardReply(ctx, chatbot, messages, req.Message, response.Content)
+	if err != nil {
+		return nil, err
+	}
 	duration := time.Since(startTime)
+	citations := retrieval.citations(reply)
 
 	// 保存对话记录
 	conversation := &model.Conversation{
-		ChatbotID:   chatbotID,
-		UserMessage: userMessage,
-		BotMessage:  response.Content,
-		CreatedAt:   time.Now(),
+		ChatbotID:      chatbot.ID,
+		ParentID:       parentID,
+		UserMessage:    req.Message,
+		BotMessage:     reply,
+		PersonaVersion: chatbot.PersonaVersion,
+		Metadata:       conversationMetadata(verdict, retrieval, citations),
+		CreatedAt:      time.Now(),
 	}
 
 	if err := s.storage.SaveConversation(ctx, conversation); err != nil {
@@ -135,38 +223,60 @@ func (s *ChatService) Chat(ctx context.Context, chatbotID string, userMessage st
 	}
 
 	return &model.ChatResponse{
-		Message:   response.Content,
-		Duration:  duration.Milliseconds(),
-		Timestamp: time.Now(),
+		ConversationID: conversation.ID,
+		ParentID:       parentID,
+		Message:        reply,
+		Duration:       duration.Milliseconds(),
+		Sources:        citations,
+		RetrievalUsed:  retrieval.used(),
+		RAG:            retrieval.decision,
+		Timestamp:      time.Now(),
 	}, nil
 }
 
-// StreamChat 流式对话
-func (s *ChatService) StreamChat(ctx context.Context, chatbotID string, userMessage string, callback func(string)) error {
+// conversationMetadata 汇总对话的附加信息，都为空时返回nil
+func conversationMetadata(verdict *model.GuardVerdict, retrieval *chatRetrieval, citations []model.Citation) *model.ConversationMetadata {
+	var trace *model.RetrievalTrace
+	if retrieval.result != nil {
+		trace = retrieval.result.Trace
+	}
+	if verdict == nil && trace == nil && len(citations) == 0 && retrieval.decision == nil {
+		return nil
+	}
+	return &model.ConversationMetadata{Guard: verdict, Retrieval: trace, Citations: citations, RAG: retrieval.decision}
+}
+
+// StreamChat 流式对话，callback依次收到回复的各个片段；返回的响应与Chat相同，不含回复内容以外的片段
+func (s *ChatService) StreamChat(ctx context.Context, chatbotID string, req *model.ChatRequest, callback func(string)) (*model.ChatResponse, error) {
 	// 获取聊天机器人配置
 	chatbot, err := s.storage.GetChatbot(ctx, chatbotID)
 	if err != nil {
-		return fmt.Errorf("get chatbot: %w", err)
+		return nil, fmt.Errorf("get chatbot: %w", err)
 	}
 
-	// 获取对话历史
-	history, err := s.storage.GetConversationHistory(ctx, chatbotID, s.config.Agent.MaxHistory)
+	// 获取当前分支的对话历史
+	history, parentID, err := s.activeHistory(ctx, chatbotID)
 	if err != nil {
-		return fmt.Errorf("get conversation history: %w", err)
+		return nil, err
 	}
 
-	// 构建消息列表
-	messages := s.buildMessages(chatbot.SystemPrompt, history, userMessage)
+	// 构建消息列表（含RAG增强）
+	messages, retrieval, err := s.buildChatMessages(ctx, chatbot, history, parentID, req)
+	if err != nil {
+		return nil, err
+	}
 
 	// 设置超时上下文
 	modelCtx, cancel := context.WithTimeout(ctx, s.config.GetModelTimeout())
 	defer cancel()
 
 	// 流式生成
-	stream, err := s.model.Stream(modelCtx, messages)
+	startTime := time.Now()
+	stream, err := s.model.Stream(modelCtx, messages, s.generateOptions(chatbot)...)
 	if err != nil {
-		return fmt.Errorf("stream generate: %w", err)
+		return nil, fmt.Errorf("stream generate: %w", err)
 	}
+	defer stream.Close()
 
 	var fullResponse strings.Builder
 	for {
@@ -175,7 +285,7 @@ func (s *ChatService) StreamChat(ctx context.Context, chatbotID string, userMess
 			break
 		}
 		if err != nil {
-			return fmt.Errorf("stream recv: %w", err)
+			return nil, fmt.Errorf("stream recv: %w", err)
 		}
 
 		content := chunk.Content
@@ -184,20 +294,38 @@ func (s *ChatService) StreamChat(ctx context.Context, chatbotID string, userMess
 			callback(content)
 		}
 	}
+	duration := time.Since(startTime)
+	reply := fullResponse.String()
+	citations := retrieval.citations(reply)
+
+	// 流式回复已经发出，人设一致性检查只记录结果，不重新生成
+	verdict := s.checkStreamedReply(ctx, chatbot, req.Message, reply)
 
 	// 保存完整对话记录
 	conversation := &model.Conversation{
-		ChatbotID:   chatbotID,
-		UserMessage: userMessage,
-		BotMessage:  fullResponse.String(),
-		CreatedAt:   time.Now(),
+		ChatbotID:      chatbotID,
+		ParentID:       parentID,
+		UserMessage:    req.Message,
+		BotMessage:     reply,
+		PersonaVersion: chatbot.PersonaVersion,
+		Metadata:       conversationMetadata(verdict, retrieval, citations),
+		CreatedAt:      time.Now(),
 	}
 
 	if err := s.storage.SaveConversation(ctx, conversation); err != nil {
-		return fmt.Errorf("save conversation: %w", err)
+		return nil, fmt.Errorf("save conversation: %w", err)
 	}
 
-	return nil
+	return &model.ChatResponse{
+		ConversationID: conversation.ID,
+		ParentID:       parentID,
+		Message:        reply,
+		Duration:       duration.Milliseconds(),
+		Sources:        citations,
+		RetrievalUsed:  retrieval.used(),
+		RAG:            retrieval.decision,
+		Timestamp:      time.Now(),
+	}, nil
 }
 
 // GetChatbots 获取所有聊天机器人
@@ -205,6 +333,11 @@ func (s *ChatService) GetChatbots(ctx context.Context) ([]*model.Chatbot, error)
 	return s.storage.GetChatbots(ctx)
 }
 
+// ListChatbots 分页查询聊天机器人
+func (s *ChatService) ListChatbots(ctx context.Context, query model.ChatbotQuery) (*model.ChatbotPage, error) {
+	return s.storage.ListChatbots(ctx, query)
+}
+
 // GetChatbot 获取指定聊天机器人
 func (s *ChatService) GetChatbot(ctx context.Context, chatbotID string) (*model.Chatbot, error) {
 	return s.storage.GetChatbot(ctx, chatbotID)
@@ -212,31 +345,149 @@ func (s *ChatService) GetChatbot(ctx context.Context, chatbotID string) (*model.
 
 // DeleteChatbot 删除聊天机器人
 func (s *ChatService) DeleteChatbot(ctx context.Context, chatbotID string) error {
-	return s.storage.DeleteChatbot(ctx, chatbotID)
+	if err := s.storage.DeleteChatbot(ctx, chatbotID); err != nil {
+		return err
+	}
+	s.prompts.Delete(chatbotID)
+	return nil
 }
 
-// GetConversationHistory 获取对话历史
+// GetConversationHistory 获取当前分支的对话历史
 func (s *ChatService) GetConversationHistory(ctx context.Context, chatbotID string, limit int) ([]*model.Conversation, error) {
 	return s.storage.GetConversationHistory(ctx, chatbotID, limit)
 }
 
-// buildSystemPrompt 构建系统提示词
-func (s *ChatService) buildSystemPrompt(personality, background string) string {
-	var parts []string
+// QueryConversationHistory 分页查询对话历史
+func (s *ChatService) QueryConversationHistory(ctx context.Context, query model.HistoryQuery) (*model.HistoryPage, error) {
+	return s.storage.QueryConversationHistory(ctx, query)
+}
+
+// generate 在模型超时时间内生成一次回复
+func (s *ChatService) generate(ctx context.Context, messages []*schema.Message, opts ...einomodel.Option) (*schema.Message, error) {
+	return s.generateWith(ctx, nil, messages, opts...)
+}
 
-	if personality != "" {
-		parts = append(parts, fmt.Sprintf("性格设定：%s", personality))
+// generateWith 用指定的模型在模型超时时间内生成一次回复，chatModel为nil时使用对话模型
+func (s *ChatService) generateWith(ctx context.Context, chatModel einomodel.BaseChatModel, messages []*schema.Message, opts ...einomodel.Option) (*schema.Message, error) {
+	if chatModel == nil {
+		chatModel = s.model
 	}
+	modelCtx, cancel := context.WithTimeout(ctx, s.config.GetModelTimeout())
+	defer cancel()
 
-	if background != "" {
-		parts = append(parts, fmt.Sprintf("背景设定：%s", background))
+	return chatModel.Generate(modelCtx, messages, opts...)
+}
+
+// generateOptions 生成参数：先应用全局配置，再由聊天机器人自身的设置覆盖
+func (s *ChatService) generateOptions(chatbot *model.Chatbot) []einomodel.Option {
+	var opts []einomodel.Option
+	if s.config.Agent.Temperature > 0 {
+		opts = append(opts, einomodel.WithTemperature(float32(s.config.Agent.Temperature)))
+	}
+	if s.config.Agent.MaxTokens > 0 {
+		opts = append(opts, einomodel.WithMaxTokens(s.config.Agent.MaxTokens))
 	}
 
-	if len(parts) > 0 {
-		return strings.Join(parts, "\n\n") + "\n\n请严格按照以上设定进行对话，保持角色的一致性。"
+	if o := chatbot.Options; o.Temperature != nil {
+		opts = append(opts, einomodel.WithTemperature(*o.Temperature))
+	}
+	if o := chatbot.Options; o.MaxTokens != nil {
+		opts = append(opts, einomodel.WithMaxTokens(*o.MaxTokens))
+	}
+	if o := chatbot.Options; o.TopP != nil {
+		opts = append(opts, einomodel.WithTopP(*o.TopP))
+	}
+
+	return opts
+}
+
+// knowledgeSearcher 支持检索知识的RAG服务
+type knowledgeSearcher interface {
+	SearchKnowledge(ctx context.Context, query string, opts model.SearchOptions) (*model.SearchResult, error)
+}
+
+// knowledgeTopK 系统提示词模板引用检索知识时的检索条数
+const knowledgeTopK = 3
+
+// chatRetrieval 一轮对话的知识检索情况
+type chatRetrieval struct {
+	decision *model.RAGDecision  // 是否检索的判断，未配置RAG服务时为nil
+	result   *model.SearchResult // 检索结果，没有检索或检索失败时为nil
+}
+
+// used 是否检索到知识并加入了提示词
+func (r *chatRetrieval) used() bool {
+	return r.decision != nil && r.decision.Used
+}
+
+// citations 解析回复引用的知识，没有检索到知识时返回nil
+func (r *chatRetrieval) citations(reply string) []model.Citation {
+	if r.result == nil || len(r.result.Hits) == 0 {
+		return nil
+	}
+	return parseCitations(reply, r.result.Hits)
+}
+
+// buildChatMessages 按当前日期、用户名和检索知识渲染系统提示词，并构建消息列表，同时返回检索情况
+//
+// 先按聊天机器人的检索时机判断本轮是否检索，检索前按配置结合parentID之前的对话改写问题，只检索聊天机器人绑定的知识库。
+// 检索到的知识按 [1]、[2]…… 编号并要求模型标注引用。
+// 模板引用了检索知识时由模板决定知识的位置，否则追加到系统消息中。
+// RAG服务不支持检索时沿用其EnhanceMessages追加知识，回复不带引用。
+func (s *ChatService) buildChatMessages(ctx context.Context, chatbot *model.Chatbot, history []*model.Conversation, parentID int64, req *model.ChatRequest) ([]*schema.Message, *chatRetrieval, error) {
+	tmpl, err := s.promptTemplateFor(chatbot)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	retrieval := &chatRetrieval{decision: s.decideRetrieval(ctx, chatbot, req.Message)}
+	retrieve := retrieval.decision != nil && retrieval.decision.Retrieve
+
+	var knowledge string
+	searcher, canSearch := s.ragService.(knowledgeSearcher)
+	if retrieve && canSearch {
+		query, variants, rewrite := s.rewriteQuery(ctx, history, parentID, req.Message)
+		opts := chatbot.Options.SearchOptions(knowledgeTopK)
+		opts.Variants = variants
+		// 检索失败时不影响对话
+		if result, err := searcher.SearchKnowledge(ctx, query, opts); err == nil {
+			result.Trace.Rewrite = rewrite
+			retrieval.result = result
+			if len(result.Hits) > 0 {
+				knowledge = formatKnowledge(result.Hits)
+				retrieval.decision.Used = true
+			}
+		}
+	}
+
+	var templateKnowledge string
+	if tmpl.usesKnowledge {
+		templateKnowledge = knowledge
+	}
+	systemPrompt, err := tmpl.render(ctx, newPromptVars(chatbot, req.UserName, templateKnowledge))
+	if err != nil {
+		return nil, nil, err
+	}
+	messages := s.buildMessages(systemPrompt, history, req.Message)
+
+	switch {
+	case !retrieve:
+	case canSearch:
+		if knowledge != "" && !tmpl.usesKnowledge {
+			messages = appendKnowledge(messages, knowledge)
+		}
+	default:
+		enhanced, trace, err := s.ragService.EnhanceMessages(ctx, chatbot.Options.SearchOptions(knowledgeTopK), req.Message, messages)
+		if err == nil && len(enhanced) > 0 {
+			messages = enhanced
+			if trace != nil {
+				retrieval.result = &model.SearchResult{Trace: trace}
+				retrieval.decision.Used = len(trace.Results) > 0
+			}
+		}
 	}
 
-	return "你是一个友好的AI助手。"
+	return messages, retrieval, nil
 }
 
 // buildMessages 构建消息列表
@@ -0,0 +1,109 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"eino/internal/document"
+	"eino/internal/model"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// citationInstruction 要求模型标注引用的资料编号
+const citationInstruction = "回答时在用到资料的句子末尾标注资料编号，如[1]，用到多段资料时写作[1][2]；资料中没有的内容不要标注。"
+
+// snippetRunes 引用来源中摘录的字符数
+const snippetRunes = 200
+
+// citationPattern 匹配回复中的引用标注：[1]、[1][2]、[1, 2]、[1，2]
+var citationPattern = regexp.MustCompile(`\[(\d+(?:\s*[,，、]\s*\d+)*)\]`)
+
+// formatKnowledge 将检索结果编号为 [1]、[2]……，附带标题和来源，末尾加上标注引用的要求
+func formatKnowledge(hits []*model.KnowledgeHit) string {
+	var b strings.Builder
+	for i, hit := range hits {
+		fmt.Fprintf(&b, "[%d]", i+1)
+		if title := hitTitle(hit); title != "" {
+			fmt.Fprintf(&b, " 《%s》", title)
+		}
+		if hit.Source != "" {
+			fmt.Fprintf(&b, "（来源：%s）", hit.Source)
+		}
+		fmt.Fprintf(&b, "\n%s\n\n", hit.Content)
+	}
+	b.WriteString(citationInstruction)
+	return b.String()
+}
+
+// appendKnowledge 把编号的知识追加到系统消息，没有系统消息时在开头添加
+func appendKnowledge(messages []*schema.Message, knowledge string) []*schema.Message {
+	enhanced := make([]*schema.Message, 0, len(messages)+1)
+	foundSystem := false
+	for _, msg := range messages {
+		if msg.Role == schema.System && !foundSystem {
+			enhanced = append(enhanced, schema.SystemMessage(msg.Content+"\n\n以下是从知识库中检索到的资料：\n\n"+knowledge))
+			foundSystem = true
+			continue
+		}
+		enhanced = append(enhanced, msg)
+	}
+
+	if !foundSystem {
+		systemPrompt := "以下是从知识库中检索到的资料，请基于这些资料回答问题：\n\n" + knowledge
+		enhanced = append([]*schema.Message{schema.SystemMessage(systemPrompt)}, enhanced...)
+	}
+	return enhanced
+}
+
+// parseCitations 解析回复中的 [n] 标注，按首次出现的顺序返回引用的资料；超出编号范围的标注忽略
+func parseCitations(reply string, hits []*model.KnowledgeHit) []model.Citation {
+	var citations []model.Citation
+	seen := make(map[int]bool)
+	for _, match := range citationPattern.FindAllStringSubmatch(reply, -1) {
+		for _, field := range strings.FieldsFunc(match[1], func(r rune) bool {
+			return r == ',' || r == '，' || r == '、' || r == ' '
+		}) {
+			n, err := strconv.Atoi(field)
+			if err != nil || n < 1 || n > len(hits) || seen[n] {
+				continue
+			}
+			seen[n] = true
+			hit := hits[n-1]
+			citations = append(citations, model.Citation{
+				Index:         n,
+				ChunkID:       hit.ID,
+				KnowledgeBase: hit.KnowledgeBase,
+				Title:         hitTitle(hit),
+				Source:        hit.Source,
+				Snippet:       snippet(hit.Content),
+			})
+		}
+	}
+	return citations
+}
+
+// hitTitle 读取分块元数据中的文档标题，没有时返回空字符串
+func hitTitle(hit *model.KnowledgeHit) string {
+	if len(hit.Metadata) == 0 {
+		return ""
+	}
+	var meta map[string]any
+	if json.Unmarshal(hit.Metadata, &meta) != nil {
+		return ""
+	}
+	title, _ := meta[document.MetaTitle].(string)
+	return title
+}
+
+// snippet 截取内容开头的snippetRunes个字符
+func snippet(content string) string {
+	content = strings.TrimSpace(content)
+	if runes := []rune(content); len(runes) > snippetRunes {
+		return string(runes[:snippetRunes]) + "…"
+	}
+	return content
+}
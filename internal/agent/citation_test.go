@@ -0,0 +1,61 @@
+package agent
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"eino/internal/document"
+	"eino/internal/model"
+)
+
+func TestParseCitations(t *testing.T) {
+	title, _ := json.Marshal(map[string]any{document.MetaTitle: "退货政策"})
+	hits := []*model.KnowledgeHit{
+		{ID: 11, KnowledgeBase: "default", Content: "购买后七天内可以无理由退货。", Metadata: title, Source: "policy.md"},
+		{ID: 12, KnowledgeBase: "default", Content: strings.Repeat("长", snippetRunes+10)},
+		{ID: 13, KnowledgeBase: "hr", Content: "年假按工龄计算。"},
+	}
+
+	tests := []struct {
+		name  string
+		reply string
+		want  []int // 引用的资料编号，按首次出现的顺序
+	}{
+		{"None", "没有引用。", nil},
+		{"Single", "七天内可以退货[1]。", []int{1}},
+		{"Adjacent", "见资料[3][1]。", []int{3, 1}},
+		{"List", "见资料[2, 1]和[1，3]。", []int{2, 1, 3}},
+		{"ChineseComma", "见资料[3、2]。", []int{3, 2}},
+		{"Duplicate", "[1]……[1]", []int{1}},
+		{"OutOfRange", "见资料[0][4][2]。", []int{2}},
+		{"NotACitation", "数组a[i]和[x1]。", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			citations := parseCitations(tt.reply, hits)
+			got := make([]int, 0, len(citations))
+			for _, c := range citations {
+				got = append(got, c.Index)
+				if hit := hits[c.Index-1]; c.ChunkID != hit.ID || c.KnowledgeBase != hit.KnowledgeBase {
+					t.Fatalf("citation %d = chunk %d in %s, want chunk %d in %s", c.Index, c.ChunkID, c.KnowledgeBase, hit.ID, hit.KnowledgeBase)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("parseCitations(%q) = %v, want %v", tt.reply, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("Fields", func(t *testing.T) {
+		citations := parseCitations("[1][2]", hits)
+		if c := citations[0]; c.Title != "退货政策" || c.Source != "policy.md" || c.Snippet != hits[0].Content {
+			t.Fatalf("citation 1 = %+v", c)
+		}
+		// 超长的内容截取前snippetRunes个字符
+		if c := citations[1]; c.Title != "" || len([]rune(c.Snippet)) != snippetRunes+1 || !strings.HasSuffix(c.Snippet, "…") {
+			t.Fatalf("citation 2 snippet = %q", c.Snippet)
+		}
+	})
+}
@@ -0,0 +1,131 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"eino/internal/llmjson"
+	"eino/internal/model"
+
+	einomodel "github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// smallTalkPhrases 不需要检索的寒暄，去掉首尾空白和标点后忽略大小写完全匹配
+var smallTalkPhrases = map[string]bool{
+	"你好": true, "您好": true, "嗨": true, "在吗": true, "在么": true,
+	"早上好": true, "晚上好": true, "晚安": true, "早安": true, "再见": true, "拜拜": true,
+	"谢谢": true, "多谢": true, "谢谢你": true, "感谢": true, "好的": true, "好": true, "嗯": true, "嗯嗯": true,
+	"哈哈": true, "哈哈哈": true, "收到": true, "明白了": true, "知道了": true,
+	"hi": true, "hello": true, "hey": true, "thanks": true, "thank you": true, "thx": true,
+	"ok": true, "okay": true, "bye": true, "good morning": true, "good night": true, "lol": true,
+}
+
+// questionMarkers 表示在询问信息的词，消息中出现时需要检索
+var questionMarkers = []string{
+	"什么", "怎么", "怎样", "如何", "为什么", "为何", "哪", "多少", "是否", "能否", "吗", "介绍", "解释", "说明",
+	"what", "how", "why", "when", "where", "which", "who", "explain", "describe", "tell me",
+}
+
+// minRetrievalRunes 不含疑问词的消息达到该长度时也检索
+const minRetrievalRunes = 12
+
+// ragClassifyPrompt 模型判断是否需要检索使用的系统提示词
+const ragClassifyPrompt = `你负责判断回答用户的消息是否需要查阅知识库。寒暄、闲聊、致谢、关于角色本身的话题不需要查阅；询问事实、规定、产品、数据等具体信息时需要查阅。
+只输出JSON，不要输出其他内容：{"retrieve": true或false, "reason": "简述原因"}`
+
+// decideRetrieval 按聊天机器人的检索时机判断本轮是否检索；未配置RAG服务时返回nil
+//
+// auto时用规则或模型判断，模型判断失败时改用规则，不会中断对话。
+func (s *ChatService) decideRetrieval(ctx context.Context, chatbot *model.Chatbot, message string) *model.RAGDecision {
+	if s.ragService == nil {
+		return nil
+	}
+
+	retrieval := s.config.RAG.Retrieval
+	mode, classifier := retrieval.RAGMode, retrieval.Classifier
+	if r := chatbot.Options.Retrieval; r != nil {
+		if r.RAG != "" {
+			mode = r.RAG
+		}
+		if r.Classifier != "" {
+			classifier = r.Classifier
+		}
+	}
+
+	decision := &model.RAGDecision{Mode: mode}
+	switch mode {
+	case model.RAGModeOff:
+		return decision
+	case model.RAGModeAuto:
+	default:
+		decision.Retrieve = true
+		return decision
+	}
+
+	decision.Classifier = classifier
+	if classifier == model.RAGClassifierModel {
+		retrieve, reason, err := s.classifyByModel(ctx, message)
+		if err == nil {
+			decision.Retrieve, decision.Reason = retrieve, reason
+			return decision
+		}
+		log.Printf("Warning: rag classifier failed for chatbot %s, falling back to rules: %v", chatbot.ID, err)
+		decision.Classifier = model.RAGClassifierRules
+	}
+	decision.Retrieve, decision.Reason = classifyByRules(message)
+	return decision
+}
+
+// classifyByRules 寒暄和致谢不检索；带问号、疑问词或较长的消息检索
+func classifyByRules(message string) (bool, string) {
+	text := strings.ToLower(strings.TrimFunc(message, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r)
+	}))
+	switch {
+	case text == "":
+		return false, "empty message"
+	case smallTalkPhrases[text]:
+		return false, "small talk"
+	case strings.ContainsAny(message, "?？"):
+		return true, "question"
+	}
+	for _, marker := range questionMarkers {
+		if strings.Contains(text, marker) {
+			return true, "question: " + marker
+		}
+	}
+	if utf8.RuneCountInString(text) >= minRetrievalRunes {
+		return true, "long message"
+	}
+	return false, "short message without a question"
+}
+
+// classifyByModel 调用模型判断是否需要检索，配置了rag.retrieval.classifier_model时使用该模型
+func (s *ChatService) classifyByModel(ctx context.Context, message string) (bool, string, error) {
+	messages := []*schema.Message{
+		schema.SystemMessage(ragClassifyPrompt),
+		schema.UserMessage(message),
+	}
+
+	response, err := s.generateWith(ctx, s.classifier, messages, einomodel.WithTemperature(0))
+	if err != nil {
+		return false, "", err
+	}
+
+	var result struct {
+		Retrieve *bool  `json:"retrieve"`
+		Reason   string `json:"reason"`
+	}
+	if err := llmjson.Unmarshal(response.Content, &result); err != nil {
+		return false, "", fmt.Errorf("parse classifier result %q: %w", response.Content, err)
+	}
+	if result.Retrieve == nil {
+		return false, "", fmt.Errorf("classifier result %q has no retrieve field", response.Content)
+	}
+	return *result.Retrieve, result.Reason, nil
+}
@@ -0,0 +1,35 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClassifyByRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  string
+		retrieve bool
+		reason   string // 原因的前缀
+	}{
+		{"Empty", "  ！！ ", false, "empty message"},
+		{"Greeting", "你好！", false, "small talk"},
+		{"Thanks", "  Thank you. ", false, "small talk"},
+		{"CaseInsensitive", "OK", false, "small talk"},
+		{"QuestionMark", "退货？", true, "question"},
+		{"FullWidthQuestionMark", "X200支持WPA3？", true, "question"},
+		{"ChineseMarker", "介绍一下X200", true, "question: 介绍"},
+		{"EnglishMarker", "Tell me about the X200", true, "question: tell me"},
+		{"LongMessage", "我上周买的路由器昨天收到了但是一直连不上网络", true, "long message"},
+		{"ShortStatement", "我买了X200", false, "short message"},
+		{"GreetingWithQuestion", "你好，退货要几天？", true, "question"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retrieve, reason := classifyByRules(tt.message)
+			if retrieve != tt.retrieve || !strings.HasPrefix(reason, tt.reason) {
+				t.Fatalf("classifyByRules(%q) = %v, %q, want %v, %q", tt.message, retrieve, reason, tt.retrieve, tt.reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,257 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"eino/internal/model"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// exportBatchSize 导出时每批读取的对话条数
+const exportBatchSize = 100
+
+// openAIRecord OpenAI chat格式的微调数据记录
+type openAIRecord struct {
+	Messages []openAIMessage `json:"messages"`
+}
+
+// openAIMessage OpenAI chat格式的单条消息
+type openAIMessage struct {
+	Role    schema.RoleType `json:"role"`
+	Content string          `json:"content"`
+}
+
+// ExportConversations 按时间顺序分批读取全部对话历史，以指定格式写入w
+func (s *ChatService) ExportConversations(ctx context.Context, chatbot *model.Chatbot, format string, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+
+	if format == model.ExportFormatMarkdown {
+		writeMarkdownHeader(bw, chatbot)
+	}
+
+	prompts := newVersionPrompts(s.storage, chatbot)
+	query := model.HistoryQuery{ChatbotID: chatbot.ID, Limit: exportBatchSize, FromOldest: true}
+	for {
+		page, err := s.storage.QueryConversationHistory(ctx, query)
+		if err != nil {
+			return fmt.Errorf("query conversation history: %w", err)
+		}
+
+		for _, conv := range page.Conversations {
+			switch format {
+			case model.ExportFormatJSONL:
+				err = enc.Encode(conv)
+			case model.ExportFormatOpenAI:
+				var systemPrompt string
+				if systemPrompt, err = prompts.get(ctx, conv.PersonaVersion); err != nil {
+					return err
+				}
+				err = enc.Encode(openAIRecord{Messages: []openAIMessage{
+					{Role: schema.System, Content: systemPrompt},
+					{Role: schema.User, Content: conv.UserMessage},
+					{Role: schema.Assistant, Content: conv.BotMessage},
+				}})
+			case model.ExportFormatMarkdown:
+				writeMarkdownTurn(bw, chatbot, conv)
+			default:
+				return fmt.Errorf("unsupported export format: %s", format)
+			}
+			if err != nil {
+				return fmt.Errorf("encode conversation: %w", err)
+			}
+		}
+
+		// 每批写出一次，保持流式输出
+		if err := bw.Flush(); err != nil {
+			return fmt.Errorf("write export: %w", err)
+		}
+		if page.NextCursor == 0 {
+			return nil
+		}
+		query.After = page.NextCursor
+	}
+}
+
+// writeMarkdownHeader 写入Markdown导出的标题和人设
+func writeMarkdownHeader(w io.Writer, chatbot *model.Chatbot) {
+	fmt.Fprintf(w, "# %s\n\n", chatbot.Name)
+	if chatbot.Personality != "" {
+		fmt.Fprintf(w, "- 性格设定：%s\n", chatbot.Personality)
+	}
+	if chatbot.Background != "" {
+		fmt.Fprintf(w, "- 背景设定：%s\n", chatbot.Background)
+	}
+	fmt.Fprintf(w, "- 导出时间：%s\n\n", time.Now().Format(time.DateTime))
+}
+
+// writeMarkdownTurn 写入一轮对话
+func writeMarkdownTurn(w io.Writer, chatbot *model.Chatbot, conv *model.Conversation) {
+	fmt.Fprintf(w, "## %s\n\n", conv.CreatedAt.Format(time.DateTime))
+	fmt.Fprintf(w, "**用户**：%s\n\n", conv.UserMessage)
+	fmt.Fprintf(w, "**%s**：%s\n\n", chatbot.Name, conv.BotMessage)
+}
+
+// ImportConversations 从JSONL导入对话历史
+//
+// 每行可以是导出的Conversation记录，也可以是OpenAI chat格式的记录（按user/assistant配对）。
+// 全部行解析成功后才写入存储，写入中途失败时删除本次已写入的记录，不会留下部分导入的对话。
+// 对话ID由存储重新分配，创建时间和附加信息（metadata）保留原值；人设版本只在导入到导出时的同一聊天机器人时保留，
+// 其他聊天机器人的版本号没有意义，记为0（未知）。
+// 导入的对话接在当前分支之后；导出记录带有id和parent_id时按原关系还原分支。
+func (s *ChatService) ImportConversations(ctx context.Context, chatbotID string, r io.Reader) (*model.ImportResult, error) {
+	if _, err := s.storage.GetChatbot(ctx, chatbotID); err != nil {
+		return nil, fmt.Errorf("get chatbot: %w", err)
+	}
+
+	convs, err := parseConversationsJSONL(r)
+	if err != nil {
+		return nil, err
+	}
+
+	_, parentID, err := s.activeHistory(ctx, chatbotID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 原ID到新ID的映射；原父节点不在导入数据中时接在上一条之后
+	ids := make(map[int64]int64, len(convs))
+	saved := make([]int64, 0, len(convs))
+	for _, conv := range convs {
+		origID, origParent := conv.ID, conv.ParentID
+		if conv.ChatbotID != chatbotID {
+			conv.PersonaVersion = 0
+		}
+		conv.ChatbotID = chatbotID
+		conv.ParentID = parentID
+		if id, ok := ids[origParent]; ok {
+			conv.ParentID = id
+		}
+		if err := s.storage.SaveConversation(ctx, conv); err != nil {
+			s.discardConversations(ctx, chatbotID, saved)
+			return nil, fmt.Errorf("save conversation: %w", err)
+		}
+		saved = append(saved, conv.ID)
+		if origID != 0 {
+			ids[origID] = conv.ID
+		}
+		parentID = conv.ID
+	}
+
+	return &model.ImportResult{Imported: len(convs)}, nil
+}
+
+// discardConversations 删除导入失败前已写入的对话，请求已取消时也执行；删除失败只记录日志
+func (s *ChatService) discardConversations(ctx context.Context, chatbotID string, ids []int64) {
+	if len(ids) == 0 {
+		return
+	}
+	if err := s.storage.DeleteConversations(context.WithoutCancel(ctx), chatbotID, ids); err != nil {
+		log.Printf("Warning: failed to delete %d partially imported conversations of chatbot %s: %v", len(ids), chatbotID, err)
+	}
+}
+
+// ImportError 导入数据格式错误
+type ImportError struct {
+	Line int
+	Err  error
+}
+
+func (e *ImportError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *ImportError) Unwrap() error {
+	return e.Err
+}
+
+// parseConversationsJSONL 解析JSONL格式的对话记录
+func parseConversationsJSONL(r io.Reader) ([]*model.Conversation, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	var convs []*model.Conversation
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var record struct {
+			model.Conversation
+			Messages []openAIMessage `json:"messages"`
+		}
+		if err := json.Unmarshal([]byte(text), &record); err != nil {
+			return nil, &ImportError{Line: line, Err: err}
+		}
+
+		if record.Messages != nil {
+			turns, err := turnsFromMessages(record.Messages)
+			if err != nil {
+				return nil, &ImportError{Line: line, Err: err}
+			}
+			convs = append(convs, turns...)
+			continue
+		}
+
+		if record.UserMessage == "" || record.BotMessage == "" {
+			return nil, &ImportError{Line: line, Err: fmt.Errorf("user_message and bot_message are required")}
+		}
+		convs = append(convs, &model.Conversation{
+			ID:             record.ID,
+			ChatbotID:      record.ChatbotID,
+			ParentID:       record.ParentID,
+			UserMessage:    record.UserMessage,
+			BotMessage:     record.BotMessage,
+			PersonaVersion: record.PersonaVersion,
+			Metadata:       record.Metadata,
+			CreatedAt:      record.CreatedAt,
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read import data: %w", err)
+	}
+
+	return convs, nil
+}
+
+// turnsFromMessages 将OpenAI chat格式的消息按user/assistant配对为对话记录，忽略system消息
+func turnsFromMessages(messages []openAIMessage) ([]*model.Conversation, error) {
+	var convs []*model.Conversation
+	var pending *model.Conversation
+	for _, msg := range messages {
+		switch msg.Role {
+		case schema.System:
+		case schema.User:
+			if pending != nil {
+				return nil, fmt.Errorf("user message without assistant reply")
+			}
+			pending = &model.Conversation{UserMessage: msg.Content}
+		case schema.Assistant:
+			if pending == nil {
+				return nil, fmt.Errorf("assistant message without user message")
+			}
+			pending.BotMessage = msg.Content
+			convs = append(convs, pending)
+			pending = nil
+		default:
+			return nil, fmt.Errorf("unsupported role: %s", msg.Role)
+		}
+	}
+
+	if pending != nil {
+		return nil, fmt.Errorf("user message without assistant reply")
+	}
+
+	return convs, nil
+}
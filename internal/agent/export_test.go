@@ -0,0 +1,186 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"eino/internal/config"
+	"eino/internal/model"
+	"eino/internal/storage"
+	"eino/internal/storage/memory"
+)
+
+// failingStorage 第failAt次保存对话时返回错误
+type failingStorage struct {
+	storage.Storage
+	saves  int
+	failAt int
+}
+
+func (s *failingStorage) SaveConversation(ctx context.Context, conv *model.Conversation) error {
+	s.saves++
+	if s.saves == s.failAt {
+		return errors.New("disk full")
+	}
+	return s.Storage.SaveConversation(ctx, conv)
+}
+
+func newImportService(t *testing.T, store storage.Storage, chatbotIDs ...string) *ChatService {
+	t.Helper()
+	for _, id := range chatbotIDs {
+		if err := store.SaveChatbot(context.Background(), &model.Chatbot{ID: id, Name: id}); err != nil {
+			t.Fatalf("SaveChatbot: %v", err)
+		}
+	}
+	return &ChatService{config: &config.Config{Agent: config.AgentConfig{MaxHistory: 20}}, storage: store}
+}
+
+func TestImportConversationsRollback(t *testing.T) {
+	ctx := context.Background()
+	store := &failingStorage{Storage: memory.NewMemoryStorage(), failAt: 3}
+	s := newImportService(t, store, "bot")
+
+	existing := &model.Conversation{ChatbotID: "bot", UserMessage: "hi", BotMessage: "hello"}
+	if err := store.Storage.SaveConversation(ctx, existing); err != nil {
+		t.Fatalf("SaveConversation: %v", err)
+	}
+
+	data := strings.Join([]string{
+		`{"user_message": "u1", "bot_message": "b1"}`,
+		`{"user_message": "u2", "bot_message": "b2"}`,
+		`{"user_message": "u3", "bot_message": "b3"}`,
+	}, "\n")
+	if _, err := s.ImportConversations(ctx, "bot", strings.NewReader(data)); err == nil {
+		t.Fatal("ImportConversations succeeded, want storage error")
+	}
+
+	history, err := store.GetConversationHistory(ctx, "bot", 10)
+	if err != nil {
+		t.Fatalf("GetConversationHistory: %v", err)
+	}
+	if len(history) != 1 || history[0].ID != existing.ID {
+		t.Fatalf("history after failed import = %+v, want only the existing conversation", history)
+	}
+}
+
+func TestImportConversationsRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewMemoryStorage()
+	s := newImportService(t, store, "source", "target")
+
+	original := &model.Conversation{
+		ChatbotID:      "source",
+		UserMessage:    "退货要几天？",
+		BotMessage:     "七天内可以退货[1]。",
+		PersonaVersion: 2,
+		Metadata: &model.ConversationMetadata{
+			Citations: []model.Citation{{Index: 1, ChunkID: 7, KnowledgeBase: "default"}},
+			RAG:       &model.RAGDecision{Mode: model.RAGModeAuto, Retrieve: true, Reason: "question"},
+		},
+	}
+	if err := store.SaveConversation(ctx, original); err != nil {
+		t.Fatalf("SaveConversation: %v", err)
+	}
+	var exported bytes.Buffer
+	if err := s.ExportConversations(ctx, &model.Chatbot{ID: "source"}, model.ExportFormatJSONL, &exported); err != nil {
+		t.Fatalf("ExportConversations: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		chatbotID      string
+		personaVersion int // 导入到其他聊天机器人时人设版本没有意义
+	}{
+		{"SameChatbot", "source", 2},
+		{"OtherChatbot", "target", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.ImportConversations(ctx, tt.chatbotID, bytes.NewReader(exported.Bytes())); err != nil {
+				t.Fatalf("ImportConversations: %v", err)
+			}
+			history, err := store.GetConversationHistory(ctx, tt.chatbotID, 1)
+			if err != nil {
+				t.Fatalf("GetConversationHistory: %v", err)
+			}
+			got := history[0]
+			if got.ID == original.ID || got.UserMessage != original.UserMessage || got.PersonaVersion != tt.personaVersion {
+				t.Fatalf("imported = %+v", got)
+			}
+			if got.Metadata == nil || len(got.Metadata.Citations) != 1 || got.Metadata.Citations[0].ChunkID != 7 ||
+				got.Metadata.RAG == nil || got.Metadata.RAG.Reason != "question" {
+				t.Fatalf("imported metadata = %+v", got.Metadata)
+			}
+		})
+	}
+}
+
+func TestExportUsesPersonaVersionPrompt(t *testing.T) {
+	ctx := context.Background()
+	s, store := newTestChatService(t)
+
+	chatbot, err := s.CreateChatbot(ctx, &model.CreateChatbotRequest{Name: "bot", Personality: "冷静", Background: "图书管理员"})
+	if err != nil {
+		t.Fatalf("CreateChatbot: %v", err)
+	}
+	v1 := chatbot.SystemPrompt
+	if chatbot, err = s.UpdateChatbot(ctx, chatbot.ID, &model.UpdateChatbotRequest{Personality: "热情"}); err != nil {
+		t.Fatalf("UpdateChatbot: %v", err)
+	}
+	current := chatbot.SystemPrompt
+	if v1 == current || chatbot.PersonaVersion != 2 {
+		t.Fatalf("update did not create a new persona version: v%d", chatbot.PersonaVersion)
+	}
+
+	// 人设版本：1为旧版本，2为当前版本，0为未知，99已不存在
+	want := map[int]string{1: v1, 2: current, 0: current, 99: current}
+	versions := []int{1, 2, 0, 99}
+	for _, version := range versions {
+		conv := &model.Conversation{ChatbotID: chatbot.ID, UserMessage: "hi", BotMessage: "hello", PersonaVersion: version}
+		if err := store.SaveConversation(ctx, conv); err != nil {
+			t.Fatalf("SaveConversation: %v", err)
+		}
+		if err := store.SaveFeedback(ctx, &model.Feedback{ConversationID: conv.ID, ChatbotID: chatbot.ID, Rating: model.RatingUp}); err != nil {
+			t.Fatalf("SaveFeedback: %v", err)
+		}
+	}
+
+	var conversations, preferences bytes.Buffer
+	if err := s.ExportConversations(ctx, chatbot, model.ExportFormatOpenAI, &conversations); err != nil {
+		t.Fatalf("ExportConversations: %v", err)
+	}
+	if err := s.ExportPreferences(ctx, chatbot, model.PreferenceFormatKTO, &preferences); err != nil {
+		t.Fatalf("ExportPreferences: %v", err)
+	}
+
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(conversations.String()), "\n") {
+		var record openAIRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("decode %q: %v", line, err)
+		}
+		got = append(got, record.Messages[0].Content)
+	}
+	for i, version := range versions {
+		if got[i] != want[version] {
+			t.Fatalf("openai record %d (v%d) system prompt = %q, want %q", i, version, got[i], want[version])
+		}
+	}
+
+	// 偏好数据按反馈顺序输出，逐条核对系统提示词
+	prompts := make(map[string]int)
+	for _, line := range strings.Split(strings.TrimSpace(preferences.String()), "\n") {
+		var record ktoRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("decode %q: %v", line, err)
+		}
+		prompts[record.Prompt[0].Content]++
+	}
+	if prompts[v1] != 1 || prompts[current] != 3 {
+		t.Fatalf("kto system prompts = %v, want v1 once and the current prompt 3 times", prompts)
+	}
+}
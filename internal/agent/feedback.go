@@ -0,0 +1,256 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"eino/internal/model"
+	"eino/internal/storage"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// ErrInvalidFeedback 反馈内容不合法
+var ErrInvalidFeedback = errors.New("invalid feedback")
+
+// SubmitFeedback 提交或更新对一轮回复的反馈
+func (s *ChatService) SubmitFeedback(ctx context.Context, conversationID int64, req *model.FeedbackRequest) (*model.Feedback, error) {
+	if err := req.Normalize(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFeedback, err)
+	}
+
+	conv, err := s.storage.GetConversation(ctx, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("get conversation: %w", err)
+	}
+
+	now := time.Now()
+	feedback := &model.Feedback{
+		ConversationID: conv.ID,
+		ChatbotID:      conv.ChatbotID,
+		Rating:         req.Rating,
+		Tags:           req.Tags,
+		Comment:        req.Comment,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	// 修改反馈时保留首次提交时间，满意度统计按首次提交时间归入时间段
+	existing, err := s.storage.GetFeedback(ctx, conversationID)
+	switch {
+	case err == nil:
+		feedback.CreatedAt = existing.CreatedAt
+	case !errors.Is(err, storage.ErrNotFound):
+		return nil, fmt.Errorf("get feedback: %w", err)
+	}
+
+	if err := s.storage.SaveFeedback(ctx, feedback); err != nil {
+		return nil, fmt.Errorf("save feedback: %w", err)
+	}
+
+	return feedback, nil
+}
+
+// GetFeedback 获取对一轮回复的反馈
+func (s *ChatService) GetFeedback(ctx context.Context, conversationID int64) (*model.Feedback, error) {
+	feedback, err := s.storage.GetFeedback(ctx, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("get feedback: %w", err)
+	}
+	return feedback, nil
+}
+
+// FeedbackStats 按时间段统计聊天机器人的满意度
+func (s *ChatService) FeedbackStats(ctx context.Context, query model.FeedbackQuery, interval string) (*model.FeedbackStats, error) {
+	if _, err := s.storage.GetChatbot(ctx, query.ChatbotID); err != nil {
+		return nil, fmt.Errorf("get chatbot: %w", err)
+	}
+
+	feedback, err := s.storage.ListFeedback(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("list feedback: %w", err)
+	}
+
+	return model.NewFeedbackStats(query.ChatbotID, interval, feedback), nil
+}
+
+// dpoRecord 成对偏好数据记录（对话格式）
+type dpoRecord struct {
+	Prompt   []openAIMessage `json:"prompt"`
+	Chosen   []openAIMessage `json:"chosen"`
+	Rejected []openAIMessage `json:"rejected"`
+}
+
+// ktoRecord 非成对偏好数据记录（对话格式）
+type ktoRecord struct {
+	Prompt     []openAIMessage `json:"prompt"`
+	Completion []openAIMessage `json:"completion"`
+	Label      bool            `json:"label"`
+}
+
+// ExportPreferences 将有评分的回复导出为偏好数据集（JSONL）
+//
+// prompt包含生成回复时人设版本的系统提示词、所在分支之前的对话（最多max_history轮）和本轮用户消息。
+// dpo格式只导出存在重新生成的回复：同一父节点下用户消息相同的兄弟记录中，点赞的回复为chosen，
+// 点踩的回复为rejected；没有点踩时，chosen之前生成且未评分的回复（被重新生成替换）视为rejected。
+func (s *ChatService) ExportPreferences(ctx context.Context, chatbot *model.Chatbot, format string, w io.Writer) error {
+	feedback, err := s.storage.ListFeedback(ctx, model.FeedbackQuery{ChatbotID: chatbot.ID})
+	if err != nil {
+		return fmt.Errorf("list feedback: %w", err)
+	}
+
+	ratings := make(map[int64]int, len(feedback))
+	for _, f := range feedback {
+		ratings[f.ConversationID] = f.Rating
+	}
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+
+	// dpo格式下每组兄弟记录只处理一次
+	type siblingKey struct {
+		parentID    int64
+		userMessage string
+	}
+	done := make(map[siblingKey]bool)
+	prompts := newVersionPrompts(s.storage, chatbot)
+
+	for _, f := range feedback {
+		conv, err := s.storage.GetConversation(ctx, f.ConversationID)
+		if errors.Is(err, storage.ErrNotFound) {
+			continue // 对话已过期或被删除
+		}
+		if err != nil {
+			return fmt.Errorf("get conversation: %w", err)
+		}
+
+		switch format {
+		case model.PreferenceFormatKTO:
+			prompt, err := s.preferencePrompt(ctx, prompts, conv)
+			if err != nil {
+				return err
+			}
+			err = enc.Encode(ktoRecord{
+				Prompt:     prompt,
+				Completion: []openAIMessage{{Role: schema.Assistant, Content: conv.BotMessage}},
+				Label:      f.Rating == model.RatingUp,
+			})
+			if err != nil {
+				return fmt.Errorf("encode preference: %w", err)
+			}
+
+		case model.PreferenceFormatDPO:
+			key := siblingKey{conv.ParentID, conv.UserMessage}
+			if done[key] {
+				continue
+			}
+			done[key] = true
+
+			pairs, err := s.preferencePairs(ctx, conv, ratings)
+			if err != nil {
+				return err
+			}
+			if len(pairs) == 0 {
+				continue
+			}
+
+			prompt, err := s.preferencePrompt(ctx, prompts, conv)
+			if err != nil {
+				return err
+			}
+			for _, pair := range pairs {
+				err := enc.Encode(dpoRecord{
+					Prompt:   prompt,
+					Chosen:   []openAIMessage{{Role: schema.Assistant, Content: pair[0].BotMessage}},
+					Rejected: []openAIMessage{{Role: schema.Assistant, Content: pair[1].BotMessage}},
+				})
+				if err != nil {
+					return fmt.Errorf("encode preference: %w", err)
+				}
+			}
+
+		default:
+			return fmt.Errorf("unsupported preference format: %s", format)
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("write export: %w", err)
+	}
+	return nil
+}
+
+// preferencePairs 在conv及其重新生成的兄弟记录中组成(chosen, rejected)对
+func (s *ChatService) preferencePairs(ctx context.Context, conv *model.Conversation, ratings map[int64]int) ([][2]*model.Conversation, error) {
+	branches, err := s.storage.GetConversationBranches(ctx, conv.ID)
+	if err != nil {
+		return nil, fmt.Errorf("get conversation branches: %w", err)
+	}
+
+	var chosen, rejected, unrated []*model.Conversation
+	for _, b := range branches {
+		// 编辑过用户消息的分支不是同一问题的重新生成
+		if b.UserMessage != conv.UserMessage {
+			continue
+		}
+		switch ratings[b.ID] {
+		case model.RatingUp:
+			chosen = append(chosen, b)
+		case model.RatingDown:
+			rejected = append(rejected, b)
+		default:
+			unrated = append(unrated, b)
+		}
+	}
+
+	var pairs [][2]*model.Conversation
+	for _, c := range chosen {
+		if len(rejected) > 0 {
+			for _, r := range rejected {
+				pairs = append(pairs, [2]*model.Conversation{c, r})
+			}
+			continue
+		}
+		for _, r := range unrated {
+			if r.ID < c.ID {
+				pairs = append(pairs, [2]*model.Conversation{c, r})
+			}
+		}
+	}
+
+	return pairs, nil
+}
+
+// preferencePrompt 构建偏好数据的prompt：生成回复时人设版本的系统提示词、分支上之前的对话和本轮用户消息
+func (s *ChatService) preferencePrompt(ctx context.Context, prompts *versionPrompts, conv *model.Conversation) ([]openAIMessage, error) {
+	systemPrompt, err := prompts.get(ctx, conv.PersonaVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []openAIMessage
+	if systemPrompt != "" {
+		messages = append(messages, openAIMessage{Role: schema.System, Content: systemPrompt})
+	}
+
+	if conv.ParentID != 0 && s.config.Agent.MaxHistory > 0 {
+		history, err := s.storage.GetConversationPath(ctx, conv.ParentID, s.config.Agent.MaxHistory)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("get conversation path: %w", err)
+		}
+		for _, h := range history {
+			messages = append(messages,
+				openAIMessage{Role: schema.User, Content: h.UserMessage},
+				openAIMessage{Role: schema.Assistant, Content: h.BotMessage},
+			)
+		}
+	}
+
+	return append(messages, openAIMessage{Role: schema.User, Content: conv.UserMessage}), nil
+}
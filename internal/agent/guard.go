@@ -0,0 +1,198 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"eino/internal/llmjson"
+	"eino/internal/model"
+
+	einomodel "github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// defaultGuardRetries 未配置时的重新生成次数
+const defaultGuardRetries = 1
+
+// disclaimerPhrases 常见的出戏声明，规则检查时忽略大小写匹配
+var disclaimerPhrases = []string{
+	"as an ai",
+	"as a language model",
+	"i am an ai",
+	"i'm an ai",
+	"i am a language model",
+	"i'm a language model",
+	"作为一个ai",
+	"作为ai",
+	"作为一个人工智能",
+	"作为人工智能",
+	"作为一个语言模型",
+	"作为语言模型",
+	"我是一个ai",
+	"我是一个人工智能",
+	"我是一个语言模型",
+	"我只是一个ai",
+}
+
+// guardJudgePrompt 模型检查使用的系统提示词
+const guardJudgePrompt = `你是角色扮演质量审核员。根据角色设定判断角色的回复是否出戏，例如违背性格或背景设定、自称AI或语言模型、跳出角色解释规则等。
+只输出JSON，不要输出其他内容：{"consistent": true或false, "reason": "不一致时简述原因"}`
+
+// guardRewritePrompt 改写回复使用的系统提示词
+const guardRewritePrompt = `你负责修正角色扮演中的出戏回复。请在保持原回复信息的前提下，按角色设定改写，去掉自称AI等出戏内容。只输出改写后的回复。`
+
+// guardReply 对回复执行人设一致性检查，按配置重新生成或改写，返回最终回复和检查结果
+//
+// 未启用检查时返回原回复和nil。模型判断失败不会中断对话，只记录日志并视为通过。
+func (s *ChatService) guardReply(ctx context.Context, chatbot *model.Chatbot, messages []*schema.Message, userMessage, reply string) (string, *model.GuardVerdict, error) {
+	guard := chatbot.Options.Guard
+	if guard == nil || !guard.Enabled {
+		return reply, nil, nil
+	}
+
+	mode := guardMode(guard)
+	retries := guard.MaxRetries
+	if retries <= 0 {
+		retries = defaultGuardRetries
+	}
+
+	verdict := &model.GuardVerdict{Mode: mode, Attempts: 1}
+	violations := s.checkReply(ctx, chatbot, guard, mode, userMessage, reply)
+
+	switch guard.Action {
+	case "", model.GuardActionRegenerate:
+		for len(violations) > 0 && verdict.Attempts <= retries {
+			response, err := s.generate(ctx, messages, s.generateOptions(chatbot)...)
+			if err != nil {
+				return "", nil, fmt.Errorf("regenerate response: %w", err)
+			}
+			verdict.Attempts++
+			reply = response.Content
+			violations = s.checkReply(ctx, chatbot, guard, mode, userMessage, reply)
+		}
+	case model.GuardActionRewrite:
+		if len(violations) > 0 {
+			rewritten, err := s.rewriteReply(ctx, chatbot, userMessage, reply, violations)
+			if err != nil {
+				return "", nil, fmt.Errorf("rewrite response: %w", err)
+			}
+			reply = rewritten
+			verdict.Rewritten = true
+			violations = s.checkReply(ctx, chatbot, guard, mode, userMessage, reply)
+		}
+	}
+
+	verdict.Passed = len(violations) == 0
+	verdict.Violations = violations
+	return reply, verdict, nil
+}
+
+// checkStreamedReply 检查已经流式输出的回复，只记录结果；未启用检查时返回nil
+func (s *ChatService) checkStreamedReply(ctx context.Context, chatbot *model.Chatbot, userMessage, reply string) *model.GuardVerdict {
+	guard := chatbot.Options.Guard
+	if guard == nil || !guard.Enabled {
+		return nil
+	}
+
+	mode := guardMode(guard)
+	violations := s.checkReply(ctx, chatbot, guard, mode, userMessage, reply)
+	return &model.GuardVerdict{
+		Passed:     len(violations) == 0,
+		Mode:       mode,
+		Attempts:   1,
+		Violations: violations,
+	}
+}
+
+// guardMode 检查方式，未配置时使用规则检查
+func guardMode(guard *model.GuardOptions) string {
+	if guard.Mode == "" {
+		return model.GuardModeRules
+	}
+	return guard.Mode
+}
+
+// checkReply 检查回复，返回发现的问题，没有问题时返回nil
+func (s *ChatService) checkReply(ctx context.Context, chatbot *model.Chatbot, guard *model.GuardOptions, mode, userMessage, reply string) []string {
+	var violations []string
+	if mode == model.GuardModeRules || mode == model.GuardModeBoth {
+		violations = checkRules(guard, reply)
+	}
+	// both模式下规则已发现问题时不再调用模型
+	if len(violations) == 0 && (mode == model.GuardModeModel || mode == model.GuardModeBoth) {
+		reason, err := s.judgeReply(ctx, chatbot, userMessage, reply)
+		if err != nil {
+			log.Printf("Warning: persona guard judge failed for chatbot %s: %v", chatbot.ID, err)
+		} else if reason != "" {
+			violations = append(violations, "judge: "+reason)
+		}
+	}
+	return violations
+}
+
+// checkRules 按禁用短语和出戏声明检查回复
+func checkRules(guard *model.GuardOptions, reply string) []string {
+	lower := strings.ToLower(reply)
+
+	var violations []string
+	for _, phrase := range guard.BannedPhrases {
+		if phrase != "" && strings.Contains(lower, strings.ToLower(phrase)) {
+			violations = append(violations, "banned phrase: "+phrase)
+		}
+	}
+	for _, phrase := range disclaimerPhrases {
+		if strings.Contains(lower, phrase) {
+			violations = append(violations, "disclaimer: "+phrase)
+			break
+		}
+	}
+	return violations
+}
+
+// judgeReply 调用模型判断回复是否出戏，出戏时返回原因；结果缺少consistent字段时视为评审失败
+func (s *ChatService) judgeReply(ctx context.Context, chatbot *model.Chatbot, userMessage, reply string) (string, error) {
+	messages := []*schema.Message{
+		schema.SystemMessage(guardJudgePrompt),
+		schema.UserMessage(fmt.Sprintf("角色设定：\n%s\n\n用户消息：\n%s\n\n角色回复：\n%s", chatbot.SystemPrompt, userMessage, reply)),
+	}
+
+	response, err := s.generate(ctx, messages, einomodel.WithTemperature(0))
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		Consistent *bool  `json:"consistent"`
+		Reason     string `json:"reason"`
+	}
+	if err := llmjson.Unmarshal(response.Content, &result); err != nil {
+		return "", fmt.Errorf("parse judge result %q: %w", response.Content, err)
+	}
+	if result.Consistent == nil {
+		return "", fmt.Errorf("judge result %q has no consistent field", response.Content)
+	}
+	if *result.Consistent {
+		return "", nil
+	}
+	if result.Reason == "" {
+		return "inconsistent", nil
+	}
+	return result.Reason, nil
+}
+
+// rewriteReply 让模型按人设改写出戏的回复
+func (s *ChatService) rewriteReply(ctx context.Context, chatbot *model.Chatbot, userMessage, reply string, violations []string) (string, error) {
+	messages := []*schema.Message{
+		schema.SystemMessage(guardRewritePrompt),
+		schema.UserMessage(fmt.Sprintf("角色设定：\n%s\n\n用户消息：\n%s\n\n原回复：\n%s\n\n问题：\n%s",
+			chatbot.SystemPrompt, userMessage, reply, strings.Join(violations, "\n"))),
+	}
+
+	response, err := s.generate(ctx, messages, s.generateOptions(chatbot)...)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(response.Content), nil
+}
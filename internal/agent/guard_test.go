@@ -0,0 +1,215 @@
+package agent
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"eino/internal/fake"
+	"eino/internal/model"
+	"eino/internal/storage"
+
+	einomodel "github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// scriptedModel 依次返回预设的回复；人设检查的评审返回judgments中的下一条，其他要求输出JSON的请求交给fake模型
+type scriptedModel struct {
+	mu        sync.Mutex
+	replies   []string
+	judgments []string
+	calls     int                 // 消耗的预设回复数
+	inputs    [][]*schema.Message // 每次生成回复时的输入
+}
+
+func (m *scriptedModel) Generate(ctx context.Context, input []*schema.Message, opts ...einomodel.Option) (*schema.Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	system := input[0].Content
+	switch {
+	case strings.Contains(system, `"consistent"`) && len(m.judgments) > 0:
+		judgment := m.judgments[0]
+		m.judgments = m.judgments[1:]
+		return schema.AssistantMessage(judgment, nil), nil
+	case strings.Contains(system, "只输出JSON"):
+		return fake.NewChatModel().Generate(ctx, input, opts...)
+	}
+	m.calls++
+	m.inputs = append(m.inputs, input)
+	if len(m.replies) == 0 {
+		return schema.AssistantMessage("（没有更多预设回复）", nil), nil
+	}
+	reply := m.replies[0]
+	m.replies = m.replies[1:]
+	return schema.AssistantMessage(reply, nil), nil
+}
+
+func (m *scriptedModel) Stream(ctx context.Context, input []*schema.Message, opts ...einomodel.Option) (*schema.StreamReader[*schema.Message], error) {
+	msg, err := m.Generate(ctx, input, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return schema.StreamReaderFromArray([]*schema.Message{msg}), nil
+}
+
+func TestGuardReply(t *testing.T) {
+	tests := []struct {
+		name      string
+		guard     model.GuardOptions
+		replies   []string
+		judgments []string
+		want      string
+		verdict   model.GuardVerdict
+	}{
+		{
+			name:    "Passes",
+			guard:   model.GuardOptions{Enabled: true},
+			replies: []string{"哈哈，船长我当然知道！"},
+			want:    "哈哈，船长我当然知道！",
+			verdict: model.GuardVerdict{Passed: true, Mode: model.GuardModeRules, Attempts: 1},
+		},
+		{
+			name:    "RegeneratePasses",
+			guard:   model.GuardOptions{Enabled: true, MaxRetries: 2},
+			replies: []string{"作为一个AI，我无法出海。", "起锚！跟我出海！"},
+			want:    "起锚！跟我出海！",
+			verdict: model.GuardVerdict{Passed: true, Mode: model.GuardModeRules, Attempts: 2},
+		},
+		{
+			name:    "RegenerateExhausted",
+			guard:   model.GuardOptions{Enabled: true, MaxRetries: 2, BannedPhrases: []string{"机器人"}},
+			replies: []string{"作为AI我不知道。", "我只是个机器人。", "我是机器人，作为AI不能回答。"},
+			want:    "我是机器人，作为AI不能回答。",
+			verdict: model.GuardVerdict{Mode: model.GuardModeRules, Attempts: 3, Violations: []string{"banned phrase: 机器人", "disclaimer: 作为ai"}},
+		},
+		{
+			name:    "Rewrite",
+			guard:   model.GuardOptions{Enabled: true, Action: model.GuardActionRewrite},
+			replies: []string{"As an AI, I cannot sail.", "  老夫这就扬帆！  "},
+			want:    "老夫这就扬帆！",
+			verdict: model.GuardVerdict{Passed: true, Mode: model.GuardModeRules, Attempts: 1, Rewritten: true},
+		},
+		{
+			name:    "RewriteStillFails",
+			guard:   model.GuardOptions{Enabled: true, Action: model.GuardActionRewrite},
+			replies: []string{"As an AI, I cannot sail.", "I'm an AI pirate."},
+			want:    "I'm an AI pirate.",
+			verdict: model.GuardVerdict{Mode: model.GuardModeRules, Attempts: 1, Rewritten: true, Violations: []string{"disclaimer: i'm an ai"}},
+		},
+		{
+			name:    "RecordOnly",
+			guard:   model.GuardOptions{Enabled: true, Action: model.GuardActionNone},
+			replies: []string{"作为AI我不知道。"},
+			want:    "作为AI我不知道。",
+			verdict: model.GuardVerdict{Mode: model.GuardModeRules, Attempts: 1, Violations: []string{"disclaimer: 作为ai"}},
+		},
+		{
+			name:      "ModelJudge",
+			guard:     model.GuardOptions{Enabled: true, Mode: model.GuardModeModel},
+			replies:   []string{"我们今天讨论一下税法。", "出海喽！"},
+			judgments: []string{`{"consistent": false, "reason": "偏离海盗背景"}`, `{"consistent": true}`},
+			want:      "出海喽！",
+			verdict:   model.GuardVerdict{Passed: true, Mode: model.GuardModeModel, Attempts: 2},
+		},
+		{
+			name:      "ModelJudgeFailure",
+			guard:     model.GuardOptions{Enabled: true, Mode: model.GuardModeModel},
+			replies:   []string{"我们今天讨论一下税法。"},
+			judgments: []string{`{"reason": "没有结论"}`},
+			want:      "我们今天讨论一下税法。",
+			verdict:   model.GuardVerdict{Passed: true, Mode: model.GuardModeModel, Attempts: 1},
+		},
+		{
+			name:      "BothStopsAtRules",
+			guard:     model.GuardOptions{Enabled: true, Mode: model.GuardModeBoth, Action: model.GuardActionNone},
+			replies:   []string{"作为AI我不知道。"},
+			judgments: []string{`{"consistent": false, "reason": "不应调用"}`},
+			want:      "作为AI我不知道。",
+			verdict:   model.GuardVerdict{Mode: model.GuardModeBoth, Attempts: 1, Violations: []string{"disclaimer: 作为ai"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s, store := newTestChatService(t)
+			scripted := &scriptedModel{replies: tt.replies, judgments: tt.judgments}
+			s.model = scripted
+
+			guard := tt.guard
+			chatbot, err := s.CreateChatbot(ctx, &model.CreateChatbotRequest{
+				Name: "船长", Personality: "豪爽", Background: "海盗",
+				Options: model.GenerationOptions{Guard: &guard},
+			})
+			if err != nil {
+				t.Fatalf("CreateChatbot: %v", err)
+			}
+
+			resp, err := s.Chat(ctx, chatbot.ID, &model.ChatRequest{Message: "能出海吗？"})
+			if err != nil {
+				t.Fatalf("Chat: %v", err)
+			}
+			if resp.Message != tt.want {
+				t.Fatalf("reply = %q, want %q", resp.Message, tt.want)
+			}
+			if scripted.calls != len(tt.replies) {
+				t.Fatalf("model generated %d replies, want %d", scripted.calls, len(tt.replies))
+			}
+
+			// 检查结果保存在对话记录的元数据中
+			history := mustChatHistory(t, store, chatbot.ID)
+			if history[0].Metadata == nil || history[0].Metadata.Guard == nil {
+				t.Fatalf("conversation metadata = %+v, want a guard verdict", history[0].Metadata)
+			}
+			if got := history[0].Metadata.Guard; !equalVerdicts(got, &tt.verdict) {
+				t.Fatalf("verdict = %+v, want %+v", got, tt.verdict)
+			}
+		})
+	}
+}
+
+func TestGuardStreamedReply(t *testing.T) {
+	ctx := context.Background()
+	s, store := newTestChatService(t)
+	scripted := &scriptedModel{replies: []string{"作为AI我不知道。"}}
+	s.model = scripted
+
+	chatbot, err := s.CreateChatbot(ctx, &model.CreateChatbotRequest{
+		Name: "船长", Personality: "豪爽", Background: "海盗",
+		Options: model.GenerationOptions{Guard: &model.GuardOptions{Enabled: true, MaxRetries: 3}},
+	})
+	if err != nil {
+		t.Fatalf("CreateChatbot: %v", err)
+	}
+
+	// 已经输出的内容不重新生成，只记录检查结果
+	resp, err := s.StreamChat(ctx, chatbot.ID, &model.ChatRequest{Message: "能出海吗？"}, func(string) {})
+	if err != nil {
+		t.Fatalf("StreamChat: %v", err)
+	}
+	if scripted.calls != 1 {
+		t.Fatalf("model generated %d replies, want 1", scripted.calls)
+	}
+	history := mustChatHistory(t, store, chatbot.ID)
+	want := &model.GuardVerdict{Mode: model.GuardModeRules, Attempts: 1, Violations: []string{"disclaimer: 作为ai"}}
+	if history[0].ID != resp.ConversationID || !equalVerdicts(history[0].Metadata.Guard, want) {
+		t.Fatalf("verdict = %+v, want %+v", history[0].Metadata.Guard, want)
+	}
+}
+
+// mustChatHistory 读取聊天机器人的对话记录，最早的在前
+func mustChatHistory(t *testing.T, store storage.Storage, chatbotID string) []*model.Conversation {
+	t.Helper()
+	history, err := store.GetConversationHistory(context.Background(), chatbotID, 10)
+	if err != nil || len(history) == 0 {
+		t.Fatalf("GetConversationHistory = %d conversations, %v", len(history), err)
+	}
+	return history
+}
+
+func equalVerdicts(a, b *model.GuardVerdict) bool {
+	return a.Passed == b.Passed && a.Mode == b.Mode && a.Attempts == b.Attempts &&
+		a.Rewritten == b.Rewritten && slices.Equal(a.Violations, b.Violations)
+}
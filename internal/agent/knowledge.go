@@ -0,0 +1,287 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"eino/internal/document"
+	"eino/internal/model"
+	"eino/internal/storage"
+)
+
+// ErrRAGDisabled 未启用RAG服务或RAG服务不支持该操作
+var ErrRAGDisabled = errors.New("rag service not enabled")
+
+// ErrInvalidDocument 文档格式不支持或分块参数不合法
+var ErrInvalidDocument = document.ErrInvalidDocument
+
+// ErrInvalidKnowledgeBase 知识库名称不合法
+var ErrInvalidKnowledgeBase = errors.New("invalid knowledge base")
+
+// ErrKnowledgeBaseExists 同名知识库已存在
+var ErrKnowledgeBaseExists = errors.New("knowledge base already exists")
+
+// documentIngester 支持导入文档的RAG服务
+type documentIngester interface {
+	IngestDocument(ctx context.Context, knowledgeBase, filename string, r io.Reader, opts model.ChunkOptions) (*model.IngestJob, error)
+	GetIngestJob(id string) (*model.IngestJob, error)
+	ListIngestJobs() []*model.IngestJob
+}
+
+// CreateKnowledgeBase 创建知识库
+func (s *ChatService) CreateKnowledgeBase(ctx context.Context, req *model.CreateKnowledgeBaseRequest) (*model.KnowledgeBase, error) {
+	if err := model.ValidateKnowledgeBaseName(req.Name); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKnowledgeBase, err)
+	}
+
+	_, err := s.storage.GetKnowledgeBase(ctx, req.Name)
+	if err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrKnowledgeBaseExists, req.Name)
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		return nil, fmt.Errorf("get knowledge base: %w", err)
+	}
+
+	kb := &model.KnowledgeBase{Name: req.Name, Description: req.Description}
+	if err := s.storage.SaveKnowledgeBase(ctx, kb); err != nil {
+		return nil, fmt.Errorf("save knowledge base: %w", err)
+	}
+	return kb, nil
+}
+
+// GetKnowledgeBase 获取知识库
+func (s *ChatService) GetKnowledgeBase(ctx context.Context, name string) (*model.KnowledgeBase, error) {
+	kb, err := s.storage.GetKnowledgeBase(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("get knowledge base: %w", err)
+	}
+	return kb, nil
+}
+
+// ListKnowledgeBases 获取全部知识库，按名称升序
+func (s *ChatService) ListKnowledgeBases(ctx context.Context) ([]*model.KnowledgeBase, error) {
+	return s.storage.ListKnowledgeBases(ctx)
+}
+
+// ensureKnowledgeBase 获取知识库，不存在时创建
+func (s *ChatService) ensureKnowledgeBase(ctx context.Context, name, description string) (*model.KnowledgeBase, error) {
+	kb, err := s.storage.GetKnowledgeBase(ctx, name)
+	if err == nil {
+		return kb, nil
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		return nil, fmt.Errorf("get knowledge base: %w", err)
+	}
+
+	kb = &model.KnowledgeBase{Name: name, Description: description}
+	if err := s.storage.SaveKnowledgeBase(ctx, kb); err != nil {
+		return nil, fmt.Errorf("save knowledge base: %w", err)
+	}
+	return kb, nil
+}
+
+// knowledgeBaseFor 解析写入知识的目标知识库：为空时使用默认知识库（不存在时创建），否则必须已存在
+func (s *ChatService) knowledgeBaseFor(ctx context.Context, name string) (string, error) {
+	if name == "" || name == model.DefaultKnowledgeBase {
+		if _, err := s.ensureKnowledgeBase(ctx, model.DefaultKnowledgeBase, "默认知识库"); err != nil {
+			return "", err
+		}
+		return model.DefaultKnowledgeBase, nil
+	}
+
+	if _, err := s.storage.GetKnowledgeBase(ctx, name); err != nil {
+		return "", fmt.Errorf("get knowledge base: %w", err)
+	}
+	return name, nil
+}
+
+// checkKnowledgeBases 检查聊天机器人绑定的知识库是否都已创建，默认知识库总是可用
+func (s *ChatService) checkKnowledgeBases(ctx context.Context, names []string) error {
+	for _, name := range names {
+		if name == model.DefaultKnowledgeBase {
+			continue
+		}
+		_, err := s.storage.GetKnowledgeBase(ctx, name)
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("%w: knowledge base %q does not exist", ErrInvalidPersona, name)
+		}
+		if err != nil {
+			return fmt.Errorf("get knowledge base: %w", err)
+		}
+	}
+	return nil
+}
+
+// AddKnowledge 添加一条知识到知识库，knowledgeBase为空时写入默认知识库，返回分块所属的文档ID
+func (s *ChatService) AddKnowledge(ctx context.Context, knowledgeBase, content string) (string, error) {
+	adder, ok := s.ragService.(knowledgeAdder)
+	if !ok {
+		return "", ErrRAGDisabled
+	}
+	kb, err := s.knowledgeBaseFor(ctx, knowledgeBase)
+	if err != nil {
+		return "", err
+	}
+	documentID, err := adder.AddKnowledge(ctx, kb, content)
+	if err != nil {
+		return "", fmt.Errorf("add knowledge: %w", err)
+	}
+	return documentID, nil
+}
+
+// SearchKnowledge 按检索方式在指定知识库中检索与query最相关的知识，未指定知识库时检索默认知识库
+func (s *ChatService) SearchKnowledge(ctx context.Context, query string, opts model.SearchOptions) (*model.SearchResult, error) {
+	searcher, ok := s.ragService.(knowledgeSearcher)
+	if !ok {
+		return nil, ErrRAGDisabled
+	}
+	if len(opts.KnowledgeBases) == 0 {
+		opts.KnowledgeBases = []string{model.DefaultKnowledgeBase}
+	}
+	results, err := searcher.SearchKnowledge(ctx, query, opts)
+	if err != nil {
+		return nil, fmt.Errorf("search knowledge: %w", err)
+	}
+	return results, nil
+}
+
+// IngestDocument 上传文档并创建后台导入任务，knowledgeBase为空时导入默认知识库
+func (s *ChatService) IngestDocument(ctx context.Context, knowledgeBase, filename string, r io.Reader, opts model.ChunkOptions) (*model.IngestJob, error) {
+	ingester, ok := s.ragService.(documentIngester)
+	if !ok {
+		return nil, ErrRAGDisabled
+	}
+	kb, err := s.knowledgeBaseFor(ctx, knowledgeBase)
+	if err != nil {
+		return nil, err
+	}
+	return ingester.IngestDocument(ctx, kb, filename, r, opts)
+}
+
+// GetIngestJob 查询文档导入任务的进度
+func (s *ChatService) GetIngestJob(id string) (*model.IngestJob, error) {
+	ingester, ok := s.ragService.(documentIngester)
+	if !ok {
+		return nil, ErrRAGDisabled
+	}
+	return ingester.GetIngestJob(id)
+}
+
+// ListIngestJobs 列出文档导入任务，最新的在前
+func (s *ChatService) ListIngestJobs() ([]*model.IngestJob, error) {
+	ingester, ok := s.ragService.(documentIngester)
+	if !ok {
+		return nil, ErrRAGDisabled
+	}
+	return ingester.ListIngestJobs(), nil
+}
+
+// knowledgeManager 支持管理knowledge_base表中知识分块的RAG服务
+type knowledgeManager interface {
+	ListKnowledge(ctx context.Context, query model.KnowledgeQuery) (*model.KnowledgePage, error)
+	GetKnowledge(ctx context.Context, id int64) (*model.KnowledgeChunk, error)
+	UpdateKnowledge(ctx context.Context, id int64, req *model.UpdateKnowledgeRequest) (*model.KnowledgeChunk, error)
+	DeleteKnowledge(ctx context.Context, id int64) error
+	DeleteDocument(ctx context.Context, documentID string) (int, error)
+}
+
+// ListKnowledge 分页查询知识分块
+func (s *ChatService) ListKnowledge(ctx context.Context, query model.KnowledgeQuery) (*model.KnowledgePage, error) {
+	m, ok := s.ragService.(knowledgeManager)
+	if !ok {
+		return nil, ErrRAGDisabled
+	}
+	return m.ListKnowledge(ctx, query)
+}
+
+// GetKnowledge 获取知识分块
+func (s *ChatService) GetKnowledge(ctx context.Context, id int64) (*model.KnowledgeChunk, error) {
+	m, ok := s.ragService.(knowledgeManager)
+	if !ok {
+		return nil, ErrRAGDisabled
+	}
+	return m.GetKnowledge(ctx, id)
+}
+
+// UpdateKnowledge 修改知识分块并重新生成嵌入向量
+func (s *ChatService) UpdateKnowledge(ctx context.Context, id int64, req *model.UpdateKnowledgeRequest) (*model.KnowledgeChunk, error) {
+	m, ok := s.ragService.(knowledgeManager)
+	if !ok {
+		return nil, ErrRAGDisabled
+	}
+	return m.UpdateKnowledge(ctx, id, req)
+}
+
+// DeleteKnowledge 删除知识分块及其向量
+func (s *ChatService) DeleteKnowledge(ctx context.Context, id int64) error {
+	m, ok := s.ragService.(knowledgeManager)
+	if !ok {
+		return ErrRAGDisabled
+	}
+	return m.DeleteKnowledge(ctx, id)
+}
+
+// DeleteDocument 删除文档的全部分块及其向量，返回删除的分块数
+func (s *ChatService) DeleteDocument(ctx context.Context, documentID string) (int, error) {
+	m, ok := s.ragService.(knowledgeManager)
+	if !ok {
+		return 0, ErrRAGDisabled
+	}
+	return m.DeleteDocument(ctx, documentID)
+}
+
+// reindexer 支持重建索引的RAG服务
+type reindexer interface {
+	StartReindex(ctx context.Context) (*model.ReindexJob, error)
+	GetReindexJob(ctx context.Context, id string) (*model.ReindexJob, error)
+	ListReindexJobs(ctx context.Context) ([]*model.ReindexJob, error)
+	PauseReindex(ctx context.Context, id string) (*model.ReindexJob, error)
+	ResumeReindex(ctx context.Context, id string) (*model.ReindexJob, error)
+}
+
+// StartReindex 创建重建索引任务，用rag.embedding_model重新嵌入全部分块
+func (s *ChatService) StartReindex(ctx context.Context) (*model.ReindexJob, error) {
+	r, ok := s.ragService.(reindexer)
+	if !ok {
+		return nil, ErrRAGDisabled
+	}
+	return r.StartReindex(ctx)
+}
+
+// GetReindexJob 查询重建索引任务的进度
+func (s *ChatService) GetReindexJob(ctx context.Context, id string) (*model.ReindexJob, error) {
+	r, ok := s.ragService.(reindexer)
+	if !ok {
+		return nil, ErrRAGDisabled
+	}
+	return r.GetReindexJob(ctx, id)
+}
+
+// ListReindexJobs 列出重建索引任务，最新的在前
+func (s *ChatService) ListReindexJobs(ctx context.Context) ([]*model.ReindexJob, error) {
+	r, ok := s.ragService.(reindexer)
+	if !ok {
+		return nil, ErrRAGDisabled
+	}
+	return r.ListReindexJobs(ctx)
+}
+
+// PauseReindex 暂停重建索引任务
+func (s *ChatService) PauseReindex(ctx context.Context, id string) (*model.ReindexJob, error) {
+	r, ok := s.ragService.(reindexer)
+	if !ok {
+		return nil, ErrRAGDisabled
+	}
+	return r.PauseReindex(ctx, id)
+}
+
+// ResumeReindex 恢复已暂停或失败的重建索引任务
+func (s *ChatService) ResumeReindex(ctx context.Context, id string) (*model.ReindexJob, error) {
+	r, ok := s.ragService.(reindexer)
+	if !ok {
+		return nil, ErrRAGDisabled
+	}
+	return r.ResumeReindex(ctx, id)
+}
@@ -0,0 +1,197 @@
+package agent
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"eino/internal/model"
+	"eino/internal/storage"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrInvalidPersona 人设内容不合法（人设包格式错误、系统提示词模板无效或绑定的知识库不存在）
+var ErrInvalidPersona = errors.New("invalid persona")
+
+// knowledgeAdder 支持写入知识库的RAG服务
+type knowledgeAdder interface {
+	AddKnowledge(ctx context.Context, knowledgeBase, content string) (string, error)
+}
+
+// knowledgeDocumentPutter 支持按文档ID幂等写入知识文档的RAG服务
+type knowledgeDocumentPutter interface {
+	PutKnowledgeDocument(ctx context.Context, knowledgeBase, documentID, title, content string) (bool, error)
+}
+
+// ParsePersonaBundle 解析YAML或JSON格式的人设包
+func ParsePersonaBundle(data []byte) (*model.PersonaBundle, error) {
+	var bundle model.PersonaBundle
+	// JSON是YAML的子集，统一按YAML解析
+	if err := yaml.Unmarshal(data, &bundle); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPersona, err)
+	}
+	if err := bundle.Normalize(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPersona, err)
+	}
+	return &bundle, nil
+}
+
+// ImportPersona 导入人设包，按slug新建或更新聊天机器人
+//
+// 每次导入都把知识文档同步到以slug命名的知识库：按标题（没有标题时按内容）识别同一文档，
+// 内容变化的文档被替换，缺少的文档被写入，重复导入同一人设包不会产生重复知识。
+// 新建的聊天机器人在知识文档写入失败时被删除，重新导入即可重试。
+func (s *ChatService) ImportPersona(ctx context.Context, bundle *model.PersonaBundle) (*model.PersonaImportResult, error) {
+	if err := bundle.Normalize(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPersona, err)
+	}
+
+	now := time.Now()
+	chatbot, err := s.storage.GetChatbotBySlug(ctx, bundle.Slug)
+	created := errors.Is(err, storage.ErrNotFound)
+	switch {
+	case created:
+		chatbot = &model.Chatbot{
+			ID:        uuid.New().String(),
+			Slug:      bundle.Slug,
+			CreatedAt: now,
+		}
+	case err != nil:
+		return nil, fmt.Errorf("get chatbot by slug: %w", err)
+	}
+
+	chatbot.Name = bundle.Name
+	chatbot.Personality = bundle.Personality
+	chatbot.Background = bundle.Background
+	chatbot.PromptTemplate = bundle.PromptTemplate
+	chatbot.PromptFormat = bundle.PromptFormat
+	chatbot.Options = bundle.Options
+	chatbot.UpdatedAt = now
+
+	// 人设包的知识文档写入以slug命名的知识库；未设置检索的知识库时绑定该知识库
+	if len(bundle.Knowledge) > 0 {
+		if _, err := s.ensureKnowledgeBase(ctx, bundle.Slug, "人设包 "+bundle.Name+" 的知识文档"); err != nil {
+			return nil, err
+		}
+		if chatbot.Options.Retrieval == nil || len(chatbot.Options.Retrieval.KnowledgeBases) == 0 {
+			retrieval := model.RetrievalOptions{}
+			if chatbot.Options.Retrieval != nil {
+				retrieval = *chatbot.Options.Retrieval
+			}
+			retrieval.KnowledgeBases = []string{bundle.Slug}
+			chatbot.Options.Retrieval = &retrieval
+		}
+	}
+
+	systemPrompt, err := s.renderSystemPrompt(chatbot)
+	if err != nil {
+		return nil, err
+	}
+	chatbot.SystemPrompt = systemPrompt
+
+	if err := s.savePersona(ctx, chatbot, "import "+bundle.Slug); err != nil {
+		return nil, err
+	}
+
+	result := &model.PersonaImportResult{Chatbot: chatbot, Created: created}
+	if result.Knowledge, err = s.syncPersonaKnowledge(ctx, bundle); err != nil {
+		if created {
+			if delErr := s.DeleteChatbot(context.WithoutCancel(ctx), chatbot.ID); delErr != nil {
+				log.Printf("Warning: failed to delete chatbot %s after a failed persona import: %v", chatbot.ID, delErr)
+			}
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// syncPersonaKnowledge 将人设包的知识文档写入以slug命名的知识库，返回新写入或替换的文档数
+func (s *ChatService) syncPersonaKnowledge(ctx context.Context, bundle *model.PersonaBundle) (int, error) {
+	if len(bundle.Knowledge) == 0 {
+		return 0, nil
+	}
+	putter, ok := s.ragService.(knowledgeDocumentPutter)
+	if !ok {
+		log.Printf("Warning: RAG service not enabled, skipped %d knowledge documents of persona %s", len(bundle.Knowledge), bundle.Slug)
+		return 0, nil
+	}
+
+	written := 0
+	for _, doc := range bundle.Knowledge {
+		changed, err := putter.PutKnowledgeDocument(ctx, bundle.Slug, personaDocumentID(bundle.Slug, doc), doc.Title, doc.Content)
+		if err != nil {
+			return written, fmt.Errorf("add knowledge %q: %w", doc.Title, err)
+		}
+		if changed {
+			written++
+		}
+	}
+	return written, nil
+}
+
+// personaDocumentID 人设包知识文档的文档ID，由slug和标题（没有标题时为内容）确定
+func personaDocumentID(slug string, doc model.KnowledgeDocument) string {
+	key := doc.Title
+	if key == "" {
+		key = "\x00" + doc.Content
+	}
+	sum := sha256.Sum256([]byte(slug + "\n" + key))
+	return "persona-" + hex.EncodeToString(sum[:16])
+}
+
+// ExportPersona 将聊天机器人导出为人设包
+func (s *ChatService) ExportPersona(ctx context.Context, chatbotID string) (*model.PersonaBundle, error) {
+	chatbot, err := s.storage.GetChatbot(ctx, chatbotID)
+	if err != nil {
+		return nil, fmt.Errorf("get chatbot: %w", err)
+	}
+	return model.BundleFromChatbot(chatbot), nil
+}
+
+// LoadPersonaDir 导入目录下所有 .yaml/.yml/.json 人设包，按文件名顺序处理
+func (s *ChatService) LoadPersonaDir(ctx context.Context, dir string) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, fmt.Errorf("read persona dir: %w", err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".yaml", ".yml", ".json":
+			if !entry.IsDir() {
+				files = append(files, filepath.Join(dir, entry.Name()))
+			}
+		}
+	}
+	sort.Strings(files)
+
+	for i, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return i, fmt.Errorf("read persona %s: %w", file, err)
+		}
+		bundle, err := ParsePersonaBundle(data)
+		if err != nil {
+			return i, fmt.Errorf("parse persona %s: %w", file, err)
+		}
+		result, err := s.ImportPersona(ctx, bundle)
+		if err != nil {
+			return i, fmt.Errorf("import persona %s: %w", file, err)
+		}
+		log.Printf("Loaded persona %s (%s, created=%t)", bundle.Slug, result.Chatbot.ID, result.Created)
+	}
+
+	return len(files), nil
+}
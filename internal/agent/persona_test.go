@@ -0,0 +1,143 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"eino/internal/config"
+	"eino/internal/model"
+	"eino/internal/storage"
+	"eino/internal/storage/memory"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// newTestChatService 用fake模型和内存存储创建聊天服务
+func newTestChatService(t *testing.T) (*ChatService, storage.Storage) {
+	t.Helper()
+	cfg := &config.Config{
+		Model: config.ModelConfig{Provider: "fake", Timeout: 10},
+		Agent: config.AgentConfig{MaxHistory: 20, MaxRetries: 1, Timeout: 10},
+		RAG: config.RAGConfig{Retrieval: config.RetrievalConfig{
+			RAGMode:    model.RAGModeAlways,
+			Classifier: model.RAGClassifierRules,
+		}},
+	}
+	store := memory.NewMemoryStorage()
+	s, err := NewChatService(cfg, store)
+	if err != nil {
+		t.Fatalf("NewChatService: %v", err)
+	}
+	return s, store
+}
+
+// stubKnowledge 记录写入的知识文档（文档ID -> 内容），failAfter次写入后返回错误，为0时不失败
+type stubKnowledge struct {
+	docs      map[string]string
+	puts      int
+	failAfter int
+}
+
+func (k *stubKnowledge) EnhanceMessages(ctx context.Context, opts model.SearchOptions, userMessage string, messages []*schema.Message) ([]*schema.Message, *model.RetrievalTrace, error) {
+	return messages, nil, nil
+}
+
+func (k *stubKnowledge) PutKnowledgeDocument(ctx context.Context, kb, documentID, title, content string) (bool, error) {
+	if k.failAfter > 0 && k.puts >= k.failAfter {
+		return false, errors.New("embedding service unavailable")
+	}
+	k.puts++
+	if k.docs[documentID] == content {
+		return false, nil
+	}
+	k.docs[documentID] = content
+	return true, nil
+}
+
+func TestImportPersonaSyncsKnowledge(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestChatService(t)
+	knowledge := &stubKnowledge{docs: map[string]string{}}
+	s.SetRAGService(knowledge)
+
+	bundle := func(docs ...model.KnowledgeDocument) *model.PersonaBundle {
+		return &model.PersonaBundle{Slug: "tutor", Name: "数学老师", Personality: "耐心", Knowledge: docs}
+	}
+	syllabus := model.KnowledgeDocument{Title: "大纲", Content: "第一章 函数"}
+	faq := model.KnowledgeDocument{Content: "答疑时间：周三"}
+
+	steps := []struct {
+		name      string
+		bundle    *model.PersonaBundle
+		created   bool
+		knowledge int // 新写入或替换的文档数
+		docs      int // 知识库中的文档数
+	}{
+		{"Create", bundle(syllabus, faq), true, 2, 2},
+		{"Unchanged", bundle(syllabus, faq), false, 0, 2},
+		{"Changed", bundle(model.KnowledgeDocument{Title: "大纲", Content: "第一章 数列"}, faq), false, 1, 2},
+		{"Added", bundle(model.KnowledgeDocument{Title: "大纲", Content: "第一章 数列"}, faq, model.KnowledgeDocument{Title: "教材", Content: "人教版"}), false, 1, 3},
+	}
+	for _, step := range steps {
+		result, err := s.ImportPersona(ctx, step.bundle)
+		if err != nil {
+			t.Fatalf("%s: ImportPersona: %v", step.name, err)
+		}
+		if result.Created != step.created || result.Knowledge != step.knowledge || len(knowledge.docs) != step.docs {
+			t.Fatalf("%s: created=%t knowledge=%d docs=%d, want %t %d %d",
+				step.name, result.Created, result.Knowledge, len(knowledge.docs), step.created, step.knowledge, step.docs)
+		}
+	}
+	if got := knowledge.docs[personaDocumentID("tutor", syllabus)]; got != "第一章 数列" {
+		t.Fatalf("syllabus content = %q, want the replaced content", got)
+	}
+}
+
+func TestImportPersonaRollsBackCreate(t *testing.T) {
+	ctx := context.Background()
+	s, store := newTestChatService(t)
+	knowledge := &stubKnowledge{docs: map[string]string{}, failAfter: 1}
+	s.SetRAGService(knowledge)
+
+	bundle := &model.PersonaBundle{Slug: "tutor", Name: "数学老师", Knowledge: []model.KnowledgeDocument{
+		{Title: "大纲", Content: "第一章 函数"},
+		{Title: "教材", Content: "人教版"},
+	}}
+	if _, err := s.ImportPersona(ctx, bundle); err == nil {
+		t.Fatal("ImportPersona succeeded, want knowledge error")
+	}
+	if _, err := store.GetChatbotBySlug(ctx, "tutor"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("GetChatbotBySlug after failed create: err = %v, want ErrNotFound", err)
+	}
+
+	// 重试时已写入的文档不再重复写入
+	knowledge.failAfter = 0
+	result, err := s.ImportPersona(ctx, bundle)
+	if err != nil {
+		t.Fatalf("ImportPersona retry: %v", err)
+	}
+	if !result.Created || result.Knowledge != 1 || len(knowledge.docs) != 2 {
+		t.Fatalf("retry: created=%t knowledge=%d docs=%d, want true 1 2", result.Created, result.Knowledge, len(knowledge.docs))
+	}
+
+	// 更新时失败不删除已有的聊天机器人
+	knowledge.failAfter = knowledge.puts
+	bundle.Knowledge[0].Content = "第一章 数列"
+	if _, err := s.ImportPersona(ctx, bundle); err == nil {
+		t.Fatal("ImportPersona update succeeded, want knowledge error")
+	}
+	if _, err := store.GetChatbotBySlug(ctx, "tutor"); err != nil {
+		t.Fatalf("GetChatbotBySlug after failed update: %v", err)
+	}
+}
+
+func TestPersonaBundleDuplicateTitles(t *testing.T) {
+	bundle := &model.PersonaBundle{Name: "bot", Knowledge: []model.KnowledgeDocument{
+		{Title: "a", Content: "1"},
+		{Title: "a", Content: "2"},
+	}}
+	if err := bundle.Normalize(); err == nil {
+		t.Fatal("Normalize accepted duplicate knowledge titles")
+	}
+}
@@ -0,0 +1,181 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"eino/internal/model"
+	"eino/internal/storage"
+)
+
+// savePersona 保存聊天机器人，人设有变化时记录新的人设版本
+func (s *ChatService) savePersona(ctx context.Context, chatbot *model.Chatbot, comment string) error {
+	if err := s.checkKnowledgeBases(ctx, chatbot.Options.KnowledgeBases()); err != nil {
+		return err
+	}
+
+	if err := s.storage.SaveChatbot(ctx, chatbot); err != nil {
+		return fmt.Errorf("save chatbot: %w", err)
+	}
+
+	if chatbot.PersonaVersion > 0 {
+		current, err := s.storage.GetPersonaVersion(ctx, chatbot.ID, chatbot.PersonaVersion)
+		if err == nil && current.SamePersona(chatbot) {
+			return nil
+		}
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("get persona version: %w", err)
+		}
+	}
+
+	version := model.NewPersonaVersion(chatbot, comment)
+	if err := s.storage.SavePersonaVersion(ctx, version); err != nil {
+		return fmt.Errorf("save persona version: %w", err)
+	}
+	chatbot.PersonaVersion = version.Version
+
+	return nil
+}
+
+// UpdateChatbot 更新聊天机器人，人设变化时生成新版本
+func (s *ChatService) UpdateChatbot(ctx context.Context, id string, req *model.UpdateChatbotRequest) (*model.Chatbot, error) {
+	chatbot, err := s.storage.GetChatbot(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get chatbot: %w", err)
+	}
+
+	if req.Name != "" {
+		chatbot.Name = req.Name
+	}
+	if req.Personality != "" {
+		chatbot.Personality = req.Personality
+	}
+	if req.Background != "" {
+		chatbot.Background = req.Background
+	}
+	if req.PromptTemplate != nil {
+		chatbot.PromptTemplate = *req.PromptTemplate
+	}
+	if req.PromptFormat != nil {
+		chatbot.PromptFormat = *req.PromptFormat
+	}
+	if req.Options != nil {
+		chatbot.Options = *req.Options
+	}
+	chatbot.UpdatedAt = time.Now()
+
+	if err := chatbot.Options.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPersona, err)
+	}
+	systemPrompt, err := s.renderSystemPrompt(chatbot)
+	if err != nil {
+		return nil, err
+	}
+	chatbot.SystemPrompt = systemPrompt
+
+	if err := s.savePersona(ctx, chatbot, req.Comment); err != nil {
+		return nil, err
+	}
+
+	return chatbot, nil
+}
+
+// ListPersonaVersions 获取聊天机器人的人设版本历史，最新版本在前
+func (s *ChatService) ListPersonaVersions(ctx context.Context, chatbotID string) ([]*model.PersonaVersion, error) {
+	if _, err := s.storage.GetChatbot(ctx, chatbotID); err != nil {
+		return nil, fmt.Errorf("get chatbot: %w", err)
+	}
+	return s.storage.ListPersonaVersions(ctx, chatbotID)
+}
+
+// GetPersonaVersion 获取指定人设版本
+func (s *ChatService) GetPersonaVersion(ctx context.Context, chatbotID string, version int) (*model.PersonaVersion, error) {
+	v, err := s.storage.GetPersonaVersion(ctx, chatbotID, version)
+	if err != nil {
+		return nil, fmt.Errorf("get persona version %d: %w", version, err)
+	}
+	return v, nil
+}
+
+// DiffPersonaVersions 比较两个人设版本
+func (s *ChatService) DiffPersonaVersions(ctx context.Context, chatbotID string, from, to int) (*model.PersonaDiff, error) {
+	fromVersion, err := s.GetPersonaVersion(ctx, chatbotID, from)
+	if err != nil {
+		return nil, err
+	}
+	toVersion, err := s.GetPersonaVersion(ctx, chatbotID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return model.DiffPersonaVersions(fromVersion, toVersion), nil
+}
+
+// RollbackPersona 回滚到指定人设版本
+//
+// 历史版本不可修改，回滚会以该版本的内容生成一个新版本。
+// 保存的系统提示词直接使用历史版本的内容，对话时仍按模板重新渲染。
+func (s *ChatService) RollbackPersona(ctx context.Context, chatbotID string, version int) (*model.Chatbot, error) {
+	chatbot, err := s.storage.GetChatbot(ctx, chatbotID)
+	if err != nil {
+		return nil, fmt.Errorf("get chatbot: %w", err)
+	}
+
+	target, err := s.GetPersonaVersion(ctx, chatbotID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	chatbot.Personality = target.Personality
+	chatbot.Background = target.Background
+	chatbot.PromptTemplate = target.PromptTemplate
+	chatbot.PromptFormat = target.PromptFormat
+	chatbot.SystemPrompt = target.SystemPrompt
+	// 没有记录生成参数的旧版本保留当前的生成参数
+	if target.Options != nil {
+		chatbot.Options = *target.Options
+	}
+	chatbot.UpdatedAt = time.Now()
+
+	if err := s.savePersona(ctx, chatbot, fmt.Sprintf("rollback to v%d", version)); err != nil {
+		return nil, err
+	}
+
+	return chatbot, nil
+}
+
+// versionPrompts 按对话记录的人设版本查找生成回复时的系统提示词，导出时缓存查过的版本
+//
+// 版本号为0（未知）或版本已不存在时使用聊天机器人当前的系统提示词。
+type versionPrompts struct {
+	storage storage.Storage
+	chatbot *model.Chatbot
+	prompts map[int]string
+}
+
+func newVersionPrompts(store storage.Storage, chatbot *model.Chatbot) *versionPrompts {
+	return &versionPrompts{storage: store, chatbot: chatbot, prompts: make(map[int]string)}
+}
+
+// get 返回人设版本version的系统提示词
+func (p *versionPrompts) get(ctx context.Context, version int) (string, error) {
+	if version <= 0 {
+		return p.chatbot.SystemPrompt, nil
+	}
+	if prompt, ok := p.prompts[version]; ok {
+		return prompt, nil
+	}
+
+	prompt := p.chatbot.SystemPrompt
+	v, err := p.storage.GetPersonaVersion(ctx, p.chatbot.ID, version)
+	switch {
+	case err == nil:
+		prompt = v.SystemPrompt
+	case !errors.Is(err, storage.ErrNotFound):
+		return "", fmt.Errorf("get persona version: %w", err)
+	}
+	p.prompts[version] = prompt
+	return prompt, nil
+}
@@ -0,0 +1,101 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"eino/internal/model"
+)
+
+func TestPersonaVersionOptions(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestChatService(t)
+
+	chatbot, err := s.CreateChatbot(ctx, &model.CreateChatbotRequest{Name: "bot", Personality: "p", Background: "b"})
+	if err != nil {
+		t.Fatalf("CreateChatbot: %v", err)
+	}
+
+	// 只修改生成参数也生成新版本
+	temperature := float32(0.2)
+	updated, err := s.UpdateChatbot(ctx, chatbot.ID, &model.UpdateChatbotRequest{
+		Options: &model.GenerationOptions{Temperature: &temperature},
+	})
+	if err != nil {
+		t.Fatalf("UpdateChatbot: %v", err)
+	}
+	if updated.PersonaVersion != 2 {
+		t.Fatalf("persona_version = %d, want 2", updated.PersonaVersion)
+	}
+
+	// 相同的生成参数不生成新版本
+	same := float32(0.2)
+	updated, err = s.UpdateChatbot(ctx, chatbot.ID, &model.UpdateChatbotRequest{
+		Options: &model.GenerationOptions{Temperature: &same},
+	})
+	if err != nil {
+		t.Fatalf("UpdateChatbot: %v", err)
+	}
+	if updated.PersonaVersion != 2 {
+		t.Fatalf("persona_version after same options = %d, want 2", updated.PersonaVersion)
+	}
+
+	diff, err := s.DiffPersonaVersions(ctx, chatbot.ID, 1, 2)
+	if err != nil {
+		t.Fatalf("DiffPersonaVersions: %v", err)
+	}
+	if len(diff.Changes) != 1 || diff.Changes[0].Field != "options" || !strings.Contains(diff.Changes[0].Diff, `+   "temperature": 0.2`) {
+		t.Fatalf("diff = %+v", diff.Changes)
+	}
+
+	rolledBack, err := s.RollbackPersona(ctx, chatbot.ID, 1)
+	if err != nil {
+		t.Fatalf("RollbackPersona: %v", err)
+	}
+	if rolledBack.Options.Temperature != nil || rolledBack.PersonaVersion != 3 {
+		t.Fatalf("rollback = version %d, temperature %v", rolledBack.PersonaVersion, rolledBack.Options.Temperature)
+	}
+
+	// 没有记录生成参数的旧版本：比较时跳过，回滚时保留当前生成参数
+	legacy, err := s.GetPersonaVersion(ctx, chatbot.ID, 2)
+	if err != nil {
+		t.Fatalf("GetPersonaVersion: %v", err)
+	}
+	legacy.Options = nil
+	if diff := model.DiffPersonaVersions(legacy, &model.PersonaVersion{Version: 3, Personality: "p", Background: "b", SystemPrompt: legacy.SystemPrompt}); len(diff.Changes) != 0 {
+		t.Fatalf("diff with legacy version = %+v", diff.Changes)
+	}
+	if legacy.SamePersona(rolledBack) {
+		t.Fatal("SamePersona with a version without options = true, want false")
+	}
+}
+
+func TestDiffPersonaVersionsLarge(t *testing.T) {
+	lines := func(prefix string, n int) string {
+		var b strings.Builder
+		for i := range n {
+			fmt.Fprintf(&b, "%s%d\n", prefix, i)
+		}
+		return b.String()
+	}
+
+	// 首尾相同的行原样保留，中间超出上限的部分整段替换
+	from := "head\n" + lines("a", 2000) + "tail\n"
+	to := "head\n" + lines("b", 2000) + "tail\n"
+	diff := model.DiffPersonaVersions(&model.PersonaVersion{Version: 1, Background: from}, &model.PersonaVersion{Version: 2, Background: to})
+	if len(diff.Changes) != 1 {
+		t.Fatalf("changes = %d, want 1", len(diff.Changes))
+	}
+	got := strings.Split(strings.TrimSuffix(diff.Changes[0].Diff, "\n"), "\n")
+	if len(got) != 4002 || got[0] != "  head" || got[1] != "- a0" || got[2001] != "+ b0" || got[4001] != "  tail" {
+		t.Fatalf("diff has %d lines: %q … %q", len(got), got[:3], got[len(got)-2:])
+	}
+
+	// 小的改动仍逐行对齐
+	diff = model.DiffPersonaVersions(&model.PersonaVersion{Personality: "a\nb\nc\n"}, &model.PersonaVersion{Personality: "a\nx\nc\n"})
+	if want := "  a\n- b\n+ x\n  c\n"; diff.Changes[0].Diff != want {
+		t.Fatalf("diff = %q, want %q", diff.Changes[0].Diff, want)
+	}
+}
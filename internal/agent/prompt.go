@@ -0,0 +1,165 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"eino/internal/model"
+
+	"github.com/cloudwego/eino/components/prompt"
+	"github.com/cloudwego/eino/schema"
+)
+
+// defaultPromptTemplate 内置的系统提示词模板（Go模板格式）
+const defaultPromptTemplate = `{{if or .Personality .Background -}}
+{{if .Personality}}性格设定：{{.Personality}}{{if .Background}}
+
+{{end}}{{end -}}
+{{if .Background}}背景设定：{{.Background}}{{end}}
+
+请严格按照以上设定进行对话，保持角色的一致性。
+{{- else -}}
+你是一个友好的AI助手。
+{{- end}}`
+
+// PromptVars 系统提示词模板变量
+//
+// Go模板中以字段名引用，如 {{.Name}}；FString模板中以小写下划线形式引用，如 {name}、{user_name}。
+type PromptVars struct {
+	Name        string
+	Personality string
+	Background  string
+	Date        string // 当前日期，YYYY-MM-DD
+	UserName    string // 对话请求中的用户名，可能为空
+	Knowledge   string // RAG检索到的知识，多段之间以空行分隔，可能为空
+}
+
+// values 转换为FString模板使用的变量表
+func (v PromptVars) values() map[string]any {
+	return map[string]any{
+		"name":        v.Name,
+		"personality": v.Personality,
+		"background":  v.Background,
+		"date":        v.Date,
+		"user_name":   v.UserName,
+		"knowledge":   v.Knowledge,
+	}
+}
+
+// newPromptVars 由聊天机器人构建模板变量
+func newPromptVars(chatbot *model.Chatbot, userName, knowledge string) PromptVars {
+	return PromptVars{
+		Name:        chatbot.Name,
+		Personality: chatbot.Personality,
+		Background:  chatbot.Background,
+		Date:        time.Now().Format(time.DateOnly),
+		UserName:    userName,
+		Knowledge:   knowledge,
+	}
+}
+
+// promptTemplate 解析后的系统提示词模板
+type promptTemplate struct {
+	goTemplate    *template.Template
+	chatTemplate  prompt.ChatTemplate
+	usesKnowledge bool // 模板引用了检索知识，由模板负责放置知识内容
+}
+
+// parsePromptTemplate 解析并校验系统提示词模板，format为空时按Go模板处理
+func parsePromptTemplate(format, text string) (*promptTemplate, error) {
+	t := &promptTemplate{}
+	switch format {
+	case "", model.PromptFormatGoTemplate:
+		tmpl, err := template.New("system_prompt").Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("%w: parse prompt template: %v", ErrInvalidPersona, err)
+		}
+		t.goTemplate = tmpl
+		t.usesKnowledge = strings.Contains(text, ".Knowledge")
+	case model.PromptFormatFString:
+		t.chatTemplate = prompt.FromMessages(schema.FString, schema.SystemMessage(text))
+		t.usesKnowledge = strings.Contains(text, "{knowledge")
+	default:
+		return nil, fmt.Errorf("%w: unsupported prompt format: %s (want %s or %s)",
+			ErrInvalidPersona, format, model.PromptFormatGoTemplate, model.PromptFormatFString)
+	}
+
+	// 用示例变量渲染一次，提前发现引用了不存在的变量等错误
+	sample := PromptVars{
+		Name:        "name",
+		Personality: "personality",
+		Background:  "background",
+		Date:        "2006-01-02",
+		UserName:    "user",
+		Knowledge:   "knowledge",
+	}
+	if _, err := t.render(context.Background(), sample); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// render 渲染系统提示词
+func (t *promptTemplate) render(ctx context.Context, vars PromptVars) (string, error) {
+	if t.goTemplate != nil {
+		var b strings.Builder
+		if err := t.goTemplate.Execute(&b, vars); err != nil {
+			return "", fmt.Errorf("%w: render prompt template: %v", ErrInvalidPersona, err)
+		}
+		return b.String(), nil
+	}
+
+	messages, err := t.chatTemplate.Format(ctx, vars.values())
+	if err != nil {
+		return "", fmt.Errorf("%w: render prompt template: %v", ErrInvalidPersona, err)
+	}
+	return messages[0].Content, nil
+}
+
+// cachedPrompt 聊天机器人解析后的模板，格式和模板文本与聊天机器人一致时有效
+type cachedPrompt struct {
+	format string
+	text   string
+	tmpl   *promptTemplate
+}
+
+// promptTemplateFor 返回聊天机器人使用的模板：自身设置的模板优先，其次是全局配置，最后是内置模板
+//
+// 自身设置的模板解析并校验后按聊天机器人ID缓存，模板或格式变化后重新解析，对话时不再重复解析和试渲染。
+func (s *ChatService) promptTemplateFor(chatbot *model.Chatbot) (*promptTemplate, error) {
+	if chatbot.PromptTemplate == "" {
+		return s.defaultPrompt, nil
+	}
+	if v, ok := s.prompts.Load(chatbot.ID); ok {
+		cached := v.(*cachedPrompt)
+		if cached.format == chatbot.PromptFormat && cached.text == chatbot.PromptTemplate {
+			return cached.tmpl, nil
+		}
+	}
+
+	tmpl, err := parsePromptTemplate(chatbot.PromptFormat, chatbot.PromptTemplate)
+	if err != nil {
+		return nil, err
+	}
+	s.prompts.Store(chatbot.ID, &cachedPrompt{format: chatbot.PromptFormat, text: chatbot.PromptTemplate, tmpl: tmpl})
+	return tmpl, nil
+}
+
+// renderSystemPrompt 校验模板并生成保存用的系统提示词
+//
+// 保存的系统提示词用于展示和版本记录，不含用户名和检索知识，日期取创建日期，
+// 保证人设不变时多次保存结果一致；对话时会按当前日期、用户名和检索结果重新渲染。
+func (s *ChatService) renderSystemPrompt(chatbot *model.Chatbot) (string, error) {
+	tmpl, err := s.promptTemplateFor(chatbot)
+	if err != nil {
+		return "", err
+	}
+
+	vars := newPromptVars(chatbot, "", "")
+	vars.Date = chatbot.CreatedAt.Format(time.DateOnly)
+	return tmpl.render(context.Background(), vars)
+}
@@ -0,0 +1,152 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"eino/internal/model"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// stubSearcher 每次检索都返回固定的结果
+type stubSearcher struct {
+	hits []*model.KnowledgeHit
+}
+
+func (s *stubSearcher) EnhanceMessages(ctx context.Context, opts model.SearchOptions, userMessage string, messages []*schema.Message) ([]*schema.Message, *model.RetrievalTrace, error) {
+	return messages, nil, nil
+}
+
+func (s *stubSearcher) SearchKnowledge(ctx context.Context, query string, opts model.SearchOptions) (*model.SearchResult, error) {
+	return &model.SearchResult{Hits: s.hits, Trace: &model.RetrievalTrace{Query: query}}, nil
+}
+
+func TestParsePromptTemplate(t *testing.T) {
+	vars := PromptVars{Name: "小海", Personality: "豪爽", Date: "2024-05-01", UserName: "阿明", Knowledge: "资料"}
+
+	tests := []struct {
+		name          string
+		format        string
+		text          string
+		want          string // 按vars渲染的结果
+		usesKnowledge bool
+		wantErr       bool
+	}{
+		{"GoDefaultFormat", "", "我是{{.Name}}，今天是{{.Date}}", "我是小海，今天是2024-05-01", false, false},
+		{"Go", model.PromptFormatGoTemplate, "{{.Personality}}的{{.Name}}{{if .UserName}}，你好{{.UserName}}{{end}}", "豪爽的小海，你好阿明", false, false},
+		{"GoKnowledge", model.PromptFormatGoTemplate, "{{.Name}}\n{{.Knowledge}}", "小海\n资料", true, false},
+		{"FString", model.PromptFormatFString, "我是{name}，你好{user_name}", "我是小海，你好阿明", false, false},
+		{"FStringKnowledge", model.PromptFormatFString, "{name}：{knowledge}", "小海：资料", true, false},
+		{"GoUnknownField", model.PromptFormatGoTemplate, "{{.Missing}}", "", false, true},
+		{"GoSyntaxError", model.PromptFormatGoTemplate, "{{.Name", "", false, true},
+		{"FStringUnknownVariable", model.PromptFormatFString, "{missing}", "", false, true},
+		{"UnknownFormat", "jinja2", "{{ name }}", "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := parsePromptTemplate(tt.format, tt.text)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidPersona) {
+					t.Fatalf("parsePromptTemplate error = %v, want ErrInvalidPersona", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsePromptTemplate: %v", err)
+			}
+			if tmpl.usesKnowledge != tt.usesKnowledge {
+				t.Fatalf("usesKnowledge = %t, want %t", tmpl.usesKnowledge, tt.usesKnowledge)
+			}
+			got, err := tmpl.render(context.Background(), vars)
+			if err != nil {
+				t.Fatalf("render: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("render = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildChatMessagesKnowledgePlacement(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestChatService(t)
+	s.SetRAGService(&stubSearcher{hits: []*model.KnowledgeHit{{ID: 1, KnowledgeBase: model.DefaultKnowledgeBase, Content: "七天内可以退货"}}})
+
+	tests := []struct {
+		name     string
+		format   string
+		template string
+		inPrompt bool // 知识由模板放置，而不是追加到系统消息末尾
+	}{
+		{"GoTemplate", model.PromptFormatGoTemplate, "你是{{.Name}}。\n资料：\n{{.Knowledge}}\n请用资料回答。", true},
+		{"FStringTemplate", model.PromptFormatFString, "你是{name}。\n资料：\n{knowledge}\n请用资料回答。", true},
+		{"WithoutKnowledge", model.PromptFormatGoTemplate, "你是{{.Name}}。", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chatbot := &model.Chatbot{ID: tt.name, Name: "客服", PromptTemplate: tt.template, PromptFormat: tt.format}
+			messages, retrieval, err := s.buildChatMessages(ctx, chatbot, nil, 0, &model.ChatRequest{Message: "怎么退货？"})
+			if err != nil {
+				t.Fatalf("buildChatMessages: %v", err)
+			}
+			if !retrieval.used() {
+				t.Fatal("retrieval not used")
+			}
+			system := messages[0].Content
+			if !strings.HasPrefix(system, "你是客服。") || strings.Count(system, "七天内可以退货") != 1 {
+				t.Fatalf("system prompt = %q, want the knowledge exactly once", system)
+			}
+			appended := strings.Contains(system, "以下是从知识库中检索到的资料")
+			if inTemplate := strings.HasSuffix(system, "请用资料回答。"); inTemplate != tt.inPrompt || appended == tt.inPrompt {
+				t.Fatalf("system prompt = %q, want knowledge placed by the template: %t", system, tt.inPrompt)
+			}
+		})
+	}
+}
+
+func TestPromptTemplateCache(t *testing.T) {
+	s, _ := newTestChatService(t)
+	chatbot := &model.Chatbot{ID: "cached", Name: "小海", PromptTemplate: "我是{{.Name}}"}
+
+	first, err := s.promptTemplateFor(chatbot)
+	if err != nil {
+		t.Fatalf("promptTemplateFor: %v", err)
+	}
+	if again, _ := s.promptTemplateFor(chatbot); again != first {
+		t.Fatal("unchanged template was parsed again")
+	}
+
+	// 模板或格式变化后重新解析
+	steps := []struct {
+		format, text, want string
+	}{
+		{model.PromptFormatGoTemplate, "{{.Name}}在此", "小海在此"},
+		{model.PromptFormatFString, "{{.Name}}在此", "{.Name}在此"},
+	}
+	prev := first
+	for _, step := range steps {
+		chatbot.PromptFormat, chatbot.PromptTemplate = step.format, step.text
+		tmpl, err := s.promptTemplateFor(chatbot)
+		if err != nil {
+			t.Fatalf("promptTemplateFor(%s %q): %v", step.format, step.text, err)
+		}
+		if tmpl == prev {
+			t.Fatalf("template %s %q served from the stale cache", step.format, step.text)
+		}
+		got, err := tmpl.render(context.Background(), newPromptVars(chatbot, "", ""))
+		if err != nil || got != step.want {
+			t.Fatalf("render = %q, %v, want %q", got, err, step.want)
+		}
+		prev = tmpl
+	}
+
+	// 清空模板后使用全局模板
+	chatbot.PromptTemplate = ""
+	if tmpl, _ := s.promptTemplateFor(chatbot); tmpl != s.defaultPrompt {
+		t.Fatal("chatbot without a template did not use the default template")
+	}
+}
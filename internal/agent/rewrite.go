@@ -0,0 +1,134 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"eino/internal/llmjson"
+	"eino/internal/model"
+
+	einomodel "github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// rewritePrompt 改写问题使用的系统提示词
+const rewritePrompt = `你负责把对话中用户的最新消息改写为可以独立理解的检索问题：根据对话记录补全代词和省略的内容，保留原消息的语言和关键词，不要回答问题。`
+
+// rewriteOutputPrompt 只改写问题时要求的输出格式
+const rewriteOutputPrompt = `只输出JSON，不要输出其他内容：{"query": "改写后的问题"}`
+
+// rewriteVariantsPrompt 同时生成其他问法时要求的输出格式
+const rewriteVariantsPrompt = `另外给出%d种用词不同的问法，用于扩大检索范围。只输出JSON，不要输出其他内容：{"query": "改写后的问题", "variants": ["其他问法"]}`
+
+// maxRewriteTurnRunes 改写时每条历史消息保留的字符数
+const maxRewriteTurnRunes = 500
+
+// rewriteQuery 按rag.query_rewrite结合最近的对话把用户消息改写为独立问题，返回检索使用的问题、其他问法和改写过程
+//
+// 参考的对话为以parentID为终点的路径上最近history轮，与作为上下文的对话条数（agent.max_history）无关。
+// 未启用时返回原消息和nil。没有对话记录且不生成其他问法时无需改写，不调用模型。
+// 改写失败时使用原消息，只记录日志，不影响对话。
+func (s *ChatService) rewriteQuery(ctx context.Context, history []*model.Conversation, parentID int64, message string) (string, []string, *model.RewriteTrace) {
+	cfg := s.config.RAG.QueryRewrite
+	if !cfg.Enabled {
+		return message, nil, nil
+	}
+	history = s.rewriteHistory(ctx, history, parentID, max(cfg.History, 0))
+	if len(history) == 0 && cfg.Variants <= 0 {
+		return message, nil, nil
+	}
+
+	trace := &model.RewriteTrace{Original: message, Query: message, History: len(history)}
+	start := time.Now()
+	query, variants, err := s.generateRewrite(ctx, history, message, cfg.Variants)
+	trace.LatencyMS = time.Since(start).Milliseconds()
+	if err != nil {
+		log.Printf("Warning: query rewrite failed, searching with the original message: %v", err)
+		trace.Error = err.Error()
+		return message, nil, trace
+	}
+
+	trace.Query, trace.Variants = query, variants
+	return query, variants, trace
+}
+
+// rewriteHistory 返回以parentID为终点的路径上最近n轮对话
+//
+// history是作为上下文的对话，也在这条路径的末尾：轮数足够，或少于agent.max_history（已是完整的路径）时直接截取，
+// 否则按parentID从存储中读取。读取失败时沿用history，只记录日志。
+func (s *ChatService) rewriteHistory(ctx context.Context, history []*model.Conversation, parentID int64, n int) []*model.Conversation {
+	if len(history) >= n || len(history) < s.config.Agent.MaxHistory || parentID == 0 {
+		return history[max(len(history)-n, 0):]
+	}
+	path, err := s.storage.GetConversationPath(ctx, parentID, n)
+	if err != nil {
+		log.Printf("Warning: failed to load conversation %d for query rewrite: %v", parentID, err)
+		return history
+	}
+	return path
+}
+
+// generateRewrite 调用模型改写问题并生成最多n种其他问法
+func (s *ChatService) generateRewrite(ctx context.Context, history []*model.Conversation, message string, n int) (string, []string, error) {
+	var b strings.Builder
+	if len(history) > 0 {
+		b.WriteString("对话记录：\n")
+		for _, conv := range history {
+			fmt.Fprintf(&b, "用户：%s\n助手：%s\n", truncateRunes(conv.UserMessage), truncateRunes(conv.BotMessage))
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "最新消息：\n%s", message)
+
+	systemPrompt := rewritePrompt + "\n" + rewriteOutputPrompt
+	if n > 0 {
+		systemPrompt = rewritePrompt + "\n" + fmt.Sprintf(rewriteVariantsPrompt, n)
+	}
+	response, err := s.generateWith(ctx, s.rewriter, []*schema.Message{
+		schema.SystemMessage(systemPrompt),
+		schema.UserMessage(b.String()),
+	}, einomodel.WithTemperature(0))
+	if err != nil {
+		return "", nil, err
+	}
+	return parseRewrite(response.Content, n)
+}
+
+// parseRewrite 解析模型返回的改写结果，保留最多n种其他问法，去掉空白、重复和与问题相同的
+func parseRewrite(content string, n int) (string, []string, error) {
+	var result struct {
+		Query    string   `json:"query"`
+		Variants []string `json:"variants"`
+	}
+	if err := llmjson.Unmarshal(content, &result); err != nil {
+		return "", nil, fmt.Errorf("parse rewrite result %q: %w", content, err)
+	}
+	query := strings.TrimSpace(result.Query)
+	if query == "" {
+		return "", nil, fmt.Errorf("rewrite result %q has no query", content)
+	}
+
+	var variants []string
+	seen := map[string]bool{strings.ToLower(query): true}
+	for _, variant := range result.Variants {
+		variant = strings.TrimSpace(variant)
+		key := strings.ToLower(variant)
+		if variant == "" || seen[key] || len(variants) >= n {
+			continue
+		}
+		seen[key] = true
+		variants = append(variants, variant)
+	}
+	return query, variants, nil
+}
+
+// truncateRunes 截取文本开头的maxRewriteTurnRunes个字符
+func truncateRunes(text string) string {
+	if runes := []rune(text); len(runes) > maxRewriteTurnRunes {
+		return string(runes[:maxRewriteTurnRunes]) + "…"
+	}
+	return text
+}
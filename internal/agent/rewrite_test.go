@@ -0,0 +1,100 @@
+package agent
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"eino/internal/config"
+	"eino/internal/model"
+	"eino/internal/storage/memory"
+)
+
+func TestParseRewrite(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		n        int
+		query    string
+		variants []string
+		wantErr  bool
+	}{
+		{"Query", `{"query": "X200支持WPA3吗"}`, 0, "X200支持WPA3吗", nil, false},
+		{"CodeFence", "```json\n{\"query\": \" 退货政策 \"}\n```", 0, "退货政策", nil, false},
+		{"Variants", `{"query": "退货政策", "variants": ["怎么退货", "退货期限"]}`, 2, "退货政策", []string{"怎么退货", "退货期限"}, false},
+		{"VariantsLimited", `{"query": "q", "variants": ["a", "b", "c"]}`, 2, "q", []string{"a", "b"}, false},
+		{"VariantsDisabled", `{"query": "q", "variants": ["a"]}`, 0, "q", nil, false},
+		{"VariantsCleaned", `{"query": "Refund", "variants": [" ", "refund", "Return policy", "return policy ", "x"]}`, 3, "Refund", []string{"Return policy", "x"}, false},
+		{"NoQuery", `{"query": "  ", "variants": ["a"]}`, 1, "", nil, true},
+		{"NotJSON", "改写后的问题是：退货政策", 0, "", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, variants, err := parseRewrite(tt.content, tt.n)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseRewrite(%q) = %q, %q, want error", tt.content, query, variants)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseRewrite(%q): %v", tt.content, err)
+			}
+			if query != tt.query || !slices.Equal(variants, tt.variants) {
+				t.Fatalf("parseRewrite(%q) = %q, %q, want %q, %q", tt.content, query, variants, tt.query, tt.variants)
+			}
+		})
+	}
+}
+
+func TestRewriteHistory(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewMemoryStorage()
+	var path []*model.Conversation
+	var parentID int64
+	for _, message := range []string{"1", "2", "3", "4", "5"} {
+		conv := &model.Conversation{ChatbotID: "bot", ParentID: parentID, UserMessage: message}
+		if err := store.SaveConversation(ctx, conv); err != nil {
+			t.Fatalf("SaveConversation: %v", err)
+		}
+		path = append(path, conv)
+		parentID = conv.ID
+	}
+
+	tests := []struct {
+		name       string
+		maxHistory int
+		window     int   // 作为上下文的对话轮数，取路径末尾
+		parentID   int64 // 为0时使用路径最后一轮
+		n          int
+		want       []string
+	}{
+		{"WindowLongEnough", 20, 5, 0, 3, []string{"3", "4", "5"}},
+		{"NoContextHistory", 0, 0, 0, 3, []string{"3", "4", "5"}},
+		{"TruncatedWindow", 2, 2, 0, 4, []string{"2", "3", "4", "5"}},
+		{"CompleteWindow", 20, 5, 0, 10, []string{"1", "2", "3", "4", "5"}},
+		{"EarlierParent", 0, 0, path[2].ID, 2, []string{"2", "3"}},
+		{"FirstTurn", 0, 0, -1, 3, nil},
+		{"Disabled", 20, 5, 0, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &ChatService{config: &config.Config{Agent: config.AgentConfig{MaxHistory: tt.maxHistory}}, storage: store}
+			parent := tt.parentID
+			switch parent {
+			case 0:
+				parent = parentID
+			case -1:
+				parent = 0
+			}
+
+			var got []string
+			for _, conv := range s.rewriteHistory(ctx, path[len(path)-tt.window:], parent, tt.n) {
+				got = append(got, conv.UserMessage)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("rewriteHistory = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -26,7 +26,7 @@ type ServerConfig struct {
 
 // ModelConfig 模型配置
 type ModelConfig struct {
-	Provider string `yaml:"provider"` // ollama, openai, anthropic
+	Provider string `yaml:"provider"` // ollama, fake（不调用模型服务，用于离线评测和调试）
 	BaseURL  string `yaml:"base_url"`
 	APIKey   string `yaml:"api_key"`
 	Model    string `yaml:"model"`
@@ -41,14 +41,20 @@ type AgentConfig struct {
 	Temperature  float64 `yaml:"temperature"`
 	MaxHistory   int     `yaml:"max_history"` // 最大对话历史条数
 	EnableStream bool    `yaml:"enable_stream"`
+	PersonaDir   string  `yaml:"persona_dir"` // 启动时导入的人设包目录，为空则不导入
+	// PromptTemplate 全局系统提示词模板，为空时使用内置模板；聊天机器人自身的模板优先
+	PromptTemplate string `yaml:"prompt_template"`
+	PromptFormat   string `yaml:"prompt_format"` // go（默认）, fstring
 }
 
 // StorageConfig 存储配置
 type StorageConfig struct {
-	Type   string `yaml:"type"` // memory, mysql, redis
-	MySQL  MySQLConfig
-	Redis  RedisConfig
-	Milvus MilvusConfig
+	Type        string `yaml:"type"`         // memory, mysql, redis, sqlite
+	AutoMigrate bool   `yaml:"auto_migrate"` // 启动时自动执行数据库迁移（mysql, sqlite）
+	MySQL       MySQLConfig
+	Redis       RedisConfig
+	SQLite      SQLiteConfig
+	Milvus      MilvusConfig
 }
 
 // MySQLConfig MySQL配置
@@ -68,6 +74,11 @@ type RedisConfig struct {
 	DB       int    `yaml:"db"`
 }
 
+// SQLiteConfig SQLite配置
+type SQLiteConfig struct {
+	Path string `yaml:"path"` // 数据库文件路径
+}
+
 // MilvusConfig Milvus配置
 type MilvusConfig struct {
 	Host string `yaml:"host"`
@@ -76,9 +87,94 @@ type MilvusConfig struct {
 
 // RAGConfig RAG配置
 type RAGConfig struct {
-	Enabled        bool   `yaml:"enabled"`
-	OllamaURL      string `yaml:"ollama_url"`
-	EmbeddingModel string `yaml:"embedding_model"`
+	Enabled bool `yaml:"enabled"`
+	// EmbeddingProvider 嵌入模型：ollama（默认）, fake（按词哈希生成向量，不调用模型服务）
+	EmbeddingProvider string `yaml:"embedding_provider"`
+	OllamaURL         string `yaml:"ollama_url"`
+	EmbeddingModel    string `yaml:"embedding_model"`
+	EmbedBatchSize    int    `yaml:"embed_batch_size"` // 每次请求嵌入的分块数
+	// EmbedConcurrency 同时发出的嵌入请求数上限，导入、重建索引和检索共享
+	EmbedConcurrency int `yaml:"embed_concurrency"`
+	EmbedTimeout     int `yaml:"embed_timeout"` // 每次嵌入请求的超时（秒）
+	EmbedRetries     int `yaml:"embed_retries"` // 嵌入请求失败后的重试次数，小于0时不重试
+	// EmbedCache 嵌入向量缓存配置
+	EmbedCache  EmbedCacheConfig `yaml:"embed_cache"`
+	VectorStore string           `yaml:"vector_store"` // milvus（默认）, memory
+	// ReindexConcurrency 重建索引时同时发出的嵌入请求数
+	ReindexConcurrency int `yaml:"reindex_concurrency"`
+	// AutoReindex 集合的嵌入模型与配置不一致时继续用原模型提供检索并在后台重建索引；默认关闭，拒绝启动
+	AutoReindex bool `yaml:"auto_reindex"`
+	// Memory 内存向量存储配置，vector_store为memory时使用
+	Memory MemoryVectorConfig `yaml:"memory"`
+	// Chunking 文档分块配置，导入文档时可以按次覆盖
+	Chunking ChunkingConfig `yaml:"chunking"`
+	// Retrieval 知识检索配置
+	Retrieval RetrievalConfig `yaml:"retrieval"`
+	// Rerank 重排序配置，未设置provider时不重排序
+	Rerank RerankConfig `yaml:"rerank"`
+	// QueryRewrite 对话时检索前改写问题的配置
+	QueryRewrite QueryRewriteConfig `yaml:"query_rewrite"`
+}
+
+// QueryRewriteConfig 检索前改写问题：结合最近的对话把用户消息改写为独立问题，可以同时生成多种问法一并检索
+type QueryRewriteConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	History  int    `yaml:"history"`  // 改写时参考的当前分支上最近的对话轮数，默认3，与agent.max_history无关
+	Variants int    `yaml:"variants"` // 多查询：额外生成的问法数，为0（默认）时只检索改写后的问题
+	Model    string `yaml:"model"`    // 改写使用的对话模型，为空时使用model.model
+}
+
+// RerankConfig 重排序配置：多取回一些候选，重新打分后保留得分最高的结果
+type RerankConfig struct {
+	Provider string `yaml:"provider"` // 为空时不重排序；api: 重排序接口（交叉编码器）；llm: 对话模型评审
+	// URL api为重排序接口的完整地址，如 http://localhost:8081/v1/rerank；llm为Ollama地址，默认rag.ollama_url
+	URL        string   `yaml:"url"`
+	APIKey     string   `yaml:"api_key"`
+	Model      string   `yaml:"model"`      // 重排序模型或评审使用的对话模型
+	Candidates int      `yaml:"candidates"` // 重排序的候选数，默认20
+	Threshold  *float64 `yaml:"threshold"`  // 得分低于阈值的结果丢弃，不设置时不过滤
+	Timeout    int      `yaml:"timeout"`    // 重排序请求的超时（秒），默认10
+}
+
+// RetrievalConfig 知识检索配置
+type RetrievalConfig struct {
+	Mode          string  `yaml:"mode"`           // hybrid（默认）, vector, keyword；聊天机器人和检索接口可以覆盖
+	VectorWeight  float64 `yaml:"vector_weight"`  // RRF融合时向量检索结果的权重，默认1
+	KeywordWeight float64 `yaml:"keyword_weight"` // RRF融合时关键词检索结果的权重，默认1
+	RRFK          int     `yaml:"rrf_k"`          // RRF的平滑常数，越大排名靠后的结果占比越高，默认60
+	// MaxDistance 向量检索结果与查询的最大距离（相似度阈值），超过的结果丢弃，不设置时不过滤；
+	// 距离的含义取决于度量：cosine为1减余弦相似度，l2为欧氏距离的平方
+	MaxDistance *float64 `yaml:"max_distance"`
+	// MinKeywordScore 关键词检索结果的最低BM25得分。设置了max_distance或该项时，融合后的结果
+	// 向量距离不超过max_distance或关键词得分不低于该项才保留，否则丢弃；都不设置时不过滤
+	MinKeywordScore *float64 `yaml:"min_keyword_score"`
+	// RAGMode 对话时的检索时机：off, auto, always（默认）；聊天机器人可以覆盖
+	RAGMode string `yaml:"rag_mode"`
+	// Classifier auto时判断是否需要检索的方式：rules（默认）, model
+	Classifier string `yaml:"classifier"`
+	// ClassifierModel classifier为model时使用的对话模型，可以用较小的模型；为空时使用model.model
+	ClassifierModel string `yaml:"classifier_model"`
+}
+
+// EmbedCacheConfig 嵌入向量缓存配置，按嵌入模型和内容的哈希缓存
+type EmbedCacheConfig struct {
+	Size  int  `yaml:"size"`  // 进程内LRU缓存的向量条数，小于0时不使用
+	Redis bool `yaml:"redis"` // 同时缓存到storage.redis，多个实例共享
+	TTL   int  `yaml:"ttl"`   // Redis缓存的过期时间（秒），为0时不过期
+}
+
+// ChunkingConfig 文档分块配置
+type ChunkingConfig struct {
+	Mode         string `yaml:"mode"`          // tokens（默认）, headings
+	ChunkSize    int    `yaml:"chunk_size"`    // 每个分块的最大token数
+	ChunkOverlap int    `yaml:"chunk_overlap"` // 相邻分块重叠的token数
+}
+
+// MemoryVectorConfig 内存向量存储配置
+type MemoryVectorConfig struct {
+	Metric           string `yaml:"metric"`            // l2（默认）, cosine
+	SnapshotPath     string `yaml:"snapshot_path"`     // 快照文件路径，为空则不持久化
+	SnapshotInterval int    `yaml:"snapshot_interval"` // 快照间隔（秒），为0时只在关闭时写入
 }
 
 // Load 加载配置文件
@@ -150,9 +246,67 @@ func Load(path string) (*Config, error) {
 	if cfg.Agent.MaxHistory == 0 {
 		cfg.Agent.MaxHistory = 20
 	}
+	if cfg.Storage.SQLite.Path == "" {
+		cfg.Storage.SQLite.Path = "data/eino.db"
+	}
 	if cfg.Model.Timeout == 0 {
 		cfg.Model.Timeout = 60
 	}
+	if cfg.RAG.EmbedBatchSize == 0 {
+		cfg.RAG.EmbedBatchSize = 16
+	}
+	if cfg.RAG.EmbedConcurrency == 0 {
+		cfg.RAG.EmbedConcurrency = 4
+	}
+	if cfg.RAG.EmbedTimeout == 0 {
+		cfg.RAG.EmbedTimeout = 30
+	}
+	if cfg.RAG.EmbedRetries == 0 {
+		cfg.RAG.EmbedRetries = 2
+	}
+	if cfg.RAG.EmbedCache.Size == 0 {
+		cfg.RAG.EmbedCache.Size = 10000
+	}
+	if cfg.RAG.ReindexConcurrency == 0 {
+		cfg.RAG.ReindexConcurrency = 2
+	}
+	if cfg.RAG.EmbeddingModel == "" {
+		cfg.RAG.EmbeddingModel = "nomic-embed-text"
+	}
+	if cfg.RAG.VectorStore == "" {
+		cfg.RAG.VectorStore = "milvus"
+	}
+	if cfg.RAG.Retrieval.Mode == "" {
+		cfg.RAG.Retrieval.Mode = "hybrid"
+	}
+	if cfg.RAG.Retrieval.VectorWeight == 0 {
+		cfg.RAG.Retrieval.VectorWeight = 1
+	}
+	if cfg.RAG.Retrieval.KeywordWeight == 0 {
+		cfg.RAG.Retrieval.KeywordWeight = 1
+	}
+	if cfg.RAG.Retrieval.RRFK == 0 {
+		cfg.RAG.Retrieval.RRFK = 60
+	}
+	if cfg.RAG.Retrieval.RAGMode == "" {
+		cfg.RAG.Retrieval.RAGMode = "always"
+	}
+	if cfg.RAG.Retrieval.Classifier == "" {
+		cfg.RAG.Retrieval.Classifier = "rules"
+	}
+	if cfg.RAG.QueryRewrite.History == 0 {
+		cfg.RAG.QueryRewrite.History = 3
+	}
+	if cfg.RAG.Rerank.Candidates == 0 {
+		cfg.RAG.Rerank.Candidates = 20
+	}
+	if cfg.RAG.Rerank.Timeout == 0 {
+		cfg.RAG.Rerank.Timeout = 10
+	}
+	if cfg.RAG.Chunking.ChunkSize == 0 {
+		cfg.RAG.Chunking.ChunkSize = 512
+		cfg.RAG.Chunking.ChunkOverlap = 64
+	}
 
 	return &cfg, nil
 }
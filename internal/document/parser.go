@@ -0,0 +1,216 @@
+package document
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/cloudwego/eino/components/document/parser"
+	"github.com/cloudwego/eino/schema"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// TextParser 纯文本解析器
+type TextParser struct{}
+
+// Parse 读取全部内容作为一个文档
+func (p *TextParser) Parse(ctx context.Context, reader io.Reader, opts ...parser.Option) ([]*schema.Document, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("read text: %w", err)
+	}
+	return newDocument(string(data), FormatText, "", opts), nil
+}
+
+// MarkdownParser Markdown解析器，保留原文以便按标题切分，第一个一级标题作为文档标题
+type MarkdownParser struct{}
+
+// Parse 读取Markdown文档
+func (p *MarkdownParser) Parse(ctx context.Context, reader io.Reader, opts ...parser.Option) ([]*schema.Document, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("read markdown: %w", err)
+	}
+
+	content := string(data)
+	var title string
+	for _, h := range markdownHeadings(content) {
+		if h.level == 1 {
+			title = h.text
+			break
+		}
+	}
+	return newDocument(content, FormatMarkdown, title, opts), nil
+}
+
+// HTMLParser HTML解析器，提取正文文本，标题标签转换为Markdown标题行以便按标题切分
+type HTMLParser struct{}
+
+// Parse 解析HTML文档，忽略script、style等不可见内容，<title>作为文档标题
+func (p *HTMLParser) Parse(ctx context.Context, reader io.Reader, opts ...parser.Option) ([]*schema.Document, error) {
+	root, err := html.Parse(reader)
+	if err != nil {
+		return nil, fmt.Errorf("parse html: %w", err)
+	}
+
+	var b htmlText
+	var title string
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.DataAtom {
+			case atom.Script, atom.Style, atom.Noscript, atom.Template, atom.Head:
+				if n.DataAtom == atom.Head {
+					title = findTitle(n)
+				}
+				return
+			case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+				level := int(n.Data[1] - '0')
+				b.block()
+				b.WriteString(strings.Repeat("#", level) + " " + collapseSpace(nodeText(n)))
+				b.block()
+				return
+			case atom.Br:
+				b.newline()
+				return
+			}
+		}
+		if n.Type == html.TextNode {
+			b.text(n.Data)
+			return
+		}
+
+		isBlock := n.Type == html.ElementNode && blockElements[n.DataAtom]
+		if isBlock {
+			b.block()
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		if isBlock {
+			b.block()
+		}
+	}
+	walk(root)
+
+	return newDocument(strings.TrimSpace(b.String()), FormatHTML, title, opts), nil
+}
+
+// blockElements 前后需要换行的块级元素
+var blockElements = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Section: true, atom.Article: true, atom.Main: true,
+	atom.Header: true, atom.Footer: true, atom.Nav: true, atom.Aside: true,
+	atom.Ul: true, atom.Ol: true, atom.Li: true, atom.Dl: true, atom.Dt: true, atom.Dd: true,
+	atom.Table: true, atom.Tr: true, atom.Pre: true, atom.Blockquote: true, atom.Hr: true,
+	atom.Figure: true, atom.Figcaption: true,
+}
+
+// htmlText 拼接HTML正文：文本内的连续空白折叠为一个空格，块级元素之间空一行
+type htmlText struct {
+	strings.Builder
+	pendingSpace bool
+}
+
+// text 追加文本节点
+func (b *htmlText) text(s string) {
+	if strings.TrimSpace(s) == "" {
+		if s != "" {
+			b.pendingSpace = true
+		}
+		return
+	}
+	if startsWithSpace(s) {
+		b.pendingSpace = true
+	}
+	if b.pendingSpace && b.Len() > 0 && !b.atLineStart() {
+		b.WriteByte(' ')
+	}
+	b.WriteString(collapseSpace(s))
+	b.pendingSpace = endsWithSpace(s)
+}
+
+// newline 换行
+func (b *htmlText) newline() {
+	b.WriteByte('\n')
+	b.pendingSpace = false
+}
+
+// block 结束当前块，保证与下一个块之间有一个空行
+func (b *htmlText) block() {
+	b.pendingSpace = false
+	if b.Len() == 0 {
+		return
+	}
+	s := b.String()
+	switch {
+	case strings.HasSuffix(s, "\n\n"):
+	case strings.HasSuffix(s, "\n"):
+		b.WriteByte('\n')
+	default:
+		b.WriteString("\n\n")
+	}
+}
+
+// atLineStart 判断是否位于行首
+func (b *htmlText) atLineStart() bool {
+	s := b.String()
+	return s == "" || s[len(s)-1] == '\n'
+}
+
+// findTitle 查找<title>的文本
+func findTitle(n *html.Node) string {
+	if n.Type == html.ElementNode && n.DataAtom == atom.Title {
+		return collapseSpace(nodeText(n))
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if title := findTitle(c); title != "" {
+			return title
+		}
+	}
+	return ""
+}
+
+// nodeText 返回节点下全部文本
+func nodeText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(nodeText(c))
+	}
+	return b.String()
+}
+
+// collapseSpace 去掉首尾空白，并将连续空白折叠为一个空格
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func startsWithSpace(s string) bool {
+	return s != "" && strings.TrimLeft(s, " \t\r\n\f") != s
+}
+
+func endsWithSpace(s string) bool {
+	return s != "" && strings.TrimRight(s, " \t\r\n\f") != s
+}
+
+// newDocument 创建文档并合并解析选项中的元数据
+func newDocument(content, format, title string, opts []parser.Option) []*schema.Document {
+	options := parser.GetCommonOptions(&parser.Options{}, opts...)
+
+	meta := map[string]any{MetaFormat: format}
+	if title != "" {
+		meta[MetaTitle] = title
+	}
+	if options.URI != "" {
+		meta[MetaSource] = options.URI
+	}
+	for k, v := range options.ExtraMeta {
+		meta[k] = v
+	}
+
+	return []*schema.Document{{Content: content, MetaData: meta}}
+}
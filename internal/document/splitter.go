@@ -0,0 +1,325 @@
+package document
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/cloudwego/eino/components/document"
+	"github.com/cloudwego/eino/schema"
+)
+
+// 分块方式
+const (
+	SplitByTokens   = "tokens"   // 按token数切分，相邻分块重叠
+	SplitByHeadings = "headings" // 先按Markdown标题切分章节，过长的章节再按token数切分
+)
+
+// 分块大小默认值和上限（token）
+const (
+	DefaultChunkSize    = 512
+	DefaultChunkOverlap = 64
+	MaxChunkSize        = 2048
+)
+
+// maxWordRunes 连续字母数字超过该长度时按多个token计，避免超长串（如base64）被算作一个token
+const maxWordRunes = 8
+
+// SplitterConfig 分块配置
+type SplitterConfig struct {
+	Mode         string // tokens（默认）, headings
+	ChunkSize    int    // 每个分块的最大token数
+	ChunkOverlap int    // 相邻分块重叠的token数，按标题切分时只在同一章节内重叠
+	// MaxBytes 分块内容的最大字节数，0表示不限制；超过时缩小分块，用于适配向量库的字段长度
+	MaxBytes int
+}
+
+// Validate 校验配置并填充默认值
+func (c *SplitterConfig) Validate() error {
+	switch c.Mode {
+	case "":
+		c.Mode = SplitByTokens
+	case SplitByTokens, SplitByHeadings:
+	default:
+		return fmt.Errorf("unsupported chunk mode: %s", c.Mode)
+	}
+	if c.ChunkSize == 0 {
+		c.ChunkSize = DefaultChunkSize
+	}
+	if c.ChunkSize < 0 || c.ChunkSize > MaxChunkSize {
+		return fmt.Errorf("chunk_size must be between 1 and %d", MaxChunkSize)
+	}
+	if c.ChunkOverlap < 0 || c.ChunkOverlap >= c.ChunkSize {
+		return fmt.Errorf("chunk_overlap must be between 0 and chunk_size-1")
+	}
+	return nil
+}
+
+// Splitter 文档分块器，实现document.Transformer
+//
+// 每个分块保留原文档的元数据，并记录分块序号、字节偏移、所在章节和token数。
+// 原文档有ID时分块ID为 "<文档ID>#<序号>"。
+type Splitter struct {
+	config SplitterConfig
+}
+
+// NewSplitter 创建分块器
+func NewSplitter(config SplitterConfig) (*Splitter, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &Splitter{config: config}, nil
+}
+
+// WithSplitterConfig 单次调用时替换分块配置
+func WithSplitterConfig(config SplitterConfig) document.TransformerOption {
+	return document.WrapTransformerImplSpecificOptFn(func(c *SplitterConfig) {
+		*c = config
+	})
+}
+
+// Transform 切分文档
+func (s *Splitter) Transform(ctx context.Context, docs []*schema.Document, opts ...document.TransformerOption) ([]*schema.Document, error) {
+	config := *document.GetTransformerImplSpecificOptions(&SplitterConfig{
+		Mode:         s.config.Mode,
+		ChunkSize:    s.config.ChunkSize,
+		ChunkOverlap: s.config.ChunkOverlap,
+		MaxBytes:     s.config.MaxBytes,
+	}, opts...)
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	var chunks []*schema.Document
+	for _, doc := range docs {
+		var sections []section
+		if config.Mode == SplitByHeadings {
+			sections = splitSections(doc.Content)
+		} else {
+			sections = []section{{start: 0, end: len(doc.Content)}}
+		}
+
+		index := 0
+		for _, sec := range sections {
+			for _, span := range splitTokens(doc.Content, sec.start, sec.end, config) {
+				chunks = append(chunks, newChunk(doc, index, span, sec.heading))
+				index++
+			}
+		}
+	}
+
+	return chunks, nil
+}
+
+// chunkSpan 分块在文档内容中的位置
+type chunkSpan struct {
+	start, end int
+	tokens     int
+}
+
+// newChunk 创建分块文档
+func newChunk(doc *schema.Document, index int, span chunkSpan, heading string) *schema.Document {
+	meta := make(map[string]any, len(doc.MetaData)+5)
+	for k, v := range doc.MetaData {
+		meta[k] = v
+	}
+	meta[MetaChunkIndex] = index
+	meta[MetaChunkStart] = span.start
+	meta[MetaChunkEnd] = span.end
+	meta[MetaTokens] = span.tokens
+	if heading != "" {
+		meta[MetaHeading] = heading
+	}
+
+	chunk := &schema.Document{
+		Content:  doc.Content[span.start:span.end],
+		MetaData: meta,
+	}
+	if doc.ID != "" {
+		chunk.ID = fmt.Sprintf("%s#%d", doc.ID, index)
+	}
+	return chunk
+}
+
+// splitTokens 将text[start:end]切分为不超过ChunkSize个token的分块，相邻分块重叠ChunkOverlap个token
+//
+// 分块尽量在段落或句子边界结束；只包含空白的区间不产生分块。
+func splitTokens(text string, start, end int, config SplitterConfig) []chunkSpan {
+	tokens := tokenize(text[start:end], start)
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	var spans []chunkSpan
+	for i := 0; i < len(tokens); {
+		j := min(i+config.ChunkSize, len(tokens))
+		if j < len(tokens) {
+			j = breakPoint(text, tokens, i, j)
+		}
+		for config.MaxBytes > 0 && j > i+1 && tokens[j-1].end-tokens[i].start > config.MaxBytes {
+			j--
+		}
+
+		spans = append(spans, chunkSpan{start: tokens[i].start, end: tokens[j-1].end, tokens: j - i})
+		if j == len(tokens) {
+			break
+		}
+		i = max(j-config.ChunkOverlap, i+1)
+	}
+	return spans
+}
+
+// breakPoint 在分块后半段中从后往前寻找段落或句子边界，找不到时在j处切分
+func breakPoint(text string, tokens []token, i, j int) int {
+	lowest := i + (j-i)/2
+	// 优先在段落边界（空行）切分
+	for k := j - 1; k > lowest; k-- {
+		if strings.Contains(text[tokens[k-1].end:tokens[k].start], "\n\n") {
+			return k
+		}
+	}
+	for k := j - 1; k > lowest; k-- {
+		gap := text[tokens[k-1].end:tokens[k].start]
+		if strings.Contains(gap, "\n") || isSentenceEnd(text[tokens[k-1].start:tokens[k-1].end]) {
+			return k
+		}
+	}
+	return j
+}
+
+// isSentenceEnd 判断token是否为句末标点
+func isSentenceEnd(tok string) bool {
+	switch tok {
+	case ".", "!", "?", ";", "。", "！", "？", "；", "…":
+		return true
+	}
+	return false
+}
+
+// token 估算的token在文本中的字节区间
+type token struct {
+	start, end int
+}
+
+// tokenize 粗略估算token：每个汉字、假名、谚文或标点记为一个token，
+// 连续字母数字每maxWordRunes个字符记为一个token，空白不计。offset加到返回的区间上。
+func tokenize(text string, offset int) []token {
+	var tokens []token
+	wordStart, wordRunes := -1, 0
+	flush := func(end int) {
+		if wordStart >= 0 {
+			tokens = append(tokens, token{start: offset + wordStart, end: offset + end})
+			wordStart, wordRunes = -1, 0
+		}
+	}
+
+	for i, r := range text {
+		switch {
+		case unicode.IsSpace(r):
+			flush(i)
+		case isCJK(r) || !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'):
+			flush(i)
+			tokens = append(tokens, token{start: offset + i, end: offset + i + utf8.RuneLen(r)})
+		default:
+			if wordRunes == maxWordRunes {
+				flush(i)
+			}
+			if wordStart < 0 {
+				wordStart = i
+			}
+			wordRunes++
+		}
+	}
+	flush(len(text))
+
+	return tokens
+}
+
+// isCJK 判断是否为中日韩文字
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// section 按标题切分出的章节
+type section struct {
+	start, end int
+	heading    string // 标题路径
+}
+
+// heading Markdown标题行
+type heading struct {
+	level      int
+	text       string
+	start, end int // 标题行的字节区间
+}
+
+// splitSections 按Markdown标题切分章节，每个章节从标题行开始；第一个标题之前的内容为一个无标题章节
+func splitSections(text string) []section {
+	headings := markdownHeadings(text)
+
+	var sections []section
+	if len(headings) == 0 || headings[0].start > 0 {
+		end := len(text)
+		if len(headings) > 0 {
+			end = headings[0].start
+		}
+		sections = append(sections, section{start: 0, end: end})
+	}
+
+	var path []heading // 当前标题路径，按级别递增
+	for i, h := range headings {
+		for len(path) > 0 && path[len(path)-1].level >= h.level {
+			path = path[:len(path)-1]
+		}
+		path = append(path, h)
+
+		names := make([]string, len(path))
+		for k, p := range path {
+			names[k] = p.text
+		}
+
+		end := len(text)
+		if i+1 < len(headings) {
+			end = headings[i+1].start
+		}
+		sections = append(sections, section{start: h.start, end: end, heading: strings.Join(names, " > ")})
+	}
+	return sections
+}
+
+// markdownHeadings 查找ATX风格的Markdown标题行（# 标题），忽略代码块中的内容
+func markdownHeadings(text string) []heading {
+	var headings []heading
+	inFence := false
+	for start := 0; start < len(text); {
+		end := strings.IndexByte(text[start:], '\n')
+		if end < 0 {
+			end = len(text)
+		} else {
+			end += start
+		}
+		line := strings.TrimRight(text[start:end], "\r")
+
+		trimmed := strings.TrimLeft(line, " ")
+		switch {
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			inFence = !inFence
+		case !inFence && len(line)-len(trimmed) < 4:
+			level := 0
+			for level < len(trimmed) && trimmed[level] == '#' {
+				level++
+			}
+			if level >= 1 && level <= 6 && (level == len(trimmed) || trimmed[level] == ' ' || trimmed[level] == '\t') {
+				text := strings.TrimSpace(strings.TrimRight(strings.TrimSpace(trimmed[level:]), "#"))
+				if text != "" {
+					headings = append(headings, heading{level: level, text: text, start: start, end: end})
+				}
+			}
+		}
+
+		start = end + 1
+	}
+	return headings
+}
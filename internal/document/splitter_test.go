@@ -0,0 +1,127 @@
+package document
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+func TestSplitTokens(t *testing.T) {
+	tests := []struct {
+		name       string
+		text       string
+		start, end int // end为0时取整个文本
+		config     SplitterConfig
+		want       []string
+	}{
+		{"Single", "a b c", 0, 0, SplitterConfig{ChunkSize: 8}, []string{"a b c"}},
+		{"Split", "a b c d e f", 0, 0, SplitterConfig{ChunkSize: 2}, []string{"a b", "c d", "e f"}},
+		{"Overlap", "a b c d e", 0, 0, SplitterConfig{ChunkSize: 3, ChunkOverlap: 1}, []string{"a b c", "c d e"}},
+		{"ParagraphBreak", "a b c\n\nd e f g", 0, 0, SplitterConfig{ChunkSize: 5}, []string{"a b c", "d e f g"}},
+		{"SentenceBreakCJK", "甲乙。丙丁戊己", 0, 0, SplitterConfig{ChunkSize: 5}, []string{"甲乙。", "丙丁戊己"}},
+		{"CJKPerRune", "你好世界", 0, 0, SplitterConfig{ChunkSize: 3, ChunkOverlap: 1}, []string{"你好世", "世界"}},
+		{"LongWord", "abcdefghijklmnopqrst", 0, 0, SplitterConfig{ChunkSize: 2}, []string{"abcdefghijklmnop", "qrst"}},
+		{"MaxBytesShrinks", "aaaa bbbb cccc", 0, 0, SplitterConfig{ChunkSize: 10, MaxBytes: 9}, []string{"aaaa bbbb", "cccc"}},
+		// 单个token超过上限时仍然单独成块，保证切分能继续
+		{"MaxBytesSingleToken", "aaaaaaaa b", 0, 0, SplitterConfig{ChunkSize: 10, MaxBytes: 3}, []string{"aaaaaaaa", "b"}},
+		{"WhitespaceOnly", " \n\t \n", 0, 0, SplitterConfig{ChunkSize: 4}, nil},
+		{"Empty", "", 0, 0, SplitterConfig{ChunkSize: 4}, nil},
+		{"SubRange", "xx a b yy", 3, 6, SplitterConfig{ChunkSize: 4}, []string{"a b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			end := tt.end
+			if end == 0 {
+				end = len(tt.text)
+			}
+			spans := splitTokens(tt.text, tt.start, end, tt.config)
+			var got []string
+			for _, span := range spans {
+				got = append(got, tt.text[span.start:span.end])
+				if span.tokens > tt.config.ChunkSize {
+					t.Fatalf("span %q has %d tokens, chunk size %d", tt.text[span.start:span.end], span.tokens, tt.config.ChunkSize)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("splitTokens(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitSections(t *testing.T) {
+	type sec struct{ heading, content string }
+	tests := []struct {
+		name string
+		text string
+		want []sec
+	}{
+		{"NoHeadings", "plain text", []sec{{"", "plain text"}}},
+		{"Path", "intro\n# A\nx\n## B\ny\n# C\nz", []sec{{"", "intro\n"}, {"A", "# A\nx\n"}, {"A > B", "## B\ny\n"}, {"C", "# C\nz"}}},
+		{"StartsWithHeading", "# A\nx", []sec{{"A", "# A\nx"}}},
+		{"SiblingReplacesDeeper", "# A\n### B\n## C\n", []sec{{"A", "# A\n"}, {"A > B", "### B\n"}, {"A > C", "## C\n"}}},
+		{"FencedCode", "# A\n```go\n# not a heading\n```\n## B\n", []sec{{"A", "# A\n```go\n# not a heading\n```\n"}, {"A > B", "## B\n"}}},
+		{"TildeFence", "# A\n~~~\n## no\n~~~\n", []sec{{"A", "# A\n~~~\n## no\n~~~\n"}}},
+		{"ClosingHashes", "  ## Title ##\nbody", []sec{{"Title", "  ## Title ##\nbody"}}},
+		{"NotHeadings", "#hashtag\n    # indented code\n####### seven\n#\n", []sec{{"", "#hashtag\n    # indented code\n####### seven\n#\n"}}},
+		{"CRLF", "# A\r\nx\r\n# B\r\n", []sec{{"A", "# A\r\nx\r\n"}, {"B", "# B\r\n"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []sec
+			for _, s := range splitSections(tt.text) {
+				got = append(got, sec{s.heading, tt.text[s.start:s.end]})
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("splitSections(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitterTransform(t *testing.T) {
+	splitter, err := NewSplitter(SplitterConfig{Mode: SplitByHeadings, ChunkSize: 4})
+	if err != nil {
+		t.Fatalf("NewSplitter: %v", err)
+	}
+	doc := &schema.Document{
+		ID:       "doc",
+		Content:  "# 安装\n下载安装包\n## 配置\na b",
+		MetaData: map[string]any{MetaSource: "guide.md"},
+	}
+	chunks, err := splitter.Transform(context.Background(), []*schema.Document{doc})
+	if err != nil {
+		t.Fatalf("Transform: %v", err)
+	}
+
+	want := []struct {
+		id, content, heading string
+	}{
+		{"doc#0", "# 安装", "安装"},
+		{"doc#1", "下载安装", "安装"},
+		{"doc#2", "包", "安装"},
+		{"doc#3", "## 配置", "安装 > 配置"},
+		{"doc#4", "a b", "安装 > 配置"},
+	}
+	if len(chunks) != len(want) {
+		var contents []string
+		for _, c := range chunks {
+			contents = append(contents, c.Content)
+		}
+		t.Fatalf("got %d chunks %q, want %d", len(chunks), contents, len(want))
+	}
+	for i, w := range want {
+		c := chunks[i]
+		if c.ID != w.id || c.Content != w.content || c.MetaData[MetaHeading] != w.heading {
+			t.Fatalf("chunk %d = %s %q heading %v, want %s %q heading %q", i, c.ID, c.Content, c.MetaData[MetaHeading], w.id, w.content, w.heading)
+		}
+		if c.MetaData[MetaSource] != "guide.md" || c.MetaData[MetaChunkIndex] != i {
+			t.Fatalf("chunk %d metadata = %v", i, c.MetaData)
+		}
+		if start, end := c.MetaData[MetaChunkStart].(int), c.MetaData[MetaChunkEnd].(int); doc.Content[start:end] != c.Content {
+			t.Fatalf("chunk %d offsets [%d,%d) do not match its content", i, start, end)
+		}
+	}
+}
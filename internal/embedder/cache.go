@@ -0,0 +1,269 @@
+package embedder
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Cache 嵌入向量缓存，键由CacheKey生成
+type Cache interface {
+	// Get 批量读取，返回与keys等长的切片，未命中的位置为nil
+	Get(ctx context.Context, keys []string) ([][]float32, error)
+	// Set 批量写入
+	Set(ctx context.Context, keys []string, vectors [][]float32) error
+}
+
+// CacheKey 嵌入向量的缓存键：模型名加内容的SHA-256
+func CacheKey(model, text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return model + ":" + hex.EncodeToString(sum[:])
+}
+
+// LRUCache 进程内的LRU缓存，按向量条数限制容量
+type LRUCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // 最近使用的在前
+	items    map[string]*list.Element
+}
+
+// lruEntry LRU缓存的条目
+type lruEntry struct {
+	key    string
+	vector []float32
+}
+
+// NewLRUCache 创建最多保存capacity条向量的LRU缓存
+func NewLRUCache(capacity int) *LRUCache {
+	return &LRUCache{
+		capacity: max(capacity, 1),
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Get 批量读取，命中的条目移到最前
+func (c *LRUCache) Get(_ context.Context, keys []string) ([][]float32, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	vectors := make([][]float32, len(keys))
+	for i, key := range keys {
+		if elem, ok := c.items[key]; ok {
+			c.order.MoveToFront(elem)
+			vectors[i] = elem.Value.(*lruEntry).vector
+		}
+	}
+	return vectors, nil
+}
+
+// Set 批量写入，超出容量时淘汰最久未使用的条目
+func (c *LRUCache) Set(_ context.Context, keys []string, vectors [][]float32) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, key := range keys {
+		if elem, ok := c.items[key]; ok {
+			elem.Value.(*lruEntry).vector = vectors[i]
+			c.order.MoveToFront(elem)
+			continue
+		}
+		c.items[key] = c.order.PushFront(&lruEntry{key: key, vector: vectors[i]})
+		for c.order.Len() > c.capacity {
+			oldest := c.order.Back()
+			c.order.Remove(oldest)
+			delete(c.items, oldest.Value.(*lruEntry).key)
+		}
+	}
+	return nil
+}
+
+// Len 返回缓存的向量条数
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// redisKeyPrefix Redis中嵌入向量缓存键的前缀
+const redisKeyPrefix = "embedding:"
+
+// RedisCache Redis缓存，多个实例共享；向量按float32小端序存储
+type RedisCache struct {
+	client *redis.Client
+	ttl    time.Duration // 为0时不过期
+}
+
+// NewRedisCache 连接Redis并创建缓存
+func NewRedisCache(addr, password string, db int, ttl time.Duration) (*RedisCache, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       db,
+	})
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("ping redis: %w", err)
+	}
+	return &RedisCache{client: client, ttl: ttl}, nil
+}
+
+// Get 用MGET批量读取，无法解码的值视为未命中
+func (c *RedisCache) Get(ctx context.Context, keys []string) ([][]float32, error) {
+	redisKeys := make([]string, len(keys))
+	for i, key := range keys {
+		redisKeys[i] = redisKeyPrefix + key
+	}
+	values, err := c.client.MGet(ctx, redisKeys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("mget embeddings: %w", err)
+	}
+
+	vectors := make([][]float32, len(keys))
+	for i, value := range values {
+		if s, ok := value.(string); ok {
+			vectors[i] = decodeVector([]byte(s))
+		}
+	}
+	return vectors, nil
+}
+
+// Set 用pipeline批量写入
+func (c *RedisCache) Set(ctx context.Context, keys []string, vectors [][]float32) error {
+	pipe := c.client.Pipeline()
+	for i, key := range keys {
+		pipe.Set(ctx, redisKeyPrefix+key, encodeVector(vectors[i]), c.ttl)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("set embeddings: %w", err)
+	}
+	return nil
+}
+
+// Close 关闭Redis连接
+func (c *RedisCache) Close() error {
+	return c.client.Close()
+}
+
+// encodeVector 将向量编码为float32小端序字节
+func encodeVector(vector []float32) []byte {
+	data := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(v))
+	}
+	return data
+}
+
+// decodeVector 解码encodeVector的结果，长度不合法时返回nil
+func decodeVector(data []byte) []float32 {
+	if len(data) == 0 || len(data)%4 != 0 {
+		return nil
+	}
+	vector := make([]float32, len(data)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+	}
+	return vector
+}
+
+// tieredCache 多级缓存，按顺序读取，较慢一级命中的条目回填到前面的级别
+type tieredCache []Cache
+
+// NewTieredCache 将多个缓存组合为多级缓存，快的在前；nil会被忽略，没有可用的缓存时返回nil
+func NewTieredCache(caches ...Cache) Cache {
+	var tiers tieredCache
+	for _, c := range caches {
+		if c != nil {
+			tiers = append(tiers, c)
+		}
+	}
+	switch len(tiers) {
+	case 0:
+		return nil
+	case 1:
+		return tiers[0]
+	default:
+		return tiers
+	}
+}
+
+// Get 逐级读取未命中的键；某一级出错时跳过该级，返回已读到的向量和第一个错误
+func (t tieredCache) Get(ctx context.Context, keys []string) ([][]float32, error) {
+	vectors := make([][]float32, len(keys))
+	missing := make([]int, len(keys))
+	for i := range keys {
+		missing[i] = i
+	}
+
+	var firstErr error
+	for level, c := range t {
+		if len(missing) == 0 {
+			break
+		}
+		levelKeys := make([]string, len(missing))
+		for i, idx := range missing {
+			levelKeys[i] = keys[idx]
+		}
+		found, err := c.Get(ctx, levelKeys)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		var hitKeys []string
+		var hitVectors [][]float32
+		remaining := missing[:0]
+		for i, idx := range missing {
+			if found[i] == nil {
+				remaining = append(remaining, idx)
+				continue
+			}
+			vectors[idx] = found[i]
+			hitKeys = append(hitKeys, keys[idx])
+			hitVectors = append(hitVectors, found[i])
+		}
+		missing = remaining
+
+		for _, upper := range t[:level] {
+			if len(hitKeys) > 0 {
+				_ = upper.Set(ctx, hitKeys, hitVectors)
+			}
+		}
+	}
+	return vectors, firstErr
+}
+
+// Set 写入每一级，返回第一个错误
+func (t tieredCache) Set(ctx context.Context, keys []string, vectors [][]float32) error {
+	var firstErr error
+	for _, c := range t {
+		if err := c.Set(ctx, keys, vectors); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Close 关闭实现了Close的缓存
+func (t tieredCache) Close() error {
+	var firstErr error
+	for _, c := range t {
+		if closer, ok := c.(interface{ Close() error }); ok {
+			if err := closer.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
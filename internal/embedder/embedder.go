@@ -0,0 +1,245 @@
+// Package embedder 实现Eino的embedding.Embedder：通过Ollama的/api/embed接口批量生成嵌入向量，
+// 限制并发请求数，失败时重试，并按模型和内容缓存结果。
+package embedder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/components/embedding"
+)
+
+// 重试间隔从retryBaseDelay开始逐次翻倍，不超过retryMaxDelay
+const (
+	retryBaseDelay = 200 * time.Millisecond
+	retryMaxDelay  = 5 * time.Second
+)
+
+// Config Ollama嵌入客户端配置
+type Config struct {
+	BaseURL     string
+	Model       string        // 默认嵌入模型，可以用embedding.WithModel按次指定
+	BatchSize   int           // 每次请求嵌入的文本数
+	Concurrency int           // 同时发出的请求数上限，所有调用共享
+	Timeout     time.Duration // 单次请求的超时，为0时不限制
+	MaxRetries  int           // 请求失败后的重试次数
+	Cache       Cache         // 嵌入向量缓存，为nil时不缓存
+}
+
+// OllamaEmbedder Ollama嵌入客户端
+type OllamaEmbedder struct {
+	baseURL    string
+	model      string
+	batchSize  int
+	maxRetries int
+	cache      Cache
+	client     *http.Client
+	sem        chan struct{} // 限制同时发出的请求数
+}
+
+var _ embedding.Embedder = (*OllamaEmbedder)(nil)
+
+// NewOllamaEmbedder 创建Ollama嵌入客户端
+func NewOllamaEmbedder(cfg Config) *OllamaEmbedder {
+	return &OllamaEmbedder{
+		baseURL:    cfg.BaseURL,
+		model:      cfg.Model,
+		batchSize:  max(cfg.BatchSize, 1),
+		maxRetries: max(cfg.MaxRetries, 0),
+		cache:      cfg.Cache,
+		client:     &http.Client{Timeout: cfg.Timeout},
+		sem:        make(chan struct{}, max(cfg.Concurrency, 1)),
+	}
+}
+
+// EmbedStrings 生成texts的嵌入向量，结果与texts一一对应
+//
+// 先查缓存，未命中的文本去重后按批请求Ollama，批次并发执行；缓存读写失败只记录日志。
+func (e *OllamaEmbedder) EmbedStrings(ctx context.Context, texts []string, opts ...embedding.Option) ([][]float64, error) {
+	options := embedding.GetCommonOptions(&embedding.Options{Model: &e.model}, opts...)
+	model := *options.Model
+
+	vectors := make([][]float32, len(texts))
+	keys := make([]string, len(texts))
+	for i, text := range texts {
+		keys[i] = CacheKey(model, text)
+	}
+	if e.cache != nil {
+		cached, err := e.cache.Get(ctx, keys)
+		if err != nil {
+			log.Printf("Warning: read embedding cache: %v", err)
+		}
+		if cached != nil {
+			copy(vectors, cached)
+		}
+	}
+
+	// 同一次调用中重复的文本只嵌入一次
+	pending := make(map[string][]int)
+	var missKeys, missTexts []string
+	for i, vector := range vectors {
+		if vector != nil {
+			continue
+		}
+		if _, ok := pending[keys[i]]; !ok {
+			missKeys = append(missKeys, keys[i])
+			missTexts = append(missTexts, texts[i])
+		}
+		pending[keys[i]] = append(pending[keys[i]], i)
+	}
+
+	if len(missTexts) > 0 {
+		embedded, err := e.embed(ctx, model, missTexts)
+		if err != nil {
+			return nil, err
+		}
+		for i, key := range missKeys {
+			for _, idx := range pending[key] {
+				vectors[idx] = embedded[i]
+			}
+		}
+		if e.cache != nil {
+			if err := e.cache.Set(ctx, missKeys, embedded); err != nil {
+				log.Printf("Warning: write embedding cache: %v", err)
+			}
+		}
+	}
+
+	result := make([][]float64, len(vectors))
+	for i, vector := range vectors {
+		result[i] = make([]float64, len(vector))
+		for j, v := range vector {
+			result[i][j] = float64(v)
+		}
+	}
+	return result, nil
+}
+
+// Close 关闭缓存的连接
+func (e *OllamaEmbedder) Close() error {
+	if closer, ok := e.cache.(interface{ Close() error }); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// embed 按batchSize分批并发请求Ollama，任一批失败时取消其余的批次
+func (e *OllamaEmbedder) embed(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	vectors := make([][]float32, len(texts))
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	for start := 0; start < len(texts); start += e.batchSize {
+		end := min(start+e.batchSize, len(texts))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			batch, err := e.embedBatch(ctx, model, texts[start:end])
+			if err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+			copy(vectors[start:end], batch)
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return vectors, nil
+}
+
+// embedBatch 占用一个并发名额请求一批嵌入向量，可重试的错误按指数退避重试
+func (e *OllamaEmbedder) embedBatch(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	select {
+	case e.sem <- struct{}{}:
+		defer func() { <-e.sem }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	delay := retryBaseDelay
+	for attempt := 0; ; attempt++ {
+		vectors, err := e.request(ctx, model, texts)
+		if err == nil {
+			return vectors, nil
+		}
+		var permanent *permanentError
+		if attempt >= e.maxRetries || errors.As(err, &permanent) || ctx.Err() != nil {
+			return nil, err
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		delay = min(2*delay, retryMaxDelay)
+	}
+}
+
+// permanentError 重试也不会成功的错误，例如模型不存在或响应格式不对
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// request 调用一次/api/embed；连接失败、超时、429和5xx可以重试，其他错误包装为permanentError
+func (e *OllamaEmbedder) request(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	jsonData, err := json.Marshal(map[string]any{
+		"model": model,
+		"input": texts,
+	})
+	if err != nil {
+		return nil, &permanentError{fmt.Errorf("marshal request: %w", err)}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.baseURL+"/api/embed", bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, &permanentError{fmt.Errorf("create request: %w", err)}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		err := fmt.Errorf("ollama API error (%d): %s", resp.StatusCode, string(body))
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError {
+			return nil, err
+		}
+		return nil, &permanentError{err}
+	}
+
+	var result struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	if len(result.Embeddings) != len(texts) {
+		return nil, &permanentError{fmt.Errorf("ollama returned %d embeddings for %d inputs", len(result.Embeddings), len(texts))}
+	}
+	return result.Embeddings, nil
+}
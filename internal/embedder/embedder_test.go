@@ -0,0 +1,250 @@
+package embedder_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"eino/internal/embedder"
+)
+
+// ollamaServer 模拟/api/embed：每段文本的向量为 [文本长度]，statuses依次作为前几次请求的状态码
+type ollamaServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	batches  [][]string // 成功请求的输入，按收到的顺序
+	requests int
+}
+
+func newOllamaServer(t *testing.T, statuses ...int) *ollamaServer {
+	s := &ollamaServer{statuses: statuses}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *ollamaServer) handle(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Model string   `json:"model"`
+		Input []string `json:"input"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.requests++
+	status := http.StatusOK
+	if len(s.statuses) > 0 {
+		status, s.statuses = s.statuses[0], s.statuses[1:]
+	}
+	if status == http.StatusOK {
+		s.batches = append(s.batches, req.Input)
+	}
+	s.mu.Unlock()
+
+	if status != http.StatusOK {
+		http.Error(w, "unavailable", status)
+		return
+	}
+	embeddings := make([][]float32, len(req.Input))
+	for i, text := range req.Input {
+		embeddings[i] = []float32{float32(len(text))}
+	}
+	json.NewEncoder(w).Encode(map[string]any{"embeddings": embeddings})
+}
+
+// batchSizes 成功请求的批大小，从大到小排列，与并发请求的到达顺序无关
+func (s *ollamaServer) batchSizes() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sizes := make([]int, len(s.batches))
+	for i, batch := range s.batches {
+		sizes[i] = len(batch)
+	}
+	slices.SortFunc(sizes, func(a, b int) int { return b - a })
+	return sizes
+}
+
+// lengths 每段文本期望的向量
+func lengths(texts []string) [][]float64 {
+	vectors := make([][]float64, len(texts))
+	for i, text := range texts {
+		vectors[i] = []float64{float64(len(text))}
+	}
+	return vectors
+}
+
+func equalVectors(a, b [][]float64) bool {
+	return slices.EqualFunc(a, b, func(x, y []float64) bool { return slices.Equal(x, y) })
+}
+
+func TestEmbedStringsBatching(t *testing.T) {
+	tests := []struct {
+		name      string
+		texts     []string
+		batchSize int
+		want      []int // 各批大小，从大到小
+	}{
+		{"SingleBatch", []string{"a", "bb", "ccc"}, 8, []int{3}},
+		{"Split", []string{"a", "bb", "ccc", "dddd", "eeeee"}, 2, []int{2, 2, 1}},
+		{"Exact", []string{"a", "bb", "ccc", "dddd"}, 2, []int{2, 2}},
+		{"Duplicates", []string{"a", "bb", "a", "bb", "ccc"}, 2, []int{2, 1}},
+		{"Empty", nil, 2, []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newOllamaServer(t)
+			e := embedder.NewOllamaEmbedder(embedder.Config{
+				BaseURL:     server.URL,
+				Model:       "test",
+				BatchSize:   tt.batchSize,
+				Concurrency: 2,
+			})
+
+			got, err := e.EmbedStrings(context.Background(), tt.texts)
+			if err != nil {
+				t.Fatalf("EmbedStrings: %v", err)
+			}
+			if want := lengths(tt.texts); !equalVectors(got, want) {
+				t.Fatalf("vectors = %v, want %v", got, want)
+			}
+			if sizes := server.batchSizes(); !slices.Equal(sizes, tt.want) {
+				t.Fatalf("batch sizes = %v, want %v", sizes, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmbedStringsRetry(t *testing.T) {
+	tests := []struct {
+		name       string
+		statuses   []int
+		maxRetries int
+		wantErr    bool
+		requests   int
+	}{
+		{"ServerError", []int{http.StatusInternalServerError}, 1, false, 2},
+		{"TooManyRequests", []int{http.StatusTooManyRequests, http.StatusServiceUnavailable}, 2, false, 3},
+		{"RetriesExhausted", []int{http.StatusBadGateway, http.StatusBadGateway}, 1, true, 2},
+		{"NoRetries", []int{http.StatusInternalServerError}, 0, true, 1},
+		{"Permanent", []int{http.StatusNotFound}, 3, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newOllamaServer(t, tt.statuses...)
+			e := embedder.NewOllamaEmbedder(embedder.Config{
+				BaseURL:    server.URL,
+				Model:      "test",
+				BatchSize:  8,
+				MaxRetries: tt.maxRetries,
+			})
+
+			texts := []string{"a", "bb"}
+			got, err := e.EmbedStrings(context.Background(), texts)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("EmbedStrings succeeded, want error")
+				}
+			} else if err != nil {
+				t.Fatalf("EmbedStrings: %v", err)
+			} else if !equalVectors(got, lengths(texts)) {
+				t.Fatalf("vectors = %v", got)
+			}
+			if server.requests != tt.requests {
+				t.Fatalf("requests = %d, want %d", server.requests, tt.requests)
+			}
+		})
+	}
+}
+
+func TestEmbedStringsCache(t *testing.T) {
+	server := newOllamaServer(t)
+	e := embedder.NewOllamaEmbedder(embedder.Config{
+		BaseURL:   server.URL,
+		Model:     "test",
+		BatchSize: 8,
+		Cache:     embedder.NewLRUCache(16),
+	})
+	ctx := context.Background()
+
+	if _, err := e.EmbedStrings(ctx, []string{"a", "bb"}); err != nil {
+		t.Fatalf("EmbedStrings: %v", err)
+	}
+	// 只有未缓存的文本会请求Ollama
+	texts := []string{"bb", "ccc", "a"}
+	got, err := e.EmbedStrings(ctx, texts)
+	if err != nil {
+		t.Fatalf("EmbedStrings: %v", err)
+	}
+	if !equalVectors(got, lengths(texts)) {
+		t.Fatalf("vectors = %v", got)
+	}
+	if sizes := server.batchSizes(); !slices.Equal(sizes, []int{2, 1}) {
+		t.Fatalf("batch sizes = %v, want [2 1]", sizes)
+	}
+	if server.batches[1][0] != "ccc" {
+		t.Fatalf("second request = %q, want [ccc]", server.batches[1])
+	}
+}
+
+func TestLRUCache(t *testing.T) {
+	tests := []struct {
+		name string
+		ops  []string // set:key 写入，get:key 读取；容量为2
+		want map[string]bool
+	}{
+		{"Evict", []string{"set:a", "set:b", "set:c"}, map[string]bool{"a": false, "b": true, "c": true}},
+		{"GetRefreshes", []string{"set:a", "set:b", "get:a", "set:c"}, map[string]bool{"a": true, "b": false, "c": true}},
+		{"SetRefreshes", []string{"set:a", "set:b", "set:a", "set:c"}, map[string]bool{"a": true, "b": false, "c": true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			c := embedder.NewLRUCache(2)
+			for _, op := range tt.ops {
+				action, key, _ := strings.Cut(op, ":")
+				if action == "set" {
+					c.Set(ctx, []string{key}, [][]float32{{float32(key[0])}})
+				} else {
+					c.Get(ctx, []string{key})
+				}
+			}
+			if c.Len() != 2 {
+				t.Fatalf("Len = %d, want 2", c.Len())
+			}
+			for key, want := range tt.want {
+				got, _ := c.Get(ctx, []string{key})
+				if (got[0] != nil) != want {
+					t.Fatalf("%s cached = %v, want %v", key, got[0] != nil, want)
+				}
+			}
+		})
+	}
+}
+
+func TestTieredCacheBackfill(t *testing.T) {
+	ctx := context.Background()
+	fast, slow := embedder.NewLRUCache(4), embedder.NewLRUCache(4)
+	slow.Set(ctx, []string{"a"}, [][]float32{{1}})
+	cache := embedder.NewTieredCache(fast, nil, slow)
+
+	got, err := cache.Get(ctx, []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !slices.Equal(got[0], []float32{1}) || got[1] != nil {
+		t.Fatalf("Get = %v", got)
+	}
+	if got, _ := fast.Get(ctx, []string{"a"}); got[0] == nil {
+		t.Fatal("hit in the slow tier was not copied to the fast tier")
+	}
+}
@@ -0,0 +1,195 @@
+package fake
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"eino/internal/storage/keyword"
+
+	einomodel "github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// noKnowledgeReply 提示词中没有编号资料时的回复
+const noKnowledgeReply = "抱歉，我没有找到相关的资料。"
+
+// passagePattern 匹配编号资料的开头，如 "[1] 《标题》（来源：a.md）"
+var passagePattern = regexp.MustCompile(`(?m)^\[(\d+)\][^\n]*\n?`)
+
+// judgeDocumentPattern 重排序评审输入中每段资料的编号
+var judgeDocumentPattern = regexp.MustCompile(`\[\d+\] `)
+
+// sentenceEnds 切分句子的标点
+const sentenceEnds = "。！？!?\n"
+
+// ChatModel 不调用模型服务的对话模型
+//
+// 普通对话从提示词中的编号资料里选出与用户消息用词重合最多的一句作为回复，并标注资料编号；
+// 系统提示词要求只输出JSON时（检索判断、改写问题、重排序、人设检查、评测打分），按要求的字段给出确定的结果。
+type ChatModel struct{}
+
+var _ einomodel.BaseChatModel = (*ChatModel)(nil)
+
+// NewChatModel 创建对话模型
+func NewChatModel() *ChatModel {
+	return &ChatModel{}
+}
+
+// Generate 生成回复，忽略生成参数
+func (m *ChatModel) Generate(_ context.Context, input []*schema.Message, _ ...einomodel.Option) (*schema.Message, error) {
+	return schema.AssistantMessage(reply(input), nil), nil
+}
+
+// Stream 按空格切分回复，逐段返回
+func (m *ChatModel) Stream(_ context.Context, input []*schema.Message, _ ...einomodel.Option) (*schema.StreamReader[*schema.Message], error) {
+	var chunks []*schema.Message
+	for _, part := range strings.SplitAfter(reply(input), " ") {
+		chunks = append(chunks, schema.AssistantMessage(part, nil))
+	}
+	return schema.StreamReaderFromArray(chunks), nil
+}
+
+// reply 按消息列表生成回复
+func reply(input []*schema.Message) string {
+	var system []string
+	var user string
+	for _, msg := range input {
+		switch msg.Role {
+		case schema.System:
+			system = append(system, msg.Content)
+		case schema.User:
+			user = msg.Content
+		}
+	}
+	systemPrompt := strings.Join(system, "\n\n")
+
+	if strings.Contains(systemPrompt, "只输出JSON") {
+		data, _ := json.Marshal(jsonReply(systemPrompt, user))
+		return string(data)
+	}
+	return answer(systemPrompt, user)
+}
+
+// answer 从编号资料中选出与问题用词重合最多的一句，标注资料编号
+func answer(systemPrompt, question string) string {
+	passages := splitPassages(systemPrompt)
+	best, bestScore, bestIndex := "", 0.0, 0
+	for i, passage := range passages {
+		for _, sentence := range splitSentences(passage) {
+			if strings.HasPrefix(sentence, "#") {
+				continue // 跳过Markdown标题
+			}
+			if score := coverage(question, sentence); score > bestScore {
+				best, bestScore, bestIndex = sentence, score, i+1
+			}
+		}
+	}
+	if best == "" {
+		return noKnowledgeReply
+	}
+	return fmt.Sprintf("%s [%d]", best, bestIndex)
+}
+
+// jsonReply 按系统提示词要求的JSON字段给出结果
+func jsonReply(systemPrompt, user string) map[string]any {
+	switch {
+	case strings.Contains(systemPrompt, `"retrieve"`):
+		return map[string]any{"retrieve": true, "reason": "fake model always retrieves"}
+	case strings.Contains(systemPrompt, `"query"`):
+		query := user
+		if _, latest, ok := strings.Cut(user, "最新消息：\n"); ok {
+			query = latest
+		}
+		return map[string]any{"query": strings.TrimSpace(query), "variants": []string{}}
+	case strings.Contains(systemPrompt, `"scores"`):
+		question, documents := splitJudgeInput(user)
+		scores := make([]float64, len(documents))
+		for i, doc := range documents {
+			scores[i] = 10 * coverage(question, doc)
+		}
+		return map[string]any{"scores": scores}
+	case strings.Contains(systemPrompt, `"consistent"`):
+		return map[string]any{"consistent": true}
+	case strings.Contains(systemPrompt, `"score"`):
+		// 评测打分：回答中的词出现在资料中的比例
+		material, answer, _ := strings.Cut(user, "回答：")
+		return map[string]any{"score": 10 * coverage(answer, material), "reason": "word coverage"}
+	}
+	return map[string]any{}
+}
+
+// splitPassages 按 [n] 切分编号资料，返回各段内容；最后一段在最后一个空行处截止，去掉资料后的说明
+func splitPassages(text string) []string {
+	locs := passagePattern.FindAllStringSubmatchIndex(text, -1)
+	passages := make([]string, 0, len(locs))
+	for i, loc := range locs {
+		if n, _ := strconv.Atoi(text[loc[2]:loc[3]]); n != i+1 {
+			continue
+		}
+		end := len(text)
+		if i+1 < len(locs) {
+			end = locs[i+1][0]
+		} else if blank := strings.LastIndex(text, "\n\n"); blank > loc[1] {
+			end = blank
+		}
+		passages = append(passages, strings.TrimSpace(text[loc[1]:end]))
+	}
+	return passages
+}
+
+// splitJudgeInput 拆分重排序评审的输入：问题和编号资料
+func splitJudgeInput(text string) (string, []string) {
+	question, documents, _ := strings.Cut(text, "资料：")
+	question = strings.TrimSpace(strings.TrimPrefix(question, "问题："))
+	var docs []string
+	for _, doc := range judgeDocumentPattern.Split(documents, -1)[1:] {
+		docs = append(docs, strings.TrimSpace(doc))
+	}
+	return question, docs
+}
+
+// splitSentences 按句末标点切分句子，保留标点
+func splitSentences(text string) []string {
+	var sentences []string
+	for len(text) > 0 {
+		i := strings.IndexAny(text, sentenceEnds)
+		if i < 0 {
+			i = len(text)
+		} else {
+			_, size := utf8.DecodeRuneInString(text[i:])
+			i += size
+		}
+		if s := strings.TrimSpace(text[:i]); s != "" {
+			sentences = append(sentences, s)
+		}
+		text = text[i:]
+	}
+	return sentences
+}
+
+// coverage text的词（去重）出现在reference中的比例，text没有词时为0
+func coverage(text, reference string) float64 {
+	terms := make(map[string]bool)
+	for _, term := range keyword.Tokenize(text) {
+		terms[term] = true
+	}
+	if len(terms) == 0 {
+		return 0
+	}
+	refTerms := make(map[string]bool)
+	for _, term := range keyword.Tokenize(reference) {
+		refTerms[term] = true
+	}
+	var hit int
+	for term := range terms {
+		if refTerms[term] {
+			hit++
+		}
+	}
+	return float64(hit) / float64(len(terms))
+}
@@ -0,0 +1,60 @@
+// Package fake 提供不依赖模型服务的对话模型和嵌入模型，结果只由输入决定，
+// 用于在没有Ollama的环境中离线运行评测和开发调试（model.provider和rag.embedding_provider设为fake）。
+package fake
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+
+	"eino/internal/storage/keyword"
+
+	"github.com/cloudwego/eino/components/embedding"
+)
+
+// EmbeddingDim 嵌入向量的维度
+const EmbeddingDim = 256
+
+// Embedder 把文本切分为关键词索引使用的词，按词的哈希累加到向量的各维并归一化；
+// 用词相同的文本距离近，可以用cosine或l2度量检索
+type Embedder struct{}
+
+var _ embedding.Embedder = (*Embedder)(nil)
+
+// NewEmbedder 创建嵌入模型
+func NewEmbedder() *Embedder {
+	return &Embedder{}
+}
+
+// EmbedStrings 生成texts的嵌入向量，忽略模型参数
+func (e *Embedder) EmbedStrings(_ context.Context, texts []string, _ ...embedding.Option) ([][]float64, error) {
+	vectors := make([][]float64, len(texts))
+	for i, text := range texts {
+		vectors[i] = embed(text)
+	}
+	return vectors, nil
+}
+
+// embed 生成一条嵌入向量，没有词的文本使用固定的单位向量
+func embed(text string) []float64 {
+	vector := make([]float64, EmbeddingDim)
+	for _, term := range keyword.Tokenize(text) {
+		h := fnv.New32a()
+		h.Write([]byte(term))
+		vector[h.Sum32()%EmbeddingDim]++
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += v * v
+	}
+	if norm == 0 {
+		vector[0] = 1
+		return vector
+	}
+	norm = math.Sqrt(norm)
+	for i := range vector {
+		vector[i] /= norm
+	}
+	return vector
+}
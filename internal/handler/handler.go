@@ -1,11 +1,18 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"eino/internal/agent"
 	"eino/internal/model"
+	"eino/internal/storage"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,17 +24,56 @@ func RegisterRoutes(router *gin.Engine, chatService *agent.ChatService) {
 		// 聊天机器人管理
 		api.POST("/chatbots", createChatbot(chatService))
 		api.GET("/chatbots", getChatbots(chatService))
+		api.POST("/chatbots/import", importPersona(chatService))
 		api.GET("/chatbots/:id", getChatbot(chatService))
 		api.PUT("/chatbots/:id", updateChatbot(chatService))
 		api.DELETE("/chatbots/:id", deleteChatbot(chatService))
 
+		// 人设版本
+		api.GET("/chatbots/:id/versions", listPersonaVersions(chatService))
+		api.GET("/chatbots/:id/versions/diff", diffPersonaVersions(chatService))
+		api.GET("/chatbots/:id/versions/:version", getPersonaVersion(chatService))
+		api.POST("/chatbots/:id/versions/:version/rollback", rollbackPersona(chatService))
+
 		// 对话接口
 		api.POST("/chatbots/:id/chat", chat(chatService))
+		api.POST("/chatbots/:id/chat/stream", chatStream(chatService))
+		api.POST("/chatbots/:id/regenerate", regenerate(chatService))
 		api.GET("/chatbots/:id/history", getHistory(chatService))
+		api.GET("/chatbots/:id/export", exportConversations(chatService))
+		api.POST("/chatbots/:id/import", importConversations(chatService))
+		api.GET("/chatbots/:id/persona", exportPersona(chatService))
+
+		// 对话分支
+		api.GET("/conversations/:id", getConversation(chatService))
+		api.POST("/conversations/:id/edit", editMessage(chatService))
+		api.GET("/conversations/:id/branches", getConversationBranches(chatService))
+
+		// 反馈
+		api.POST("/conversations/:id/feedback", submitFeedback(chatService))
+		api.GET("/conversations/:id/feedback", getFeedback(chatService))
+		api.GET("/chatbots/:id/feedback/stats", feedbackStats(chatService))
+		api.GET("/chatbots/:id/feedback/export", exportPreferences(chatService))
 
 		// RAG知识库接口（如果启用）
+		api.POST("/knowledge/bases", createKnowledgeBase(chatService))
+		api.GET("/knowledge/bases", listKnowledgeBases(chatService))
+		api.GET("/knowledge/bases/:name", getKnowledgeBase(chatService))
 		api.POST("/knowledge", addKnowledge(chatService))
 		api.GET("/knowledge/search", searchKnowledge(chatService))
+		api.POST("/knowledge/documents", uploadDocument(chatService))
+		api.DELETE("/knowledge/documents/:id", deleteDocument(chatService))
+		api.GET("/knowledge/items", listKnowledge(chatService))
+		api.GET("/knowledge/items/:id", getKnowledge(chatService))
+		api.PUT("/knowledge/items/:id", updateKnowledge(chatService))
+		api.DELETE("/knowledge/items/:id", deleteKnowledge(chatService))
+		api.GET("/knowledge/jobs", listIngestJobs(chatService))
+		api.GET("/knowledge/jobs/:id", getIngestJob(chatService))
+		api.POST("/knowledge/reindex", startReindex(chatService))
+		api.GET("/knowledge/reindex", listReindexJobs(chatService))
+		api.GET("/knowledge/reindex/:id", getReindexJob(chatService))
+		api.POST("/knowledge/reindex/:id/pause", pauseReindex(chatService))
+		api.POST("/knowledge/reindex/:id/resume", resumeReindex(chatService))
 	}
 
 	// 健康检查
@@ -47,6 +93,13 @@ func createChatbot(service *agent.ChatService) gin.HandlerFunc {
 		}
 
 		chatbot, err := service.CreateChatbot(c.Request.Context(), &req)
+		if errors.Is(err, agent.ErrInvalidPersona) {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{
+				Error:   "invalid_persona",
+				Message: err.Error(),
+			})
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, model.ErrorResponse{
 				Error:   "create_chatbot_failed",
@@ -59,10 +112,27 @@ func createChatbot(service *agent.ChatService) gin.HandlerFunc {
 	}
 }
 
-// getChatbots 获取所有聊天机器人
+// 聊天机器人列表分页参数
+const (
+	defaultChatbotLimit = 20
+	maxChatbotLimit     = 100
+)
+
+// getChatbots 分页获取聊天机器人列表
+//
+// 查询参数：limit、offset、q（名称关键词）、sort（created, updated, name）、order（asc, desc）
 func getChatbots(service *agent.ChatService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		chatbots, err := service.GetChatbots(c.Request.Context())
+		query, err := parseChatbotQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{
+				Error:   "invalid_request",
+				Message: err.Error(),
+			})
+			return
+		}
+
+		page, err := service.ListChatbots(c.Request.Context(), query)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, model.ErrorResponse{
 				Error:   "get_chatbots_failed",
@@ -71,8 +141,54 @@ func getChatbots(service *agent.ChatService) gin.HandlerFunc {
 			return
 		}
 
-		c.JSON(http.StatusOK, chatbots)
+		c.JSON(http.StatusOK, page)
+	}
+}
+
+// parseChatbotQuery 解析聊天机器人列表查询参数
+func parseChatbotQuery(c *gin.Context) (model.ChatbotQuery, error) {
+	query := model.ChatbotQuery{
+		Limit:  defaultChatbotLimit,
+		Search: strings.TrimSpace(c.Query("q")),
+		Sort:   c.DefaultQuery("sort", model.ChatbotSortCreated),
+	}
+
+	if l := c.Query("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 1 {
+			return query, fmt.Errorf("invalid limit: %s", l)
+		}
+		query.Limit = min(limit, maxChatbotLimit)
+	}
+
+	if o := c.Query("offset"); o != "" {
+		offset, err := strconv.Atoi(o)
+		if err != nil || offset < 0 {
+			return query, fmt.Errorf("invalid offset: %s", o)
+		}
+		query.Offset = offset
+	}
+
+	switch query.Sort {
+	case model.ChatbotSortCreated, model.ChatbotSortUpdated:
+		// 时间默认降序，最新的在前
+		query.Desc = true
+	case model.ChatbotSortName:
+	default:
+		return query, fmt.Errorf("invalid sort: %s", query.Sort)
+	}
+
+	switch order := c.Query("order"); order {
+	case "":
+	case "asc":
+		query.Desc = false
+	case "desc":
+		query.Desc = true
+	default:
+		return query, fmt.Errorf("invalid order: %s", order)
 	}
+
+	return query, nil
 }
 
 // getChatbot 获取指定聊天机器人
@@ -80,22 +196,28 @@ func getChatbot(service *agent.ChatService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := c.Param("id")
 		chatbot, err := service.GetChatbot(c.Request.Context(), id)
-		if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
 			c.JSON(http.StatusNotFound, model.ErrorResponse{
 				Error:   "chatbot_not_found",
 				Message: err.Error(),
 			})
 			return
 		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+				Error:   "get_chatbot_failed",
+				Message: err.Error(),
+			})
+			return
+		}
 
 		c.JSON(http.StatusOK, chatbot)
 	}
 }
 
-// updateChatbot 更新聊天机器人
+// updateChatbot 更新聊天机器人，人设变化时生成新版本
 func updateChatbot(service *agent.ChatService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		_ = c.Param("id") // 暂时未使用
 		var req model.UpdateChatbotRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, model.ErrorResponse{
@@ -105,17 +227,163 @@ func updateChatbot(service *agent.ChatService) gin.HandlerFunc {
 			return
 		}
 
-		// TODO: 实现更新逻辑
-		_ = req // 暂时未使用
-		c.JSON(http.StatusOK, gin.H{"message": "update not implemented yet"})
+		chatbot, err := service.UpdateChatbot(c.Request.Context(), c.Param("id"), &req)
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			c.JSON(http.StatusNotFound, model.ErrorResponse{
+				Error:   "chatbot_not_found",
+				Message: err.Error(),
+			})
+		case errors.Is(err, agent.ErrInvalidPersona):
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{
+				Error:   "invalid_persona",
+				Message: err.Error(),
+			})
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+				Error:   "update_chatbot_failed",
+				Message: err.Error(),
+			})
+		default:
+			c.JSON(http.StatusOK, chatbot)
+		}
+	}
+}
+
+// listPersonaVersions 获取人设版本历史
+func listPersonaVersions(service *agent.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		versions, err := service.ListPersonaVersions(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			writePersonaVersionError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"versions": versions})
+	}
+}
+
+// getPersonaVersion 获取指定人设版本
+func getPersonaVersion(service *agent.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		version, err := parseVersionParam(c.Param("version"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{
+				Error:   "invalid_request",
+				Message: err.Error(),
+			})
+			return
+		}
+
+		v, err := service.GetPersonaVersion(c.Request.Context(), c.Param("id"), version)
+		if err != nil {
+			writePersonaVersionError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, v)
+	}
+}
+
+// diffPersonaVersions 比较两个人设版本
+//
+// 查询参数：from、to（版本号）
+func diffPersonaVersions(service *agent.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		from, err := parseVersionParam(c.Query("from"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{
+				Error:   "invalid_request",
+				Message: fmt.Sprintf("from: %v", err),
+			})
+			return
+		}
+		to, err := parseVersionParam(c.Query("to"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{
+				Error:   "invalid_request",
+				Message: fmt.Sprintf("to: %v", err),
+			})
+			return
+		}
+
+		diff, err := service.DiffPersonaVersions(c.Request.Context(), c.Param("id"), from, to)
+		if err != nil {
+			writePersonaVersionError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, diff)
+	}
+}
+
+// rollbackPersona 回滚到指定人设版本
+func rollbackPersona(service *agent.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		version, err := parseVersionParam(c.Param("version"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{
+				Error:   "invalid_request",
+				Message: err.Error(),
+			})
+			return
+		}
+
+		chatbot, err := service.RollbackPersona(c.Request.Context(), c.Param("id"), version)
+		if err != nil {
+			writePersonaVersionError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, chatbot)
+	}
+}
+
+// parseVersionParam 解析人设版本号
+func parseVersionParam(v string) (int, error) {
+	version, err := strconv.Atoi(v)
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("invalid version: %q", v)
+	}
+	return version, nil
+}
+
+// writePersonaVersionError 输出人设版本接口的错误响应
+func writePersonaVersionError(c *gin.Context, err error) {
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Error:   "not_found",
+			Message: err.Error(),
+		})
+		return
 	}
+	// 回滚到的版本绑定了已不存在的知识库等
+	if errors.Is(err, agent.ErrInvalidPersona) {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Error:   "invalid_persona",
+			Message: err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+		Error:   "persona_version_failed",
+		Message: err.Error(),
+	})
 }
 
 // deleteChatbot 删除聊天机器人
 func deleteChatbot(service *agent.ChatService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := c.Param("id")
-		if err := service.DeleteChatbot(c.Request.Context(), id); err != nil {
+		err := service.DeleteChatbot(c.Request.Context(), id)
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, model.ErrorResponse{
+				Error:   "chatbot_not_found",
+				Message: err.Error(),
+			})
+			return
+		}
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, model.ErrorResponse{
 				Error:   "delete_chatbot_failed",
 				Message: err.Error(),
@@ -140,7 +408,14 @@ func chat(service *agent.ChatService) gin.HandlerFunc {
 			return
 		}
 
-		response, err := service.Chat(c.Request.Context(), chatbotID, req.Message)
+		response, err := service.Chat(c.Request.Context(), chatbotID, &req)
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, model.ErrorResponse{
+				Error:   "chatbot_not_found",
+				Message: err.Error(),
+			})
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, model.ErrorResponse{
 				Error:   "chat_failed",
@@ -153,44 +428,192 @@ func chat(service *agent.ChatService) gin.HandlerFunc {
 	}
 }
 
-// getHistory 获取对话历史
-func getHistory(service *agent.ChatService) gin.HandlerFunc {
+// regenerate 重新生成最后一轮回复
+func regenerate(service *agent.ChatService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		chatbotID := c.Param("id")
-		limit := 20
-		if l := c.Query("limit"); l != "" {
-			if _, err := fmt.Sscanf(l, "%d", &limit); err != nil {
-				limit = 20
+		// 请求体可选
+		var req model.RegenerateRequest
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, model.ErrorResponse{
+					Error:   "invalid_request",
+					Message: err.Error(),
+				})
+				return
+			}
+		}
+
+		response, err := service.Regenerate(c.Request.Context(), c.Param("id"), &req)
+		writeChatResult(c, response, err)
+	}
+}
+
+// editMessage 编辑历史对话中的用户消息，生成新分支
+func editMessage(service *agent.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := parseConversationID(c)
+		if !ok {
+			return
+		}
+
+		var req model.ChatRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{
+				Error:   "invalid_request",
+				Message: err.Error(),
+			})
+			return
+		}
+
+		response, err := service.EditMessage(c.Request.Context(), id, &req)
+		writeChatResult(c, response, err)
+	}
+}
+
+// chatStream 流式对话（Server-Sent Events）
+//
+// message事件为回复片段 {"content": "..."}，完成后发送done事件，内容与对话接口的响应相同；
+// 生成开始后出错时发送error事件。收到第一个片段前出错时与对话接口一样返回JSON错误。
+func chatStream(service *agent.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.ChatRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{
+				Error:   "invalid_request",
+				Message: err.Error(),
+			})
+			return
+		}
+
+		started := false
+		startStream := func() {
+			if !started {
+				c.Header("Content-Type", "text/event-stream")
+				c.Header("Cache-Control", "no-cache")
+				c.Header("Connection", "keep-alive")
+				started = true
 			}
 		}
 
-		history, err := service.GetConversationHistory(c.Request.Context(), chatbotID, limit)
+		response, err := service.StreamChat(c.Request.Context(), c.Param("id"), &req, func(content string) {
+			if content == "" {
+				return
+			}
+			startStream()
+			c.SSEvent("message", gin.H{"content": content})
+			c.Writer.Flush()
+		})
+		if err != nil && !started {
+			writeChatResult(c, nil, err)
+			return
+		}
+
+		startStream()
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, model.ErrorResponse{
-				Error:   "get_history_failed",
+			c.SSEvent("error", model.ErrorResponse{
+				Error:   "chat_failed",
 				Message: err.Error(),
 			})
+		} else {
+			c.SSEvent("done", response)
+		}
+		c.Writer.Flush()
+	}
+}
+
+// writeChatResult 输出生成回复类接口的结果
+func writeChatResult(c *gin.Context, response *model.ChatResponse, err error) {
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Error:   "not_found",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+			Error:   "chat_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// getConversation 获取单条对话记录
+func getConversation(service *agent.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := parseConversationID(c)
+		if !ok {
+			return
+		}
+
+		conv, err := service.GetConversation(c.Request.Context(), id)
+		if err != nil {
+			writeConversationError(c, err)
 			return
 		}
 
-		c.JSON(http.StatusOK, history)
+		c.JSON(http.StatusOK, conv)
 	}
 }
 
-// healthCheck 健康检查
-func healthCheck(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"status":  "ok",
-		"service": "eino-chatbot",
+// getConversationBranches 获取对话同一位置的全部分支，用于比较
+func getConversationBranches(service *agent.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := parseConversationID(c)
+		if !ok {
+			return
+		}
+
+		branches, err := service.GetConversationBranches(c.Request.Context(), id)
+		if err != nil {
+			writeConversationError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"branches": branches})
+	}
+}
+
+// parseConversationID 解析路径中的对话ID，不合法时直接输出400
+func parseConversationID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id < 1 {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Error:   "invalid_request",
+			Message: fmt.Sprintf("invalid conversation id: %q", c.Param("id")),
+		})
+		return 0, false
+	}
+	return id, true
+}
+
+// writeConversationError 输出对话记录接口的错误响应
+func writeConversationError(c *gin.Context, err error) {
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Error:   "conversation_not_found",
+			Message: err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+		Error:   "get_conversation_failed",
+		Message: err.Error(),
 	})
 }
 
-// addKnowledge 添加知识到向量库
-func addKnowledge(service *agent.ChatService) gin.HandlerFunc {
+// submitFeedback 提交对一轮回复的反馈，重复提交时覆盖
+func submitFeedback(service *agent.ChatService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var req struct {
-			Content string `json:"content" binding:"required"`
+		id, ok := parseConversationID(c)
+		if !ok {
+			return
 		}
+
+		var req model.FeedbackRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, model.ErrorResponse{
 				Error:   "invalid_request",
@@ -199,24 +622,902 @@ func addKnowledge(service *agent.ChatService) gin.HandlerFunc {
 			return
 		}
 
-		// TODO: 实现添加知识功能
-		c.JSON(http.StatusOK, gin.H{"message": "knowledge added"})
+		feedback, err := service.SubmitFeedback(c.Request.Context(), id, &req)
+		if errors.Is(err, agent.ErrInvalidFeedback) {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{
+				Error:   "invalid_feedback",
+				Message: err.Error(),
+			})
+			return
+		}
+		if err != nil {
+			writeConversationError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, feedback)
 	}
 }
 
-// searchKnowledge 搜索知识
-func searchKnowledge(service *agent.ChatService) gin.HandlerFunc {
+// getFeedback 获取对一轮回复的反馈
+func getFeedback(service *agent.ChatService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		query := c.Query("q")
-		if query == "" {
+		id, ok := parseConversationID(c)
+		if !ok {
+			return
+		}
+
+		feedback, err := service.GetFeedback(c.Request.Context(), id)
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, model.ErrorResponse{
+				Error:   "feedback_not_found",
+				Message: err.Error(),
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+				Error:   "get_feedback_failed",
+				Message: err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, feedback)
+	}
+}
+
+// feedbackStats 按时间段统计满意度
+//
+// 查询参数：interval（day、week、month，默认day）、from/to（RFC3339或YYYY-MM-DD）
+func feedbackStats(service *agent.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		interval := c.DefaultQuery("interval", model.StatsIntervalDay)
+		if !model.ValidStatsInterval(interval) {
 			c.JSON(http.StatusBadRequest, model.ErrorResponse{
 				Error:   "invalid_request",
-				Message: "query parameter 'q' is required",
+				Message: fmt.Sprintf("unsupported interval: %s (want day, week or month)", interval),
+			})
+			return
+		}
+
+		from, to, err := parseTimeRange(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{
+				Error:   "invalid_request",
+				Message: err.Error(),
 			})
 			return
 		}
 
-		// TODO: 实现搜索知识功能
-		c.JSON(http.StatusOK, gin.H{"results": []string{}})
+		query := model.FeedbackQuery{ChatbotID: c.Param("id"), From: from, To: to}
+		stats, err := service.FeedbackStats(c.Request.Context(), query, interval)
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, model.ErrorResponse{
+				Error:   "chatbot_not_found",
+				Message: err.Error(),
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+				Error:   "feedback_stats_failed",
+				Message: err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, stats)
+	}
+}
+
+// exportPreferences 导出偏好数据集（JSONL）
+func exportPreferences(service *agent.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format := c.DefaultQuery("format", model.PreferenceFormatDPO)
+		if format != model.PreferenceFormatDPO && format != model.PreferenceFormatKTO {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{
+				Error:   "invalid_request",
+				Message: fmt.Sprintf("unsupported format: %s (want dpo or kto)", format),
+			})
+			return
+		}
+
+		chatbot, err := service.GetChatbot(c.Request.Context(), c.Param("id"))
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, model.ErrorResponse{
+				Error:   "chatbot_not_found",
+				Message: err.Error(),
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+				Error:   "get_chatbot_failed",
+				Message: err.Error(),
+			})
+			return
+		}
+
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s.jsonl"`, chatbot.ID, format))
+		c.Status(http.StatusOK)
+
+		// 响应头已发出，出错时只能中断输出
+		if err := service.ExportPreferences(c.Request.Context(), chatbot, format, c.Writer); err != nil {
+			log.Printf("Export preferences of %s failed: %v", chatbot.ID, err)
+		}
+	}
+}
+
+// 对话历史分页参数
+const (
+	defaultHistoryLimit = 20
+	maxHistoryLimit     = 100
+)
+
+// getHistory 分页获取对话历史
+//
+// 查询参数：limit、before/after（对话ID游标）、from/to（RFC3339或YYYY-MM-DD）、q（关键词）
+func getHistory(service *agent.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query, err := parseHistoryQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{
+				Error:   "invalid_request",
+				Message: err.Error(),
+			})
+			return
+		}
+
+		page, err := service.QueryConversationHistory(c.Request.Context(), query)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+				Error:   "get_history_failed",
+				Message: err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, page)
+	}
+}
+
+// parseHistoryQuery 解析对话历史查询参数
+func parseHistoryQuery(c *gin.Context) (model.HistoryQuery, error) {
+	query := model.HistoryQuery{
+		ChatbotID: c.Param("id"),
+		Limit:     defaultHistoryLimit,
+		Search:    strings.TrimSpace(c.Query("q")),
+	}
+
+	if l := c.Query("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 1 {
+			return query, fmt.Errorf("invalid limit: %s", l)
+		}
+		query.Limit = min(limit, maxHistoryLimit)
+	}
+
+	for name, target := range map[string]*int64{"before": &query.Before, "after": &query.After} {
+		if v := c.Query(name); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || id < 1 {
+				return query, fmt.Errorf("invalid %s: %s", name, v)
+			}
+			*target = id
+		}
+	}
+
+	from, to, err := parseTimeRange(c)
+	if err != nil {
+		return query, err
+	}
+	query.From, query.To = from, to
+
+	return query, nil
+}
+
+// parseTimeRange 解析from/to查询参数，to只给日期时包含当天
+func parseTimeRange(c *gin.Context) (from, to time.Time, err error) {
+	if v := c.Query("from"); v != "" {
+		from, _, err = parseTimeParam(v)
+		if err != nil {
+			return from, to, fmt.Errorf("invalid from: %s", v)
+		}
+	}
+
+	if v := c.Query("to"); v != "" {
+		var dateOnly bool
+		to, dateOnly, err = parseTimeParam(v)
+		if err != nil {
+			return from, to, fmt.Errorf("invalid to: %s", v)
+		}
+		if dateOnly {
+			to = to.AddDate(0, 0, 1)
+		}
+	}
+
+	return from, to, nil
+}
+
+// parseTimeParam 解析RFC3339时间或YYYY-MM-DD日期（按本地时区）
+func parseTimeParam(v string) (t time.Time, dateOnly bool, err error) {
+	if t, err = time.Parse(time.RFC3339, v); err == nil {
+		return t, false, nil
+	}
+	t, err = time.ParseInLocation(time.DateOnly, v, time.Local)
+	return t, true, err
+}
+
+// exportContentTypes 各导出格式的响应类型和文件扩展名
+var exportContentTypes = map[string][2]string{
+	model.ExportFormatJSONL:    {"application/x-ndjson", "jsonl"},
+	model.ExportFormatOpenAI:   {"application/x-ndjson", "openai.jsonl"},
+	model.ExportFormatMarkdown: {"text/markdown; charset=utf-8", "md"},
+}
+
+// maxImportSize 导入请求体大小上限
+const maxImportSize = 32 << 20
+
+// exportConversations 流式导出全部对话历史
+//
+// format=yaml|json 时导出聊天机器人的人设包
+func exportConversations(service *agent.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format := c.DefaultQuery("format", model.ExportFormatJSONL)
+		contentType, ok := exportContentTypes[format]
+		if !ok {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{
+				Error:   "invalid_request",
+				Message: fmt.Sprintf("unsupported format: %s (want jsonl, markdown or openai)", format),
+			})
+			return
+		}
+
+		chatbot, err := service.GetChatbot(c.Request.Context(), c.Param("id"))
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, model.ErrorResponse{
+				Error:   "chatbot_not_found",
+				Message: err.Error(),
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+				Error:   "get_chatbot_failed",
+				Message: err.Error(),
+			})
+			return
+		}
+
+		c.Header("Content-Type", contentType[0])
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, chatbot.ID, contentType[1]))
+		c.Status(http.StatusOK)
+
+		// 响应头已发出，出错时只能中断输出
+		if err := service.ExportConversations(c.Request.Context(), chatbot, format, c.Writer); err != nil {
+			log.Printf("Export conversations of %s failed: %v", chatbot.ID, err)
+		}
+	}
+}
+
+// importConversations 从JSONL导入对话历史
+func importConversations(service *agent.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body := http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+		result, err := service.ImportConversations(c.Request.Context(), c.Param("id"), body)
+
+		var importErr *agent.ImportError
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			c.JSON(http.StatusNotFound, model.ErrorResponse{
+				Error:   "chatbot_not_found",
+				Message: err.Error(),
+			})
+		case errors.As(err, &tooLarge):
+			c.JSON(http.StatusRequestEntityTooLarge, model.ErrorResponse{
+				Error:   "import_too_large",
+				Message: err.Error(),
+			})
+		case errors.As(err, &importErr):
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{
+				Error:   "invalid_import_data",
+				Message: err.Error(),
+			})
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+				Error:   "import_failed",
+				Message: err.Error(),
+			})
+		default:
+			c.JSON(http.StatusOK, result)
+		}
+	}
+}
+
+// maxPersonaSize 人设包请求体大小上限
+const maxPersonaSize = 8 << 20
+
+// importPersona 导入人设包，按slug新建或更新聊天机器人
+func importPersona(service *agent.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxPersonaSize))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, model.ErrorResponse{
+				Error:   "import_too_large",
+				Message: err.Error(),
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{
+				Error:   "invalid_request",
+				Message: err.Error(),
+			})
+			return
+		}
+
+		bundle, err := agent.ParsePersonaBundle(data)
+		if err == nil {
+			var result *model.PersonaImportResult
+			if result, err = service.ImportPersona(c.Request.Context(), bundle); err == nil {
+				status := http.StatusOK
+				if result.Created {
+					status = http.StatusCreated
+				}
+				c.JSON(status, result)
+				return
+			}
+		}
+
+		if errors.Is(err, agent.ErrInvalidPersona) {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{
+				Error:   "invalid_persona",
+				Message: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+			Error:   "import_persona_failed",
+			Message: err.Error(),
+		})
+	}
+}
+
+// exportPersona 导出人设包，format可选yaml（默认）或json
+func exportPersona(service *agent.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format := c.DefaultQuery("format", model.PersonaFormatYAML)
+		if format != model.PersonaFormatYAML && format != model.PersonaFormatJSON {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{
+				Error:   "invalid_request",
+				Message: fmt.Sprintf("unsupported format: %s (want yaml or json)", format),
+			})
+			return
+		}
+
+		bundle, err := service.ExportPersona(c.Request.Context(), c.Param("id"))
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, model.ErrorResponse{
+				Error:   "chatbot_not_found",
+				Message: err.Error(),
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+				Error:   "export_persona_failed",
+				Message: err.Error(),
+			})
+			return
+		}
+
+		name := bundle.Slug
+		if name == "" {
+			name = c.Param("id")
+		}
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, name, format))
+		if format == model.PersonaFormatYAML {
+			c.YAML(http.StatusOK, bundle)
+			return
+		}
+		c.JSON(http.StatusOK, bundle)
+	}
+}
+
+// healthCheck 健康检查
+func healthCheck(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"service": "eino-chatbot",
+	})
+}
+
+// addKnowledge 添加知识到向量库
+func addKnowledge(service *agent.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.KnowledgeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{
+				Error:   "invalid_request",
+				Message: err.Error(),
+			})
+			return
+		}
+
+		documentID, err := service.AddKnowledge(c.Request.Context(), req.KnowledgeBase, req.Content)
+		if err != nil {
+			writeKnowledgeError(c, err, "add_knowledge_failed")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "knowledge added", "document_id": documentID})
+	}
+}
+
+// 知识分块列表分页参数
+const (
+	defaultKnowledgeLimit = 20
+	maxKnowledgeLimit     = 100
+)
+
+// listKnowledge 分页获取knowledge_base表中的知识分块
+//
+// 查询参数：kb（知识库）、document_id、source、limit、offset，按ID升序
+func listKnowledge(service *agent.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := model.KnowledgeQuery{
+			KnowledgeBase: c.Query("kb"),
+			DocumentID:    c.Query("document_id"),
+			Source:        c.Query("source"),
+			Limit:         defaultKnowledgeLimit,
+		}
+		if l := c.Query("limit"); l != "" {
+			limit, err := strconv.Atoi(l)
+			if err != nil || limit < 1 {
+				c.JSON(http.StatusBadRequest, model.ErrorResponse{
+					Error:   "invalid_request",
+					Message: fmt.Sprintf("invalid limit: %s", l),
+				})
+				return
+			}
+			query.Limit = min(limit, maxKnowledgeLimit)
+		}
+		if o := c.Query("offset"); o != "" {
+			offset, err := strconv.Atoi(o)
+			if err != nil || offset < 0 {
+				c.JSON(http.StatusBadRequest, model.ErrorResponse{
+					Error:   "invalid_request",
+					Message: fmt.Sprintf("invalid offset: %s", o),
+				})
+				return
+			}
+			query.Offset = offset
+		}
+
+		page, err := service.ListKnowledge(c.Request.Context(), query)
+		if err != nil {
+			writeKnowledgeError(c, err, "list_knowledge_failed")
+			return
+		}
+		c.JSON(http.StatusOK, page)
+	}
+}
+
+// parseKnowledgeID 解析路径中的知识分块ID，不合法时输出400
+func parseKnowledgeID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Error:   "invalid_request",
+			Message: fmt.Sprintf("invalid knowledge id: %s", c.Param("id")),
+		})
+		return 0, false
+	}
+	return id, true
+}
+
+// getKnowledge 获取知识分块
+func getKnowledge(service *agent.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := parseKnowledgeID(c)
+		if !ok {
+			return
+		}
+		chunk, err := service.GetKnowledge(c.Request.Context(), id)
+		if err != nil {
+			writeKnowledgeItemError(c, err, "get_knowledge_failed")
+			return
+		}
+		c.JSON(http.StatusOK, chunk)
+	}
+}
+
+// updateKnowledge 修改知识分块并重新生成嵌入向量
+func updateKnowledge(service *agent.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := parseKnowledgeID(c)
+		if !ok {
+			return
+		}
+		var req model.UpdateKnowledgeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{
+				Error:   "invalid_request",
+				Message: err.Error(),
+			})
+			return
+		}
+
+		chunk, err := service.UpdateKnowledge(c.Request.Context(), id, &req)
+		if err != nil {
+			writeKnowledgeItemError(c, err, "update_knowledge_failed")
+			return
+		}
+		c.JSON(http.StatusOK, chunk)
+	}
+}
+
+// deleteKnowledge 删除知识分块及其向量
+func deleteKnowledge(service *agent.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := parseKnowledgeID(c)
+		if !ok {
+			return
+		}
+		if err := service.DeleteKnowledge(c.Request.Context(), id); err != nil {
+			writeKnowledgeItemError(c, err, "delete_knowledge_failed")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "knowledge deleted"})
+	}
+}
+
+// deleteDocument 删除文档（一次添加或导入）的全部分块及其向量
+func deleteDocument(service *agent.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		deleted, err := service.DeleteDocument(c.Request.Context(), c.Param("id"))
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, model.ErrorResponse{
+				Error:   "document_not_found",
+				Message: err.Error(),
+			})
+			return
+		}
+		if err != nil {
+			writeKnowledgeError(c, err, "delete_document_failed")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "document deleted", "deleted_chunks": deleted})
+	}
+}
+
+// writeKnowledgeItemError 输出知识分块接口的错误：分块不存在为404，其他同writeKnowledgeError
+func writeKnowledgeItemError(c *gin.Context, err error, code string) {
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Error:   "knowledge_not_found",
+			Message: err.Error(),
+		})
+		return
+	}
+	writeKnowledgeError(c, err, code)
+}
+
+// defaultKnowledgeTopK 知识检索默认返回条数
+const defaultKnowledgeTopK = 5
+
+// searchKnowledge 搜索知识，kb参数指定知识库（可重复或逗号分隔），未指定时搜索默认知识库；
+// mode参数指定检索方式（hybrid, vector, keyword），未指定时使用rag.retrieval.mode
+func searchKnowledge(service *agent.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := c.Query("q")
+		if query == "" {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{
+				Error:   "invalid_request",
+				Message: "query parameter 'q' is required",
+			})
+			return
+		}
+
+		topK := defaultKnowledgeTopK
+		if v := c.Query("top_k"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || n > 100 {
+				c.JSON(http.StatusBadRequest, model.ErrorResponse{
+					Error:   "invalid_request",
+					Message: "top_k must be between 1 and 100",
+				})
+				return
+			}
+			topK = n
+		}
+
+		var kbs []string
+		for _, v := range c.QueryArray("kb") {
+			for _, name := range strings.Split(v, ",") {
+				if name = strings.TrimSpace(name); name != "" {
+					kbs = append(kbs, name)
+				}
+			}
+		}
+
+		mode := c.Query("mode")
+		if err := model.ValidateSearchMode(mode); err != nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{
+				Error:   "invalid_request",
+				Message: err.Error(),
+			})
+			return
+		}
+
+		result, err := service.SearchKnowledge(c.Request.Context(), query, model.SearchOptions{KnowledgeBases: kbs, TopK: topK, Mode: mode})
+		if err != nil {
+			writeKnowledgeError(c, err, "search_knowledge_failed")
+			return
+		}
+
+		// results只有内容，保持与早期版本兼容；hits包含分块ID、来源和得分，trace为检索过程
+		results := make([]string, len(result.Hits))
+		for i, hit := range result.Hits {
+			results[i] = hit.Content
+		}
+		c.JSON(http.StatusOK, gin.H{"results": results, "hits": result.Hits, "trace": result.Trace})
+	}
+}
+
+// maxDocumentSize 上传文档大小上限
+const maxDocumentSize = 32 << 20
+
+// uploadDocument 上传文档（multipart表单字段file），创建后台导入任务
+//
+// 表单字段knowledge_base指定知识库（默认为default），mode、chunk_size、chunk_overlap覆盖默认分块参数。
+func uploadDocument(service *agent.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxDocumentSize)
+
+		var opts model.ChunkOptions
+		if err := c.ShouldBind(&opts); err != nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{
+				Error:   "invalid_request",
+				Message: err.Error(),
+			})
+			return
+		}
+
+		fileHeader, err := c.FormFile("file")
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, model.ErrorResponse{
+				Error:   "document_too_large",
+				Message: err.Error(),
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{
+				Error:   "invalid_request",
+				Message: "multipart form field 'file' is required",
+			})
+			return
+		}
+
+		file, err := fileHeader.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{
+				Error:   "invalid_request",
+				Message: err.Error(),
+			})
+			return
+		}
+		defer file.Close()
+
+		job, err := service.IngestDocument(c.Request.Context(), c.PostForm("knowledge_base"), fileHeader.Filename, file, opts)
+		if err != nil {
+			writeKnowledgeError(c, err, "ingest_document_failed")
+			return
+		}
+
+		c.JSON(http.StatusAccepted, job)
+	}
+}
+
+// listIngestJobs 列出文档导入任务
+func listIngestJobs(service *agent.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		jobs, err := service.ListIngestJobs()
+		if err != nil {
+			writeKnowledgeError(c, err, "list_ingest_jobs_failed")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"jobs": jobs})
+	}
+}
+
+// getIngestJob 查询文档导入任务的进度
+func getIngestJob(service *agent.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		job, err := service.GetIngestJob(c.Param("id"))
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, model.ErrorResponse{
+				Error:   "job_not_found",
+				Message: err.Error(),
+			})
+			return
+		}
+		if err != nil {
+			writeKnowledgeError(c, err, "get_ingest_job_failed")
+			return
+		}
+		c.JSON(http.StatusOK, job)
+	}
+}
+
+// startReindex 创建重建索引任务
+func startReindex(service *agent.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		job, err := service.StartReindex(c.Request.Context())
+		if err != nil {
+			writeReindexError(c, err, "start_reindex_failed")
+			return
+		}
+		c.JSON(http.StatusAccepted, job)
+	}
+}
+
+// listReindexJobs 列出重建索引任务
+func listReindexJobs(service *agent.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		jobs, err := service.ListReindexJobs(c.Request.Context())
+		if err != nil {
+			writeReindexError(c, err, "list_reindex_jobs_failed")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"jobs": jobs})
+	}
+}
+
+// getReindexJob 查询重建索引任务的进度
+func getReindexJob(service *agent.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		job, err := service.GetReindexJob(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			writeReindexError(c, err, "get_reindex_job_failed")
+			return
+		}
+		c.JSON(http.StatusOK, job)
+	}
+}
+
+// pauseReindex 暂停重建索引任务
+func pauseReindex(service *agent.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		job, err := service.PauseReindex(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			writeReindexError(c, err, "pause_reindex_failed")
+			return
+		}
+		c.JSON(http.StatusOK, job)
+	}
+}
+
+// resumeReindex 恢复已暂停或失败的重建索引任务
+func resumeReindex(service *agent.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		job, err := service.ResumeReindex(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			writeReindexError(c, err, "resume_reindex_failed")
+			return
+		}
+		c.JSON(http.StatusAccepted, job)
+	}
+}
+
+// writeReindexError 输出重建索引接口的错误：任务不存在为404，任务状态不允许该操作为409，其他同writeKnowledgeError
+func writeReindexError(c *gin.Context, err error, code string) {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Error:   "job_not_found",
+			Message: err.Error(),
+		})
+	case errors.Is(err, storage.ErrConflict):
+		c.JSON(http.StatusConflict, model.ErrorResponse{
+			Error:   "reindex_conflict",
+			Message: err.Error(),
+		})
+	default:
+		writeKnowledgeError(c, err, code)
+	}
+}
+
+// createKnowledgeBase 创建知识库
+func createKnowledgeBase(service *agent.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.CreateKnowledgeBaseRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{
+				Error:   "invalid_request",
+				Message: err.Error(),
+			})
+			return
+		}
+
+		kb, err := service.CreateKnowledgeBase(c.Request.Context(), &req)
+		switch {
+		case errors.Is(err, agent.ErrInvalidKnowledgeBase):
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{
+				Error:   "invalid_knowledge_base",
+				Message: err.Error(),
+			})
+		case errors.Is(err, agent.ErrKnowledgeBaseExists):
+			c.JSON(http.StatusConflict, model.ErrorResponse{
+				Error:   "knowledge_base_exists",
+				Message: err.Error(),
+			})
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+				Error:   "create_knowledge_base_failed",
+				Message: err.Error(),
+			})
+		default:
+			c.JSON(http.StatusCreated, kb)
+		}
+	}
+}
+
+// listKnowledgeBases 获取全部知识库
+func listKnowledgeBases(service *agent.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		kbs, err := service.ListKnowledgeBases(c.Request.Context())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+				Error:   "list_knowledge_bases_failed",
+				Message: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"knowledge_bases": kbs})
+	}
+}
+
+// getKnowledgeBase 获取知识库
+func getKnowledgeBase(service *agent.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		kb, err := service.GetKnowledgeBase(c.Request.Context(), c.Param("name"))
+		if err != nil {
+			writeKnowledgeError(c, err, "get_knowledge_base_failed")
+			return
+		}
+		c.JSON(http.StatusOK, kb)
+	}
+}
+
+// writeKnowledgeError 输出知识库接口的错误：未启用RAG为503，知识库不存在为404，文档或参数不合法为400，其他为500
+func writeKnowledgeError(c *gin.Context, err error, code string) {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Error:   "knowledge_base_not_found",
+			Message: err.Error(),
+		})
+	case errors.Is(err, agent.ErrRAGDisabled):
+		c.JSON(http.StatusServiceUnavailable, model.ErrorResponse{
+			Error:   "rag_disabled",
+			Message: err.Error(),
+		})
+	case errors.Is(err, agent.ErrInvalidDocument):
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Error:   "invalid_document",
+			Message: err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+			Error:   code,
+			Message: err.Error(),
+		})
 	}
 }
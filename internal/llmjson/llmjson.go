@@ -0,0 +1,23 @@
+// Package llmjson 解析模型按提示词要求输出的JSON
+package llmjson
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Extract 截取文本中第一个 { 到最后一个 } 之间的内容，兼容模型在JSON前后输出说明或代码块包裹；
+// 没有完整的对象时原样返回
+func Extract(text string) string {
+	start := strings.IndexByte(text, '{')
+	end := strings.LastIndexByte(text, '}')
+	if start < 0 || end < start {
+		return text
+	}
+	return text[start : end+1]
+}
+
+// Unmarshal 从模型输出中截取JSON对象并解析到v
+func Unmarshal(text string, v any) error {
+	return json.Unmarshal([]byte(Extract(text)), v)
+}
@@ -0,0 +1,43 @@
+package llmjson_test
+
+import (
+	"testing"
+
+	"eino/internal/llmjson"
+)
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"Plain", `{"score": 8}`, `{"score": 8}`},
+		{"CodeFence", "```json\n{\"score\": 8}\n```", `{"score": 8}`},
+		{"Explanation", "结果如下：{\"a\": {\"b\": 1}} 以上。", `{"a": {"b": 1}}`},
+		{"NoObject", "no json here", "no json here"},
+		{"Reversed", "} oops {", "} oops {"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := llmjson.Extract(tt.text); got != tt.want {
+				t.Fatalf("Extract(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	var result struct {
+		Retrieve *bool `json:"retrieve"`
+	}
+	if err := llmjson.Unmarshal("好的：\n{\"retrieve\": true}", &result); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if result.Retrieve == nil || !*result.Retrieve {
+		t.Fatalf("retrieve = %v, want true", result.Retrieve)
+	}
+	if err := llmjson.Unmarshal("not json", &result); err == nil {
+		t.Fatal("Unmarshal of text without an object succeeded")
+	}
+}
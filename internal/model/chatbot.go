@@ -1,51 +1,413 @@
 package model
 
-import "time"
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
 
 // Chatbot 聊天机器人模型
 type Chatbot struct {
-	ID           string    `json:"id"`
-	Name         string    `json:"name"`
-	Personality  string    `json:"personality"`   // 性格设定
-	Background   string    `json:"background"`    // 背景设定
-	SystemPrompt string    `json:"system_prompt"` // 系统提示词（自动生成）
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID             string            `json:"id"`
+	Slug           string            `json:"slug,omitempty"` // 稳定标识，人设包按此更新
+	Name           string            `json:"name"`
+	Personality    string            `json:"personality"`               // 性格设定
+	Background     string            `json:"background"`                // 背景设定
+	PromptTemplate string            `json:"prompt_template,omitempty"` // 系统提示词模板（覆盖默认模板）
+	PromptFormat   string            `json:"prompt_format,omitempty"`   // 模板格式：go（默认）, fstring
+	Options        GenerationOptions `json:"options"`                   // 生成参数
+	SystemPrompt   string            `json:"system_prompt"`             // 系统提示词（自动生成）
+	PersonaVersion int               `json:"persona_version"`           // 当前人设版本
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+}
+
+// GenerationOptions 生成参数，未设置的字段使用全局配置
+type GenerationOptions struct {
+	Temperature *float32      `json:"temperature,omitempty" yaml:"temperature,omitempty"`
+	MaxTokens   *int          `json:"max_tokens,omitempty" yaml:"max_tokens,omitempty"`
+	TopP        *float32      `json:"top_p,omitempty" yaml:"top_p,omitempty"`
+	Guard       *GuardOptions `json:"guard,omitempty" yaml:"guard,omitempty"` // 人设一致性检查
+	// Retrieval 知识检索设置
+	Retrieval *RetrievalOptions `json:"retrieval,omitempty" yaml:"retrieval,omitempty"`
+}
+
+// RetrievalOptions 知识检索设置
+type RetrievalOptions struct {
+	// KnowledgeBases 绑定的知识库，只从这些知识库检索；为空时只检索默认知识库
+	KnowledgeBases []string `json:"knowledge_bases,omitempty" yaml:"knowledge_bases,omitempty"`
+	// Mode 检索方式：hybrid, vector, keyword；为空时使用rag.retrieval.mode
+	Mode string `json:"mode,omitempty" yaml:"mode,omitempty"`
+	// RAG 检索时机：off, auto, always；为空时使用rag.retrieval.rag_mode
+	RAG string `json:"rag,omitempty" yaml:"rag,omitempty"`
+	// Classifier auto时判断是否需要检索的方式：rules, model；为空时使用rag.retrieval.classifier
+	Classifier string `json:"classifier,omitempty" yaml:"classifier,omitempty"`
+	// MaxDistance 向量检索结果与查询的最大距离，超过的结果丢弃；为空时使用rag.retrieval.max_distance
+	MaxDistance *float64 `json:"max_distance,omitempty" yaml:"max_distance,omitempty"`
+	// MinKeywordScore 关键词检索结果的最低BM25得分，与max_distance一起决定融合后的结果是否相关；为空时使用rag.retrieval.min_keyword_score
+	MinKeywordScore *float64 `json:"min_keyword_score,omitempty" yaml:"min_keyword_score,omitempty"`
+}
+
+// 检索时机
+const (
+	RAGModeOff    = "off"    // 不检索
+	RAGModeAuto   = "auto"   // 由分类器判断本轮是否需要检索
+	RAGModeAlways = "always" // 每轮都检索
+)
+
+// 自动检索时判断是否需要检索的方式
+const (
+	RAGClassifierRules = "rules" // 规则：寒暄、致谢等不检索，提问和较长的消息检索
+	RAGClassifierModel = "model" // 调用一次模型判断
+)
+
+// ValidateRAGMode 校验检索时机，空字符串表示使用默认值
+func ValidateRAGMode(mode string) error {
+	switch mode {
+	case "", RAGModeOff, RAGModeAuto, RAGModeAlways:
+		return nil
+	}
+	return fmt.Errorf("invalid rag mode %q (want off, auto or always)", mode)
+}
+
+// ValidateRAGClassifier 校验检索分类方式，空字符串表示使用默认值
+func ValidateRAGClassifier(classifier string) error {
+	switch classifier {
+	case "", RAGClassifierRules, RAGClassifierModel:
+		return nil
+	}
+	return fmt.Errorf("invalid rag classifier %q (want rules or model)", classifier)
+}
+
+// KnowledgeBases 返回聊天机器人检索的知识库
+func (o *GenerationOptions) KnowledgeBases() []string {
+	if o.Retrieval == nil || len(o.Retrieval.KnowledgeBases) == 0 {
+		return []string{DefaultKnowledgeBase}
+	}
+	return o.Retrieval.KnowledgeBases
+}
+
+// SearchOptions 按检索设置返回检索topK条知识的参数
+func (o *GenerationOptions) SearchOptions(topK int) SearchOptions {
+	opts := SearchOptions{KnowledgeBases: o.KnowledgeBases(), TopK: topK}
+	if o.Retrieval != nil {
+		opts.Mode = o.Retrieval.Mode
+		opts.MaxDistance = o.Retrieval.MaxDistance
+		opts.MinKeywordScore = o.Retrieval.MinKeywordScore
+	}
+	return opts
+}
+
+// 人设一致性检查方式
+const (
+	GuardModeRules = "rules" // 规则检查：禁用短语和“作为AI”类声明
+	GuardModeModel = "model" // 再调用一次模型判断
+	GuardModeBoth  = "both"  // 先规则后模型
+)
+
+// 未通过人设一致性检查时的处理方式
+const (
+	GuardActionRegenerate = "regenerate" // 重新生成，最多MaxRetries次
+	GuardActionRewrite    = "rewrite"    // 让模型按人设改写回复
+	GuardActionNone       = "none"       // 只记录结果
+)
+
+// GuardOptions 人设一致性检查配置
+type GuardOptions struct {
+	Enabled       bool     `json:"enabled" yaml:"enabled"`
+	Mode          string   `json:"mode,omitempty" yaml:"mode,omitempty"`                     // rules（默认）, model, both
+	BannedPhrases []string `json:"banned_phrases,omitempty" yaml:"banned_phrases,omitempty"` // 额外的禁用短语，忽略大小写
+	Action        string   `json:"action,omitempty" yaml:"action,omitempty"`                 // regenerate（默认）, rewrite, none
+	MaxRetries    int      `json:"max_retries,omitempty" yaml:"max_retries,omitempty"`       // 重新生成次数，默认1
+}
+
+// 系统提示词模板格式
+const (
+	PromptFormatGoTemplate = "go"      // text/template，如 {{.Name}}
+	PromptFormatFString    = "fstring" // Eino FString，如 {name}
+)
+
+// Validate 校验生成参数
+func (o *GenerationOptions) Validate() error {
+	if g := o.Guard; g != nil {
+		switch g.Mode {
+		case "", GuardModeRules, GuardModeModel, GuardModeBoth:
+		default:
+			return fmt.Errorf("invalid guard mode %q (want rules, model or both)", g.Mode)
+		}
+		switch g.Action {
+		case "", GuardActionRegenerate, GuardActionRewrite, GuardActionNone:
+		default:
+			return fmt.Errorf("invalid guard action %q (want regenerate, rewrite or none)", g.Action)
+		}
+		if g.MaxRetries < 0 {
+			return fmt.Errorf("guard max_retries must not be negative")
+		}
+	}
+	if r := o.Retrieval; r != nil {
+		for _, name := range r.KnowledgeBases {
+			if err := ValidateKnowledgeBaseName(name); err != nil {
+				return err
+			}
+		}
+		if err := ValidateSearchMode(r.Mode); err != nil {
+			return err
+		}
+		if err := ValidateRAGMode(r.RAG); err != nil {
+			return err
+		}
+		if err := ValidateRAGClassifier(r.Classifier); err != nil {
+			return err
+		}
+		if r.MaxDistance != nil && *r.MaxDistance < 0 {
+			return fmt.Errorf("retrieval max_distance must not be negative")
+		}
+		if r.MinKeywordScore != nil && *r.MinKeywordScore < 0 {
+			return fmt.Errorf("retrieval min_keyword_score must not be negative")
+		}
+	}
+	return nil
 }
 
 // CreateChatbotRequest 创建聊天机器人请求
 type CreateChatbotRequest struct {
-	Name        string `json:"name" binding:"required"`
-	Personality string `json:"personality" binding:"required"`
-	Background  string `json:"background" binding:"required"`
+	Name           string            `json:"name" binding:"required"`
+	Personality    string            `json:"personality" binding:"required"`
+	Background     string            `json:"background" binding:"required"`
+	PromptTemplate string            `json:"prompt_template"` // 可选，覆盖全局模板
+	PromptFormat   string            `json:"prompt_format"`   // go（默认）, fstring
+	Options        GenerationOptions `json:"options"`
 }
 
-// UpdateChatbotRequest 更新聊天机器人请求
+// UpdateChatbotRequest 更新聊天机器人请求，空字段表示不修改
 type UpdateChatbotRequest struct {
-	Name        string `json:"name"`
-	Personality string `json:"personality"`
-	Background  string `json:"background"`
+	Name           string             `json:"name"`
+	Personality    string             `json:"personality"`
+	Background     string             `json:"background"`
+	PromptTemplate *string            `json:"prompt_template"` // 设为空字符串时恢复默认模板
+	PromptFormat   *string            `json:"prompt_format"`
+	Options        *GenerationOptions `json:"options"` // 整体替换生成参数
+	Comment        string             `json:"comment"` // 人设版本说明
+}
+
+// 聊天机器人列表排序字段
+const (
+	ChatbotSortCreated = "created"
+	ChatbotSortUpdated = "updated"
+	ChatbotSortName    = "name"
+)
+
+// ChatbotQuery 聊天机器人列表查询条件
+type ChatbotQuery struct {
+	Offset int
+	Limit  int
+	Search string // 名称关键词（忽略大小写）
+	Sort   string // created, updated, name，默认created
+	Desc   bool   // 是否降序
+}
+
+// Match 判断聊天机器人是否满足查询条件（不含分页条件）
+func (q *ChatbotQuery) Match(chatbot *Chatbot) bool {
+	return q.Search == "" || strings.Contains(strings.ToLower(chatbot.Name), strings.ToLower(q.Search))
+}
+
+// Less 按排序字段比较两个聊天机器人，字段相同时按ID排序以保证顺序稳定
+func (q *ChatbotQuery) Less(a, b *Chatbot) bool {
+	var cmp int
+	switch q.Sort {
+	case ChatbotSortUpdated:
+		cmp = a.UpdatedAt.Compare(b.UpdatedAt)
+	case ChatbotSortName:
+		cmp = strings.Compare(a.Name, b.Name)
+	default:
+		cmp = a.CreatedAt.Compare(b.CreatedAt)
+	}
+	if cmp == 0 {
+		cmp = strings.Compare(a.ID, b.ID)
+	}
+	if q.Desc {
+		return cmp > 0
+	}
+	return cmp < 0
+}
+
+// ChatbotPage 聊天机器人分页结果
+type ChatbotPage struct {
+	Chatbots []*Chatbot `json:"chatbots"`
+	Total    int        `json:"total"`
+	Offset   int        `json:"offset"`
+	Limit    int        `json:"limit"`
+}
+
+// NewChatbotPage 在内存中过滤、排序并分页，适用于无法在存储端查询的实现
+func NewChatbotPage(q ChatbotQuery, all []*Chatbot) *ChatbotPage {
+	matched := make([]*Chatbot, 0, len(all))
+	for _, chatbot := range all {
+		if q.Match(chatbot) {
+			matched = append(matched, chatbot)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return q.Less(matched[i], matched[j]) })
+
+	page := &ChatbotPage{Chatbots: []*Chatbot{}, Total: len(matched), Offset: q.Offset, Limit: q.Limit}
+	if q.Offset < len(matched) && q.Limit > 0 {
+		end := min(q.Offset+q.Limit, len(matched))
+		page.Chatbots = matched[q.Offset:end]
+	}
+
+	return page
 }
 
-// Conversation 对话记录
+// Conversation 对话记录（一轮用户消息和回复）
+//
+// 对话记录按ParentID组成树：重新生成回复或编辑历史消息时，会在同一父节点下新增一条记录作为新分支。
+// 最新的一条记录所在的路径为当前分支。
 type Conversation struct {
-	ID          int64     `json:"id"`
-	ChatbotID   string    `json:"chatbot_id"`
-	UserMessage string    `json:"user_message"`
-	BotMessage  string    `json:"bot_message"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID             int64                 `json:"id"`
+	ChatbotID      string                `json:"chatbot_id"`
+	ParentID       int64                 `json:"parent_id,omitempty"` // 上一轮对话ID，0表示对话起点；同一父节点下的多条记录为不同分支
+	UserMessage    string                `json:"user_message"`
+	BotMessage     string                `json:"bot_message"`
+	PersonaVersion int                   `json:"persona_version,omitempty"` // 生成回复时的人设版本，0表示未知（如导入的对话）
+	Metadata       *ConversationMetadata `json:"metadata,omitempty"`
+	CreatedAt      time.Time             `json:"created_at"`
+}
+
+// ConversationMetadata 对话的附加信息
+type ConversationMetadata struct {
+	Guard     *GuardVerdict   `json:"guard,omitempty"`     // 人设一致性检查结果
+	Retrieval *RetrievalTrace `json:"retrieval,omitempty"` // 知识检索过程
+	Citations []Citation      `json:"citations,omitempty"` // 回复引用的知识
+	RAG       *RAGDecision    `json:"rag,omitempty"`       // 是否检索的判断
+}
+
+// RAGDecision 一轮对话是否检索知识的判断
+type RAGDecision struct {
+	Mode       string `json:"mode"`                 // off, auto, always
+	Classifier string `json:"classifier,omitempty"` // auto时的判断方式
+	Retrieve   bool   `json:"retrieve"`             // 是否检索
+	Used       bool   `json:"used"`                 // 检索到知识并加入了提示词
+	Reason     string `json:"reason,omitempty"`
+}
+
+// GuardVerdict 人设一致性检查结果
+type GuardVerdict struct {
+	Passed     bool     `json:"passed"`
+	Mode       string   `json:"mode"`
+	Attempts   int      `json:"attempts"`             // 生成次数（含首次）
+	Rewritten  bool     `json:"rewritten,omitempty"`  // 回复是否经过改写
+	Violations []string `json:"violations,omitempty"` // 最终回复仍存在的问题
+}
+
+// HistoryQuery 对话历史分页查询条件
+//
+// 未设置After时从最新记录向前翻页（Before为上一页返回的next_cursor）；
+// 设置After或FromOldest时向后翻页。两种方式返回的记录均按时间正序排列。
+type HistoryQuery struct {
+	ChatbotID  string
+	Limit      int
+	Before     int64     // 只返回ID小于Before的记录，0表示不限
+	After      int64     // 只返回ID大于After的记录，0表示不限
+	FromOldest bool      // 从最早的记录开始向后翻页
+	From       time.Time // 创建时间下限（包含），零值表示不限
+	To         time.Time // 创建时间上限（不包含），零值表示不限
+	Search     string    // 在用户消息和回复中搜索的关键词
+}
+
+// Forward 是否向后（按时间正序）翻页
+func (q *HistoryQuery) Forward() bool {
+	return q.After > 0 || q.FromOldest
+}
+
+// Match 判断对话记录是否满足查询条件（不含分页条件）
+func (q *HistoryQuery) Match(conv *Conversation) bool {
+	if q.Before > 0 && conv.ID >= q.Before {
+		return false
+	}
+	if q.After > 0 && conv.ID <= q.After {
+		return false
+	}
+	if !q.From.IsZero() && conv.CreatedAt.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !conv.CreatedAt.Before(q.To) {
+		return false
+	}
+	if q.Search != "" {
+		keyword := strings.ToLower(q.Search)
+		if !strings.Contains(strings.ToLower(conv.UserMessage), keyword) &&
+			!strings.Contains(strings.ToLower(conv.BotMessage), keyword) {
+			return false
+		}
+	}
+	return true
+}
+
+// HistoryPage 对话历史分页结果
+type HistoryPage struct {
+	Conversations []*Conversation `json:"conversations"`
+	NextCursor    int64           `json:"next_cursor,omitempty"` // 继续同方向翻页的游标，为空表示没有更多
+}
+
+// NewHistoryPage 根据多取一条的查询结果构建分页结果
+//
+// convs按查询方向排列（向前翻页为ID降序，向后翻页为ID升序），长度最多为Limit+1。
+func NewHistoryPage(q HistoryQuery, convs []*Conversation) *HistoryPage {
+	if q.Limit <= 0 {
+		return &HistoryPage{Conversations: []*Conversation{}}
+	}
+
+	page := &HistoryPage{Conversations: convs}
+	if len(convs) > q.Limit {
+		page.Conversations = convs[:q.Limit]
+		page.NextCursor = page.Conversations[q.Limit-1].ID
+	}
+
+	// 向前翻页时反转为时间正序
+	if !q.Forward() {
+		list := page.Conversations
+		for i, j := 0, len(list)-1; i < j; i, j = i+1, j-1 {
+			list[i], list[j] = list[j], list[i]
+		}
+	}
+
+	return page
+}
+
+// 对话导出格式
+const (
+	ExportFormatJSONL    = "jsonl"    // 每行一条Conversation
+	ExportFormatMarkdown = "markdown" // 便于阅读的对话记录
+	ExportFormatOpenAI   = "openai"   // OpenAI chat格式微调数据，每行一轮对话并附带系统提示词
+)
+
+// ImportResult 对话导入结果
+type ImportResult struct {
+	Imported int `json:"imported"`
 }
 
 // ChatRequest 聊天请求
 type ChatRequest struct {
-	Message string `json:"message" binding:"required"`
+	Message  string `json:"message" binding:"required"`
+	UserName string `json:"user_name,omitempty"` // 用户名，可在系统提示词模板中引用
+}
+
+// RegenerateRequest 重新生成回复请求
+type RegenerateRequest struct {
+	UserName string `json:"user_name,omitempty"`
 }
 
 // ChatResponse 聊天响应
 type ChatResponse struct {
-	Message   string    `json:"message"`
-	Duration  int64     `json:"duration"` // 毫秒
-	Timestamp time.Time `json:"timestamp"`
+	ConversationID int64        `json:"conversation_id"` // 本轮对话记录ID，可用于编辑或查看分支
+	ParentID       int64        `json:"parent_id,omitempty"`
+	Message        string       `json:"message"`
+	Duration       int64        `json:"duration"`          // 毫秒
+	Sources        []Citation   `json:"sources,omitempty"` // 回复引用的知识，按在回复中首次出现的顺序
+	RetrievalUsed  bool         `json:"retrieval_used"`    // 是否检索到知识并加入了提示词
+	RAG            *RAGDecision `json:"rag,omitempty"`     // 是否检索的判断，未启用RAG时为空
+	Timestamp      time.Time    `json:"timestamp"`
 }
 
 // ErrorResponse 错误响应
@@ -0,0 +1,194 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 反馈评分
+const (
+	RatingUp   = 1  // 点赞
+	RatingDown = -1 // 点踩
+)
+
+// Feedback 对一轮回复的反馈，每轮对话最多一条，重复提交时覆盖
+type Feedback struct {
+	ConversationID int64     `json:"conversation_id"`
+	ChatbotID      string    `json:"chatbot_id"`
+	Rating         int       `json:"rating"` // 1点赞，-1点踩
+	Tags           []string  `json:"tags,omitempty"`
+	Comment        string    `json:"comment,omitempty"`
+	CreatedAt      time.Time `json:"created_at"` // 首次提交时间
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// FeedbackRequest 提交反馈请求
+type FeedbackRequest struct {
+	Rating  int      `json:"rating"`
+	Tags    []string `json:"tags,omitempty"`
+	Comment string   `json:"comment,omitempty"`
+}
+
+// 反馈内容长度限制
+const (
+	maxFeedbackTags    = 20
+	maxFeedbackTagLen  = 64
+	maxFeedbackComment = 2000
+)
+
+// Normalize 校验反馈请求，并去除标签的首尾空白和重复项
+func (r *FeedbackRequest) Normalize() error {
+	if r.Rating != RatingUp && r.Rating != RatingDown {
+		return fmt.Errorf("rating must be %d or %d, got %d", RatingUp, RatingDown, r.Rating)
+	}
+	if len([]rune(r.Comment)) > maxFeedbackComment {
+		return fmt.Errorf("comment exceeds %d characters", maxFeedbackComment)
+	}
+
+	seen := make(map[string]bool, len(r.Tags))
+	tags := make([]string, 0, len(r.Tags))
+	for _, tag := range r.Tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if len([]rune(tag)) > maxFeedbackTagLen {
+			return fmt.Errorf("tag %q exceeds %d characters", tag, maxFeedbackTagLen)
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	if len(tags) > maxFeedbackTags {
+		return fmt.Errorf("at most %d tags allowed", maxFeedbackTags)
+	}
+	r.Tags = tags
+
+	return nil
+}
+
+// FeedbackQuery 反馈查询条件
+type FeedbackQuery struct {
+	ChatbotID string
+	From      time.Time // 首次提交时间下限（包含），零值表示不限
+	To        time.Time // 首次提交时间上限（不包含），零值表示不限
+}
+
+// Match 判断反馈是否满足查询条件
+func (q *FeedbackQuery) Match(f *Feedback) bool {
+	if f.ChatbotID != q.ChatbotID {
+		return false
+	}
+	if !q.From.IsZero() && f.CreatedAt.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !f.CreatedAt.Before(q.To) {
+		return false
+	}
+	return true
+}
+
+// 满意度统计的时间粒度
+const (
+	StatsIntervalDay   = "day"
+	StatsIntervalWeek  = "week"
+	StatsIntervalMonth = "month"
+)
+
+// FeedbackStats 聊天机器人的满意度统计
+type FeedbackStats struct {
+	ChatbotID string `json:"chatbot_id"`
+	Interval  string `json:"interval"`
+	FeedbackCounts
+	Tags    map[string]int   `json:"tags"`    // 各标签出现次数
+	Buckets []FeedbackBucket `json:"buckets"` // 按时间正序，只包含有反馈的时间段
+}
+
+// FeedbackBucket 一个时间段内的反馈统计
+type FeedbackBucket struct {
+	Start time.Time `json:"start"`
+	FeedbackCounts
+}
+
+// FeedbackCounts 反馈计数
+type FeedbackCounts struct {
+	Total        int     `json:"total"`
+	Up           int     `json:"up"`
+	Down         int     `json:"down"`
+	Satisfaction float64 `json:"satisfaction"` // 点赞占比，没有反馈时为0
+}
+
+// add 计入一条反馈
+func (c *FeedbackCounts) add(f *Feedback) {
+	c.Total++
+	if f.Rating == RatingUp {
+		c.Up++
+	} else {
+		c.Down++
+	}
+	c.Satisfaction = float64(c.Up) / float64(c.Total)
+}
+
+// ValidStatsInterval 判断统计粒度是否合法
+func ValidStatsInterval(interval string) bool {
+	switch interval {
+	case StatsIntervalDay, StatsIntervalWeek, StatsIntervalMonth:
+		return true
+	}
+	return false
+}
+
+// NewFeedbackStats 按时间粒度汇总反馈，时间段按本地时区划分，周从周一开始
+func NewFeedbackStats(chatbotID, interval string, feedback []*Feedback) *FeedbackStats {
+	stats := &FeedbackStats{
+		ChatbotID: chatbotID,
+		Interval:  interval,
+		Tags:      make(map[string]int),
+		Buckets:   []FeedbackBucket{},
+	}
+
+	buckets := make(map[time.Time]*FeedbackBucket)
+	for _, f := range feedback {
+		stats.add(f)
+		for _, tag := range f.Tags {
+			stats.Tags[tag]++
+		}
+
+		start := bucketStart(f.CreatedAt, interval)
+		bucket, ok := buckets[start]
+		if !ok {
+			bucket = &FeedbackBucket{Start: start}
+			buckets[start] = bucket
+		}
+		bucket.add(f)
+	}
+
+	for _, bucket := range buckets {
+		stats.Buckets = append(stats.Buckets, *bucket)
+	}
+	sort.Slice(stats.Buckets, func(i, j int) bool { return stats.Buckets[i].Start.Before(stats.Buckets[j].Start) })
+
+	return stats
+}
+
+// bucketStart 返回时间所在时间段的起点
+func bucketStart(t time.Time, interval string) time.Time {
+	t = t.Local()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+	switch interval {
+	case StatsIntervalWeek:
+		offset := (int(day.Weekday()) + 6) % 7 // 周一为0
+		return day.AddDate(0, 0, -offset)
+	case StatsIntervalMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.Local)
+	default:
+		return day
+	}
+}
+
+// 偏好数据集导出格式
+const (
+	PreferenceFormatDPO = "dpo" // 成对偏好：同一轮对话的重新生成中，点赞的回复为chosen，点踩或被重新生成替换的回复为rejected
+	PreferenceFormatKTO = "kto" // 非成对偏好：每条有评分的回复一行，点赞为true
+)
@@ -0,0 +1,272 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// DefaultKnowledgeBase 默认知识库：未指定知识库时写入该库，未绑定知识库的聊天机器人只检索该库
+const DefaultKnowledgeBase = "default"
+
+// KnowledgeBase 命名知识库，聊天机器人通过检索设置绑定一个或多个知识库
+type KnowledgeBase struct {
+	Name        string    `json:"name"` // 唯一名称：小写字母、数字和连字符
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// CreateKnowledgeBaseRequest 创建知识库请求
+type CreateKnowledgeBaseRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+}
+
+// ValidateKnowledgeBaseName 校验知识库名称，规则与人设包slug相同
+func ValidateKnowledgeBaseName(name string) error {
+	if !slugPattern.MatchString(name) {
+		return fmt.Errorf("invalid knowledge base name %q: use lowercase letters, digits and hyphens", name)
+	}
+	return nil
+}
+
+// 文档导入任务状态
+const (
+	IngestStatusPending   = "pending"   // 等待处理
+	IngestStatusRunning   = "running"   // 正在解析、分块或嵌入
+	IngestStatusCompleted = "completed" // 全部分块已写入知识库
+	IngestStatusFailed    = "failed"    // 失败，已写入的分块已从知识库删除
+)
+
+// ChunkOptions 文档分块参数，未设置的字段使用配置的默认值
+type ChunkOptions struct {
+	Mode         string `json:"mode,omitempty" form:"mode"` // tokens, headings
+	ChunkSize    int    `json:"chunk_size,omitempty" form:"chunk_size"`
+	ChunkOverlap *int   `json:"chunk_overlap,omitempty" form:"chunk_overlap"`
+}
+
+// IngestJob 文档导入任务，文档解析和嵌入在后台执行，通过任务查询进度
+type IngestJob struct {
+	ID             string       `json:"id"`
+	KnowledgeBase  string       `json:"knowledge_base"`
+	Filename       string       `json:"filename"`
+	Status         string       `json:"status"`
+	Chunking       ChunkOptions `json:"chunking"`        // 实际使用的分块参数
+	TotalChunks    int          `json:"total_chunks"`    // 分块完成前为0
+	EmbeddedChunks int          `json:"embedded_chunks"` // 已嵌入并写入知识库的分块数
+	Error          string       `json:"error,omitempty"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+	FinishedAt     *time.Time   `json:"finished_at,omitempty"`
+}
+
+// Done 任务是否已结束
+func (j *IngestJob) Done() bool {
+	return j.Status == IngestStatusCompleted || j.Status == IngestStatusFailed
+}
+
+// KnowledgeRequest 添加单条知识请求，内容较长时会按配置分块
+type KnowledgeRequest struct {
+	KnowledgeBase string `json:"knowledge_base"` // 为空时写入默认知识库
+	Content       string `json:"content" binding:"required"`
+}
+
+// KnowledgeChunk 知识分块，保存在knowledge_base表中，是向量库的数据来源
+//
+// 重建索引时从表中读取分块重新生成向量，向量库中的主键与ID相同。
+type KnowledgeChunk struct {
+	ID            int64           `json:"id"`
+	KnowledgeBase string          `json:"knowledge_base"`
+	DocumentID    string          `json:"document_id,omitempty"` // 所属文档，同一次添加或导入的分块相同
+	Content       string          `json:"content"`
+	Source        string          `json:"source,omitempty"`   // 来源，如上传的文件名
+	Category      string          `json:"category,omitempty"` // 分类（可选）
+	Metadata      json.RawMessage `json:"metadata,omitempty"` // 分块元数据（JSON对象），与向量库中的一致
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
+
+// KnowledgeQuery 知识分块分页查询条件，结果按ID升序
+type KnowledgeQuery struct {
+	KnowledgeBase string // 为空表示不限
+	DocumentID    string // 为空表示不限
+	Source        string // 为空表示不限
+	Offset        int
+	Limit         int
+}
+
+// Match 判断分块是否满足查询条件（不含分页条件）
+func (q *KnowledgeQuery) Match(chunk *KnowledgeChunk) bool {
+	return (q.KnowledgeBase == "" || chunk.KnowledgeBase == q.KnowledgeBase) &&
+		(q.DocumentID == "" || chunk.DocumentID == q.DocumentID) &&
+		(q.Source == "" || chunk.Source == q.Source)
+}
+
+// KnowledgePage 知识分块分页结果
+type KnowledgePage struct {
+	Items  []*KnowledgeChunk `json:"items"`
+	Total  int               `json:"total"`
+	Offset int               `json:"offset"`
+	Limit  int               `json:"limit"`
+}
+
+// NewKnowledgePage 在内存中过滤并分页，all需按ID升序，适用于无法在存储端查询的实现
+func NewKnowledgePage(q KnowledgeQuery, all []*KnowledgeChunk) *KnowledgePage {
+	matched := make([]*KnowledgeChunk, 0, len(all))
+	for _, chunk := range all {
+		if q.Match(chunk) {
+			matched = append(matched, chunk)
+		}
+	}
+
+	page := &KnowledgePage{Items: []*KnowledgeChunk{}, Total: len(matched), Offset: q.Offset, Limit: q.Limit}
+	if q.Offset < len(matched) && q.Limit > 0 {
+		end := min(q.Offset+q.Limit, len(matched))
+		page.Items = matched[q.Offset:end]
+	}
+	return page
+}
+
+// UpdateKnowledgeRequest 修改知识分块请求，未设置的字段保持不变；修改后重新生成嵌入向量
+type UpdateKnowledgeRequest struct {
+	Content  *string `json:"content"`
+	Source   *string `json:"source"`
+	Category *string `json:"category"`
+}
+
+// 重建索引任务状态
+const (
+	ReindexStatusPending   = "pending"   // 等待执行
+	ReindexStatusRunning   = "running"   // 正在嵌入分块
+	ReindexStatusPaused    = "paused"    // 已暂停，可以恢复
+	ReindexStatusCompleted = "completed" // 已切换到新集合
+	ReindexStatusFailed    = "failed"    // 失败，可以恢复；原集合不受影响
+)
+
+// ReindexJob 重建索引任务：把knowledge_base表中的全部分块嵌入到新集合，完成后切换别名
+//
+// 任务状态保存在存储中，服务重启后继续执行未结束的任务。
+type ReindexJob struct {
+	ID             string     `json:"id"`
+	Status         string     `json:"status"`
+	Collection     string     `json:"collection"`      // 写入的新集合
+	EmbeddingModel string     `json:"embedding_model"` // 新集合使用的嵌入模型，即创建任务时的rag.embedding_model
+	EmbeddingDim   int        `json:"embedding_dim"`
+	TotalChunks    int        `json:"total_chunks"`    // 任务创建时的分块数，执行中新增的分块也会写入新集合
+	EmbeddedChunks int        `json:"embedded_chunks"` // 已写入新集合的分块数
+	LastChunkID    int64      `json:"last_chunk_id"`   // 已写入新集合的最大分块ID，恢复时从其后继续
+	Error          string     `json:"error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	FinishedAt     *time.Time `json:"finished_at,omitempty"`
+}
+
+// Active 任务是否尚未结束（等待、执行中或已暂停）
+func (j *ReindexJob) Active() bool {
+	switch j.Status {
+	case ReindexStatusPending, ReindexStatusRunning, ReindexStatusPaused:
+		return true
+	}
+	return false
+}
+
+// 知识检索方式
+const (
+	SearchModeHybrid  = "hybrid"  // 向量检索和关键词检索的结果按RRF融合
+	SearchModeVector  = "vector"  // 只用向量检索
+	SearchModeKeyword = "keyword" // 只用BM25关键词检索
+)
+
+// ValidateSearchMode 校验检索方式，空字符串表示使用rag.retrieval.mode
+func ValidateSearchMode(mode string) error {
+	switch mode {
+	case "", SearchModeHybrid, SearchModeVector, SearchModeKeyword:
+		return nil
+	}
+	return fmt.Errorf("invalid retrieval mode %q (want hybrid, vector or keyword)", mode)
+}
+
+// SearchOptions 知识检索参数
+type SearchOptions struct {
+	KnowledgeBases []string // 为空时检索默认知识库
+	TopK           int
+	Mode           string   // hybrid, vector, keyword；为空时使用rag.retrieval.mode
+	MaxDistance    *float64 // 向量检索结果的最大距离；为空时使用rag.retrieval.max_distance
+	// MinKeywordScore 关键词检索结果的最低BM25得分；为空时使用rag.retrieval.min_keyword_score
+	MinKeywordScore *float64
+	Variants        []string // 问题的其他问法，分别检索后与问题的结果按RRF合并
+}
+
+// KnowledgeHit 一条知识检索结果
+type KnowledgeHit struct {
+	ID            int64           `json:"id"` // 分块ID；早期版本创建的集合中为向量库分配的主键
+	KnowledgeBase string          `json:"knowledge_base"`
+	Content       string          `json:"content"`
+	Source        string          `json:"source,omitempty"`
+	Metadata      json.RawMessage `json:"metadata,omitempty"`
+	Score         float64         `json:"score"`                   // 融合得分，越大越相关
+	VectorRank    int             `json:"vector_rank,omitempty"`   // 在向量检索结果中的名次，从1开始；未命中为0
+	Distance      *float32        `json:"distance,omitempty"`      // 与查询向量的距离，向量检索未命中时为空
+	KeywordRank   int             `json:"keyword_rank,omitempty"`  // 在关键词检索结果中的名次，从1开始；未命中为0
+	KeywordScore  float64         `json:"keyword_score,omitempty"` // BM25得分
+	RerankScore   *float64        `json:"rerank_score,omitempty"`  // 重排序得分，未重排序时为空
+}
+
+// SearchResult 知识检索结果和检索过程
+type SearchResult struct {
+	Hits  []*KnowledgeHit `json:"hits"`
+	Trace *RetrievalTrace `json:"trace"`
+}
+
+// RetrievalTrace 一次知识检索的过程，由检索接口返回，对话时保存在对话记录的附加信息中
+type RetrievalTrace struct {
+	Query           string        `json:"query"`
+	Mode            string        `json:"mode"` // 实际使用的检索方式
+	KnowledgeBases  []string      `json:"knowledge_bases"`
+	MaxDistance     *float64      `json:"max_distance,omitempty"`      // 向量检索结果的最大距离
+	MinKeywordScore *float64      `json:"min_keyword_score,omitempty"` // 关键词检索结果的最低BM25得分
+	Filtered        int           `json:"filtered,omitempty"`          // 融合后未通过相关性阈值被丢弃的结果数
+	Candidates      int           `json:"candidates"`                  // 融合后的候选数
+	SearchMS        int64         `json:"search_ms"`                   // 检索耗时（毫秒），含生成查询向量
+	Rewrite         *RewriteTrace `json:"rewrite,omitempty"`           // 对话时检索前改写问题的过程，未改写时为空
+	Variants        []string      `json:"variants,omitempty"`          // 一并检索的其他问法
+	Rerank          *RerankTrace  `json:"rerank,omitempty"`            // 未启用重排序时为空
+	Results         []int64       `json:"results"`                     // 最终使用的分块ID，按相关度从高到低
+}
+
+// RewriteTrace 检索前把问题改写为独立问题的过程
+type RewriteTrace struct {
+	Original  string   `json:"original"`           // 用户的原始消息
+	Query     string   `json:"query"`              // 改写后的独立问题，失败时为原始消息
+	Variants  []string `json:"variants,omitempty"` // 多查询时生成的其他问法
+	History   int      `json:"history"`            // 参考的对话轮数
+	LatencyMS int64    `json:"latency_ms"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// RerankTrace 重排序过程
+type RerankTrace struct {
+	Provider  string        `json:"provider"`
+	Model     string        `json:"model,omitempty"`
+	LatencyMS int64         `json:"latency_ms"`
+	Threshold *float64      `json:"threshold,omitempty"`
+	Scores    []RerankScore `json:"scores,omitempty"` // 全部候选的得分，按得分从高到低
+	Error     string        `json:"error,omitempty"`  // 重排序失败时保留融合后的顺序
+}
+
+// RerankScore 一个候选的重排序得分
+type RerankScore struct {
+	ID    int64   `json:"id"`
+	Score float64 `json:"score"`
+	Kept  bool    `json:"kept"` // 是否在前top_k条内且不低于阈值
+}
+
+// Citation 回复引用的一段知识
+type Citation struct {
+	Index         int    `json:"index"` // 提示词中的资料编号，即回复中的[n]
+	ChunkID       int64  `json:"chunk_id"`
+	KnowledgeBase string `json:"knowledge_base"`
+	Title         string `json:"title,omitempty"` // 文档标题，直接添加的文本没有标题
+	Source        string `json:"source,omitempty"`
+	Snippet       string `json:"snippet"` // 分块内容的开头部分
+}
@@ -0,0 +1,100 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// slugPattern 合法的slug：小写字母、数字和连字符
+var slugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,63}$`)
+
+// 人设包导出格式
+const (
+	PersonaFormatYAML = "yaml"
+	PersonaFormatJSON = "json"
+)
+
+// PersonaBundle 人设包，可用YAML或JSON描述，便于在git中维护
+type PersonaBundle struct {
+	Slug           string              `json:"slug" yaml:"slug"`
+	Name           string              `json:"name" yaml:"name"`
+	Personality    string              `json:"personality" yaml:"personality"`
+	Background     string              `json:"background" yaml:"background"`
+	PromptTemplate string              `json:"prompt_template,omitempty" yaml:"prompt_template,omitempty"`
+	PromptFormat   string              `json:"prompt_format,omitempty" yaml:"prompt_format,omitempty"`
+	Options        GenerationOptions   `json:"options" yaml:"options,omitempty"`
+	Knowledge      []KnowledgeDocument `json:"knowledge,omitempty" yaml:"knowledge,omitempty"`
+}
+
+// KnowledgeDocument 人设包附带的知识文档
+type KnowledgeDocument struct {
+	Title   string `json:"title,omitempty" yaml:"title,omitempty"`
+	Source  string `json:"source,omitempty" yaml:"source,omitempty"`
+	Content string `json:"content" yaml:"content"`
+}
+
+// Normalize 补全slug并校验人设包
+func (b *PersonaBundle) Normalize() error {
+	if strings.TrimSpace(b.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if b.Slug == "" {
+		b.Slug = Slugify(b.Name)
+	}
+	if !slugPattern.MatchString(b.Slug) {
+		return fmt.Errorf("invalid slug %q: use lowercase letters, digits and hyphens", b.Slug)
+	}
+	if err := b.Options.Validate(); err != nil {
+		return err
+	}
+	titles := make(map[string]bool, len(b.Knowledge))
+	for i, doc := range b.Knowledge {
+		if strings.TrimSpace(doc.Content) == "" {
+			return fmt.Errorf("knowledge[%d]: content is required", i)
+		}
+		// 标题用于在重复导入时识别同一文档
+		if doc.Title != "" && titles[doc.Title] {
+			return fmt.Errorf("knowledge[%d]: duplicate title %q", i, doc.Title)
+		}
+		titles[doc.Title] = true
+	}
+	return nil
+}
+
+// PersonaImportResult 人设包导入结果
+type PersonaImportResult struct {
+	Chatbot   *Chatbot `json:"chatbot"`
+	Created   bool     `json:"created"`   // 新建还是更新
+	Knowledge int      `json:"knowledge"` // 新写入或替换的知识文档数，未变化的文档不计
+}
+
+// Slugify 由名称生成slug，非ASCII字母数字的字符会被丢弃
+func Slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+			dash = false
+		case b.Len() > 0 && !dash:
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// BundleFromChatbot 将聊天机器人导出为人设包（不含知识文档）
+func BundleFromChatbot(chatbot *Chatbot) *PersonaBundle {
+	return &PersonaBundle{
+		Slug:           chatbot.Slug,
+		Name:           chatbot.Name,
+		Personality:    chatbot.Personality,
+		Background:     chatbot.Background,
+		PromptTemplate: chatbot.PromptTemplate,
+		PromptFormat:   chatbot.PromptFormat,
+		Options:        chatbot.Options,
+	}
+}
@@ -0,0 +1,203 @@
+package model
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// PersonaVersion 人设版本，保存后不可修改
+type PersonaVersion struct {
+	ChatbotID      string             `json:"chatbot_id"`
+	Version        int                `json:"version"` // 从1开始，由存储分配
+	Personality    string             `json:"personality"`
+	Background     string             `json:"background"`
+	PromptTemplate string             `json:"prompt_template,omitempty"`
+	PromptFormat   string             `json:"prompt_format,omitempty"`
+	SystemPrompt   string             `json:"system_prompt"`
+	Options        *GenerationOptions `json:"options,omitempty"` // 生成参数，早于记录生成参数的版本为nil
+	Comment        string             `json:"comment,omitempty"` // 版本说明，如 "rollback to v2"
+	CreatedAt      time.Time          `json:"created_at"`
+}
+
+// NewPersonaVersion 由聊天机器人当前的人设生成新版本（版本号待存储分配）
+func NewPersonaVersion(chatbot *Chatbot, comment string) *PersonaVersion {
+	options := cloneOptions(chatbot.Options)
+	return &PersonaVersion{
+		ChatbotID:      chatbot.ID,
+		Personality:    chatbot.Personality,
+		Background:     chatbot.Background,
+		PromptTemplate: chatbot.PromptTemplate,
+		PromptFormat:   chatbot.PromptFormat,
+		SystemPrompt:   chatbot.SystemPrompt,
+		Options:        &options,
+		Comment:        comment,
+		CreatedAt:      time.Now(),
+	}
+}
+
+// SamePersona 判断聊天机器人的人设是否与该版本一致
+func (v *PersonaVersion) SamePersona(chatbot *Chatbot) bool {
+	return v.Personality == chatbot.Personality &&
+		v.Background == chatbot.Background &&
+		v.PromptTemplate == chatbot.PromptTemplate &&
+		v.PromptFormat == chatbot.PromptFormat &&
+		v.SystemPrompt == chatbot.SystemPrompt &&
+		v.Options != nil && optionsJSON(*v.Options) == optionsJSON(chatbot.Options)
+}
+
+// cloneOptions 深拷贝生成参数，版本不与聊天机器人共享指针字段
+func cloneOptions(o GenerationOptions) GenerationOptions {
+	var clone GenerationOptions
+	if data, err := json.Marshal(o); err == nil && json.Unmarshal(data, &clone) == nil {
+		return clone
+	}
+	return o
+}
+
+// optionsJSON 生成参数的JSON文本，用于比较和按行展示差异
+func optionsJSON(o GenerationOptions) string {
+	data, err := json.MarshalIndent(o, "", "  ")
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// PersonaDiff 两个人设版本的差异
+type PersonaDiff struct {
+	ChatbotID string             `json:"chatbot_id"`
+	From      int                `json:"from"`
+	To        int                `json:"to"`
+	Changes   []PersonaFieldDiff `json:"changes"`
+}
+
+// PersonaFieldDiff 单个字段的差异
+type PersonaFieldDiff struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Diff  string `json:"diff"` // 按行比较的统一格式差异，"-"为删除行，"+"为新增行
+}
+
+// DiffPersonaVersions 比较两个人设版本，只返回有变化的字段
+func DiffPersonaVersions(from, to *PersonaVersion) *PersonaDiff {
+	diff := &PersonaDiff{
+		ChatbotID: to.ChatbotID,
+		From:      from.Version,
+		To:        to.Version,
+		Changes:   []PersonaFieldDiff{},
+	}
+
+	fields := []struct {
+		name     string
+		from, to string
+	}{
+		{"personality", from.Personality, to.Personality},
+		{"background", from.Background, to.Background},
+		{"prompt_template", from.PromptTemplate, to.PromptTemplate},
+		{"prompt_format", from.PromptFormat, to.PromptFormat},
+		{"system_prompt", from.SystemPrompt, to.SystemPrompt},
+	}
+	// 没有记录生成参数的旧版本不参与比较
+	if from.Options != nil && to.Options != nil {
+		fields = append(fields, struct {
+			name     string
+			from, to string
+		}{"options", optionsJSON(*from.Options), optionsJSON(*to.Options)})
+	}
+	for _, f := range fields {
+		if f.from == f.to {
+			continue
+		}
+		diff.Changes = append(diff.Changes, PersonaFieldDiff{
+			Field: f.name,
+			From:  f.from,
+			To:    f.to,
+			Diff:  diffLines(f.from, f.to),
+		})
+	}
+
+	return diff
+}
+
+// maxDiffCells 最长公共子序列矩阵的最大单元数，超出时整段按删除和新增展示
+const maxDiffCells = 1 << 20
+
+// diffLines 基于最长公共子序列按行比较两段文本
+//
+// 相同的首尾行先剔除，剩余部分过大时不再计算最长公共子序列，避免占用过多内存。
+func diffLines(a, b string) string {
+	x, y := splitLines(a), splitLines(b)
+
+	var out strings.Builder
+	prefix := 0
+	for prefix < len(x) && prefix < len(y) && x[prefix] == y[prefix] {
+		out.WriteString("  " + x[prefix] + "\n")
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(x)-prefix && suffix < len(y)-prefix && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+		suffix++
+	}
+	common := x[len(x)-suffix:]
+	x, y = x[prefix:len(x)-suffix], y[prefix:len(y)-suffix]
+
+	if (len(x)+1)*(len(y)+1) > maxDiffCells {
+		for _, line := range x {
+			out.WriteString("- " + line + "\n")
+		}
+		for _, line := range y {
+			out.WriteString("+ " + line + "\n")
+		}
+	} else {
+		writeLCSDiff(&out, x, y)
+	}
+
+	for _, line := range common {
+		out.WriteString("  " + line + "\n")
+	}
+	return out.String()
+}
+
+// writeLCSDiff 按最长公共子序列写出x到y的逐行差异
+func writeLCSDiff(out *strings.Builder, x, y []string) {
+	// lcs[i][j] 为 x[i:] 与 y[j:] 的最长公共子序列长度
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			out.WriteString("  " + x[i] + "\n")
+			i++
+			j++
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			out.WriteString("- " + x[i] + "\n")
+			i++
+		default:
+			out.WriteString("+ " + y[j] + "\n")
+			j++
+		}
+	}
+}
+
+// splitLines 按行拆分文本，空文本返回空切片
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
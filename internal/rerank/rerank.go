@@ -0,0 +1,148 @@
+// Package rerank 为检索到的候选知识重新打分：调用交叉编码器的重排序接口，或让对话模型充当评审。
+package rerank
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"eino/internal/llmjson"
+
+	einomodel "github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// Reranker 按与query的相关度为documents打分，返回与documents一一对应的得分，越大越相关
+type Reranker interface {
+	Rerank(ctx context.Context, query string, documents []string) ([]float64, error)
+}
+
+// APIReranker 调用重排序接口，请求和响应格式与Jina、Cohere兼容，
+// llama.cpp（--reranking）、vLLM、Xinference等部署的交叉编码器都提供该接口
+type APIReranker struct {
+	url    string
+	apiKey string
+	model  string
+	client *http.Client
+}
+
+// NewAPIReranker 创建重排序接口客户端，url为完整地址，如 http://localhost:8081/v1/rerank
+func NewAPIReranker(url, apiKey, model string, timeout time.Duration) *APIReranker {
+	return &APIReranker{url: url, apiKey: apiKey, model: model, client: &http.Client{Timeout: timeout}}
+}
+
+// Rerank 一次请求为全部文档打分，得分为接口返回的relevance_score
+func (r *APIReranker) Rerank(ctx context.Context, query string, documents []string) ([]float64, error) {
+	jsonData, err := json.Marshal(map[string]any{
+		"model":     r.model,
+		"query":     query,
+		"documents": documents,
+		"top_n":     len(documents),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+r.apiKey)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("rerank API error (%d): %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Results []struct {
+			Index          int     `json:"index"`
+			RelevanceScore float64 `json:"relevance_score"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	scores := make([]float64, len(documents))
+	scored := make([]bool, len(documents))
+	for _, item := range result.Results {
+		if item.Index < 0 || item.Index >= len(documents) {
+			return nil, fmt.Errorf("rerank API returned index %d for %d documents", item.Index, len(documents))
+		}
+		scores[item.Index] = item.RelevanceScore
+		scored[item.Index] = true
+	}
+	for i, ok := range scored {
+		if !ok {
+			return nil, fmt.Errorf("rerank API returned no score for document %d", i)
+		}
+	}
+	return scores, nil
+}
+
+// judgePrompt 对话模型评审使用的系统提示词
+const judgePrompt = `你是检索结果的相关性评审。判断每段资料对回答用户问题的帮助程度，按0到10打分：10表示直接回答了问题，0表示完全无关。
+只输出JSON，不要输出其他内容：{"scores": [按资料编号顺序的得分]}`
+
+// maxJudgeDocumentRunes 交给对话模型评审时每段资料保留的字符数
+const maxJudgeDocumentRunes = 1000
+
+// LLMReranker 让对话模型一次为全部资料打分，得分换算到0~1
+type LLMReranker struct {
+	model einomodel.BaseChatModel
+}
+
+// NewLLMReranker 创建对话模型评审
+func NewLLMReranker(chatModel einomodel.BaseChatModel) *LLMReranker {
+	return &LLMReranker{model: chatModel}
+}
+
+// Rerank 把编号的资料和问题交给模型，解析返回的得分
+func (r *LLMReranker) Rerank(ctx context.Context, query string, documents []string) ([]float64, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "问题：\n%s\n\n资料：", query)
+	for i, doc := range documents {
+		if runes := []rune(doc); len(runes) > maxJudgeDocumentRunes {
+			doc = string(runes[:maxJudgeDocumentRunes]) + "…"
+		}
+		fmt.Fprintf(&b, "\n\n[%d] %s", i+1, doc)
+	}
+
+	response, err := r.model.Generate(ctx, []*schema.Message{
+		schema.SystemMessage(judgePrompt),
+		schema.UserMessage(b.String()),
+	}, einomodel.WithTemperature(0))
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Scores []float64 `json:"scores"`
+	}
+	if err := llmjson.Unmarshal(response.Content, &result); err != nil {
+		return nil, fmt.Errorf("parse judge scores %q: %w", response.Content, err)
+	}
+	if len(result.Scores) != len(documents) {
+		return nil, fmt.Errorf("judge returned %d scores for %d documents", len(result.Scores), len(documents))
+	}
+	scores := make([]float64, len(result.Scores))
+	for i, score := range result.Scores {
+		scores[i] = min(max(score, 0), 10) / 10
+	}
+	return scores, nil
+}
@@ -0,0 +1,103 @@
+package rerank_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"eino/internal/rerank"
+
+	einomodel "github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// cannedModel 总是返回同一段内容的对话模型
+type cannedModel string
+
+func (m cannedModel) Generate(context.Context, []*schema.Message, ...einomodel.Option) (*schema.Message, error) {
+	return schema.AssistantMessage(string(m), nil), nil
+}
+
+func (m cannedModel) Stream(context.Context, []*schema.Message, ...einomodel.Option) (*schema.StreamReader[*schema.Message], error) {
+	return schema.StreamReaderFromArray([]*schema.Message{schema.AssistantMessage(string(m), nil)}), nil
+}
+
+var documents = []string{"退货政策", "年假规定", "路由器说明"}
+
+func TestLLMReranker(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []float64
+		wantErr bool
+	}{
+		{"Plain", `{"scores": [10, 0, 5]}`, []float64{1, 0, 0.5}, false},
+		{"CodeFence", "```json\n{\"scores\": [8, 2, 6]}\n```", []float64{0.8, 0.2, 0.6}, false},
+		{"Clamped", `{"scores": [12, -3, 7]}`, []float64{1, 0, 0.7}, false},
+		{"TooFew", `{"scores": [10, 0]}`, nil, true},
+		{"TooMany", `{"scores": [10, 0, 5, 1]}`, nil, true},
+		{"NotJSON", "第一段最相关", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scores, err := rerank.NewLLMReranker(cannedModel(tt.content)).Rerank(context.Background(), "怎么退货", documents)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Rerank = %v, want error", scores)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Rerank: %v", err)
+			}
+			if !slices.Equal(scores, tt.want) {
+				t.Fatalf("Rerank = %v, want %v", scores, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPIReranker(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		want    []float64
+		wantErr bool
+	}{
+		{"Sorted", http.StatusOK, `{"results": [{"index": 2, "relevance_score": 0.9}, {"index": 0, "relevance_score": 0.4}, {"index": 1, "relevance_score": 0.1}]}`, []float64{0.4, 0.1, 0.9}, false},
+		{"MissingScore", http.StatusOK, `{"results": [{"index": 0, "relevance_score": 0.4}, {"index": 1, "relevance_score": 0.1}]}`, nil, true},
+		{"BadIndex", http.StatusOK, `{"results": [{"index": 3, "relevance_score": 0.4}]}`, nil, true},
+		{"ErrorStatus", http.StatusBadRequest, `{"error": "model not found"}`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if got := r.Header.Get("Authorization"); got != "Bearer key" {
+					t.Errorf("Authorization = %q", got)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			r := rerank.NewAPIReranker(server.URL+"/v1/rerank", "key", "test", time.Second)
+			scores, err := r.Rerank(context.Background(), "怎么退货", documents)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Rerank = %v, want error", scores)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Rerank: %v", err)
+			}
+			if !slices.Equal(scores, tt.want) {
+				t.Fatalf("Rerank = %v, want %v", scores, tt.want)
+			}
+		})
+	}
+}
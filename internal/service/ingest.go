@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"eino/internal/document"
+	"eino/internal/model"
+	"eino/internal/storage"
+	"eino/internal/storage/milvus"
+
+	einodoc "github.com/cloudwego/eino/components/document"
+	"github.com/cloudwego/eino/components/document/parser"
+	"github.com/google/uuid"
+)
+
+// 文档导入限制
+const (
+	maxConcurrentIngests = 2   // 同时执行的导入任务数
+	maxIngestJobs        = 100 // 保留的任务数，超过时丢弃最早结束的任务
+)
+
+// IngestDocument 创建导入到知识库kb的文档导入任务
+//
+// 同步读取r保存为临时文件后立即返回任务，解析、分块、嵌入和写入知识库在后台执行。
+// 任务ID同时作为文档ID，可以按文档ID列出或删除导入的分块。
+// 支持纯文本、Markdown和HTML，按filename的扩展名识别格式。
+func (s *RAGService) IngestDocument(ctx context.Context, kb, filename string, r io.Reader, opts model.ChunkOptions) (*model.IngestJob, error) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if !document.SupportedExt(ext) {
+		return nil, fmt.Errorf("%w: unsupported file type %q", document.ErrInvalidDocument, ext)
+	}
+
+	config, err := s.splitterConfig(opts)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", document.ErrInvalidDocument, err)
+	}
+
+	// 临时文件保留扩展名，加载器按扩展名选择解析器
+	f, err := os.CreateTemp("", "eino-ingest-*"+ext)
+	if err != nil {
+		return nil, fmt.Errorf("create temp file: %w", err)
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, fmt.Errorf("save document: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return nil, fmt.Errorf("save document: %w", err)
+	}
+
+	now := time.Now()
+	job := &model.IngestJob{
+		ID:            uuid.New().String(),
+		KnowledgeBase: kb,
+		Filename:      filepath.Base(filename),
+		Status:        model.IngestStatusPending,
+		Chunking:      chunkOptions(config),
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	s.addJob(job)
+
+	s.ingestWG.Add(1)
+	go func() {
+		defer s.ingestWG.Done()
+		s.runIngest(s.ingestCtx, job.ID, f.Name(), config)
+	}()
+
+	return s.GetIngestJob(job.ID)
+}
+
+// GetIngestJob 获取导入任务的当前状态
+func (s *RAGService) GetIngestJob(id string) (*model.IngestJob, error) {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, fmt.Errorf("ingest job %s: %w", id, storage.ErrNotFound)
+	}
+	copied := *job
+	return &copied, nil
+}
+
+// ListIngestJobs 列出导入任务，最新的在前
+func (s *RAGService) ListIngestJobs() []*model.IngestJob {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+
+	jobs := make([]*model.IngestJob, 0, len(s.jobOrder))
+	for i := len(s.jobOrder) - 1; i >= 0; i-- {
+		copied := *s.jobs[s.jobOrder[i]]
+		jobs = append(jobs, &copied)
+	}
+	return jobs
+}
+
+// runIngest 执行导入任务：加载、分块、按批嵌入并写入知识库，结束后删除临时文件
+//
+// ctx在关闭服务时取消，等待中或执行中的任务随之失败。失败时已写入的分块被删除，embedded_chunks归零。
+func (s *RAGService) runIngest(ctx context.Context, jobID, path string, config document.SplitterConfig) {
+	defer os.Remove(path)
+
+	var err error
+	select {
+	case s.ingestSem <- struct{}{}:
+		defer func() { <-s.ingestSem }()
+		s.updateJob(jobID, func(job *model.IngestJob) { job.Status = model.IngestStatusRunning })
+		err = s.ingest(ctx, jobID, path, config)
+	case <-ctx.Done():
+		err = fmt.Errorf("service closed: %w", ctx.Err())
+	}
+
+	s.updateJob(jobID, func(job *model.IngestJob) {
+		now := time.Now()
+		job.FinishedAt = &now
+		if err != nil {
+			job.Status = model.IngestStatusFailed
+			job.Error = err.Error()
+			job.EmbeddedChunks = 0
+			return
+		}
+		job.Status = model.IngestStatusCompleted
+	})
+	if err != nil {
+		log.Printf("Warning: ingest job %s failed: %v", jobID, err)
+	}
+}
+
+// ingest 加载并切分文档，按批写入知识库，同时更新任务进度
+func (s *RAGService) ingest(ctx context.Context, jobID, path string, config document.SplitterConfig) error {
+	job, err := s.GetIngestJob(jobID)
+	if err != nil {
+		return err
+	}
+
+	docs, err := s.loader.Load(ctx, einodoc.Source{URI: path},
+		einodoc.WithParserOptions(parser.WithExtraMeta(map[string]any{document.MetaSource: job.Filename})))
+	if err != nil {
+		return fmt.Errorf("load document: %w", err)
+	}
+	for _, doc := range docs {
+		doc.ID = jobID
+	}
+
+	chunks, err := s.splitter.Transform(ctx, docs, document.WithSplitterConfig(config))
+	if err != nil {
+		return fmt.Errorf("split document: %w", err)
+	}
+	if len(chunks) == 0 {
+		return fmt.Errorf("document has no content")
+	}
+	s.updateJob(jobID, func(job *model.IngestJob) { job.TotalChunks = len(chunks) })
+
+	return s.indexChunks(ctx, job.KnowledgeBase, jobID, chunks, func(done int) {
+		s.updateJob(jobID, func(job *model.IngestJob) { job.EmbeddedChunks = done })
+	})
+}
+
+// splitterConfig 用默认分块参数补全请求中未设置的字段
+func (s *RAGService) splitterConfig(opts model.ChunkOptions) (document.SplitterConfig, error) {
+	config := document.SplitterConfig{
+		Mode:         s.chunking.Mode,
+		ChunkSize:    s.chunking.ChunkSize,
+		ChunkOverlap: *s.chunking.ChunkOverlap,
+		MaxBytes:     milvus.MaxContentLength,
+	}
+	if opts.Mode != "" {
+		config.Mode = opts.Mode
+	}
+	if opts.ChunkSize != 0 {
+		config.ChunkSize = opts.ChunkSize
+		// 只修改分块大小时，重叠不超过分块大小
+		if opts.ChunkOverlap == nil && config.ChunkOverlap >= config.ChunkSize {
+			config.ChunkOverlap = config.ChunkSize / 8
+		}
+	}
+	if opts.ChunkOverlap != nil {
+		config.ChunkOverlap = *opts.ChunkOverlap
+	}
+
+	if err := config.Validate(); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+// chunkOptions 将分块配置转换为任务中展示的分块参数
+func chunkOptions(config document.SplitterConfig) model.ChunkOptions {
+	overlap := config.ChunkOverlap
+	return model.ChunkOptions{Mode: config.Mode, ChunkSize: config.ChunkSize, ChunkOverlap: &overlap}
+}
+
+// addJob 登记任务，超过保留数量时丢弃最早结束的任务
+func (s *RAGService) addJob(job *model.IngestJob) {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+
+	s.jobs[job.ID] = job
+	s.jobOrder = append(s.jobOrder, job.ID)
+
+	for i := 0; len(s.jobOrder) > maxIngestJobs && i < len(s.jobOrder); {
+		id := s.jobOrder[i]
+		if !s.jobs[id].Done() {
+			i++
+			continue
+		}
+		delete(s.jobs, id)
+		s.jobOrder = append(s.jobOrder[:i], s.jobOrder[i+1:]...)
+	}
+}
+
+// updateJob 修改任务状态并更新修改时间
+func (s *RAGService) updateJob(id string, fn func(job *model.IngestJob)) {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+
+	if job, ok := s.jobs[id]; ok {
+		fn(job)
+		job.UpdatedAt = time.Now()
+	}
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"eino/internal/model"
+)
+
+// longDocument 生成按默认分块参数会切成多个分块的文档
+func longDocument(paragraphs int) string {
+	var b strings.Builder
+	for i := range paragraphs {
+		fmt.Fprintf(&b, "第%d段。", i)
+		b.WriteString(strings.Repeat(fmt.Sprintf("word%d ", i), 600))
+		b.WriteString("\n\n")
+	}
+	return b.String()
+}
+
+// waitIngest 等待导入任务结束
+func waitIngest(t *testing.T, s *RAGService, id string) *model.IngestJob {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, err := s.GetIngestJob(id)
+		if err != nil {
+			t.Fatalf("GetIngestJob: %v", err)
+		}
+		if job.Done() {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("ingest job %s still %s", id, job.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestIngestDiscardsPartialDocument(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+	s.embedBatchSize = 1
+	s.vectorStore = &failingStore{VectorStore: s.vectorStore, failAt: 3}
+
+	job, err := s.IngestDocument(ctx, model.DefaultKnowledgeBase, "long.txt", strings.NewReader(longDocument(4)), model.ChunkOptions{})
+	if err != nil {
+		t.Fatalf("IngestDocument: %v", err)
+	}
+	job = waitIngest(t, s, job.ID)
+	if job.Status != model.IngestStatusFailed || job.EmbeddedChunks != 0 || job.TotalChunks < 3 {
+		t.Fatalf("job = %+v, want failed with embedded_chunks 0", job)
+	}
+
+	// 前两批已写入的分块和向量都被删除
+	page, err := s.ListKnowledge(ctx, model.KnowledgeQuery{DocumentID: job.ID, Limit: 10})
+	if err != nil {
+		t.Fatalf("ListKnowledge: %v", err)
+	}
+	if len(page.Items) != 0 {
+		t.Fatalf("%d chunks left after a failed ingest", len(page.Items))
+	}
+	result, err := s.SearchKnowledge(ctx, "word0", model.SearchOptions{TopK: 5})
+	if err != nil {
+		t.Fatalf("SearchKnowledge: %v", err)
+	}
+	if len(result.Hits) != 0 {
+		t.Fatalf("search returned %d hits after a failed ingest", len(result.Hits))
+	}
+}
+
+func TestCloseStopsPendingIngests(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+
+	// 占满并发数，新任务只能等待
+	for range maxConcurrentIngests {
+		s.ingestSem <- struct{}{}
+	}
+	job, err := s.IngestDocument(ctx, model.DefaultKnowledgeBase, "a.txt", strings.NewReader("内容"), model.ChunkOptions{})
+	if err != nil {
+		t.Fatalf("IngestDocument: %v", err)
+	}
+
+	s.Close()
+	job, err = s.GetIngestJob(job.ID)
+	if err != nil {
+		t.Fatalf("GetIngestJob: %v", err)
+	}
+	if job.Status != model.IngestStatusFailed {
+		t.Fatalf("job status after Close = %s, want failed", job.Status)
+	}
+}
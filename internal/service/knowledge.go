@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"eino/internal/document"
+	"eino/internal/model"
+	"eino/internal/storage"
+	"eino/internal/storage/milvus"
+	"eino/internal/storage/vector"
+)
+
+// deleteBatchSize 删除文档时每次删除的分块数
+const deleteBatchSize = 500
+
+// ListKnowledge 分页查询knowledge_base表中的知识分块
+func (s *RAGService) ListKnowledge(ctx context.Context, query model.KnowledgeQuery) (*model.KnowledgePage, error) {
+	page, err := s.store.QueryKnowledgeChunks(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query knowledge chunks: %w", err)
+	}
+	return page, nil
+}
+
+// GetKnowledge 获取知识分块
+func (s *RAGService) GetKnowledge(ctx context.Context, id int64) (*model.KnowledgeChunk, error) {
+	chunk, err := s.store.GetKnowledgeChunk(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get knowledge chunk %d: %w", id, err)
+	}
+	return chunk, nil
+}
+
+// UpdateKnowledge 修改知识分块并重新生成嵌入向量，向量的主键不变
+//
+// 有未完成的重建索引任务时，同时更新任务的新集合，已写入新集合的分块不会保留旧内容。
+func (s *RAGService) UpdateKnowledge(ctx context.Context, id int64, req *model.UpdateKnowledgeRequest) (*model.KnowledgeChunk, error) {
+	if req.Content != nil {
+		if strings.TrimSpace(*req.Content) == "" {
+			return nil, fmt.Errorf("%w: content is empty", document.ErrInvalidDocument)
+		}
+		if len(*req.Content) > milvus.MaxContentLength {
+			return nil, fmt.Errorf("%w: content exceeds %d bytes", document.ErrInvalidDocument, milvus.MaxContentLength)
+		}
+	}
+
+	// 持有读锁，修改期间不会切换集合；持有chunkMu，重建索引不会用修改前读到的内容覆盖新集合
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.chunkMu.Lock()
+	defer s.chunkMu.Unlock()
+
+	chunk, err := s.GetKnowledge(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Content != nil {
+		chunk.Content = *req.Content
+	}
+	if req.Category != nil {
+		chunk.Category = *req.Category
+	}
+	if req.Source != nil {
+		chunk.Source = *req.Source
+		if chunk.Metadata, err = setMetadata(chunk.Metadata, document.MetaSource, chunk.Source); err != nil {
+			return nil, err
+		}
+	}
+
+	embeddings, err := s.embedBatch(ctx, s.activeModel, []string{chunk.Content})
+	if err != nil {
+		return nil, fmt.Errorf("generate embeddings: %w", err)
+	}
+	if err := s.store.UpdateKnowledgeChunk(ctx, chunk); err != nil {
+		return nil, fmt.Errorf("update knowledge chunk: %w", err)
+	}
+	if err := s.vectorStore.Insert(ctx, s.collectionName, []vector.Entity{chunkEntity(chunk, embeddings[0])}); err != nil {
+		return nil, err
+	}
+	s.keywords.Add(chunk)
+
+	// 在更新表之后查询任务，之后创建的任务会读到新内容
+	jobs, err := s.pendingReindexJobs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, job := range jobs {
+		if err := s.insertReindexChunks(ctx, job, []*model.KnowledgeChunk{chunk}); err != nil && !errors.Is(err, vector.ErrCollectionNotFound) {
+			return nil, fmt.Errorf("update reindex collection %s: %w", job.Collection, err)
+		}
+	}
+	return chunk, nil
+}
+
+// DeleteKnowledge 删除知识分块及其向量
+func (s *RAGService) DeleteKnowledge(ctx context.Context, id int64) error {
+	if _, err := s.GetKnowledge(ctx, id); err != nil {
+		return err
+	}
+	return s.deleteChunks(ctx, []int64{id})
+}
+
+// DeleteDocument 删除文档的全部分块及其向量，返回删除的分块数；文档没有分块时返回storage.ErrNotFound
+func (s *RAGService) DeleteDocument(ctx context.Context, documentID string) (int, error) {
+	deleted := 0
+	for {
+		page, err := s.ListKnowledge(ctx, model.KnowledgeQuery{DocumentID: documentID, Limit: deleteBatchSize})
+		if err != nil {
+			return deleted, err
+		}
+		if len(page.Items) == 0 {
+			break
+		}
+
+		ids := make([]int64, len(page.Items))
+		for i, chunk := range page.Items {
+			ids[i] = chunk.ID
+		}
+		if err := s.deleteChunks(ctx, ids); err != nil {
+			return deleted, err
+		}
+		deleted += len(ids)
+	}
+
+	if deleted == 0 {
+		return 0, fmt.Errorf("document %s: %w", documentID, storage.ErrNotFound)
+	}
+	return deleted, nil
+}
+
+// deleteChunks 先删除当前集合和重建索引任务新集合中的向量，再删除表中的分块
+//
+// 删除向量失败时分块保留，可以重试；反过来会留下无法再通过接口删除的向量。
+func (s *RAGService) deleteChunks(ctx context.Context, ids []int64) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.chunkMu.Lock()
+	defer s.chunkMu.Unlock()
+
+	if !s.rowBacked {
+		return fmt.Errorf("delete knowledge: collection %s was created by an earlier version, run \"server reembed\" to migrate it", s.collectionName)
+	}
+	if err := s.deleteVectors(ctx, ids); err != nil {
+		return err
+	}
+
+	if err := s.store.DeleteKnowledgeChunks(ctx, ids); err != nil {
+		return fmt.Errorf("delete knowledge chunks: %w", err)
+	}
+	s.keywords.Remove(ids...)
+	return nil
+}
+
+// deleteVectors 删除当前集合和重建索引任务新集合中的向量，调用方需持有mu的读锁和chunkMu的写锁
+func (s *RAGService) deleteVectors(ctx context.Context, ids []int64) error {
+	if err := s.vectorStore.Delete(ctx, s.collectionName, ids); err != nil {
+		return err
+	}
+	jobs, err := s.pendingReindexJobs(ctx)
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if err := s.vectorStore.Delete(ctx, job.Collection, ids); err != nil && !errors.Is(err, vector.ErrCollectionNotFound) {
+			return fmt.Errorf("delete from reindex collection %s: %w", job.Collection, err)
+		}
+	}
+	return nil
+}
+
+// pendingReindexJobs 返回新集合可能还会被使用的任务：未结束的任务和可以恢复的失败任务
+func (s *RAGService) pendingReindexJobs(ctx context.Context) ([]*model.ReindexJob, error) {
+	jobs, err := s.ListReindexJobs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pending := make([]*model.ReindexJob, 0)
+	for _, job := range jobs {
+		// 嵌入模型与配置不一致的任务不能再恢复
+		if job.Status != model.ReindexStatusCompleted && job.EmbeddingModel == s.embeddingModel && job.EmbeddingDim == s.embeddingDim {
+			pending = append(pending, job)
+		}
+	}
+	return pending, nil
+}
+
+// setMetadata 修改JSON对象形式的元数据中的一个字段
+func setMetadata(metadata json.RawMessage, key string, value any) (json.RawMessage, error) {
+	meta := make(map[string]any)
+	if len(metadata) > 0 {
+		if err := json.Unmarshal(metadata, &meta); err != nil {
+			return nil, fmt.Errorf("unmarshal chunk metadata: %w", err)
+		}
+	}
+	meta[key] = value
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return nil, fmt.Errorf("marshal chunk metadata: %w", err)
+	}
+	return data, nil
+}
@@ -2,139 +2,552 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"net/http"
+	"log"
 	"strings"
+	"sync"
+	"time"
 
+	"eino/internal/config"
+	"eino/internal/document"
+	"eino/internal/embedder"
+	"eino/internal/fake"
+	"eino/internal/model"
+	"eino/internal/rerank"
+	"eino/internal/storage"
+	"eino/internal/storage/keyword"
 	"eino/internal/storage/milvus"
+	"eino/internal/storage/vector"
+	"github.com/cloudwego/eino/components/embedding"
 	"github.com/cloudwego/eino/schema"
+	"github.com/google/uuid"
 )
 
+// VectorStore 知识库向量存储，由Milvus或内存向量存储实现
+type VectorStore interface {
+	// CreateCollection 创建集合（如果不存在），记录嵌入模型和维度
+	CreateCollection(ctx context.Context, collectionName string, info vector.CollectionInfo) error
+	// DescribeCollection 获取集合元数据，collectionName可以是别名；集合不存在时返回vector.ErrCollectionNotFound
+	DescribeCollection(ctx context.Context, collectionName string) (*vector.CollectionInfo, error)
+	DropCollection(ctx context.Context, collectionName string) error
+	// SetAlias 将别名指向集合，别名已存在时原子地切换到新集合
+	SetAlias(ctx context.Context, alias, collectionName string) error
+	// Insert 批量写入向量数据，主键由调用方指定的集合中主键已存在时覆盖
+	Insert(ctx context.Context, collectionName string, entities []vector.Entity) error
+	// Delete 按主键删除向量，不存在的主键忽略
+	Delete(ctx context.Context, collectionName string, ids []int64) error
+	// Search 在指定知识库中搜索相似向量，返回按距离从近到远排列的结果
+	Search(ctx context.Context, collectionName string, queryVector []float32, kbs []string, topK int) ([]vector.SearchResult, error)
+	// Distances 计算查询向量与指定主键的向量之间的距离，度量与Search一致，不存在的主键不返回
+	Distances(ctx context.Context, collectionName string, queryVector []float32, ids []int64) (map[int64]float32, error)
+	// Scan 遍历集合中的全部数据，返回的实体不含向量
+	Scan(ctx context.Context, collectionName string, fn func(vector.Entity) error) error
+	Close() error
+}
+
+// ErrEmbeddingMismatch 向量集合的嵌入模型或维度与rag.embedding_model不一致
+var ErrEmbeddingMismatch = errors.New("embedding model mismatch")
+
 // RAGService RAG（检索增强生成）服务
+//
+// collectionName是指向当前物理集合的别名，重建索引完成后切换到新集合。
+// 分块保存在knowledge_base表中，向量的主键与分块ID相同；早期版本创建的集合没有别名，
+// 主键自动生成，分块只在向量库中，需要先执行Reembed迁移。
 type RAGService struct {
-	ollamaURL      string
-	embeddingModel string
-	milvusStorage  *milvus.MilvusStorage
-	collectionName string
+	embedder       embedding.Embedder
+	embeddingModel string // rag.embedding_model，新建集合和重建索引使用的模型
 	embeddingDim   int
+	vectorStore    VectorStore
+	keywords       *keyword.Index // knowledge_base表中分块的关键词索引
+	retrieval      config.RetrievalConfig
+	rerank         config.RerankConfig
+	reranker       rerank.Reranker // 未启用重排序时为nil
+	store          storage.Storage
+	collectionName string
+	embedBatchSize int
+
+	// mu 保护当前集合的嵌入模型；写入分块时持有读锁，切换集合时持有写锁
+	mu          sync.RWMutex
+	activeModel string // 当前集合的嵌入模型，重建索引完成前可能与embeddingModel不同
+	activeDim   int
+	rowBacked   bool // 当前集合的主键是否为分块ID
+	autoReindex bool // rag.auto_reindex
+
+	// chunkMu 串行化分块的修改与重建索引的写入：修改、删除分块时持有写锁，
+	// 重建索引读取分块并写入新集合时持有读锁，新集合不会写回已删除的分块或修改前的内容。
+	// 需要同时持有mu时先获取mu
+	chunkMu sync.RWMutex
+
+	loader   *document.FileLoader
+	splitter *document.Splitter
+	chunking model.ChunkOptions // 默认分块参数
+
+	jobsMu       sync.Mutex
+	jobs         map[string]*model.IngestJob
+	jobOrder     []string      // 任务ID，按创建时间正序
+	ingestSem    chan struct{} // 限制同时执行的导入任务数
+	ingestCtx    context.Context
+	ingestCancel context.CancelFunc // 关闭服务时取消正在执行的导入任务
+	ingestWG     sync.WaitGroup
+
+	reindexConcurrency int
+	reindexMu          sync.Mutex
+	reindexCancel      map[string]context.CancelCauseFunc // 正在执行的重建索引任务
+	reindexWG          sync.WaitGroup
 }
 
 // NewRAGService 创建RAG服务
-func NewRAGService(milvusHost string, milvusPort int, ollamaURL string) (*RAGService, error) {
+//
+// 启动时生成一次嵌入向量探测模型的维度。知识库集合不存在时按配置的模型创建。
+// 集合的嵌入模型或维度与配置不一致时返回ErrEmbeddingMismatch，需要先执行Reembed；
+// 开启rag.auto_reindex时，分块保存在knowledge_base表中的集合继续用原模型提供检索，同时在后台重建索引。
+// 上次运行未结束的重建索引任务在启动后继续执行。
+func NewRAGService(cfg config.RAGConfig, storageCfg config.StorageConfig, store storage.Storage) (*RAGService, error) {
+	ctx := context.Background()
 
-	// 初始化Milvus存储
-	milvusStorage, err := milvus.NewMilvusStorage(milvusHost, milvusPort)
+	service, err := newRAGService(cfg, storageCfg, store)
 	if err != nil {
-		return nil, fmt.Errorf("create milvus storage: %w", err)
+		return nil, err
 	}
-
-	service := &RAGService{
-		ollamaURL:      ollamaURL,
-		embeddingModel: "nomic-embed-text", // 使用支持嵌入的模型
-		milvusStorage:  milvusStorage,
-		collectionName: "knowledge_base",
-		embeddingDim:   768, // nomic-embed-text的维度
+	if err := service.openCollection(ctx); err != nil {
+		service.Close()
+		return nil, err
 	}
-
-	// 创建集合（如果不存在）
-	if err := service.milvusStorage.CreateCollection(context.Background(), service.collectionName, service.embeddingDim); err != nil {
-		return nil, fmt.Errorf("create collection: %w", err)
+	if err := service.loadKeywordIndex(ctx); err != nil {
+		service.Close()
+		return nil, err
+	}
+	if err := service.resumeReindexJobs(ctx); err != nil {
+		service.Close()
+		return nil, err
 	}
-
 	return service, nil
 }
 
-// generateEmbedding 使用Ollama API生成嵌入向量
-func (s *RAGService) generateEmbedding(ctx context.Context, text string) ([]float32, error) {
-	url := fmt.Sprintf("%s/api/embeddings", s.ollamaURL)
+// newRAGService 创建RAG服务并探测嵌入维度，不检查知识库集合
+func newRAGService(cfg config.RAGConfig, storageCfg config.StorageConfig, store storage.Storage) (*RAGService, error) {
+	ctx := context.Background()
 
-	reqBody := map[string]interface{}{
-		"model":  s.embeddingModel,
-		"prompt": text,
+	chunking := document.SplitterConfig{
+		Mode:         cfg.Chunking.Mode,
+		ChunkSize:    cfg.Chunking.ChunkSize,
+		ChunkOverlap: cfg.Chunking.ChunkOverlap,
+		MaxBytes:     milvus.MaxContentLength,
+	}
+	if err := chunking.Validate(); err != nil {
+		return nil, fmt.Errorf("rag.chunking: %w", err)
+	}
+	if err := model.ValidateSearchMode(cfg.Retrieval.Mode); err != nil {
+		return nil, fmt.Errorf("rag.retrieval: %w", err)
+	}
+	splitter, err := document.NewSplitter(chunking)
+	if err != nil {
+		return nil, fmt.Errorf("create splitter: %w", err)
+	}
+	loader, err := document.NewFileLoader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("create document loader: %w", err)
 	}
 
-	jsonData, err := json.Marshal(reqBody)
+	reranker, err := newReranker(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("marshal request: %w", err)
+		return nil, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(string(jsonData)))
+	textEmbedder, err := newEmbedder(cfg, storageCfg.Redis)
 	if err != nil {
-		return nil, fmt.Errorf("create request: %w", err)
+		return nil, err
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	vectorStore, err := newVectorStore(cfg, storageCfg.Milvus)
 	if err != nil {
-		return nil, fmt.Errorf("do request: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("ollama API error: %s", string(body))
+	ingestCtx, ingestCancel := context.WithCancel(context.Background())
+	service := &RAGService{
+		embedder:           textEmbedder,
+		embeddingModel:     cfg.EmbeddingModel,
+		vectorStore:        vectorStore,
+		keywords:           keyword.NewIndex(),
+		retrieval:          cfg.Retrieval,
+		rerank:             cfg.Rerank,
+		reranker:           reranker,
+		store:              store,
+		collectionName:     "knowledge_base",
+		embedBatchSize:     max(cfg.EmbedBatchSize, 1),
+		loader:             loader,
+		splitter:           splitter,
+		chunking:           chunkOptions(chunking),
+		jobs:               make(map[string]*model.IngestJob),
+		ingestSem:          make(chan struct{}, maxConcurrentIngests),
+		ingestCtx:          ingestCtx,
+		ingestCancel:       ingestCancel,
+		reindexConcurrency: max(cfg.ReindexConcurrency, 1),
+		autoReindex:        cfg.AutoReindex,
+		reindexCancel:      make(map[string]context.CancelCauseFunc),
 	}
 
-	var result struct {
-		Embedding []float64 `json:"embedding"`
+	if service.embeddingDim, err = service.probeDimension(ctx); err != nil {
+		service.Close()
+		return nil, err
 	}
+	return service, nil
+}
 
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("decode response: %w", err)
+// probeDimension 生成一条嵌入向量，得到嵌入模型的维度
+func (s *RAGService) probeDimension(ctx context.Context) (int, error) {
+	embeddings, err := s.embedBatch(ctx, s.embeddingModel, []string{"dimension probe"})
+	if err != nil {
+		return 0, fmt.Errorf("probe embedding model %s: %w", s.embeddingModel, err)
+	}
+	if len(embeddings[0]) == 0 {
+		return 0, fmt.Errorf("probe embedding model %s: empty embedding", s.embeddingModel)
 	}
+	return len(embeddings[0]), nil
+}
 
-	// 转换为float32
-	embedding := make([]float32, len(result.Embedding))
-	for i, v := range result.Embedding {
-		embedding[i] = float32(v)
+// collectionInfo 按配置的嵌入模型创建集合时记录的元数据，主键为分块ID
+func (s *RAGService) collectionInfo() vector.CollectionInfo {
+	return vector.CollectionInfo{EmbeddingModel: s.embeddingModel, Dim: s.embeddingDim, RowIDs: true}
+}
+
+// newCollectionName 生成新物理集合的名称
+func (s *RAGService) newCollectionName() string {
+	return fmt.Sprintf("%s_%d", s.collectionName, time.Now().UnixNano())
+}
+
+// openCollection 打开知识库集合，不存在时创建物理集合和别名，已存在时检查嵌入模型和维度
+func (s *RAGService) openCollection(ctx context.Context) error {
+	info, err := s.vectorStore.DescribeCollection(ctx, s.collectionName)
+	if errors.Is(err, vector.ErrCollectionNotFound) {
+		return s.createCollection(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.activeModel, s.activeDim, s.rowBacked = info.EmbeddingModel, info.Dim, info.RowIDs
+	if info.Dim != s.embeddingDim || (info.EmbeddingModel != "" && info.EmbeddingModel != s.embeddingModel) {
+		stored := info.EmbeddingModel
+		if stored == "" {
+			stored = "an unrecorded model"
+		}
+		if !info.RowIDs || !s.autoReindex {
+			hint := ""
+			if info.RowIDs {
+				hint = " (or set rag.auto_reindex to serve with the old model while reindexing in the background)"
+			}
+			return fmt.Errorf("%w: collection %s was embedded with %s (dim %d) but rag.embedding_model is %s (dim %d); "+
+				"run \"server reembed\" to re-embed the existing knowledge with %s%s, or switch rag.embedding_model back",
+				ErrEmbeddingMismatch, s.collectionName, stored, info.Dim, s.embeddingModel, s.embeddingDim, s.embeddingModel, hint)
+		}
+		log.Printf("Warning: collection %s was embedded with %s (dim %d), serving with it until the reindex to %s completes",
+			s.collectionName, stored, info.Dim, s.embeddingModel)
+		return nil
+	}
+	if info.EmbeddingModel == "" {
+		log.Printf("Warning: collection %s does not record its embedding model, assuming %s (dim %d)", s.collectionName, s.embeddingModel, s.embeddingDim)
+		s.activeModel = s.embeddingModel
+	}
+	if !info.RowIDs {
+		log.Printf("Warning: collection %s was created by an earlier version, run \"server reembed\" to store its chunks in the knowledge_base table", s.collectionName)
+	}
+	return nil
+}
+
+// createCollection 按配置的嵌入模型创建物理集合，并将别名指向它
+func (s *RAGService) createCollection(ctx context.Context) error {
+	name := s.newCollectionName()
+	if err := s.vectorStore.CreateCollection(ctx, name, s.collectionInfo()); err != nil {
+		return fmt.Errorf("create collection: %w", err)
 	}
+	if err := s.vectorStore.SetAlias(ctx, s.collectionName, name); err != nil {
+		return err
+	}
+	s.activeModel, s.activeDim, s.rowBacked = s.embeddingModel, s.embeddingDim, true
+	return nil
+}
 
-	return embedding, nil
+// newVectorStore 按rag.vector_store创建向量存储
+func newVectorStore(cfg config.RAGConfig, milvusCfg config.MilvusConfig) (VectorStore, error) {
+	switch cfg.VectorStore {
+	case "memory":
+		metric, err := vector.ParseMetric(cfg.Memory.Metric)
+		if err != nil {
+			return nil, fmt.Errorf("rag.memory.metric: %w", err)
+		}
+		interval := time.Duration(cfg.Memory.SnapshotInterval) * time.Second
+		store, err := vector.NewMemoryStore(metric, cfg.Memory.SnapshotPath, interval)
+		if err != nil {
+			return nil, fmt.Errorf("create memory vector store: %w", err)
+		}
+		return store, nil
+	case "milvus", "":
+		store, err := milvus.NewMilvusStorage(milvusCfg.Host, milvusCfg.Port)
+		if err != nil {
+			return nil, fmt.Errorf("create milvus storage: %w", err)
+		}
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unknown rag.vector_store %q: use milvus or memory", cfg.VectorStore)
+	}
 }
 
-// AddKnowledge 添加知识到向量库
-func (s *RAGService) AddKnowledge(ctx context.Context, content string) error {
-	// 生成嵌入向量
-	embedding, err := s.generateEmbedding(ctx, content)
+// newEmbedder 按配置创建嵌入客户端，缓存向量的LRU和Redis可以分别关闭
+//
+// Redis不可用时只记录日志，不影响启动。fake不调用模型服务，也不使用缓存。
+func newEmbedder(cfg config.RAGConfig, redisCfg config.RedisConfig) (embedding.Embedder, error) {
+	switch cfg.EmbeddingProvider {
+	case "", "ollama":
+	case "fake":
+		return fake.NewEmbedder(), nil
+	default:
+		return nil, fmt.Errorf("unknown rag.embedding_provider %q: use ollama or fake", cfg.EmbeddingProvider)
+	}
+
+	var lru, remote embedder.Cache
+	if cfg.EmbedCache.Size > 0 {
+		lru = embedder.NewLRUCache(cfg.EmbedCache.Size)
+	}
+	if cfg.EmbedCache.Redis {
+		addr := fmt.Sprintf("%s:%d", redisCfg.Host, redisCfg.Port)
+		ttl := time.Duration(cfg.EmbedCache.TTL) * time.Second
+		if c, err := embedder.NewRedisCache(addr, redisCfg.Password, redisCfg.DB, ttl); err != nil {
+			log.Printf("Warning: embedding cache disabled for redis %s: %v", addr, err)
+		} else {
+			remote = c
+		}
+	}
+
+	return embedder.NewOllamaEmbedder(embedder.Config{
+		BaseURL:     cfg.OllamaURL,
+		Model:       cfg.EmbeddingModel,
+		BatchSize:   cfg.EmbedBatchSize,
+		Concurrency: cfg.EmbedConcurrency,
+		Timeout:     time.Duration(cfg.EmbedTimeout) * time.Second,
+		MaxRetries:  cfg.EmbedRetries,
+		Cache:       embedder.NewTieredCache(lru, remote),
+	}), nil
+}
+
+// embedBatch 用嵌入模型embeddingModel生成多条文本的嵌入向量
+func (s *RAGService) embedBatch(ctx context.Context, embeddingModel string, texts []string) ([][]float32, error) {
+	embeddings, err := s.embedder.EmbedStrings(ctx, texts, embedding.WithModel(embeddingModel))
+	if err != nil {
+		return nil, err
+	}
+
+	// 转换为float32
+	vectors := make([][]float32, len(embeddings))
+	for i, e := range embeddings {
+		vectors[i] = make([]float32, len(e))
+		for j, v := range e {
+			vectors[i][j] = float32(v)
+		}
+	}
+	return vectors, nil
+}
+
+// AddKnowledge 添加知识到指定知识库，内容较长时按默认分块参数切分，返回分块所属的文档ID
+func (s *RAGService) AddKnowledge(ctx context.Context, kb, content string) (string, error) {
+	documentID := uuid.New().String()
+	chunks, err := s.splitter.Transform(ctx, []*schema.Document{{
+		ID:       documentID,
+		Content:  content,
+		MetaData: map[string]any{document.MetaFormat: document.FormatText},
+	}})
 	if err != nil {
-		return fmt.Errorf("generate embedding: %w", err)
+		return "", fmt.Errorf("split content: %w", err)
 	}
 
-	// 插入Milvus
-	return s.milvusStorage.Insert(ctx, s.collectionName, content, embedding)
+	if err := s.indexChunks(ctx, kb, documentID, chunks, nil); err != nil {
+		return "", err
+	}
+	return documentID, nil
 }
 
-// SearchKnowledge 搜索相关知识
-func (s *RAGService) SearchKnowledge(ctx context.Context, query string, topK int) ([]string, error) {
-	// 生成查询向量
-	embedding, err := s.generateEmbedding(ctx, query)
+// PutKnowledgeDocument 以documentID写入知识库kb中的一篇文档，返回是否写入了新内容
+//
+// 分块元数据记录文档内容的哈希：文档已有相同内容的分块时不做修改，内容变化时删除原有分块后重新写入，
+// 因此可以重复调用。
+func (s *RAGService) PutKnowledgeDocument(ctx context.Context, kb, documentID, title, content string) (bool, error) {
+	sum := sha256.Sum256([]byte(content))
+	hash := hex.EncodeToString(sum[:])
+
+	page, err := s.ListKnowledge(ctx, model.KnowledgeQuery{DocumentID: documentID, Limit: 1})
+	if err != nil {
+		return false, err
+	}
+	if len(page.Items) > 0 {
+		var meta map[string]any
+		if err := json.Unmarshal(page.Items[0].Metadata, &meta); err == nil && meta[document.MetaHash] == hash && page.Items[0].KnowledgeBase == kb {
+			return false, nil
+		}
+		if _, err := s.DeleteDocument(ctx, documentID); err != nil {
+			return false, fmt.Errorf("delete outdated document %s: %w", documentID, err)
+		}
+	}
+
+	meta := map[string]any{document.MetaFormat: document.FormatText, document.MetaHash: hash}
+	if title != "" {
+		meta[document.MetaTitle] = title
+	}
+	chunks, err := s.splitter.Transform(ctx, []*schema.Document{{ID: documentID, Content: content, MetaData: meta}})
 	if err != nil {
-		return nil, fmt.Errorf("generate query embedding: %w", err)
+		return false, fmt.Errorf("split content: %w", err)
+	}
+	if err := s.indexChunks(ctx, kb, documentID, chunks, nil); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// indexChunks 按批生成文档documentID的分块的嵌入向量并写入知识库kb，每写入一批调用一次progress
+//
+// 当前集合的主键为分块ID时，先将分块保存到knowledge_base表，再以分块ID为主键写入向量。
+// 某一批写入失败时删除之前各批已写入的分块，文档要么完整写入，要么不留下分块。
+func (s *RAGService) indexChunks(ctx context.Context, kb, documentID string, chunks []*schema.Document, progress func(done int)) error {
+	var indexed []int64
+	for start := 0; start < len(chunks); start += s.embedBatchSize {
+		batch := chunks[start:min(start+s.embedBatchSize, len(chunks))]
+
+		rows := make([]*model.KnowledgeChunk, len(batch))
+		contents := make([]string, len(batch))
+		for i, chunk := range batch {
+			meta, err := json.Marshal(chunkMetadata(chunk))
+			if err != nil {
+				s.discardIndexed(ctx, documentID, indexed)
+				return fmt.Errorf("marshal chunk metadata: %w", err)
+			}
+			source, _ := chunk.MetaData[document.MetaSource].(string)
+			rows[i] = &model.KnowledgeChunk{KnowledgeBase: kb, DocumentID: documentID, Content: chunk.Content, Source: source, Metadata: meta}
+			contents[i] = chunk.Content
+		}
+
+		if err := s.indexRows(ctx, rows, contents); err != nil {
+			s.discardIndexed(ctx, documentID, indexed)
+			return err
+		}
+		for _, row := range rows {
+			indexed = append(indexed, row.ID)
+		}
+
+		if progress != nil {
+			progress(start + len(batch))
+		}
+	}
+	return nil
+}
+
+// discardIndexed 删除文档已写入的分块，ctx可能已经取消，清理使用不随其取消的上下文；清理失败只记录日志
+func (s *RAGService) discardIndexed(ctx context.Context, documentID string, ids []int64) {
+	if len(ids) == 0 {
+		return
 	}
+	if err := s.deleteChunks(context.WithoutCancel(ctx), ids); err != nil {
+		log.Printf("Warning: failed to delete %d chunks of document %s after a failed insert: %v", len(ids), documentID, err)
+	}
+}
+
+// indexRows 用当前集合的嵌入模型写入一批分块，持有读锁，写入期间不会切换集合
+//
+// 主键为分块ID时先保存分块得到ID，写入向量失败时删除这些分块。
+func (s *RAGService) indexRows(ctx context.Context, rows []*model.KnowledgeChunk, contents []string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	// 搜索相似向量
-	contents, _, err := s.milvusStorage.Search(ctx, s.collectionName, embedding, topK)
+	embeddings, err := s.embedBatch(ctx, s.activeModel, contents)
 	if err != nil {
-		return nil, fmt.Errorf("search vectors: %w", err)
+		return fmt.Errorf("generate embeddings: %w", err)
+	}
+	if s.rowBacked {
+		if err := s.store.SaveKnowledgeChunks(ctx, rows); err != nil {
+			return fmt.Errorf("save knowledge chunks: %w", err)
+		}
+	}
+
+	entities := make([]vector.Entity, len(rows))
+	for i, row := range rows {
+		entities[i] = chunkEntity(row, embeddings[i])
+		if !s.rowBacked {
+			entities[i].ID = 0
+		}
+	}
+	if err := s.vectorStore.Insert(ctx, s.collectionName, entities); err != nil {
+		if s.rowBacked {
+			s.discardRows(ctx, rows)
+		}
+		return fmt.Errorf("insert vectors: %w", err)
 	}
+	if s.rowBacked {
+		s.keywords.Add(rows...)
+	}
+	return nil
+}
+
+// discardRows 写入向量失败后删除刚保存的分块和可能已写入的部分向量，避免留下没有向量的分块，调用方需持有mu的读锁
+//
+// ctx可能已经取消（写入失败的原因），清理使用不随其取消的上下文；清理失败只记录日志。
+func (s *RAGService) discardRows(ctx context.Context, rows []*model.KnowledgeChunk) {
+	s.chunkMu.Lock()
+	defer s.chunkMu.Unlock()
+
+	ctx = context.WithoutCancel(ctx)
+	ids := make([]int64, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+	// 重建索引可能已经读到这些分块并写入了新集合
+	if err := s.deleteVectors(ctx, ids); err != nil {
+		log.Printf("Warning: failed to delete vectors of chunks %v after a failed insert: %v", ids, err)
+	}
+	if err := s.store.DeleteKnowledgeChunks(ctx, ids); err != nil {
+		log.Printf("Warning: failed to delete chunks %v after a failed insert: %v", ids, err)
+	}
+}
 
-	return contents, nil
+// chunkEntity 分块对应的向量数据，主键为分块ID
+func chunkEntity(chunk *model.KnowledgeChunk, embedding []float32) vector.Entity {
+	return vector.Entity{
+		ID:            chunk.ID,
+		KnowledgeBase: chunk.KnowledgeBase,
+		Content:       chunk.Content,
+		Metadata:      chunk.Metadata,
+		Embedding:     embedding,
+	}
 }
 
-// EnhanceMessages 增强消息列表（添加相关知识）
-func (s *RAGService) EnhanceMessages(ctx context.Context, userMessage string, originalMessages []*schema.Message) ([]*schema.Message, error) {
+// chunkMetadata 分块写入向量库的元数据：原文档元数据加上分块ID
+func chunkMetadata(chunk *schema.Document) map[string]any {
+	meta := make(map[string]any, len(chunk.MetaData)+1)
+	for k, v := range chunk.MetaData {
+		meta[k] = v
+	}
+	if chunk.ID != "" {
+		meta["chunk_id"] = chunk.ID
+	}
+	return meta
+}
+
+// EnhanceMessages 增强消息列表（按聊天机器人的检索设置添加检索到的相关知识），同时返回检索过程
+func (s *RAGService) EnhanceMessages(ctx context.Context, opts model.SearchOptions, userMessage string, originalMessages []*schema.Message) ([]*schema.Message, *model.RetrievalTrace, error) {
 	// 搜索相关知识
-	knowledge, err := s.SearchKnowledge(ctx, userMessage, 3)
+	result, err := s.SearchKnowledge(ctx, userMessage, opts)
 	if err != nil {
 		// 如果搜索失败，返回原始消息
-		return originalMessages, nil
+		return originalMessages, nil, nil
 	}
 
-	if len(knowledge) == 0 {
-		return originalMessages, nil
+	if len(result.Hits) == 0 {
+		return originalMessages, result.Trace, nil
 	}
+	knowledge := hitContents(result.Hits)
 
 	// 构建增强的消息列表
 	enhancedMessages := make([]*schema.Message, 0)
@@ -160,10 +573,16 @@ func (s *RAGService) EnhanceMessages(ctx context.Context, userMessage string, or
 		enhancedMessages = append([]*schema.Message{schema.SystemMessage(systemPrompt)}, enhancedMessages...)
 	}
 
-	return enhancedMessages, nil
+	return enhancedMessages, result.Trace, nil
 }
 
-// Close 关闭服务
+// Close 关闭服务，正在执行的重建索引任务停止，下次启动后继续；未完成的导入任务失败
 func (s *RAGService) Close() error {
-	return s.milvusStorage.Close()
+	s.ingestCancel()
+	s.ingestWG.Wait()
+	s.stopReindexJobs()
+	if closer, ok := s.embedder.(io.Closer); ok {
+		closer.Close()
+	}
+	return s.vectorStore.Close()
 }
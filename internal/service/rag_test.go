@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"eino/internal/config"
+	"eino/internal/model"
+	"eino/internal/storage"
+	"eino/internal/storage/memory"
+	"eino/internal/storage/vector"
+)
+
+// newTestService 按离线评测配置创建RAG服务：fake嵌入、内存存储和内存向量库
+func newTestService(t *testing.T) (*RAGService, storage.Storage) {
+	t.Helper()
+	cfg, err := config.Load("configs/eval.yaml")
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	store := memory.NewMemoryStorage()
+	s, err := NewRAGService(cfg.RAG, cfg.Storage, store)
+	if err != nil {
+		t.Fatalf("NewRAGService: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s, store
+}
+
+// errInjected 测试中注入的写入错误
+var errInjected = errors.New("injected failure")
+
+// failingStore 包装向量库，第failAt次（从1开始）及之后的Insert返回errInjected，failAt为0时不失败
+type failingStore struct {
+	VectorStore
+
+	mu      sync.Mutex
+	failAt  int
+	inserts int
+}
+
+func (f *failingStore) Insert(ctx context.Context, collectionName string, entities []vector.Entity) error {
+	f.mu.Lock()
+	f.inserts++
+	fail := f.failAt > 0 && f.inserts >= f.failAt
+	f.mu.Unlock()
+	if fail {
+		return errInjected
+	}
+	return f.VectorStore.Insert(ctx, collectionName, entities)
+}
+
+func TestPutKnowledgeDocument(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+
+	chunks := func() []*model.KnowledgeChunk {
+		t.Helper()
+		page, err := s.ListKnowledge(ctx, model.KnowledgeQuery{DocumentID: "doc", Limit: 10})
+		if err != nil {
+			t.Fatalf("ListKnowledge: %v", err)
+		}
+		return page.Items
+	}
+
+	steps := []struct {
+		name, content string
+		changed       bool
+	}{
+		{"Create", "退货政策：七天内无理由退货", true},
+		{"Unchanged", "退货政策：七天内无理由退货", false},
+		{"Changed", "退货政策：十五天内无理由退货", true},
+	}
+	var ids []int64
+	for _, step := range steps {
+		changed, err := s.PutKnowledgeDocument(ctx, model.DefaultKnowledgeBase, "doc", "退货政策", step.content)
+		if err != nil {
+			t.Fatalf("%s: PutKnowledgeDocument: %v", step.name, err)
+		}
+		items := chunks()
+		if changed != step.changed || len(items) != 1 || items[0].Content != step.content {
+			t.Fatalf("%s: changed=%t chunks=%d, want changed=%t and one chunk with the new content", step.name, changed, len(items), step.changed)
+		}
+		ids = append(ids, items[0].ID)
+	}
+	if ids[0] != ids[1] || ids[1] == ids[2] {
+		t.Fatalf("chunk IDs = %v, want the unchanged put to keep the chunk and the changed put to replace it", ids)
+	}
+
+	// 替换后检索不到旧内容的向量
+	result, err := s.SearchKnowledge(ctx, "七天内无理由退货", model.SearchOptions{TopK: 5, Mode: model.SearchModeVector})
+	if err != nil {
+		t.Fatalf("SearchKnowledge: %v", err)
+	}
+	if len(result.Hits) != 1 || result.Hits[0].ID != ids[2] {
+		t.Fatalf("hits = %d, want only the replaced chunk", len(result.Hits))
+	}
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"eino/internal/config"
+	"eino/internal/document"
+	"eino/internal/model"
+	"eino/internal/storage"
+	"eino/internal/storage/vector"
+)
+
+// importBatchSize 迁移早期版本的集合时每次保存的分块数
+const importBatchSize = 500
+
+// Reembed 用rag.embedding_model重新生成知识库中全部分块的嵌入向量，返回处理的分块数
+//
+// 在前台执行重建索引任务，上次中断的任务从中断处继续。早期版本创建的集合没有把分块保存在
+// knowledge_base表中，先将集合中的分块导入表中再重建索引，完成后原集合由别名替代。
+// 每写入一批调用一次progress，progress可以为nil。执行前需要先停止服务。
+func Reembed(ctx context.Context, cfg config.RAGConfig, storageCfg config.StorageConfig, store storage.Storage, progress func(done, total int)) (int, error) {
+	s, err := newRAGService(cfg, storageCfg, store)
+	if err != nil {
+		return 0, err
+	}
+	defer s.Close()
+
+	return s.reembed(ctx, progress)
+}
+
+// reembed 必要时导入早期版本集合中的分块，然后在前台执行重建索引任务
+func (s *RAGService) reembed(ctx context.Context, progress func(done, total int)) (int, error) {
+	info, err := s.vectorStore.DescribeCollection(ctx, s.collectionName)
+	if errors.Is(err, vector.ErrCollectionNotFound) {
+		// 没有需要重新嵌入的数据，直接按配置的模型创建集合
+		return 0, s.createCollection(ctx)
+	}
+	if err != nil {
+		return 0, err
+	}
+	s.activeModel, s.activeDim, s.rowBacked = info.EmbeddingModel, info.Dim, info.RowIDs
+
+	s.reindexMu.Lock()
+	job, err := s.activeReindexJob(ctx)
+	if err == nil && job == nil {
+		if !info.RowIDs {
+			err = s.importCollection(ctx, info.Collection)
+			// 导入后新集合以分块ID为主键
+			s.rowBacked = true
+		}
+		if err == nil {
+			job, err = s.newReindexJob(ctx)
+		}
+	}
+	s.reindexMu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	err = s.runReindex(ctx, job, func(job *model.ReindexJob) {
+		if progress != nil {
+			progress(job.EmbeddedChunks, job.TotalChunks)
+		}
+	})
+	if err != nil {
+		return job.EmbeddedChunks, fmt.Errorf("reembed: %w", err)
+	}
+	return job.EmbeddedChunks, nil
+}
+
+// importCollection 将早期版本集合中的分块保存到knowledge_base表，表中必须还没有分块
+func (s *RAGService) importCollection(ctx context.Context, collectionName string) error {
+	n, err := s.store.CountKnowledgeChunks(ctx)
+	if err != nil {
+		return fmt.Errorf("count knowledge chunks: %w", err)
+	}
+	if n > 0 {
+		return fmt.Errorf("collection %s was created by an earlier version but the knowledge_base table already has %d chunks; "+
+			"delete them (an earlier import may have been interrupted) and run again", collectionName, n)
+	}
+
+	batch := make([]*model.KnowledgeChunk, 0, importBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := s.store.SaveKnowledgeChunks(ctx, batch); err != nil {
+			return fmt.Errorf("save knowledge chunks: %w", err)
+		}
+		batch = make([]*model.KnowledgeChunk, 0, importBatchSize)
+		return nil
+	}
+
+	err = s.vectorStore.Scan(ctx, collectionName, func(e vector.Entity) error {
+		chunk := &model.KnowledgeChunk{KnowledgeBase: e.KnowledgeBase, Content: e.Content}
+		if len(e.Metadata) > 0 {
+			chunk.Metadata = e.Metadata
+			var meta map[string]any
+			if json.Unmarshal(e.Metadata, &meta) == nil {
+				chunk.Source, _ = meta[document.MetaSource].(string)
+			}
+		}
+		batch = append(batch, chunk)
+		if len(batch) < importBatchSize {
+			return nil
+		}
+		return flush()
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return fmt.Errorf("import collection %s: %w", collectionName, err)
+	}
+	return nil
+}
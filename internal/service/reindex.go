@@ -0,0 +1,481 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"eino/internal/model"
+	"eino/internal/storage"
+	"eino/internal/storage/vector"
+
+	"github.com/google/uuid"
+)
+
+// 重建索引任务被取消的原因，任务状态由取消方负责保存
+var (
+	errReindexPaused  = errors.New("reindex job paused")
+	errReindexStopped = errors.New("rag service closed")
+)
+
+// StartReindex 创建重建索引任务并在后台执行
+//
+// 任务用rag.embedding_model将knowledge_base表中的全部分块嵌入到新集合，
+// 完成后将知识库别名原子地切换到新集合并删除原集合，执行期间检索不受影响。
+// 同时只能有一个未结束的任务，否则返回storage.ErrConflict。
+func (s *RAGService) StartReindex(ctx context.Context) (*model.ReindexJob, error) {
+	s.reindexMu.Lock()
+	defer s.reindexMu.Unlock()
+
+	job, err := s.activeReindexJob(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if job != nil {
+		return nil, fmt.Errorf("%w: reindex job %s is %s", storage.ErrConflict, job.ID, job.Status)
+	}
+
+	if job, err = s.newReindexJob(ctx); err != nil {
+		return nil, err
+	}
+	copied := *job
+	s.startReindex(job)
+	return &copied, nil
+}
+
+// GetReindexJob 获取重建索引任务的当前状态
+func (s *RAGService) GetReindexJob(ctx context.Context, id string) (*model.ReindexJob, error) {
+	job, err := s.store.GetReindexJob(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get reindex job: %w", err)
+	}
+	return job, nil
+}
+
+// ListReindexJobs 列出重建索引任务，最新的在前
+func (s *RAGService) ListReindexJobs(ctx context.Context) ([]*model.ReindexJob, error) {
+	jobs, err := s.store.ListReindexJobs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list reindex jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+// PauseReindex 暂停等待中或执行中的任务，已写入新集合的分块在恢复后不再重复嵌入
+func (s *RAGService) PauseReindex(ctx context.Context, id string) (*model.ReindexJob, error) {
+	s.reindexMu.Lock()
+	defer s.reindexMu.Unlock()
+
+	job, err := s.GetReindexJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != model.ReindexStatusPending && job.Status != model.ReindexStatusRunning {
+		return nil, fmt.Errorf("%w: reindex job %s is %s", storage.ErrConflict, id, job.Status)
+	}
+
+	job.Status = model.ReindexStatusPaused
+	job.UpdatedAt = time.Now()
+	if err := s.store.SaveReindexJob(ctx, job); err != nil {
+		return nil, fmt.Errorf("save reindex job: %w", err)
+	}
+	if cancel, ok := s.reindexCancel[id]; ok {
+		cancel(errReindexPaused)
+	}
+	return job, nil
+}
+
+// ResumeReindex 恢复已暂停或失败的任务，从上次写入的分块之后继续
+func (s *RAGService) ResumeReindex(ctx context.Context, id string) (*model.ReindexJob, error) {
+	s.reindexMu.Lock()
+	defer s.reindexMu.Unlock()
+
+	job, err := s.GetReindexJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case job.Status != model.ReindexStatusPaused && job.Status != model.ReindexStatusFailed:
+		return nil, fmt.Errorf("%w: reindex job %s is %s", storage.ErrConflict, id, job.Status)
+	case s.reindexCancel[id] != nil:
+		return nil, fmt.Errorf("%w: reindex job %s is still stopping", storage.ErrConflict, id)
+	case job.EmbeddingModel != s.embeddingModel || job.EmbeddingDim != s.embeddingDim:
+		return nil, fmt.Errorf("%w: reindex job %s embeds with %s but rag.embedding_model is %s, start a new reindex",
+			storage.ErrConflict, id, job.EmbeddingModel, s.embeddingModel)
+	}
+	if job.Status == model.ReindexStatusFailed {
+		active, err := s.activeReindexJob(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if active != nil {
+			return nil, fmt.Errorf("%w: reindex job %s is %s", storage.ErrConflict, active.ID, active.Status)
+		}
+	}
+
+	// 新集合可能已被删除（例如之后创建的任务清理了失败任务的集合），此时从头开始
+	if _, err := s.vectorStore.DescribeCollection(ctx, job.Collection); errors.Is(err, vector.ErrCollectionNotFound) {
+		if err := s.vectorStore.CreateCollection(ctx, job.Collection, s.collectionInfo()); err != nil {
+			return nil, fmt.Errorf("create collection: %w", err)
+		}
+		job.LastChunkID, job.EmbeddedChunks = 0, 0
+	} else if err != nil {
+		return nil, err
+	}
+
+	job.Status = model.ReindexStatusPending
+	job.Error = ""
+	job.FinishedAt = nil
+	job.UpdatedAt = time.Now()
+	if err := s.store.SaveReindexJob(ctx, job); err != nil {
+		return nil, fmt.Errorf("save reindex job: %w", err)
+	}
+	copied := *job
+	s.startReindex(job)
+	return &copied, nil
+}
+
+// resumeReindexJobs 启动时继续上次运行未结束的任务；开启rag.auto_reindex、当前集合的嵌入模型与配置不一致且没有任务时创建任务
+func (s *RAGService) resumeReindexJobs(ctx context.Context) error {
+	s.reindexMu.Lock()
+	defer s.reindexMu.Unlock()
+
+	job, err := s.activeReindexJob(ctx)
+	if err != nil {
+		return err
+	}
+	if job != nil {
+		if job.Status != model.ReindexStatusPaused {
+			log.Printf("Resuming reindex job %s (%d/%d chunks)", job.ID, job.EmbeddedChunks, job.TotalChunks)
+			s.startReindex(job)
+		}
+		return nil
+	}
+
+	s.mu.RLock()
+	outdated := s.autoReindex && s.rowBacked && (s.activeModel != s.embeddingModel || s.activeDim != s.embeddingDim)
+	s.mu.RUnlock()
+	if !outdated {
+		return nil
+	}
+	if job, err = s.newReindexJob(ctx); err != nil {
+		return err
+	}
+	log.Printf("Started reindex job %s to re-embed %d chunks with %s", job.ID, job.TotalChunks, job.EmbeddingModel)
+	s.startReindex(job)
+	return nil
+}
+
+// activeReindexJob 返回未结束的任务，没有时返回nil，调用方需持有reindexMu
+//
+// 任务的嵌入模型与rag.embedding_model不一致时（任务执行期间修改了配置），任务标记为失败并删除其集合。
+func (s *RAGService) activeReindexJob(ctx context.Context) (*model.ReindexJob, error) {
+	jobs, err := s.ListReindexJobs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var active *model.ReindexJob
+	for _, job := range jobs {
+		if !job.Active() {
+			continue
+		}
+		if job.EmbeddingModel == s.embeddingModel && job.EmbeddingDim == s.embeddingDim && active == nil {
+			active = job
+			continue
+		}
+
+		now := time.Now()
+		job.Status = model.ReindexStatusFailed
+		job.Error = fmt.Sprintf("rag.embedding_model changed to %s", s.embeddingModel)
+		job.UpdatedAt = now
+		job.FinishedAt = &now
+		if err := s.store.SaveReindexJob(ctx, job); err != nil {
+			return nil, fmt.Errorf("save reindex job: %w", err)
+		}
+		if err := s.vectorStore.DropCollection(ctx, job.Collection); err != nil {
+			log.Printf("Warning: failed to drop collection %s of reindex job %s: %v", job.Collection, job.ID, err)
+		}
+	}
+	return active, nil
+}
+
+// newReindexJob 创建新集合并保存任务，调用方需持有reindexMu
+//
+// 之前失败的任务留下的集合在此时删除，这些任务恢复时从头开始。
+func (s *RAGService) newReindexJob(ctx context.Context) (*model.ReindexJob, error) {
+	s.mu.RLock()
+	rowBacked := s.rowBacked
+	s.mu.RUnlock()
+	if !rowBacked {
+		return nil, fmt.Errorf("%w: collection %s was created by an earlier version, run \"server reembed\" to migrate it",
+			storage.ErrConflict, s.collectionName)
+	}
+
+	jobs, err := s.ListReindexJobs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, job := range jobs {
+		if job.Status != model.ReindexStatusFailed {
+			continue
+		}
+		if err := s.vectorStore.DropCollection(ctx, job.Collection); err != nil {
+			log.Printf("Warning: failed to drop collection %s of reindex job %s: %v", job.Collection, job.ID, err)
+		}
+	}
+
+	total, err := s.store.CountKnowledgeChunks(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("count knowledge chunks: %w", err)
+	}
+
+	now := time.Now()
+	job := &model.ReindexJob{
+		ID:             uuid.New().String(),
+		Status:         model.ReindexStatusPending,
+		Collection:     s.newCollectionName(),
+		EmbeddingModel: s.embeddingModel,
+		EmbeddingDim:   s.embeddingDim,
+		TotalChunks:    total,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if err := s.vectorStore.CreateCollection(ctx, job.Collection, s.collectionInfo()); err != nil {
+		return nil, fmt.Errorf("create collection: %w", err)
+	}
+	if err := s.store.SaveReindexJob(ctx, job); err != nil {
+		s.vectorStore.DropCollection(ctx, job.Collection)
+		return nil, fmt.Errorf("save reindex job: %w", err)
+	}
+	return job, nil
+}
+
+// startReindex 在后台执行任务，调用方需持有reindexMu
+func (s *RAGService) startReindex(job *model.ReindexJob) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	s.reindexCancel[job.ID] = cancel
+	s.reindexWG.Add(1)
+
+	go func() {
+		defer s.reindexWG.Done()
+		s.runReindex(ctx, job, nil)
+
+		s.reindexMu.Lock()
+		delete(s.reindexCancel, job.ID)
+		s.reindexMu.Unlock()
+		cancel(nil)
+	}()
+}
+
+// stopReindexJobs 停止正在执行的任务并等待其退出，任务保持执行中状态，下次启动后继续
+func (s *RAGService) stopReindexJobs() {
+	s.reindexMu.Lock()
+	for _, cancel := range s.reindexCancel {
+		cancel(errReindexStopped)
+	}
+	s.reindexMu.Unlock()
+
+	s.reindexWG.Wait()
+}
+
+// runReindex 执行任务直到完成、失败或被取消，每写入一批分块调用一次progress，progress可以为nil
+//
+// 任务失败时保留新集合，恢复后从上次写入的分块之后继续；被暂停或停止时不修改任务状态。
+func (s *RAGService) runReindex(ctx context.Context, job *model.ReindexJob, progress func(job *model.ReindexJob)) error {
+	err := s.updateReindexJob(ctx, job, func() { job.Status = model.ReindexStatusRunning })
+	if err == nil {
+		err = s.reindex(ctx, job, progress)
+	}
+	if err == nil {
+		s.finishReindexJob(job, nil)
+		log.Printf("Reindex job %s completed, collection %s now points to %s", job.ID, s.collectionName, job.Collection)
+		return nil
+	}
+
+	if cause := context.Cause(ctx); errors.Is(cause, errReindexPaused) || errors.Is(cause, errReindexStopped) {
+		return cause
+	}
+	log.Printf("Warning: reindex job %s failed: %v", job.ID, err)
+	s.finishReindexJob(job, err)
+	return err
+}
+
+// reindex 按分块ID顺序将分块嵌入到新集合，全部完成后切换别名
+func (s *RAGService) reindex(ctx context.Context, job *model.ReindexJob, progress func(job *model.ReindexJob)) error {
+	for {
+		n, err := s.reindexPage(ctx, job)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			break
+		}
+		if err := s.updateReindexJob(ctx, job, func() {}); err != nil {
+			return err
+		}
+		if progress != nil {
+			progress(job)
+		}
+	}
+	return s.switchCollection(ctx, job)
+}
+
+// reindexPage 读取上次写入的分块之后的一页分块，分批并发嵌入并写入新集合，返回写入的分块数
+//
+// 每页包含reindex_concurrency批，同时最多发出reindex_concurrency个嵌入请求。
+func (s *RAGService) reindexPage(ctx context.Context, job *model.ReindexJob) (int, error) {
+	chunks, err := s.store.ListKnowledgeChunks(ctx, job.LastChunkID, s.embedBatchSize*s.reindexConcurrency)
+	if err != nil {
+		return 0, fmt.Errorf("list knowledge chunks: %w", err)
+	}
+	if len(chunks) == 0 {
+		return 0, nil
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 0, s.reindexConcurrency)
+	var errsMu sync.Mutex
+	for start := 0; start < len(chunks); start += s.embedBatchSize {
+		batch := chunks[start:min(start+s.embedBatchSize, len(chunks))]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.reindexBatch(ctx, job, batch); err != nil {
+				errsMu.Lock()
+				errs = append(errs, err)
+				errsMu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return 0, errs[0]
+	}
+
+	job.LastChunkID = chunks[len(chunks)-1].ID
+	job.EmbeddedChunks += len(chunks)
+	job.TotalChunks = max(job.TotalChunks, job.EmbeddedChunks)
+	return len(chunks), nil
+}
+
+// reindexBatch 重新读取一批分块，用任务的嵌入模型生成向量并写入新集合
+//
+// 读取页面之后分块可能已被修改或删除，持有chunkMu的读锁按ID范围重新读取，
+// 已删除的分块不再写入，修改过的分块使用新内容。
+func (s *RAGService) reindexBatch(ctx context.Context, job *model.ReindexJob, chunks []*model.KnowledgeChunk) error {
+	s.chunkMu.RLock()
+	defer s.chunkMu.RUnlock()
+
+	// 分块ID递增，范围内只可能少不可能多
+	first, last := chunks[0].ID, chunks[len(chunks)-1].ID
+	current, err := s.store.ListKnowledgeChunks(ctx, first-1, len(chunks))
+	if err != nil {
+		return fmt.Errorf("list knowledge chunks: %w", err)
+	}
+	current = slices.DeleteFunc(current, func(chunk *model.KnowledgeChunk) bool { return chunk.ID > last })
+	if len(current) == 0 {
+		return nil
+	}
+	return s.insertReindexChunks(ctx, job, current)
+}
+
+// insertReindexChunks 用任务的嵌入模型生成分块的向量并写入新集合，主键已存在时覆盖，调用方需持有chunkMu
+func (s *RAGService) insertReindexChunks(ctx context.Context, job *model.ReindexJob, chunks []*model.KnowledgeChunk) error {
+	contents := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		contents[i] = chunk.Content
+	}
+	embeddings, err := s.embedBatch(ctx, job.EmbeddingModel, contents)
+	if err != nil {
+		return fmt.Errorf("generate embeddings: %w", err)
+	}
+
+	entities := make([]vector.Entity, len(chunks))
+	for i, chunk := range chunks {
+		entities[i] = chunkEntity(chunk, embeddings[i])
+	}
+	return s.vectorStore.Insert(ctx, job.Collection, entities)
+}
+
+// switchCollection 将知识库别名切换到任务的新集合，并删除原集合
+//
+// 持有写锁，先补齐最后一次读取之后新写入的分块，切换期间不会再写入新分块。
+func (s *RAGService) switchCollection(ctx context.Context, job *model.ReindexJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for {
+		n, err := s.reindexPage(ctx, job)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			break
+		}
+	}
+
+	old, err := s.vectorStore.DescribeCollection(ctx, s.collectionName)
+	if errors.Is(err, vector.ErrCollectionNotFound) {
+		old = nil
+	} else if err != nil {
+		return err
+	}
+	if old != nil && old.Collection == s.collectionName {
+		// 早期版本创建的集合与别名同名，只能先删除集合再创建别名
+		if err := s.vectorStore.DropCollection(ctx, s.collectionName); err != nil {
+			return err
+		}
+	}
+	if err := s.vectorStore.SetAlias(ctx, s.collectionName, job.Collection); err != nil {
+		return err
+	}
+	if old != nil && old.Collection != s.collectionName && old.Collection != job.Collection {
+		if err := s.vectorStore.DropCollection(ctx, old.Collection); err != nil {
+			log.Printf("Warning: failed to drop previous collection %s: %v", old.Collection, err)
+		}
+	}
+
+	s.activeModel, s.activeDim, s.rowBacked = job.EmbeddingModel, job.EmbeddingDim, true
+	return nil
+}
+
+// updateReindexJob 修改并保存执行中的任务；任务已被暂停或停止时不保存，返回取消原因
+func (s *RAGService) updateReindexJob(ctx context.Context, job *model.ReindexJob, fn func()) error {
+	s.reindexMu.Lock()
+	defer s.reindexMu.Unlock()
+
+	// 暂停和停止在持有reindexMu时取消任务，此处检查后不会覆盖暂停状态
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+	fn()
+	job.UpdatedAt = time.Now()
+	if err := s.store.SaveReindexJob(ctx, job); err != nil {
+		return fmt.Errorf("save reindex job: %w", err)
+	}
+	return nil
+}
+
+// finishReindexJob 保存任务结果，err为nil时任务完成，否则失败
+func (s *RAGService) finishReindexJob(job *model.ReindexJob, err error) {
+	s.reindexMu.Lock()
+	defer s.reindexMu.Unlock()
+
+	now := time.Now()
+	job.UpdatedAt = now
+	job.FinishedAt = &now
+	job.Status = model.ReindexStatusCompleted
+	if err != nil {
+		job.Status = model.ReindexStatusFailed
+		job.Error = err.Error()
+	}
+	// 任务可能已被取消，使用新的context保存结果
+	if err := s.store.SaveReindexJob(context.Background(), job); err != nil {
+		log.Printf("Warning: failed to save reindex job %s: %v", job.ID, err)
+	}
+}
@@ -0,0 +1,282 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"eino/internal/config"
+	"eino/internal/model"
+	"eino/internal/storage"
+	"eino/internal/storage/memory"
+	"eino/internal/storage/vector"
+)
+
+// hookStorage 包装存储，第一次ListKnowledgeChunks返回后调用一次hook
+type hookStorage struct {
+	storage.Storage
+
+	once sync.Once
+	hook func()
+}
+
+func (h *hookStorage) ListKnowledgeChunks(ctx context.Context, afterID int64, limit int) ([]*model.KnowledgeChunk, error) {
+	chunks, err := h.Storage.ListKnowledgeChunks(ctx, afterID, limit)
+	h.once.Do(h.hook)
+	return chunks, err
+}
+
+// addChunks 每段内容作为一个文档写入默认知识库，返回分块ID
+func addChunks(t *testing.T, s *RAGService, contents ...string) []int64 {
+	t.Helper()
+	ctx := context.Background()
+	ids := make([]int64, len(contents))
+	for i, content := range contents {
+		documentID, err := s.AddKnowledge(ctx, model.DefaultKnowledgeBase, content)
+		if err != nil {
+			t.Fatalf("AddKnowledge: %v", err)
+		}
+		page, err := s.ListKnowledge(ctx, model.KnowledgeQuery{DocumentID: documentID, Limit: 2})
+		if err != nil || len(page.Items) != 1 {
+			t.Fatalf("ListKnowledge(%s) = %v, %v, want one chunk", documentID, page, err)
+		}
+		ids[i] = page.Items[0].ID
+	}
+	return ids
+}
+
+// collectionContents 集合中的全部向量，分块ID -> 内容
+func collectionContents(t *testing.T, s *RAGService, collection string) map[int64]string {
+	t.Helper()
+	contents := make(map[int64]string)
+	err := s.vectorStore.Scan(context.Background(), collection, func(e vector.Entity) error {
+		contents[e.ID] = e.Content
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Scan(%s): %v", collection, err)
+	}
+	return contents
+}
+
+// currentCollection 知识库别名当前指向的集合
+func currentCollection(t *testing.T, s *RAGService) string {
+	t.Helper()
+	info, err := s.vectorStore.DescribeCollection(context.Background(), s.collectionName)
+	if err != nil {
+		t.Fatalf("DescribeCollection(%s): %v", s.collectionName, err)
+	}
+	return info.Collection
+}
+
+// mustReindexJob 读取任务并检查状态和已写入的分块数
+func mustReindexJob(t *testing.T, s *RAGService, id, status string, embedded int) *model.ReindexJob {
+	t.Helper()
+	job, err := s.GetReindexJob(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetReindexJob: %v", err)
+	}
+	if job.Status != status || job.EmbeddedChunks != embedded {
+		t.Fatalf("job = %s with %d embedded chunks (%s), want %s with %d", job.Status, job.EmbeddedChunks, job.Error, status, embedded)
+	}
+	return job
+}
+
+// stopAfterFirstPage 执行任务，写入第一页并保存进度后以cause取消；
+// 取消函数登记在reindexCancel中，与后台执行的任务一样可以被暂停
+func stopAfterFirstPage(t *testing.T, s *RAGService, cause error) *model.ReindexJob {
+	t.Helper()
+	ctx, cancel := context.WithCancelCause(context.Background())
+	s.reindexMu.Lock()
+	job, err := s.newReindexJob(ctx)
+	if err == nil {
+		s.reindexCancel[job.ID] = cancel
+	}
+	s.reindexMu.Unlock()
+	if err != nil {
+		t.Fatalf("newReindexJob: %v", err)
+	}
+
+	var once sync.Once
+	err = s.runReindex(ctx, job, func(job *model.ReindexJob) {
+		once.Do(func() {
+			if cause != errReindexPaused {
+				cancel(cause)
+			} else if _, err := s.PauseReindex(context.Background(), job.ID); err != nil {
+				t.Errorf("PauseReindex: %v", err)
+			}
+		})
+	})
+	if !errors.Is(err, cause) {
+		t.Fatalf("runReindex error = %v, want %v", err, cause)
+	}
+
+	s.reindexMu.Lock()
+	delete(s.reindexCancel, job.ID)
+	s.reindexMu.Unlock()
+	return job
+}
+
+// runReindexJob 创建任务并在当前goroutine中执行到结束
+func runReindexJob(t *testing.T, s *RAGService) *model.ReindexJob {
+	t.Helper()
+	s.reindexMu.Lock()
+	job, err := s.newReindexJob(context.Background())
+	s.reindexMu.Unlock()
+	if err != nil {
+		t.Fatalf("newReindexJob: %v", err)
+	}
+	if err := s.runReindex(context.Background(), job, nil); err != nil {
+		t.Fatalf("runReindex: %v", err)
+	}
+	return job
+}
+
+func TestReindexSeesConcurrentWrites(t *testing.T) {
+	s, store := newTestService(t)
+	ctx := context.Background()
+	ids := addChunks(t, s, "退货政策：七天内无理由退货", "运费由买家承担", "会员享受免费配送")
+
+	// 任务读取第一页之后、写入新集合之前删除和修改分块
+	updated := "运费由商家承担"
+	s.store = &hookStorage{Storage: store, hook: func() {
+		if err := s.DeleteKnowledge(ctx, ids[0]); err != nil {
+			t.Errorf("DeleteKnowledge: %v", err)
+		}
+		if _, err := s.UpdateKnowledge(ctx, ids[1], &model.UpdateKnowledgeRequest{Content: &updated}); err != nil {
+			t.Errorf("UpdateKnowledge: %v", err)
+		}
+	}}
+
+	job := runReindexJob(t, s)
+	got := collectionContents(t, s, job.Collection)
+	want := map[int64]string{ids[1]: updated, ids[2]: "会员享受免费配送"}
+	if len(got) != len(want) || got[ids[1]] != want[ids[1]] || got[ids[2]] != want[ids[2]] {
+		t.Fatalf("reindexed collection = %v, want %v", got, want)
+	}
+}
+
+func TestReindexSwitchesAlias(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+	ids := addChunks(t, s, "退货政策：七天内无理由退货", "运费由买家承担", "会员享受免费配送")
+	old := currentCollection(t, s)
+
+	started, err := s.StartReindex(ctx)
+	if err != nil {
+		t.Fatalf("StartReindex: %v", err)
+	}
+	s.reindexWG.Wait()
+
+	job := mustReindexJob(t, s, started.ID, model.ReindexStatusCompleted, len(ids))
+	if job.LastChunkID != ids[len(ids)-1] || job.FinishedAt == nil {
+		t.Fatalf("job = %+v, want last chunk %d and a finish time", job, ids[len(ids)-1])
+	}
+	if got := currentCollection(t, s); got != job.Collection || got == old {
+		t.Fatalf("alias points to %s, want %s", got, job.Collection)
+	}
+	if _, err := s.vectorStore.DescribeCollection(ctx, old); !errors.Is(err, vector.ErrCollectionNotFound) {
+		t.Fatalf("DescribeCollection(old) error = %v, want ErrCollectionNotFound", err)
+	}
+	if got := collectionContents(t, s, job.Collection); len(got) != len(ids) {
+		t.Fatalf("reindexed collection = %v, want %d chunks", got, len(ids))
+	}
+
+	// 切换后的检索和写入都使用新集合
+	result, err := s.SearchKnowledge(ctx, "退货政策", model.SearchOptions{TopK: 1, Mode: model.SearchModeVector})
+	if err != nil {
+		t.Fatalf("SearchKnowledge: %v", err)
+	}
+	if len(result.Hits) != 1 || result.Hits[0].ID != ids[0] {
+		t.Fatalf("hits = %+v, want chunk %d", result.Hits, ids[0])
+	}
+	added := addChunks(t, s, "积分可以抵扣运费")
+	if got := collectionContents(t, s, job.Collection); got[added[0]] != "积分可以抵扣运费" {
+		t.Fatalf("chunk added after the switch is missing from %s", job.Collection)
+	}
+}
+
+func TestReindexPauseResume(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+	s.embedBatchSize, s.reindexConcurrency = 1, 1
+	ids := addChunks(t, s, "退货政策：七天内无理由退货", "运费由买家承担", "会员享受免费配送")
+	old := currentCollection(t, s)
+
+	job := stopAfterFirstPage(t, s, errReindexPaused)
+	paused := mustReindexJob(t, s, job.ID, model.ReindexStatusPaused, 1)
+	if paused.LastChunkID != ids[0] {
+		t.Fatalf("paused at chunk %d, want %d", paused.LastChunkID, ids[0])
+	}
+	if got := currentCollection(t, s); got != old {
+		t.Fatalf("alias switched to %s while the job is paused", got)
+	}
+
+	// 暂停的任务仍未结束，不能再创建任务，也不能重复暂停
+	if _, err := s.StartReindex(ctx); !errors.Is(err, storage.ErrConflict) {
+		t.Fatalf("StartReindex while paused error = %v, want ErrConflict", err)
+	}
+	if _, err := s.PauseReindex(ctx, job.ID); !errors.Is(err, storage.ErrConflict) {
+		t.Fatalf("PauseReindex while paused error = %v, want ErrConflict", err)
+	}
+
+	// 暂停期间新增的分块在恢复后写入
+	ids = append(ids, addChunks(t, s, "积分可以抵扣运费")...)
+	if _, err := s.ResumeReindex(ctx, job.ID); err != nil {
+		t.Fatalf("ResumeReindex: %v", err)
+	}
+	s.reindexWG.Wait()
+
+	// 从暂停的位置继续，已写入的分块不重复计数
+	done := mustReindexJob(t, s, job.ID, model.ReindexStatusCompleted, len(ids))
+	if got := currentCollection(t, s); got != done.Collection {
+		t.Fatalf("alias points to %s, want %s", got, done.Collection)
+	}
+	if got := collectionContents(t, s, done.Collection); len(got) != len(ids) {
+		t.Fatalf("reindexed collection = %v, want %d chunks", got, len(ids))
+	}
+	if _, err := s.ResumeReindex(ctx, job.ID); !errors.Is(err, storage.ErrConflict) {
+		t.Fatalf("ResumeReindex after completion error = %v, want ErrConflict", err)
+	}
+}
+
+func TestReindexResumesAfterRestart(t *testing.T) {
+	cfg, err := config.Load("configs/eval.yaml")
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	// 向量库写入快照，重启后保留集合
+	cfg.RAG.Memory.SnapshotPath = filepath.Join(t.TempDir(), "vectors.snapshot")
+	store := memory.NewMemoryStorage()
+
+	s, err := NewRAGService(cfg.RAG, cfg.Storage, store)
+	if err != nil {
+		t.Fatalf("NewRAGService: %v", err)
+	}
+	s.embedBatchSize, s.reindexConcurrency = 1, 1
+	ids := addChunks(t, s, "退货政策：七天内无理由退货", "运费由买家承担", "会员享受免费配送")
+
+	// 停止的任务保持执行中状态
+	job := stopAfterFirstPage(t, s, errReindexStopped)
+	mustReindexJob(t, s, job.ID, model.ReindexStatusRunning, 1)
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	restarted, err := NewRAGService(cfg.RAG, cfg.Storage, store)
+	if err != nil {
+		t.Fatalf("NewRAGService after restart: %v", err)
+	}
+	t.Cleanup(func() { restarted.Close() })
+	restarted.reindexWG.Wait()
+
+	done := mustReindexJob(t, restarted, job.ID, model.ReindexStatusCompleted, len(ids))
+	if got := currentCollection(t, restarted); got != done.Collection {
+		t.Fatalf("alias points to %s, want %s", got, done.Collection)
+	}
+	if got := collectionContents(t, restarted, done.Collection); len(got) != len(ids) {
+		t.Fatalf("reindexed collection = %v, want %d chunks", got, len(ids))
+	}
+}
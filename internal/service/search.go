@@ -0,0 +1,390 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"eino/internal/config"
+	"eino/internal/document"
+	"eino/internal/model"
+	"eino/internal/rerank"
+	"eino/internal/storage/keyword"
+	"eino/internal/storage/vector"
+
+	"github.com/cloudwego/eino-ext/components/model/ollama"
+)
+
+// defaultSearchTopK 未指定条数时检索的知识条数
+const defaultSearchTopK = 3
+
+// keywordIndexPageSize 启动时从knowledge_base表加载关键词索引的每页分块数
+const keywordIndexPageSize = 500
+
+// ErrKeywordSearchUnavailable 早期版本创建的集合没有把分块保存在knowledge_base表中，无法建立关键词索引
+var ErrKeywordSearchUnavailable = errors.New("keyword search unavailable")
+
+// candidateCount 混合检索时每种方式取回的候选条数，多取一些让融合后的排序更准确
+func candidateCount(n int) int {
+	return max(2*n, 20)
+}
+
+// loadKeywordIndex 从knowledge_base表加载全部分块到关键词索引
+//
+// 早期版本创建的集合没有把分块保存在表中，关键词检索不可用，混合检索只使用向量检索。
+func (s *RAGService) loadKeywordIndex(ctx context.Context) error {
+	if !s.rowBacked {
+		log.Printf("Warning: keyword search is unavailable until collection %s is migrated with \"server reembed\"", s.collectionName)
+		return nil
+	}
+
+	var afterID int64
+	for {
+		chunks, err := s.store.ListKnowledgeChunks(ctx, afterID, keywordIndexPageSize)
+		if err != nil {
+			return fmt.Errorf("load keyword index: %w", err)
+		}
+		if len(chunks) == 0 {
+			return nil
+		}
+		s.keywords.Add(chunks...)
+		afterID = chunks[len(chunks)-1].ID
+	}
+}
+
+// SearchKnowledge 按检索方式在指定知识库中检索相关知识，返回按相关度从高到低排列的结果和检索过程
+//
+// 混合检索分别取回向量检索和关键词检索的候选，按倒数排名融合（RRF）：
+// 得分为各方式的权重除以rrf_k加名次之和。单一方式的得分按同样的公式计算。
+// 设置了最大距离或最低关键词得分时，融合后的结果须满足其中之一才保留（见relevant），
+// 避免只在关键词上沾边的结果被加入提示词。
+// 指定了其他问法时分别检索每种问法，各问法的结果再按倒数排名合并。
+// 启用重排序时融合后保留rerank.candidates条候选，重新打分后取前topK条；重排序失败时沿用融合的顺序。
+func (s *RAGService) SearchKnowledge(ctx context.Context, query string, opts model.SearchOptions) (*model.SearchResult, error) {
+	mode := opts.Mode
+	if mode == "" {
+		mode = s.retrieval.Mode
+	}
+	if err := model.ValidateSearchMode(mode); err != nil {
+		return nil, err
+	}
+	kbs := opts.KnowledgeBases
+	if len(kbs) == 0 {
+		kbs = []string{model.DefaultKnowledgeBase}
+	}
+	topK := opts.TopK
+	if topK <= 0 {
+		topK = defaultSearchTopK
+	}
+	candidates := topK
+	if s.reranker != nil {
+		candidates = max(s.rerank.Candidates, topK)
+	}
+	maxDistance := opts.MaxDistance
+	if maxDistance == nil {
+		maxDistance = s.retrieval.MaxDistance
+	}
+	minKeywordScore := opts.MinKeywordScore
+	if minKeywordScore == nil {
+		minKeywordScore = s.retrieval.MinKeywordScore
+	}
+
+	trace := &model.RetrievalTrace{
+		Query:           query,
+		KnowledgeBases:  kbs,
+		MaxDistance:     maxDistance,
+		MinKeywordScore: minKeywordScore,
+	}
+	start := time.Now()
+	hits, err := s.retrieve(ctx, query, mode, kbs, candidates, trace)
+	if err != nil {
+		return nil, err
+	}
+	if len(opts.Variants) > 0 {
+		lists := [][]*model.KnowledgeHit{hits}
+		for _, variant := range opts.Variants {
+			variantHits, err := s.retrieve(ctx, variant, mode, kbs, candidates, trace)
+			if err != nil {
+				return nil, err
+			}
+			lists = append(lists, variantHits)
+		}
+		hits = s.mergeQueries(lists, candidates)
+		trace.Variants = opts.Variants
+	}
+	trace.Candidates = len(hits)
+	trace.SearchMS = time.Since(start).Milliseconds()
+
+	if s.reranker != nil && len(hits) > 0 {
+		hits, trace.Rerank = s.rerankHits(ctx, query, hits, topK)
+	} else if len(hits) > topK {
+		hits = hits[:topK]
+	}
+
+	trace.Results = make([]int64, len(hits))
+	for i, hit := range hits {
+		trace.Results[i] = hit.ID
+	}
+	return &model.SearchResult{Hits: hits, Trace: trace}, nil
+}
+
+// retrieve 按检索方式取回前n条融合后的结果，在trace中记录实际使用的检索方式，并累计未通过相关性阈值的结果数
+//
+// 关键词检索设置了最大距离时也生成查询向量，计算各结果与查询的距离，不参与排序。
+func (s *RAGService) retrieve(ctx context.Context, query, mode string, kbs []string, n int, trace *model.RetrievalTrace) ([]*model.KnowledgeHit, error) {
+	// 持有读锁，查询向量与集合使用同一个嵌入模型
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if !s.rowBacked {
+		if mode == model.SearchModeKeyword {
+			return nil, fmt.Errorf("%w: collection %s was created by an earlier version, run \"server reembed\" to migrate it",
+				ErrKeywordSearchUnavailable, s.collectionName)
+		}
+		mode = model.SearchModeVector
+	}
+	trace.Mode = mode
+
+	perSource := n
+	if mode == model.SearchModeHybrid {
+		perSource = candidateCount(n)
+	}
+
+	var queryVector []float32
+	if mode != model.SearchModeKeyword || trace.MaxDistance != nil {
+		embeddings, err := s.embedBatch(ctx, s.activeModel, []string{query})
+		if err != nil {
+			return nil, fmt.Errorf("generate query embedding: %w", err)
+		}
+		queryVector = embeddings[0]
+	}
+	var vectorResults []vector.SearchResult
+	if mode != model.SearchModeKeyword {
+		var err error
+		if vectorResults, err = s.vectorStore.Search(ctx, s.collectionName, queryVector, kbs, perSource); err != nil {
+			return nil, fmt.Errorf("search vectors: %w", err)
+		}
+	}
+	var keywordHits []keyword.Hit
+	if mode != model.SearchModeVector {
+		keywordHits = s.keywords.Search(query, kbs, perSource)
+	}
+
+	hits := s.fuse(vectorResults, keywordHits)
+	if mode == model.SearchModeKeyword && queryVector != nil {
+		if err := s.attachDistances(ctx, hits, queryVector); err != nil {
+			return nil, err
+		}
+	}
+
+	if trace.MaxDistance != nil || trace.MinKeywordScore != nil {
+		kept := hits[:0]
+		for _, hit := range hits {
+			if relevant(hit, trace.MaxDistance, trace.MinKeywordScore) {
+				kept = append(kept, hit)
+			}
+		}
+		trace.Filtered += len(hits) - len(kept)
+		hits = kept
+	}
+	if len(hits) > n {
+		hits = hits[:n]
+	}
+	return hits, nil
+}
+
+// relevant 融合后的结果是否通过相关性阈值：与查询的向量距离不超过maxDistance，或关键词得分不低于minKeywordScore
+//
+// 只设置了maxDistance时，没有向量距离的结果（只被关键词检索命中）不保留；只设置了minKeywordScore时同理。
+func relevant(hit *model.KnowledgeHit, maxDistance, minKeywordScore *float64) bool {
+	if maxDistance != nil && hit.Distance != nil && float64(*hit.Distance) <= *maxDistance {
+		return true
+	}
+	return minKeywordScore != nil && hit.KeywordRank > 0 && hit.KeywordScore >= *minKeywordScore
+}
+
+// attachDistances 为关键词检索结果补上与查询向量的距离，调用方需持有mu的读锁
+func (s *RAGService) attachDistances(ctx context.Context, hits []*model.KnowledgeHit, queryVector []float32) error {
+	ids := make([]int64, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ID
+	}
+	distances, err := s.vectorStore.Distances(ctx, s.collectionName, queryVector, ids)
+	if err != nil {
+		return fmt.Errorf("vector distances: %w", err)
+	}
+	for _, hit := range hits {
+		if distance, ok := distances[hit.ID]; ok {
+			hit.Distance = &distance
+		}
+	}
+	return nil
+}
+
+// rerankHits 重新为候选打分，按得分从高到低保留不低于阈值的前topK条
+func (s *RAGService) rerankHits(ctx context.Context, query string, hits []*model.KnowledgeHit, topK int) ([]*model.KnowledgeHit, *model.RerankTrace) {
+	trace := &model.RerankTrace{Provider: s.rerank.Provider, Model: s.rerank.Model, Threshold: s.rerank.Threshold}
+
+	start := time.Now()
+	scores, err := s.reranker.Rerank(ctx, query, hitContents(hits))
+	trace.LatencyMS = time.Since(start).Milliseconds()
+	if err != nil {
+		log.Printf("Warning: rerank failed, keeping fused order: %v", err)
+		trace.Error = err.Error()
+		return hits[:min(topK, len(hits))], trace
+	}
+
+	for i, hit := range hits {
+		hit.RerankScore = &scores[i]
+	}
+	slices.SortStableFunc(hits, func(a, b *model.KnowledgeHit) int {
+		return cmp.Compare(*b.RerankScore, *a.RerankScore)
+	})
+
+	kept := make([]*model.KnowledgeHit, 0, topK)
+	for _, hit := range hits {
+		keep := len(kept) < topK && (s.rerank.Threshold == nil || *hit.RerankScore >= *s.rerank.Threshold)
+		if keep {
+			kept = append(kept, hit)
+		}
+		trace.Scores = append(trace.Scores, model.RerankScore{ID: hit.ID, Score: *hit.RerankScore, Kept: keep})
+	}
+	return kept, trace
+}
+
+// mergeQueries 按倒数排名合并多种问法的检索结果，同一分块的得分相加，返回前n条
+//
+// 合并后的分块保留第一次出现时各检索方式的名次和得分。
+func (s *RAGService) mergeQueries(lists [][]*model.KnowledgeHit, n int) []*model.KnowledgeHit {
+	k := float64(s.retrieval.RRFK)
+	byID := make(map[int64]*model.KnowledgeHit)
+	var merged []*model.KnowledgeHit
+	for _, list := range lists {
+		for i, hit := range list {
+			m, ok := byID[hit.ID]
+			if !ok {
+				m = hit
+				m.Score = 0
+				byID[hit.ID] = m
+				merged = append(merged, m)
+			}
+			m.Score += 1 / (k + float64(i+1))
+		}
+	}
+
+	// 得分相同时原问题的结果在前
+	slices.SortStableFunc(merged, func(a, b *model.KnowledgeHit) int {
+		return cmp.Compare(b.Score, a.Score)
+	})
+	if len(merged) > n {
+		merged = merged[:n]
+	}
+	return merged
+}
+
+// fuse 按倒数排名融合向量检索和关键词检索的结果，同一分块的得分相加，按得分从高到低返回全部结果
+func (s *RAGService) fuse(vectorResults []vector.SearchResult, keywordHits []keyword.Hit) []*model.KnowledgeHit {
+	k := float64(s.retrieval.RRFK)
+	byID := make(map[int64]*model.KnowledgeHit)
+	hits := make([]*model.KnowledgeHit, 0, len(vectorResults)+len(keywordHits))
+
+	for i, r := range vectorResults {
+		distance := r.Distance
+		hit := &model.KnowledgeHit{
+			ID:            r.ID,
+			KnowledgeBase: r.KnowledgeBase,
+			Content:       r.Content,
+			Source:        metadataSource(r.Metadata),
+			Metadata:      r.Metadata,
+			Score:         s.retrieval.VectorWeight / (k + float64(i+1)),
+			VectorRank:    i + 1,
+			Distance:      &distance,
+		}
+		byID[r.ID] = hit
+		hits = append(hits, hit)
+	}
+
+	for i, h := range keywordHits {
+		hit, ok := byID[h.Chunk.ID]
+		if !ok {
+			hit = &model.KnowledgeHit{
+				ID:            h.Chunk.ID,
+				KnowledgeBase: h.Chunk.KnowledgeBase,
+				Content:       h.Chunk.Content,
+				Source:        h.Chunk.Source,
+				Metadata:      h.Chunk.Metadata,
+			}
+			hits = append(hits, hit)
+		}
+		hit.Score += s.retrieval.KeywordWeight / (k + float64(i+1))
+		hit.KeywordRank = i + 1
+		hit.KeywordScore = h.Score
+	}
+
+	// 得分相同时向量检索名次靠前的在前
+	slices.SortStableFunc(hits, func(a, b *model.KnowledgeHit) int {
+		return cmp.Compare(b.Score, a.Score)
+	})
+	return hits
+}
+
+// newReranker 按rag.rerank创建重排序器，未设置provider时返回nil
+func newReranker(cfg config.RAGConfig) (rerank.Reranker, error) {
+	rc := cfg.Rerank
+	timeout := time.Duration(rc.Timeout) * time.Second
+	switch rc.Provider {
+	case "":
+		return nil, nil
+	case "api":
+		if rc.URL == "" {
+			return nil, fmt.Errorf("rag.rerank.url is required for provider api")
+		}
+		return rerank.NewAPIReranker(rc.URL, rc.APIKey, rc.Model, timeout), nil
+	case "llm":
+		if rc.Model == "" {
+			return nil, fmt.Errorf("rag.rerank.model is required for provider llm")
+		}
+		baseURL := rc.URL
+		if baseURL == "" {
+			baseURL = cfg.OllamaURL
+		}
+		chatModel, err := ollama.NewChatModel(context.Background(), &ollama.ChatModelConfig{
+			BaseURL: baseURL,
+			Model:   rc.Model,
+			Timeout: timeout,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("create rerank model: %w", err)
+		}
+		return rerank.NewLLMReranker(chatModel), nil
+	default:
+		return nil, fmt.Errorf("unknown rag.rerank.provider %q: use api or llm", rc.Provider)
+	}
+}
+
+// metadataSource 读取分块元数据中的来源，元数据为空或不是JSON对象时返回空字符串
+func metadataSource(metadata []byte) string {
+	if len(metadata) == 0 {
+		return ""
+	}
+	var meta map[string]any
+	if json.Unmarshal(metadata, &meta) != nil {
+		return ""
+	}
+	source, _ := meta[document.MetaSource].(string)
+	return source
+}
+
+// hitContents 返回检索结果的内容
+func hitContents(hits []*model.KnowledgeHit) []string {
+	contents := make([]string, len(hits))
+	for i, hit := range hits {
+		contents[i] = hit.Content
+	}
+	return contents
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"math"
+	"slices"
+	"testing"
+
+	"eino/internal/model"
+	"eino/internal/storage/keyword"
+	"eino/internal/storage/vector"
+)
+
+// hitIDs 检索结果的分块ID
+func hitIDs(hits []*model.KnowledgeHit) []int64 {
+	ids := make([]int64, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ID
+	}
+	return ids
+}
+
+func TestFuse(t *testing.T) {
+	s, _ := newTestService(t)
+	s.retrieval.RRFK, s.retrieval.VectorWeight, s.retrieval.KeywordWeight = 10, 2, 1
+
+	vectorResults := []vector.SearchResult{
+		{Entity: vector.Entity{ID: 1, Content: "a"}, Distance: 0.1},
+		{Entity: vector.Entity{ID: 2, Content: "b"}, Distance: 0.2},
+	}
+	keywordHits := []keyword.Hit{
+		{Chunk: &model.KnowledgeChunk{ID: 3, Content: "c"}, Score: 5},
+		{Chunk: &model.KnowledgeChunk{ID: 1, Content: "a"}, Score: 3},
+	}
+	hits := s.fuse(vectorResults, keywordHits)
+
+	// 得分为权重/(rrf_k+名次)之和
+	want := []struct {
+		id                      int64
+		score                   float64
+		vectorRank, keywordRank int
+		distance                float32 // 0表示没有向量距离
+		keywordScore            float64
+	}{
+		{1, 2.0/11 + 1.0/12, 1, 2, 0.1, 3},
+		{2, 2.0 / 12, 2, 0, 0.2, 0},
+		{3, 1.0 / 11, 0, 1, 0, 5},
+	}
+	if len(hits) != len(want) {
+		t.Fatalf("fused %d hits, want %d", len(hits), len(want))
+	}
+	for i, w := range want {
+		hit := hits[i]
+		if hit.ID != w.id || math.Abs(hit.Score-w.score) > 1e-9 || hit.VectorRank != w.vectorRank ||
+			hit.KeywordRank != w.keywordRank || hit.KeywordScore != w.keywordScore {
+			t.Fatalf("hits[%d] = %+v, want %+v", i, hit, w)
+		}
+		if (hit.Distance == nil) != (w.distance == 0) || hit.Distance != nil && *hit.Distance != w.distance {
+			t.Fatalf("hits[%d] distance = %v, want %v", i, hit.Distance, w.distance)
+		}
+	}
+
+	// 得分相同时向量检索的结果在前
+	s.retrieval.VectorWeight = 1
+	hits = s.fuse(vectorResults[:1], keywordHits[:1])
+	if got := hitIDs(hits); !slices.Equal(got, []int64{1, 3}) {
+		t.Fatalf("tied hits = %v, want [1 3]", got)
+	}
+}
+
+func TestRelevant(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	distance := float32(0.25)
+	vectorHit := &model.KnowledgeHit{VectorRank: 1, Distance: &distance}
+	keywordHit := &model.KnowledgeHit{KeywordRank: 1, KeywordScore: 2}
+	bothHit := &model.KnowledgeHit{VectorRank: 1, Distance: &distance, KeywordRank: 1, KeywordScore: 2}
+
+	tests := []struct {
+		name                    string
+		hit                     *model.KnowledgeHit
+		maxDistance, minKeyword *float64
+		want                    bool
+	}{
+		{"WithinMaxDistance", vectorHit, f(0.5), nil, true},
+		{"AtMaxDistance", vectorHit, f(0.25), nil, true},
+		{"BeyondMaxDistance", vectorHit, f(0.2), nil, false},
+		{"VectorHitWithOnlyMinKeywordScore", vectorHit, nil, f(0), false},
+		{"KeywordHitWithOnlyMaxDistance", keywordHit, f(0.5), nil, false},
+		{"AboveMinKeywordScore", keywordHit, nil, f(1), true},
+		{"BelowMinKeywordScore", keywordHit, nil, f(3), false},
+		{"EitherThresholdKeeps", bothHit, f(0.2), f(1), true},
+		{"DistanceKeepsLowKeywordScore", bothHit, f(0.5), f(3), true},
+		{"BothThresholdsFail", bothHit, f(0.2), f(3), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := relevant(tt.hit, tt.maxDistance, tt.minKeyword); got != tt.want {
+				t.Fatalf("relevant = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeQueries(t *testing.T) {
+	s, _ := newTestService(t)
+	s.retrieval.RRFK = 10
+
+	original := []*model.KnowledgeHit{{ID: 1, VectorRank: 1, Score: 0.5}, {ID: 2, VectorRank: 2, Score: 0.4}}
+	variant := []*model.KnowledgeHit{{ID: 2, VectorRank: 1, KeywordRank: 1, Score: 0.9}, {ID: 3, Score: 0.1}}
+	merged := s.mergeQueries([][]*model.KnowledgeHit{original, variant}, 2)
+
+	// 分块2在两种问法中都被命中，排在最前；保留第一次出现时的名次
+	if got := hitIDs(merged); !slices.Equal(got, []int64{2, 1}) {
+		t.Fatalf("merged = %v, want [2 1]", got)
+	}
+	if math.Abs(merged[0].Score-(1.0/12+1.0/11)) > 1e-9 || merged[0].VectorRank != 2 || merged[0].KeywordRank != 0 {
+		t.Fatalf("merged[0] = %+v", merged[0])
+	}
+	if math.Abs(merged[1].Score-1.0/11) > 1e-9 {
+		t.Fatalf("merged[1] score = %v, want %v", merged[1].Score, 1.0/11)
+	}
+}
+
+func TestSearchThresholds(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+	ids := addChunks(t, s, "alpha", "alpha one", "beta gamma")
+	f := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name                    string
+		maxDistance, minKeyword *float64
+		want                    []int64
+		filtered                int
+	}{
+		{"NoThresholds", nil, nil, []int64{ids[0], ids[1], ids[2]}, 0},
+		// 只被向量检索命中的分块没有关键词得分
+		{"MinKeywordScore", nil, f(0), []int64{ids[0], ids[1]}, 1},
+		{"MaxDistance", f(0.01), nil, []int64{ids[0]}, 2},
+		{"EitherThreshold", f(0.01), f(0), []int64{ids[0], ids[1]}, 1},
+		{"NothingRelevant", f(-1), f(1000), nil, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := s.SearchKnowledge(ctx, "alpha", model.SearchOptions{
+				TopK: 3, Mode: model.SearchModeHybrid, MaxDistance: tt.maxDistance, MinKeywordScore: tt.minKeyword,
+			})
+			if err != nil {
+				t.Fatalf("SearchKnowledge: %v", err)
+			}
+			if got := hitIDs(result.Hits); !slices.Equal(got, tt.want) {
+				t.Fatalf("hits = %v, want %v", got, tt.want)
+			}
+			if result.Trace.Filtered != tt.filtered || result.Trace.Candidates != len(tt.want) {
+				t.Fatalf("trace filtered %d of %d candidates, want %d of %d",
+					result.Trace.Filtered, result.Trace.Candidates, tt.filtered, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestKeywordSearchMaxDistance(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+	// alpha出现在多数分块中，关键词得分最高的是含有少见词beta的长分块，向量距离最近的却是只有alpha的短分块
+	ids := addChunks(t, s, "alpha", "alpha one", "alpha two", "alpha three", "beta gamma delta epsilon zeta")
+
+	search := func(maxDistance float64) []*model.KnowledgeHit {
+		t.Helper()
+		result, err := s.SearchKnowledge(ctx, "alpha beta", model.SearchOptions{TopK: 1, Mode: model.SearchModeKeyword, MaxDistance: &maxDistance})
+		if err != nil {
+			t.Fatalf("SearchKnowledge: %v", err)
+		}
+		return result.Hits
+	}
+
+	// 关键词结果不在向量检索的前topK条中，也要按其自身的距离判断
+	hits := search(0.9)
+	if len(hits) != 1 || hits[0].ID != ids[4] || hits[0].Distance == nil {
+		t.Fatalf("hits = %+v, want the beta chunk with its distance", hits)
+	}
+	distance := float64(*hits[0].Distance)
+	if distance <= 0.3 || distance >= 0.9 {
+		t.Fatalf("distance = %v, want between 0.3 and 0.9", distance)
+	}
+	if hits := search(distance - 0.01); len(hits) != 0 {
+		t.Fatalf("hits with max_distance below the chunk distance = %+v, want none", hits)
+	}
+}
@@ -0,0 +1,10 @@
+package storage
+
+import "eino/internal/storage/errs"
+
+var (
+	// ErrNotFound 资源不存在，所有存储实现统一返回该错误，可用 errors.Is 判断
+	ErrNotFound = errs.ErrNotFound
+	// ErrConflict 操作与资源的当前状态冲突，例如已有未结束的任务
+	ErrConflict = errs.ErrConflict
+)
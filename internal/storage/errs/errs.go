@@ -0,0 +1,14 @@
+// Package errs 定义各存储实现共用的哨兵错误
+//
+// 独立成包是为了让 memory、mysql 等实现包引用同一个错误值，
+// 而不与 storage 包（依赖这些实现包）形成循环引用。
+package errs
+
+import "errors"
+
+var (
+	// ErrNotFound 资源不存在
+	ErrNotFound = errors.New("not found")
+	// ErrConflict 操作与资源的当前状态冲突
+	ErrConflict = errors.New("conflict")
+)
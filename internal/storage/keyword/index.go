@@ -0,0 +1,203 @@
+// Package keyword 提供进程内的BM25关键词索引，用于按精确的词（产品编号、名称等）检索知识分块，
+// 与向量检索的结果融合实现混合检索。
+package keyword
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+
+	"eino/internal/model"
+)
+
+// BM25参数
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Index 知识分块的倒排索引，按BM25打分；可以并发使用
+type Index struct {
+	mu       sync.RWMutex
+	docs     map[int64]*indexedChunk
+	postings map[string]map[int64]int // 词 -> 分块ID -> 词频
+	totalLen int                      // 全部分块的词数之和
+}
+
+// indexedChunk 索引中的一个分块
+type indexedChunk struct {
+	chunk  model.KnowledgeChunk
+	length int
+}
+
+// Hit 一条检索结果
+type Hit struct {
+	Chunk *model.KnowledgeChunk
+	Score float64 // BM25得分，越大越相关
+}
+
+// NewIndex 创建空索引
+func NewIndex() *Index {
+	return &Index{
+		docs:     make(map[int64]*indexedChunk),
+		postings: make(map[string]map[int64]int),
+	}
+}
+
+// Add 加入分块，ID已存在时替换原内容
+func (x *Index) Add(chunks ...*model.KnowledgeChunk) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	for _, chunk := range chunks {
+		x.remove(chunk.ID)
+
+		terms := Tokenize(chunk.Content)
+		for _, term := range terms {
+			posting, ok := x.postings[term]
+			if !ok {
+				posting = make(map[int64]int)
+				x.postings[term] = posting
+			}
+			posting[chunk.ID]++
+		}
+		x.docs[chunk.ID] = &indexedChunk{chunk: *chunk, length: len(terms)}
+		x.totalLen += len(terms)
+	}
+}
+
+// Remove 删除分块，不存在的ID忽略
+func (x *Index) Remove(ids ...int64) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	for _, id := range ids {
+		x.remove(id)
+	}
+}
+
+// remove 删除一个分块，调用方持有写锁
+func (x *Index) remove(id int64) {
+	doc, ok := x.docs[id]
+	if !ok {
+		return
+	}
+	for _, term := range Tokenize(doc.chunk.Content) {
+		posting := x.postings[term]
+		delete(posting, id)
+		if len(posting) == 0 {
+			delete(x.postings, term)
+		}
+	}
+	x.totalLen -= doc.length
+	delete(x.docs, id)
+}
+
+// Len 返回索引中的分块数
+func (x *Index) Len() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return len(x.docs)
+}
+
+// Search 在指定知识库中检索与query相关的分块，返回按得分从高到低排列的前topK条
+//
+// 文档频率和平均长度按全部知识库统计。
+func (x *Index) Search(query string, kbs []string, topK int) []Hit {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	if len(x.docs) == 0 || topK <= 0 {
+		return []Hit{}
+	}
+
+	n := float64(len(x.docs))
+	avgLen := float64(x.totalLen) / n
+	scores := make(map[int64]float64)
+	seen := make(map[string]bool)
+	for _, term := range Tokenize(query) {
+		// 查询中重复的词只计一次
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+
+		posting := x.postings[term]
+		if len(posting) == 0 {
+			continue
+		}
+		df := float64(len(posting))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for id, tf := range posting {
+			doc := x.docs[id]
+			if !slices.Contains(kbs, doc.chunk.KnowledgeBase) {
+				continue
+			}
+			f := float64(tf)
+			scores[id] += idf * f * (bm25K1 + 1) / (f + bm25K1*(1-bm25B+bm25B*float64(doc.length)/avgLen))
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		chunk := x.docs[id].chunk
+		hits = append(hits, Hit{Chunk: &chunk, Score: score})
+	}
+	// 得分相同时ID小的在前，结果稳定
+	slices.SortFunc(hits, func(a, b Hit) int {
+		switch {
+		case a.Score > b.Score:
+			return -1
+		case a.Score < b.Score:
+			return 1
+		}
+		return cmp.Compare(a.Chunk.ID, b.Chunk.ID)
+	})
+	if len(hits) > topK {
+		hits = hits[:topK]
+	}
+	return hits
+}
+
+// Tokenize 将文本切分为索引词：连续的字母数字转为小写后作为一个词，
+// 中日韩文字按单字和相邻两字各作为一个词，空白和标点忽略
+func Tokenize(text string) []string {
+	var terms []string
+	var word strings.Builder
+	var prevCJK rune // 上一个字符是中日韩文字时为该字符，否则为0
+	flush := func() {
+		if word.Len() > 0 {
+			terms = append(terms, word.String())
+			word.Reset()
+		}
+	}
+
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			flush()
+			terms = append(terms, string(r))
+			if prevCJK != 0 {
+				terms = append(terms, string([]rune{prevCJK, r}))
+			}
+			prevCJK = r
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			word.WriteRune(unicode.ToLower(r))
+		default:
+			flush()
+		}
+		prevCJK = 0
+	}
+	flush()
+
+	return terms
+}
+
+// isCJK 判断是否为中日韩文字
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
@@ -0,0 +1,94 @@
+package keyword_test
+
+import (
+	"slices"
+	"testing"
+
+	"eino/internal/model"
+	"eino/internal/storage/keyword"
+)
+
+func ids(hits []keyword.Hit) []int64 {
+	out := make([]int64, len(hits))
+	for i, h := range hits {
+		out[i] = h.Chunk.ID
+	}
+	return out
+}
+
+func newIndex() *keyword.Index {
+	x := keyword.NewIndex()
+	x.Add(
+		&model.KnowledgeChunk{ID: 1, KnowledgeBase: "default", Content: "The X200 router supports WPA3."},
+		&model.KnowledgeChunk{ID: 2, KnowledgeBase: "default", Content: "Routers and switches: the X100 and X200 compared."},
+		&model.KnowledgeChunk{ID: 3, KnowledgeBase: "default", Content: "退货政策：购买后七天内可以无理由退货。"},
+		&model.KnowledgeChunk{ID: 4, KnowledgeBase: "hr", Content: "Annual leave policy for the X200 team."},
+	)
+	return x
+}
+
+func TestTokenize(t *testing.T) {
+	got := keyword.Tokenize("Model X-200, 退货政策")
+	want := []string{"model", "x", "200", "退", "货", "退货", "政", "货政", "策", "政策"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("Tokenize = %q, want %q", got, want)
+	}
+}
+
+func TestIndexSearch(t *testing.T) {
+	x := newIndex()
+
+	t.Run("Ranking", func(t *testing.T) {
+		// 较短的分块中同一个词的得分更高
+		hits := x.Search("x200 router", []string{"default"}, 10)
+		if !slices.Equal(ids(hits), []int64{1, 2}) {
+			t.Fatalf("hits = %v", ids(hits))
+		}
+		if hits[0].Score <= hits[1].Score {
+			t.Fatalf("scores = %v, %v", hits[0].Score, hits[1].Score)
+		}
+	})
+
+	t.Run("CJK", func(t *testing.T) {
+		if hits := x.Search("怎么退货", []string{"default"}, 10); !slices.Equal(ids(hits), []int64{3}) {
+			t.Fatalf("hits = %v", ids(hits))
+		}
+	})
+
+	t.Run("KnowledgeBaseFilter", func(t *testing.T) {
+		if hits := x.Search("x200", []string{"hr"}, 10); !slices.Equal(ids(hits), []int64{4}) {
+			t.Fatalf("hits = %v", ids(hits))
+		}
+		if hits := x.Search("x200", []string{"missing"}, 10); len(hits) != 0 {
+			t.Fatalf("hits = %v, want empty", ids(hits))
+		}
+	})
+
+	t.Run("TopK", func(t *testing.T) {
+		if hits := x.Search("x200", []string{"default", "hr"}, 2); len(hits) != 2 {
+			t.Fatalf("hits = %v, want 2", ids(hits))
+		}
+	})
+}
+
+func TestIndexUpdate(t *testing.T) {
+	x := newIndex()
+
+	// 替换内容后旧的词不再命中
+	x.Add(&model.KnowledgeChunk{ID: 1, KnowledgeBase: "default", Content: "The Z900 access point."})
+	if hits := x.Search("wpa3", []string{"default"}, 10); len(hits) != 0 {
+		t.Fatalf("hits after update = %v, want empty", ids(hits))
+	}
+	hits := x.Search("z900", []string{"default"}, 10)
+	if !slices.Equal(ids(hits), []int64{1}) || hits[0].Chunk.Content != "The Z900 access point." {
+		t.Fatalf("hits = %+v", hits)
+	}
+
+	x.Remove(1, 2, 99)
+	if x.Len() != 2 {
+		t.Fatalf("Len = %d, want 2", x.Len())
+	}
+	if hits := x.Search("x200", []string{"default"}, 10); len(hits) != 0 {
+		t.Fatalf("hits after remove = %v, want empty", ids(hits))
+	}
+}
@@ -1,7 +0,0 @@
-package memory
-
-import "errors"
-
-var (
-	ErrChatbotNotFound = errors.New("chatbot not found")
-)
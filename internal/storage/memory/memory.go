@@ -3,24 +3,39 @@ package memory
 import (
 	"context"
 	"eino/internal/model"
+	"eino/internal/storage/errs"
+	"sort"
 	"sync"
 	"time"
 )
 
 // MemoryStorage 内存存储实现
 type MemoryStorage struct {
-	chatbots      map[string]*model.Chatbot
-	conversations map[string][]*model.Conversation
-	mu            sync.RWMutex
-	convID        int64
+	chatbots       map[string]*model.Chatbot
+	conversations  map[string][]*model.Conversation
+	convByID       map[int64]*model.Conversation      // 对话ID索引，指向conversations中的记录
+	versions       map[string][]*model.PersonaVersion // 按版本号递增
+	feedback       map[int64]*model.Feedback          // 按对话ID
+	knowledgeBases map[string]*model.KnowledgeBase    // 按名称
+	chunks         []*model.KnowledgeChunk            // 按ID升序
+	reindexJobs    map[string]*model.ReindexJob       // 按任务ID
+	mu             sync.RWMutex
+	convID         int64
+	chunkID        int64
 }
 
 // NewMemoryStorage 创建内存存储实例
 func NewMemoryStorage() *MemoryStorage {
 	return &MemoryStorage{
-		chatbots:      make(map[string]*model.Chatbot),
-		conversations: make(map[string][]*model.Conversation),
-		convID:        1,
+		chatbots:       make(map[string]*model.Chatbot),
+		conversations:  make(map[string][]*model.Conversation),
+		convByID:       make(map[int64]*model.Conversation),
+		versions:       make(map[string][]*model.PersonaVersion),
+		feedback:       make(map[int64]*model.Feedback),
+		knowledgeBases: make(map[string]*model.KnowledgeBase),
+		reindexJobs:    make(map[string]*model.ReindexJob),
+		convID:         1,
+		chunkID:        1,
 	}
 }
 
@@ -29,8 +44,13 @@ func (s *MemoryStorage) SaveChatbot(ctx context.Context, chatbot *model.Chatbot)
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	chatbot.UpdatedAt = time.Now()
-	s.chatbots[chatbot.ID] = chatbot
+	if chatbot.UpdatedAt.IsZero() {
+		chatbot.UpdatedAt = time.Now()
+	}
+
+	// 保存副本，避免调用方后续修改影响存储内容
+	cb := *chatbot
+	s.chatbots[chatbot.ID] = &cb
 	return nil
 }
 
@@ -41,7 +61,7 @@ func (s *MemoryStorage) GetChatbot(ctx context.Context, id string) (*model.Chatb
 
 	chatbot, ok := s.chatbots[id]
 	if !ok {
-		return nil, ErrChatbotNotFound
+		return nil, errs.ErrNotFound
 	}
 
 	// 返回副本，避免并发修改
@@ -49,6 +69,21 @@ func (s *MemoryStorage) GetChatbot(ctx context.Context, id string) (*model.Chatb
 	return &result, nil
 }
 
+// GetChatbotBySlug 按slug获取聊天机器人
+func (s *MemoryStorage) GetChatbotBySlug(ctx context.Context, slug string) (*model.Chatbot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, chatbot := range s.chatbots {
+		if chatbot.Slug != "" && chatbot.Slug == slug {
+			result := *chatbot
+			return &result, nil
+		}
+	}
+
+	return nil, errs.ErrNotFound
+}
+
 // GetChatbots 获取所有聊天机器人
 func (s *MemoryStorage) GetChatbots(ctx context.Context) ([]*model.Chatbot, error) {
 	s.mu.RLock()
@@ -61,20 +96,49 @@ func (s *MemoryStorage) GetChatbots(ctx context.Context) ([]*model.Chatbot, erro
 		chatbots = append(chatbots, &cb)
 	}
 
+	// 与数据库实现保持一致：按创建时间倒序
+	query := model.ChatbotQuery{Sort: model.ChatbotSortCreated, Desc: true}
+	sort.Slice(chatbots, func(i, j int) bool { return query.Less(chatbots[i], chatbots[j]) })
+
 	return chatbots, nil
 }
 
+// ListChatbots 分页查询聊天机器人
+func (s *MemoryStorage) ListChatbots(ctx context.Context, q model.ChatbotQuery) (*model.ChatbotPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	chatbots := make([]*model.Chatbot, 0, len(s.chatbots))
+	for _, chatbot := range s.chatbots {
+		chatbots = append(chatbots, chatbot)
+	}
+
+	page := model.NewChatbotPage(q, chatbots)
+	// 返回副本
+	for i, chatbot := range page.Chatbots {
+		cb := *chatbot
+		page.Chatbots[i] = &cb
+	}
+
+	return page, nil
+}
+
 // DeleteChatbot 删除聊天机器人
 func (s *MemoryStorage) DeleteChatbot(ctx context.Context, id string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if _, ok := s.chatbots[id]; !ok {
-		return ErrChatbotNotFound
+		return errs.ErrNotFound
 	}
 
+	for _, conv := range s.conversations[id] {
+		delete(s.convByID, conv.ID)
+		delete(s.feedback, conv.ID)
+	}
 	delete(s.chatbots, id)
 	delete(s.conversations, id)
+	delete(s.versions, id)
 	return nil
 }
 
@@ -85,7 +149,9 @@ func (s *MemoryStorage) SaveConversation(ctx context.Context, conv *model.Conver
 
 	conv.ID = s.convID
 	s.convID++
-	conv.CreatedAt = time.Now()
+	if conv.CreatedAt.IsZero() {
+		conv.CreatedAt = time.Now()
+	}
 
 	if s.conversations[conv.ChatbotID] == nil {
 		s.conversations[conv.ChatbotID] = make([]*model.Conversation, 0)
@@ -94,31 +160,442 @@ func (s *MemoryStorage) SaveConversation(ctx context.Context, conv *model.Conver
 	// 返回副本
 	convCopy := *conv
 	s.conversations[conv.ChatbotID] = append(s.conversations[conv.ChatbotID], &convCopy)
+	s.convByID[conv.ID] = &convCopy
+	return nil
+}
+
+// DeleteConversations 删除对话记录及其反馈
+func (s *MemoryStorage) DeleteConversations(ctx context.Context, chatbotID string, ids []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		if conv, ok := s.convByID[id]; ok && conv.ChatbotID == chatbotID {
+			deleted[id] = true
+			delete(s.convByID, id)
+			delete(s.feedback, id)
+		}
+	}
+	if len(deleted) == 0 {
+		return nil
+	}
+
+	kept := s.conversations[chatbotID][:0]
+	for _, conv := range s.conversations[chatbotID] {
+		if !deleted[conv.ID] {
+			kept = append(kept, conv)
+		}
+	}
+	s.conversations[chatbotID] = kept
 	return nil
 }
 
-// GetConversationHistory 获取对话历史
+// GetConversationHistory 获取当前分支的对话历史
 func (s *MemoryStorage) GetConversationHistory(ctx context.Context, chatbotID string, limit int) ([]*model.Conversation, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	convs, ok := s.conversations[chatbotID]
-	if !ok {
+	convs := s.conversations[chatbotID]
+	if len(convs) == 0 {
 		return []*model.Conversation{}, nil
 	}
 
-	// 获取最近的limit条记录
-	start := 0
-	if len(convs) > limit {
-		start = len(convs) - limit
+	// 最新的记录即当前分支的末端
+	return s.path(convs[len(convs)-1].ID, limit), nil
+}
+
+// GetConversation 获取对话记录
+func (s *MemoryStorage) GetConversation(ctx context.Context, id int64) (*model.Conversation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	conv, ok := s.convByID[id]
+	if !ok {
+		return nil, errs.ErrNotFound
+	}
+
+	result := *conv
+	return &result, nil
+}
+
+// GetConversationPath 获取以id为终点的对话路径
+func (s *MemoryStorage) GetConversationPath(ctx context.Context, id int64, limit int) ([]*model.Conversation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.convByID[id]; !ok {
+		return nil, errs.ErrNotFound
 	}
 
-	result := make([]*model.Conversation, 0, limit)
-	for i := start; i < len(convs); i++ {
+	return s.path(id, limit), nil
+}
+
+// path 沿父节点向上收集最近limit轮对话并按时间正序返回，调用方需持有读锁
+func (s *MemoryStorage) path(id int64, limit int) []*model.Conversation {
+	result := make([]*model.Conversation, 0)
+	for id != 0 && len(result) < limit {
+		conv, ok := s.convByID[id]
+		if !ok {
+			break
+		}
 		// 返回副本
-		conv := *convs[i]
-		result = append(result, &conv)
+		c := *conv
+		result = append(result, &c)
+		id = conv.ParentID
+	}
+
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result
+}
+
+// GetConversationBranches 获取与id同一父节点的全部对话记录
+func (s *MemoryStorage) GetConversationBranches(ctx context.Context, id int64) ([]*model.Conversation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	target, ok := s.convByID[id]
+	if !ok {
+		return nil, errs.ErrNotFound
+	}
+
+	result := make([]*model.Conversation, 0)
+	for _, conv := range s.conversations[target.ChatbotID] {
+		if conv.ParentID == target.ParentID {
+			c := *conv
+			result = append(result, &c)
+		}
+	}
+
+	return result, nil
+}
+
+// QueryConversationHistory 分页查询对话历史
+func (s *MemoryStorage) QueryConversationHistory(ctx context.Context, q model.HistoryQuery) (*model.HistoryPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	convs := s.conversations[q.ChatbotID]
+	result := make([]*model.Conversation, 0)
+	if q.Limit <= 0 {
+		return model.NewHistoryPage(q, result), nil
+	}
+
+	// 记录按ID递增追加，可二分定位游标位置；多取一条用于判断是否还有下一页
+	if q.Forward() {
+		start := sort.Search(len(convs), func(i int) bool { return convs[i].ID > q.After })
+		for i := start; i < len(convs) && len(result) <= q.Limit; i++ {
+			if q.Match(convs[i]) {
+				conv := *convs[i]
+				result = append(result, &conv)
+			}
+		}
+	} else {
+		end := len(convs)
+		if q.Before > 0 {
+			end = sort.Search(len(convs), func(i int) bool { return convs[i].ID >= q.Before })
+		}
+		for i := end - 1; i >= 0 && len(result) <= q.Limit; i-- {
+			if q.Match(convs[i]) {
+				conv := *convs[i]
+				result = append(result, &conv)
+			}
+		}
+	}
+
+	return model.NewHistoryPage(q, result), nil
+}
+
+// SavePersonaVersion 保存人设版本，分配版本号并设为聊天机器人的当前版本
+func (s *MemoryStorage) SavePersonaVersion(ctx context.Context, v *model.PersonaVersion) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chatbot, ok := s.chatbots[v.ChatbotID]
+	if !ok {
+		return errs.ErrNotFound
+	}
+
+	v.Version = len(s.versions[v.ChatbotID]) + 1
+	if v.CreatedAt.IsZero() {
+		v.CreatedAt = time.Now()
+	}
+
+	vc := *v
+	s.versions[v.ChatbotID] = append(s.versions[v.ChatbotID], &vc)
+	chatbot.PersonaVersion = v.Version
+	return nil
+}
+
+// GetPersonaVersion 获取指定人设版本
+func (s *MemoryStorage) GetPersonaVersion(ctx context.Context, chatbotID string, version int) (*model.PersonaVersion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	versions := s.versions[chatbotID]
+	if version < 1 || version > len(versions) {
+		return nil, errs.ErrNotFound
+	}
+
+	v := *versions[version-1]
+	return &v, nil
+}
+
+// ListPersonaVersions 获取聊天机器人的全部人设版本，最新版本在前
+func (s *MemoryStorage) ListPersonaVersions(ctx context.Context, chatbotID string) ([]*model.PersonaVersion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	versions := s.versions[chatbotID]
+	result := make([]*model.PersonaVersion, 0, len(versions))
+	for i := len(versions) - 1; i >= 0; i-- {
+		v := *versions[i]
+		result = append(result, &v)
+	}
+
+	return result, nil
+}
+
+// SaveFeedback 保存反馈，同一对话已有反馈时覆盖
+func (s *MemoryStorage) SaveFeedback(ctx context.Context, f *model.Feedback) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if f.CreatedAt.IsZero() {
+		f.CreatedAt = now
+	}
+	if f.UpdatedAt.IsZero() {
+		f.UpdatedAt = now
+	}
+
+	fc := *f
+	fc.Tags = append([]string(nil), f.Tags...)
+	s.feedback[f.ConversationID] = &fc
+	return nil
+}
+
+// GetFeedback 获取对话的反馈
+func (s *MemoryStorage) GetFeedback(ctx context.Context, conversationID int64) (*model.Feedback, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	f, ok := s.feedback[conversationID]
+	if !ok {
+		return nil, errs.ErrNotFound
+	}
+
+	result := *f
+	result.Tags = append([]string(nil), f.Tags...)
+	return &result, nil
+}
+
+// ListFeedback 查询聊天机器人的反馈，按对话ID升序
+func (s *MemoryStorage) ListFeedback(ctx context.Context, q model.FeedbackQuery) ([]*model.Feedback, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]*model.Feedback, 0)
+	for _, f := range s.feedback {
+		if q.Match(f) {
+			fc := *f
+			fc.Tags = append([]string(nil), f.Tags...)
+			result = append(result, &fc)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ConversationID < result[j].ConversationID })
+
+	return result, nil
+}
+
+// SaveKnowledgeBase 保存知识库，同名知识库已存在时覆盖描述
+func (s *MemoryStorage) SaveKnowledgeBase(ctx context.Context, kb *model.KnowledgeBase) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.knowledgeBases[kb.Name]; ok {
+		kb.CreatedAt = existing.CreatedAt
+	} else if kb.CreatedAt.IsZero() {
+		kb.CreatedAt = time.Now()
+	}
+
+	kbc := *kb
+	s.knowledgeBases[kb.Name] = &kbc
+	return nil
+}
+
+// GetKnowledgeBase 获取知识库
+func (s *MemoryStorage) GetKnowledgeBase(ctx context.Context, name string) (*model.KnowledgeBase, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	kb, ok := s.knowledgeBases[name]
+	if !ok {
+		return nil, errs.ErrNotFound
+	}
+
+	result := *kb
+	return &result, nil
+}
+
+// ListKnowledgeBases 获取全部知识库，按名称升序
+func (s *MemoryStorage) ListKnowledgeBases(ctx context.Context) ([]*model.KnowledgeBase, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]*model.KnowledgeBase, 0, len(s.knowledgeBases))
+	for _, kb := range s.knowledgeBases {
+		kbc := *kb
+		result = append(result, &kbc)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+
+	return result, nil
+}
+
+// SaveKnowledgeChunks 批量保存知识分块，分配自增ID
+func (s *MemoryStorage) SaveKnowledgeChunks(ctx context.Context, chunks []*model.KnowledgeChunk) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, chunk := range chunks {
+		chunk.ID = s.chunkID
+		s.chunkID++
+		if chunk.CreatedAt.IsZero() {
+			chunk.CreatedAt = now
+		}
+		chunk.UpdatedAt = chunk.CreatedAt
+		cc := *chunk
+		s.chunks = append(s.chunks, &cc)
+	}
+	return nil
+}
+
+// ListKnowledgeChunks 按ID升序获取ID大于afterID的最多limit个分块
+func (s *MemoryStorage) ListKnowledgeChunks(ctx context.Context, afterID int64, limit int) ([]*model.KnowledgeChunk, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := sort.Search(len(s.chunks), func(i int) bool { return s.chunks[i].ID > afterID })
+	end := min(start+limit, len(s.chunks))
+
+	result := make([]*model.KnowledgeChunk, 0, end-start)
+	for _, chunk := range s.chunks[start:end] {
+		cc := *chunk
+		result = append(result, &cc)
+	}
+	return result, nil
+}
+
+// CountKnowledgeChunks 获取知识分块总数
+func (s *MemoryStorage) CountKnowledgeChunks(ctx context.Context) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.chunks), nil
+}
+
+// QueryKnowledgeChunks 分页查询知识分块，按ID升序
+func (s *MemoryStorage) QueryKnowledgeChunks(ctx context.Context, query model.KnowledgeQuery) (*model.KnowledgePage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	page := model.NewKnowledgePage(query, s.chunks)
+	for i, chunk := range page.Items {
+		cc := *chunk
+		page.Items[i] = &cc
+	}
+	return page, nil
+}
+
+// findChunk 按ID查找分块下标，调用方需持有锁
+func (s *MemoryStorage) findChunk(id int64) (int, bool) {
+	i := sort.Search(len(s.chunks), func(i int) bool { return s.chunks[i].ID >= id })
+	return i, i < len(s.chunks) && s.chunks[i].ID == id
+}
+
+// GetKnowledgeChunk 获取知识分块
+func (s *MemoryStorage) GetKnowledgeChunk(ctx context.Context, id int64) (*model.KnowledgeChunk, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i, ok := s.findChunk(id)
+	if !ok {
+		return nil, errs.ErrNotFound
+	}
+	cc := *s.chunks[i]
+	return &cc, nil
+}
+
+// UpdateKnowledgeChunk 修改分块的内容、来源、分类和元数据
+func (s *MemoryStorage) UpdateKnowledgeChunk(ctx context.Context, chunk *model.KnowledgeChunk) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.findChunk(chunk.ID)
+	if !ok {
+		return errs.ErrNotFound
+	}
+	chunk.UpdatedAt = time.Now()
+	stored := s.chunks[i]
+	stored.Content = chunk.Content
+	stored.Source = chunk.Source
+	stored.Category = chunk.Category
+	stored.Metadata = chunk.Metadata
+	stored.UpdatedAt = chunk.UpdatedAt
+	return nil
+}
+
+// DeleteKnowledgeChunks 删除知识分块，不存在的ID忽略
+func (s *MemoryStorage) DeleteKnowledgeChunks(ctx context.Context, ids []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range ids {
+		if i, ok := s.findChunk(id); ok {
+			s.chunks = append(s.chunks[:i], s.chunks[i+1:]...)
+		}
+	}
+	return nil
+}
+
+// SaveReindexJob 保存重建索引任务
+func (s *MemoryStorage) SaveReindexJob(ctx context.Context, job *model.ReindexJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jc := *job
+	s.reindexJobs[job.ID] = &jc
+	return nil
+}
+
+// GetReindexJob 获取重建索引任务
+func (s *MemoryStorage) GetReindexJob(ctx context.Context, id string) (*model.ReindexJob, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	job, ok := s.reindexJobs[id]
+	if !ok {
+		return nil, errs.ErrNotFound
+	}
+
+	result := *job
+	return &result, nil
+}
+
+// ListReindexJobs 获取全部重建索引任务，最新创建的在前
+func (s *MemoryStorage) ListReindexJobs(ctx context.Context) ([]*model.ReindexJob, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]*model.ReindexJob, 0, len(s.reindexJobs))
+	for _, job := range s.reindexJobs {
+		jc := *job
+		result = append(result, &jc)
 	}
+	sort.Slice(result, func(i, j int) bool { return result[i].CreatedAt.After(result[j].CreatedAt) })
 
 	return result, nil
 }
@@ -0,0 +1,15 @@
+package memory_test
+
+import (
+	"testing"
+
+	"eino/internal/storage"
+	"eino/internal/storage/memory"
+	"eino/internal/storage/storagetest"
+)
+
+func TestMemoryStorageContract(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return memory.NewMemoryStorage()
+	})
+}
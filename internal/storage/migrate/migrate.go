@@ -0,0 +1,470 @@
+// Package migrate 数据库版本化迁移
+//
+// 迁移脚本内嵌在 migrations 包中，已执行的版本记录在 schema_migrations 表。
+// 执行前会获取数据库级别的锁，多个实例同时启动时只有一个会真正执行迁移。
+//
+// MySQL的DDL语句会隐式提交，无法把整个脚本放在一个事务中：脚本执行到一半失败时，之前的语句已经生效。
+// 因此每条语句成功后在 schema_migration_steps 表记录进度，再次执行时从失败的语句继续，
+// 不会因为重复添加列或索引而失败。已部分执行的脚本不能再修改，否则记录的进度与语句对不上。
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"eino/migrations"
+)
+
+// 支持的数据库方言
+const (
+	DialectMySQL  = "mysql"
+	DialectSQLite = "sqlite"
+)
+
+// mysqlLockName MySQL迁移锁名称
+const mysqlLockName = "eino_schema_migrations"
+
+// mysqlLockTimeout 获取MySQL迁移锁的最长等待时间（秒）
+const mysqlLockTimeout = 60
+
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration 单个迁移版本
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status 迁移版本状态
+type Status struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// Migrator 迁移执行器
+type Migrator struct {
+	db         *sql.DB
+	dialect    string
+	migrations []Migration
+}
+
+// New 创建迁移执行器，加载指定方言的内嵌迁移脚本
+func New(db *sql.DB, dialect string) (*Migrator, error) {
+	if dialect != DialectMySQL && dialect != DialectSQLite {
+		return nil, fmt.Errorf("unsupported migration dialect: %s", dialect)
+	}
+
+	sub, err := fs.Sub(migrations.FS, dialect)
+	if err != nil {
+		return nil, fmt.Errorf("open migrations: %w", err)
+	}
+
+	list, err := load(sub)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, dialect: dialect, migrations: list}, nil
+}
+
+// load 读取并按版本号排序迁移脚本
+func load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		matches := fileNamePattern.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse migration version %s: %w", entry.Name(), err)
+		}
+
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: matches[2]}
+			byVersion[version] = m
+		} else if m.Name != matches[2] {
+			return nil, fmt.Errorf("migration version %d has conflicting names: %s, %s", version, m.Name, matches[2])
+		}
+
+		if matches[3] == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", m.Version, m.Name)
+		}
+		list = append(list, *m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+
+	return list, nil
+}
+
+// Up 执行所有未执行的迁移，返回本次执行的版本
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := m.appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			if _, ok := done[mig.Version]; ok {
+				continue
+			}
+			if err := m.execScript(ctx, conn, mig.Version, directionUp, mig.Up); err != nil {
+				return fmt.Errorf("apply migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			if _, err := conn.ExecContext(ctx,
+				`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+				mig.Version, mig.Name, time.Now(),
+			); err != nil {
+				return fmt.Errorf("record migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			if err := clearSteps(ctx, conn, mig.Version); err != nil {
+				return err
+			}
+			applied = append(applied, mig)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return applied, nil
+}
+
+// Down 回滚最近的steps个迁移，返回本次回滚的版本
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := m.appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			mig := m.migrations[i]
+			if _, ok := done[mig.Version]; !ok {
+				continue
+			}
+			if mig.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down script", mig.Version, mig.Name)
+			}
+			if err := m.execScript(ctx, conn, mig.Version, directionDown, mig.Down); err != nil {
+				return fmt.Errorf("revert migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			if _, err := conn.ExecContext(ctx,
+				`DELETE FROM schema_migrations WHERE version = ?`, mig.Version,
+			); err != nil {
+				return fmt.Errorf("unrecord migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			if err := clearSteps(ctx, conn, mig.Version); err != nil {
+				return err
+			}
+			reverted = append(reverted, mig)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return reverted, nil
+}
+
+// Status 获取所有迁移版本的执行状态
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get connection: %w", err)
+	}
+	defer conn.Close()
+
+	done, err := m.appliedVersions(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		status := Status{Version: mig.Version, Name: mig.Name}
+		if appliedAt, ok := done[mig.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// Pending 获取尚未执行的迁移版本，按版本号升序
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for i, status := range statuses {
+		if !status.Applied {
+			pending = append(pending, m.migrations[i])
+		}
+	}
+	return pending, nil
+}
+
+// withLock 在独占连接上持有迁移锁执行fn
+//
+// MySQL使用GET_LOCK命名锁；SQLite使用BEGIN IMMEDIATE写事务，
+// 整批迁移在同一事务内执行，失败时全部回滚。
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) (err error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("get connection: %w", err)
+	}
+	defer conn.Close()
+
+	switch m.dialect {
+	case DialectMySQL:
+		var got sql.NullInt64
+		if err := conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, ?)`, mysqlLockName, mysqlLockTimeout).Scan(&got); err != nil {
+			return fmt.Errorf("acquire migration lock: %w", err)
+		}
+		if !got.Valid || got.Int64 != 1 {
+			return fmt.Errorf("acquire migration lock: timeout after %ds", mysqlLockTimeout)
+		}
+		defer conn.ExecContext(context.Background(), `SELECT RELEASE_LOCK(?)`, mysqlLockName)
+	case DialectSQLite:
+		if _, err := conn.ExecContext(ctx, `BEGIN IMMEDIATE`); err != nil {
+			return fmt.Errorf("acquire migration lock: %w", err)
+		}
+		defer func() {
+			if err != nil {
+				conn.ExecContext(context.Background(), `ROLLBACK`)
+				return
+			}
+			if _, commitErr := conn.ExecContext(ctx, `COMMIT`); commitErr != nil {
+				err = fmt.Errorf("commit migrations: %w", commitErr)
+			}
+		}()
+	}
+
+	if err := ensureTable(ctx, conn); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+// ensureTable 创建schema_migrations表和记录脚本执行进度的schema_migration_steps表（如果不存在）
+func ensureTable(ctx context.Context, conn *sql.Conn) error {
+	query := `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at TIMESTAMP NOT NULL
+		)
+	`
+	if _, err := conn.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	query = `
+		CREATE TABLE IF NOT EXISTS schema_migration_steps (
+			version BIGINT NOT NULL,
+			direction VARCHAR(8) NOT NULL,
+			done INT NOT NULL,
+			PRIMARY KEY (version, direction)
+		)
+	`
+	if _, err := conn.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("create schema_migration_steps: %w", err)
+	}
+	return nil
+}
+
+// appliedVersions 读取已执行的迁移版本及执行时间
+func (m *Migrator) appliedVersions(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	if err := ensureTable(ctx, conn); err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("query schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	done := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("scan schema_migrations: %w", err)
+		}
+		done[version] = appliedAt
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return done, nil
+}
+
+// 迁移脚本的执行方向，记录进度时区分
+const (
+	directionUp   = "up"
+	directionDown = "down"
+)
+
+// execScript 逐条执行迁移脚本中的SQL语句，跳过上次已成功执行的语句，每条语句成功后记录进度
+func (m *Migrator) execScript(ctx context.Context, conn *sql.Conn, version int64, direction, script string) error {
+	var done int
+	err := conn.QueryRowContext(ctx,
+		`SELECT done FROM schema_migration_steps WHERE version = ? AND direction = ?`, version, direction,
+	).Scan(&done)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("query schema_migration_steps: %w", err)
+	}
+
+	stmts := splitStatements(script, m.dialect)
+	for i := done; i < len(stmts); i++ {
+		if _, err := conn.ExecContext(ctx, stmts[i]); err != nil {
+			return fmt.Errorf("exec statement %d %q: %w", i+1, firstLine(stmts[i]), err)
+		}
+		if err := saveStep(ctx, conn, version, direction, i+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveStep 记录脚本已成功执行的语句数
+func saveStep(ctx context.Context, conn *sql.Conn, version int64, direction string, done int) error {
+	if _, err := conn.ExecContext(ctx,
+		`DELETE FROM schema_migration_steps WHERE version = ? AND direction = ?`, version, direction,
+	); err != nil {
+		return fmt.Errorf("save migration step: %w", err)
+	}
+	if _, err := conn.ExecContext(ctx,
+		`INSERT INTO schema_migration_steps (version, direction, done) VALUES (?, ?, ?)`, version, direction, done,
+	); err != nil {
+		return fmt.Errorf("save migration step: %w", err)
+	}
+	return nil
+}
+
+// clearSteps 版本执行或回滚完成后删除其进度
+func clearSteps(ctx context.Context, conn *sql.Conn, version int64) error {
+	if _, err := conn.ExecContext(ctx, `DELETE FROM schema_migration_steps WHERE version = ?`, version); err != nil {
+		return fmt.Errorf("clear migration steps: %w", err)
+	}
+	return nil
+}
+
+// splitStatements 按语句末尾的分号拆分SQL脚本
+//
+// 引号（'、"、`）内的分号不作为分隔符；-- 行注释和 /* */ 块注释会被去掉，其中的分号同样忽略。
+// MySQL字符串中的反斜杠转义下一个字符，SQLite没有反斜杠转义。
+func splitStatements(script, dialect string) []string {
+	var (
+		stmts []string
+		stmt  strings.Builder
+	)
+	flush := func() {
+		if s := strings.TrimSpace(stmt.String()); s != "" {
+			stmts = append(stmts, s)
+		}
+		stmt.Reset()
+	}
+
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			// 引号内原样保留，连续两个引号表示转义
+			end := i + 1
+			for end < len(script) {
+				if script[end] == '\\' && c != '`' && dialect == DialectMySQL {
+					end += 2
+					continue
+				}
+				if script[end] == c {
+					if end+1 < len(script) && script[end+1] == c {
+						end += 2
+						continue
+					}
+					break
+				}
+				end++
+			}
+			end = min(end+1, len(script))
+			stmt.WriteString(script[i:end])
+			i = end - 1
+		case c == '-' && strings.HasPrefix(script[i:], "--"):
+			end := strings.IndexByte(script[i:], '\n')
+			if end < 0 {
+				i = len(script)
+			} else {
+				i += end - 1
+			}
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				i = len(script)
+			} else {
+				i += end + 3
+			}
+			stmt.WriteByte(' ')
+		case c == ';':
+			flush()
+		default:
+			stmt.WriteByte(c)
+		}
+	}
+	flush()
+	return stmts
+}
+
+// firstLine 返回语句首行，用于错误信息
+func firstLine(stmt string) string {
+	if i := strings.IndexByte(stmt, '\n'); i >= 0 {
+		return stmt[:i]
+	}
+	return stmt
+}
@@ -0,0 +1,167 @@
+package migrate_test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"eino/internal/storage/migrate"
+	"eino/internal/storage/sqlite"
+)
+
+func newMigrator(t *testing.T, path string) *migrate.Migrator {
+	t.Helper()
+	db, err := sqlite.Open(path)
+	if err != nil {
+		t.Fatalf("sqlite.Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	m, err := migrate.New(db, migrate.DialectSQLite)
+	if err != nil {
+		t.Fatalf("migrate.New: %v", err)
+	}
+	return m
+}
+
+func TestUpDown(t *testing.T) {
+	ctx := context.Background()
+	m := newMigrator(t, filepath.Join(t.TempDir(), "eino.db"))
+
+	applied, err := m.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if len(applied) == 0 {
+		t.Fatal("Up applied no migrations on empty database")
+	}
+
+	again, err := m.Up(ctx)
+	if err != nil {
+		t.Fatalf("second Up: %v", err)
+	}
+	if len(again) != 0 {
+		t.Fatalf("second Up applied %d migrations, want 0", len(again))
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	for _, st := range statuses {
+		if !st.Applied {
+			t.Fatalf("migration %d_%s not applied", st.Version, st.Name)
+		}
+	}
+
+	reverted, err := m.Down(ctx, len(statuses))
+	if err != nil {
+		t.Fatalf("Down: %v", err)
+	}
+	if len(reverted) != len(statuses) {
+		t.Fatalf("Down reverted %d migrations, want %d", len(reverted), len(statuses))
+	}
+
+	reapplied, err := m.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up after Down: %v", err)
+	}
+	if len(reapplied) != len(statuses) {
+		t.Fatalf("Up after Down applied %d migrations, want %d", len(reapplied), len(statuses))
+	}
+}
+
+func TestConcurrentUp(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "eino.db")
+
+	const instances = 4
+	migrators := make([]*migrate.Migrator, instances)
+	for i := range migrators {
+		migrators[i] = newMigrator(t, path)
+	}
+
+	var wg sync.WaitGroup
+	counts := make([]int, instances)
+	errs := make([]error, instances)
+	for i, m := range migrators {
+		wg.Add(1)
+		go func(i int, m *migrate.Migrator) {
+			defer wg.Done()
+			applied, err := m.Up(ctx)
+			counts[i], errs[i] = len(applied), err
+		}(i, m)
+	}
+	wg.Wait()
+
+	// 只有一个实例真正执行迁移
+	total, runners := 0, 0
+	for i := range migrators {
+		if errs[i] != nil {
+			t.Fatalf("instance %d Up: %v", i, errs[i])
+		}
+		total += counts[i]
+		if counts[i] > 0 {
+			runners++
+		}
+	}
+	if runners != 1 {
+		t.Fatalf("%d instances applied migrations, want 1", runners)
+	}
+
+	statuses, err := migrators[0].Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if total != len(statuses) {
+		t.Fatalf("applied %d migrations in total, want %d", total, len(statuses))
+	}
+}
+
+// TestResumePartialMigration 脚本执行到一半失败后（如MySQL的DDL已隐式提交），再次执行时从失败的语句继续
+func TestResumePartialMigration(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "eino.db")
+	m := newMigrator(t, path)
+
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	reverted, err := m.Down(ctx, 1)
+	if err != nil || len(reverted) != 1 {
+		t.Fatalf("Down: reverted %d, err %v", len(reverted), err)
+	}
+	last := reverted[0]
+
+	// 模拟上次执行时第一条语句已生效、第二条失败
+	db, err := sqlite.Open(path)
+	if err != nil {
+		t.Fatalf("sqlite.Open: %v", err)
+	}
+	defer db.Close()
+	first := strings.SplitN(last.Up, ";", 2)[0]
+	if _, err := db.ExecContext(ctx, first); err != nil {
+		t.Fatalf("exec first statement: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, `INSERT INTO schema_migration_steps (version, direction, done) VALUES (?, 'up', 1)`, last.Version); err != nil {
+		t.Fatalf("record step: %v", err)
+	}
+
+	applied, err := m.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up after partial migration: %v", err)
+	}
+	if len(applied) != 1 || applied[0].Version != last.Version {
+		t.Fatalf("Up applied %v, want version %d", applied, last.Version)
+	}
+
+	var steps int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM schema_migration_steps`).Scan(&steps); err != nil {
+		t.Fatalf("count steps: %v", err)
+	}
+	if steps != 0 {
+		t.Fatalf("%d migration steps left after Up, want 0", steps)
+	}
+}
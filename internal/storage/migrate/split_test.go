@@ -0,0 +1,34 @@
+package migrate
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect string
+		script  string
+		want    []string
+	}{
+		{"Simple", DialectSQLite, "CREATE TABLE a (id INT);\nCREATE TABLE b (id INT);\n", []string{"CREATE TABLE a (id INT)", "CREATE TABLE b (id INT)"}},
+		{"NoTrailingSemicolon", DialectSQLite, "SELECT 1;SELECT 2", []string{"SELECT 1", "SELECT 2"}},
+		{"Empty", DialectSQLite, " ;\n-- 只有注释\n;", nil},
+		{"LineComment", DialectSQLite, "-- 创建表; 注释中的分号\nCREATE TABLE a (id INT); -- 行尾注释;\nDROP TABLE b;", []string{"CREATE TABLE a (id INT)", "DROP TABLE b"}},
+		{"BlockComment", DialectSQLite, "/* 多行;\n注释 */ SELECT 1; SELECT/*;*/2;", []string{"SELECT 1", "SELECT 2"}},
+		{"SingleQuote", DialectSQLite, "INSERT INTO t VALUES ('a;b');SELECT 1;", []string{"INSERT INTO t VALUES ('a;b')", "SELECT 1"}},
+		{"DoubledQuote", DialectSQLite, "INSERT INTO t VALUES ('it''s; ok');", []string{"INSERT INTO t VALUES ('it''s; ok')"}},
+		{"DashesInString", DialectSQLite, "INSERT INTO t VALUES ('--;/*');SELECT 1;", []string{"INSERT INTO t VALUES ('--;/*')", "SELECT 1"}},
+		{"QuotedIdentifiers", DialectMySQL, "CREATE TABLE `a;b` (\"c;d\" INT);", []string{"CREATE TABLE `a;b` (\"c;d\" INT)"}},
+		{"MySQLBackslash", DialectMySQL, `INSERT INTO t VALUES ('a\';b');SELECT 1;`, []string{`INSERT INTO t VALUES ('a\';b')`, "SELECT 1"}},
+		{"SQLiteBackslash", DialectSQLite, `INSERT INTO t VALUES ('C:\');SELECT 1;`, []string{`INSERT INTO t VALUES ('C:\')`, "SELECT 1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitStatements(tt.script, tt.dialect); !slices.Equal(got, tt.want) {
+				t.Fatalf("splitStatements(%q) = %q, want %q", tt.script, got, tt.want)
+			}
+		})
+	}
+}
@@ -2,16 +2,42 @@ package milvus
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"eino/internal/model"
+	"eino/internal/storage/vector"
 
 	"github.com/milvus-io/milvus-sdk-go/v2/client"
 	"github.com/milvus-io/milvus-sdk-go/v2/entity"
 )
 
+// MaxContentLength content字段的最大字节数
+const MaxContentLength = 65535
+
+// 记录集合元数据的集合属性
+const (
+	propEmbeddingModel = "eino.embedding_model"
+	propEmbeddingDim   = "eino.embedding_dim"
+)
+
 // MilvusStorage Milvus向量数据库存储
 // 注意：这是一个基础实现，完整的Milvus集成需要根据实际SDK版本调整
 type MilvusStorage struct {
 	client client.Client
+
+	mu sync.Mutex
+	// fields 集合（或别名）的字段，早期版本创建的集合没有metadata和kb_id字段
+	fields map[string]map[string]bool
+	// autoID 主键由Milvus自动生成的集合
+	autoID map[string]bool
+	// loaded 已加载到内存、可以搜索的集合
+	loaded map[string]bool
 }
 
 // NewMilvusStorage 创建Milvus存储实例
@@ -26,11 +52,16 @@ func NewMilvusStorage(host string, port int) (*MilvusStorage, error) {
 		return nil, fmt.Errorf("create milvus client: %w", err)
 	}
 
-	return &MilvusStorage{client: c}, nil
+	return &MilvusStorage{
+		client: c,
+		fields: make(map[string]map[string]bool),
+		autoID: make(map[string]bool),
+		loaded: make(map[string]bool),
+	}, nil
 }
 
-// CreateCollection 创建集合（如果不存在）
-func (s *MilvusStorage) CreateCollection(ctx context.Context, collectionName string, dim int) error {
+// CreateCollection 创建集合（如果不存在），嵌入模型和维度记录在集合属性中
+func (s *MilvusStorage) CreateCollection(ctx context.Context, collectionName string, info vector.CollectionInfo) error {
 	// 检查集合是否存在
 	exists, err := s.client.HasCollection(ctx, collectionName)
 	if err != nil {
@@ -38,7 +69,13 @@ func (s *MilvusStorage) CreateCollection(ctx context.Context, collectionName str
 	}
 
 	if exists {
-		return nil // 集合已存在
+		// 集合已存在，记录已有字段
+		coll, err := s.client.DescribeCollection(ctx, collectionName)
+		if err != nil {
+			return fmt.Errorf("describe collection: %w", err)
+		}
+		s.setFields(collectionName, coll.Schema.Fields)
+		return nil
 	}
 
 	// 定义schema
@@ -50,27 +87,42 @@ func (s *MilvusStorage) CreateCollection(ctx context.Context, collectionName str
 				Name:       "id",
 				DataType:   entity.FieldTypeInt64,
 				PrimaryKey: true,
-				AutoID:     true,
+				AutoID:     !info.RowIDs,
 			},
 			{
 				Name:     "content",
 				DataType: entity.FieldTypeVarChar,
 				TypeParams: map[string]string{
-					"max_length": "65535",
+					"max_length": fmt.Sprintf("%d", MaxContentLength),
+				},
+			},
+			{
+				Name:     "metadata",
+				DataType: entity.FieldTypeJSON,
+			},
+			{
+				// 知识库名称，作为partition key，按知识库过滤时只搜索对应分区
+				Name:           "kb_id",
+				DataType:       entity.FieldTypeVarChar,
+				IsPartitionKey: true,
+				TypeParams: map[string]string{
+					"max_length": "64",
 				},
 			},
 			{
 				Name:     "embedding",
 				DataType: entity.FieldTypeFloatVector,
 				TypeParams: map[string]string{
-					"dim": fmt.Sprintf("%d", dim),
+					"dim": strconv.Itoa(info.Dim),
 				},
 			},
 		},
 	}
 
 	// 创建集合
-	if err := s.client.CreateCollection(ctx, schema, entity.DefaultShardNumber); err != nil {
+	if err := s.client.CreateCollection(ctx, schema, entity.DefaultShardNumber,
+		client.WithCollectionProperty(propEmbeddingModel, info.EmbeddingModel),
+		client.WithCollectionProperty(propEmbeddingDim, strconv.Itoa(info.Dim))); err != nil {
 		return fmt.Errorf("create collection: %w", err)
 	}
 
@@ -84,34 +136,402 @@ func (s *MilvusStorage) CreateCollection(ctx context.Context, collectionName str
 		return fmt.Errorf("create index: %w", err)
 	}
 
+	s.setFields(collectionName, schema.Fields)
 	return nil
 }
 
-// Insert 插入向量数据
-func (s *MilvusStorage) Insert(ctx context.Context, collectionName string, content string, embedding []float32) error {
-	// 准备数据
-	contents := []string{content}
-	embeddings := [][]float32{embedding}
+// DescribeCollection 获取集合元数据，collectionName可以是别名；集合不存在时返回vector.ErrCollectionNotFound
+//
+// 维度取自embedding字段；早期版本创建的集合没有记录嵌入模型，EmbeddingModel为空。
+func (s *MilvusStorage) DescribeCollection(ctx context.Context, collectionName string) (*vector.CollectionInfo, error) {
+	exists, err := s.client.HasCollection(ctx, collectionName)
+	if err != nil {
+		return nil, fmt.Errorf("check collection exists: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", vector.ErrCollectionNotFound, collectionName)
+	}
+
+	coll, err := s.client.DescribeCollection(ctx, collectionName)
+	if err != nil {
+		return nil, fmt.Errorf("describe collection: %w", err)
+	}
+	s.setFields(collectionName, coll.Schema.Fields)
 
-	// 插入数据
-	_, err := s.client.Insert(ctx, collectionName, "",
-		entity.NewColumnVarChar("content", contents),
-		entity.NewColumnFloatVector("embedding", len(embedding), embeddings))
+	info := &vector.CollectionInfo{Collection: coll.Name, EmbeddingModel: coll.Properties[propEmbeddingModel]}
+	for _, field := range coll.Schema.Fields {
+		switch {
+		case field.PrimaryKey:
+			info.RowIDs = !field.AutoID
+		case field.Name == "embedding":
+			if info.Dim, err = strconv.Atoi(field.TypeParams["dim"]); err != nil {
+				return nil, fmt.Errorf("describe collection: invalid embedding dim %q", field.TypeParams["dim"])
+			}
+		}
+	}
+	return info, nil
+}
+
+// DropCollection 删除集合，集合不存在时不做任何事
+func (s *MilvusStorage) DropCollection(ctx context.Context, collectionName string) error {
+	exists, err := s.client.HasCollection(ctx, collectionName)
 	if err != nil {
+		return fmt.Errorf("check collection exists: %w", err)
+	}
+	if exists {
+		if err := s.client.DropCollection(ctx, collectionName); err != nil {
+			return fmt.Errorf("drop collection: %w", err)
+		}
+	}
+	s.forget(collectionName)
+	return nil
+}
+
+// SetAlias 将别名指向集合，别名已存在时原子地切换到新集合
+func (s *MilvusStorage) SetAlias(ctx context.Context, alias, collectionName string) error {
+	coll, err := s.client.DescribeCollection(ctx, alias)
+	switch {
+	case err != nil:
+		// 别名不存在
+		if err := s.client.CreateAlias(ctx, collectionName, alias); err != nil {
+			return fmt.Errorf("create alias: %w", err)
+		}
+	case coll.Name == alias:
+		return fmt.Errorf("set alias: %s is a collection", alias)
+	default:
+		if err := s.client.AlterAlias(ctx, collectionName, alias); err != nil {
+			return fmt.Errorf("alter alias: %w", err)
+		}
+	}
+	s.forget(alias)
+	return nil
+}
+
+// forget 清除集合的字段和加载状态缓存
+func (s *MilvusStorage) forget(collectionName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.fields, collectionName)
+	delete(s.autoID, collectionName)
+	delete(s.loaded, collectionName)
+}
+
+// setFields 记录集合的字段和主键是否自动生成
+func (s *MilvusStorage) setFields(collectionName string, fields []*entity.Field) {
+	names := make(map[string]bool, len(fields))
+	autoID := false
+	for _, field := range fields {
+		names[field.Name] = true
+		if field.PrimaryKey {
+			autoID = field.AutoID
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fields[collectionName] = names
+	s.autoID[collectionName] = autoID
+}
+
+// ensureFields 集合的字段尚未记录时查询集合结构，别名切换后需要重新查询
+func (s *MilvusStorage) ensureFields(ctx context.Context, collectionName string) error {
+	s.mu.Lock()
+	_, ok := s.fields[collectionName]
+	s.mu.Unlock()
+	if ok {
+		return nil
+	}
+	_, err := s.DescribeCollection(ctx, collectionName)
+	return err
+}
+
+// hasField 判断集合是否有指定字段
+func (s *MilvusStorage) hasField(collectionName, field string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fields[collectionName][field]
+}
+
+// Insert 批量写入向量数据，主键由调用方指定的集合中主键已存在时覆盖
+//
+// 集合没有metadata字段时忽略元数据；没有kb_id字段（早期版本创建）时只能写入默认知识库。
+func (s *MilvusStorage) Insert(ctx context.Context, collectionName string, entities []vector.Entity) error {
+	if len(entities) == 0 {
+		return nil
+	}
+	if err := s.ensureFields(ctx, collectionName); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	rowIDs := !s.autoID[collectionName]
+	s.mu.Unlock()
+
+	hasKB := s.hasField(collectionName, "kb_id")
+	ids := make([]int64, len(entities))
+	contents := make([]string, len(entities))
+	kbs := make([]string, len(entities))
+	metadata := make([][]byte, len(entities))
+	embeddings := make([][]float32, len(entities))
+	for i, e := range entities {
+		if len(e.Content) > MaxContentLength {
+			return fmt.Errorf("insert vector: content %d exceeds %d bytes", i, MaxContentLength)
+		}
+		if rowIDs && e.ID <= 0 {
+			return fmt.Errorf("insert vector: entity %d has no id", i)
+		}
+		if !hasKB && e.KnowledgeBase != model.DefaultKnowledgeBase {
+			return fmt.Errorf("insert vector: collection %s has no kb_id field, only the %s knowledge base is supported", collectionName, model.DefaultKnowledgeBase)
+		}
+		ids[i] = e.ID
+		contents[i] = e.Content
+		kbs[i] = e.KnowledgeBase
+		metadata[i] = e.Metadata
+		if len(e.Metadata) == 0 {
+			metadata[i] = []byte("{}")
+		}
+		embeddings[i] = e.Embedding
+	}
+
+	columns := []entity.Column{
+		entity.NewColumnVarChar("content", contents),
+		entity.NewColumnFloatVector("embedding", len(embeddings[0]), embeddings),
+	}
+	if hasKB {
+		columns = append(columns, entity.NewColumnVarChar("kb_id", kbs))
+	}
+	if s.hasField(collectionName, "metadata") {
+		columns = append(columns, entity.NewColumnJSONBytes("metadata", metadata))
+	}
+
+	if rowIDs {
+		columns = append(columns, entity.NewColumnInt64("id", ids))
+		if _, err := s.client.Upsert(ctx, collectionName, "", columns...); err != nil {
+			return fmt.Errorf("upsert vector: %w", err)
+		}
+		return nil
+	}
+	if _, err := s.client.Insert(ctx, collectionName, "", columns...); err != nil {
 		return fmt.Errorf("insert vector: %w", err)
 	}
 
 	return nil
 }
 
-// Search 搜索相似向量
-// 注意：此实现需要根据实际Milvus SDK版本调整
-func (s *MilvusStorage) Search(ctx context.Context, collectionName string, queryVector []float32, topK int) ([]string, []float32, error) {
-	// TODO: 实现搜索功能
-	// 由于Milvus SDK版本差异，这里提供一个占位实现
-	// 实际使用时需要根据SDK文档调整
+// Delete 按主键删除向量，不存在的主键忽略
+func (s *MilvusStorage) Delete(ctx context.Context, collectionName string, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := s.ensureFields(ctx, collectionName); err != nil {
+		return err
+	}
+	if err := s.client.DeleteByPks(ctx, collectionName, "", entity.NewColumnInt64("id", ids)); err != nil {
+		return fmt.Errorf("delete vectors: %w", err)
+	}
+	return nil
+}
+
+// Search 在指定知识库中搜索相似向量，返回按距离从近到远排列的结果，距离为L2
+//
+// 集合没有kb_id字段时，其中的数据都属于默认知识库。
+func (s *MilvusStorage) Search(ctx context.Context, collectionName string, queryVector []float32, kbs []string, topK int) ([]vector.SearchResult, error) {
+	if err := s.ensureFields(ctx, collectionName); err != nil {
+		return nil, err
+	}
+
+	hasKB := s.hasField(collectionName, "kb_id")
+	hasMetadata := s.hasField(collectionName, "metadata")
+	outputFields := []string{"content"}
+	var expr string
+	if hasKB {
+		quoted := make([]string, len(kbs))
+		for i, kb := range kbs {
+			quoted[i] = strconv.Quote(kb)
+		}
+		expr = fmt.Sprintf("kb_id in [%s]", strings.Join(quoted, ", "))
+		outputFields = append(outputFields, "kb_id")
+	} else if !slices.Contains(kbs, model.DefaultKnowledgeBase) {
+		return []vector.SearchResult{}, nil
+	}
+	if hasMetadata {
+		outputFields = append(outputFields, "metadata")
+	}
+
+	if err := s.load(ctx, collectionName); err != nil {
+		return nil, err
+	}
+
+	sp, err := entity.NewIndexHNSWSearchParam(max(topK, 64))
+	if err != nil {
+		return nil, fmt.Errorf("create search param: %w", err)
+	}
+
+	results, err := s.client.Search(ctx, collectionName, nil, expr, outputFields,
+		[]entity.Vector{entity.FloatVector(queryVector)}, "embedding", entity.L2, topK, sp)
+	if err != nil {
+		return nil, fmt.Errorf("search vectors: %w", err)
+	}
+	if len(results) == 0 {
+		return []vector.SearchResult{}, nil
+	}
+
+	result := results[0]
+	if result.Err != nil {
+		return nil, fmt.Errorf("search vectors: %w", result.Err)
+	}
+
+	hits := make([]vector.SearchResult, 0, result.ResultCount)
+	for i := 0; i < result.ResultCount; i++ {
+		hit := vector.SearchResult{Entity: vector.Entity{KnowledgeBase: model.DefaultKnowledgeBase}, Distance: result.Scores[i]}
+		if hit.ID, err = result.IDs.GetAsInt64(i); err != nil {
+			return nil, fmt.Errorf("read id: %w", err)
+		}
+		if hit.Content, err = result.Fields.GetColumn("content").GetAsString(i); err != nil {
+			return nil, fmt.Errorf("read content: %w", err)
+		}
+		if hasKB {
+			if hit.KnowledgeBase, err = result.Fields.GetColumn("kb_id").GetAsString(i); err != nil {
+				return nil, fmt.Errorf("read kb_id: %w", err)
+			}
+		}
+		if hasMetadata {
+			column, ok := result.Fields.GetColumn("metadata").(*entity.ColumnJSONBytes)
+			if !ok {
+				return nil, fmt.Errorf("read metadata: unexpected column type")
+			}
+			if hit.Metadata, err = column.ValueByIdx(i); err != nil {
+				return nil, fmt.Errorf("read metadata: %w", err)
+			}
+		}
+		hits = append(hits, hit)
+	}
+	return hits, nil
+}
+
+// Distances 计算查询向量与指定主键的向量之间的L2距离，不存在的主键不返回
+func (s *MilvusStorage) Distances(ctx context.Context, collectionName string, queryVector []float32, ids []int64) (map[int64]float32, error) {
+	distances := make(map[int64]float32, len(ids))
+	if len(ids) == 0 {
+		return distances, nil
+	}
+	if err := s.load(ctx, collectionName); err != nil {
+		return nil, err
+	}
+
+	members := make([]string, len(ids))
+	for i, id := range ids {
+		members[i] = strconv.FormatInt(id, 10)
+	}
+	expr := fmt.Sprintf("id in [%s]", strings.Join(members, ", "))
+	sp, err := entity.NewIndexHNSWSearchParam(max(len(ids), 64))
+	if err != nil {
+		return nil, fmt.Errorf("create search param: %w", err)
+	}
+
+	results, err := s.client.Search(ctx, collectionName, nil, expr, nil,
+		[]entity.Vector{entity.FloatVector(queryVector)}, "embedding", entity.L2, len(ids), sp)
+	if err != nil {
+		return nil, fmt.Errorf("vector distances: %w", err)
+	}
+	if len(results) == 0 {
+		return distances, nil
+	}
+	result := results[0]
+	if result.Err != nil {
+		return nil, fmt.Errorf("vector distances: %w", result.Err)
+	}
+	for i := 0; i < result.ResultCount; i++ {
+		id, err := result.IDs.GetAsInt64(i)
+		if err != nil {
+			return nil, fmt.Errorf("read id: %w", err)
+		}
+		distances[id] = result.Scores[i]
+	}
+	return distances, nil
+}
+
+// Scan 按主键顺序遍历集合中的全部数据，返回的实体不含向量；fn返回错误时停止遍历
+//
+// 集合没有kb_id字段时，数据都属于默认知识库。
+func (s *MilvusStorage) Scan(ctx context.Context, collectionName string, fn func(vector.Entity) error) error {
+	if _, err := s.DescribeCollection(ctx, collectionName); err != nil {
+		return err
+	}
+	if err := s.load(ctx, collectionName); err != nil {
+		return err
+	}
+
+	hasKB := s.hasField(collectionName, "kb_id")
+	hasMetadata := s.hasField(collectionName, "metadata")
+	outputFields := []string{"id", "content"}
+	if hasKB {
+		outputFields = append(outputFields, "kb_id")
+	}
+	if hasMetadata {
+		outputFields = append(outputFields, "metadata")
+	}
+
+	it, err := s.client.QueryIterator(ctx, client.NewQueryIteratorOption(collectionName).
+		WithOutputFields(outputFields...).WithBatchSize(500))
+	if err != nil {
+		return fmt.Errorf("scan collection: %w", err)
+	}
+	for {
+		rs, err := it.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("scan collection: %w", err)
+		}
+
+		for i := 0; i < rs.Len(); i++ {
+			e := vector.Entity{KnowledgeBase: model.DefaultKnowledgeBase}
+			if e.ID, err = rs.GetColumn("id").GetAsInt64(i); err != nil {
+				return fmt.Errorf("read id: %w", err)
+			}
+			if e.Content, err = rs.GetColumn("content").GetAsString(i); err != nil {
+				return fmt.Errorf("read content: %w", err)
+			}
+			if hasKB {
+				if e.KnowledgeBase, err = rs.GetColumn("kb_id").GetAsString(i); err != nil {
+					return fmt.Errorf("read kb_id: %w", err)
+				}
+			}
+			if hasMetadata {
+				column, ok := rs.GetColumn("metadata").(*entity.ColumnJSONBytes)
+				if !ok {
+					return fmt.Errorf("read metadata: unexpected column type")
+				}
+				if e.Metadata, err = column.ValueByIdx(i); err != nil {
+					return fmt.Errorf("read metadata: %w", err)
+				}
+			}
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// load 加载集合到内存，每个集合只加载一次
+func (s *MilvusStorage) load(ctx context.Context, collectionName string) error {
+	s.mu.Lock()
+	loaded := s.loaded[collectionName]
+	s.mu.Unlock()
+	if loaded {
+		return nil
+	}
 
-	return []string{}, []float32{}, fmt.Errorf("Milvus search not fully implemented yet, please refer to SDK documentation")
+	if err := s.client.LoadCollection(ctx, collectionName, false); err != nil {
+		return fmt.Errorf("load collection: %w", err)
+	}
+
+	s.mu.Lock()
+	s.loaded[collectionName] = true
+	s.mu.Unlock()
+	return nil
 }
 
 // Close 关闭连接
@@ -1,7 +0,0 @@
-package mysql
-
-import "errors"
-
-var (
-	ErrChatbotNotFound = errors.New("chatbot not found")
-)
@@ -0,0 +1,50 @@
+package mysql_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	"eino/internal/storage"
+	"eino/internal/storage/migrate"
+	"eino/internal/storage/mysql"
+	"eino/internal/storage/storagetest"
+)
+
+// 需要一个空的测试库，表结构由迁移自动创建，例如：
+// EINO_TEST_MYSQL_DSN="root:@tcp(127.0.0.1:3306)/eino_test?parseTime=True"
+func TestMySQLStorageContract(t *testing.T) {
+	dsn := os.Getenv("EINO_TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("EINO_TEST_MYSQL_DSN not set")
+	}
+
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		db, err := sql.Open("mysql", dsn)
+		if err != nil {
+			t.Fatalf("open mysql: %v", err)
+		}
+		defer db.Close()
+
+		m, err := migrate.New(db, migrate.DialectMySQL)
+		if err != nil {
+			t.Fatalf("migrate.New: %v", err)
+		}
+		if _, err := m.Up(context.Background()); err != nil {
+			t.Fatalf("migrate up: %v", err)
+		}
+		// 清空数据，对话记录通过外键级联删除
+		for _, table := range []string{"chatbots", "knowledge_bases", "knowledge_base", "reindex_jobs"} {
+			if _, err := db.Exec("DELETE FROM " + table); err != nil {
+				t.Fatalf("reset mysql: %v", err)
+			}
+		}
+
+		s, err := mysql.NewMySQLStorage(dsn)
+		if err != nil {
+			t.Fatalf("NewMySQLStorage: %v", err)
+		}
+		return s
+	})
+}
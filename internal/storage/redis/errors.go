@@ -1,7 +0,0 @@
-package redis
-
-import "errors"
-
-var (
-	ErrChatbotNotFound = errors.New("chatbot not found")
-)
@@ -3,10 +3,14 @@ package redis
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
+	"strconv"
 	"time"
 
 	"eino/internal/model"
+	"eino/internal/storage/errs"
 
 	"github.com/redis/go-redis/v9"
 )
@@ -34,8 +38,39 @@ func NewRedisStorage(addr, password string, db int) (*RedisStorage, error) {
 	return &RedisStorage{client: client}, nil
 }
 
+// Redis键名
+const (
+	chatbotIndexKey  = "chatbots"            // 所有聊天机器人ID的集合
+	conversationSeq  = "conversation:id"     // 对话ID自增序列
+	knowledgeBaseKey = "knowledge_bases"     // 知识库哈希，字段为名称
+	chunkSeq         = "knowledge_chunk:id"  // 知识分块ID自增序列
+	chunkKey         = "knowledge_chunks"    // 知识分块哈希，字段为ID
+	chunkIndexKey    = "knowledge_chunk_ids" // 知识分块ID有序集合，分数为ID
+	reindexJobKey    = "reindex_jobs"        // 重建索引任务哈希，字段为任务ID
+)
+
+// conversationTTL 对话记录的过期时间
+const conversationTTL = 7 * 24 * time.Hour
+
+// chunkKBIndexKey 知识库的分块ID有序集合，分数为ID
+func chunkKBIndexKey(kb string) string {
+	return "knowledge_chunk_ids:kb:" + kb
+}
+
+// chunkDocIndexKey 文档的分块ID有序集合，分数为ID
+func chunkDocIndexKey(documentID string) string {
+	return "knowledge_chunk_ids:doc:" + documentID
+}
+
+// chunkScanBatch 按条件过滤分块时每批读取的分块数
+const chunkScanBatch = 500
+
 // SaveChatbot 保存聊天机器人（缓存）
 func (s *RedisStorage) SaveChatbot(ctx context.Context, chatbot *model.Chatbot) error {
+	if chatbot.UpdatedAt.IsZero() {
+		chatbot.UpdatedAt = time.Now()
+	}
+
 	key := fmt.Sprintf("chatbot:%s", chatbot.ID)
 
 	data, err := json.Marshal(chatbot)
@@ -43,8 +78,18 @@ func (s *RedisStorage) SaveChatbot(ctx context.Context, chatbot *model.Chatbot)
 		return fmt.Errorf("marshal chatbot: %w", err)
 	}
 
+	pipe := s.client.TxPipeline()
 	// 缓存24小时
-	return s.client.Set(ctx, key, data, 24*time.Hour).Err()
+	pipe.Set(ctx, key, data, 24*time.Hour)
+	pipe.SAdd(ctx, chatbotIndexKey, chatbot.ID)
+	if chatbot.Slug != "" {
+		pipe.Set(ctx, fmt.Sprintf("chatbot:slug:%s", chatbot.Slug), chatbot.ID, 24*time.Hour)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("save chatbot: %w", err)
+	}
+
+	return nil
 }
 
 // GetChatbot 获取聊天机器人（从缓存）
@@ -53,7 +98,7 @@ func (s *RedisStorage) GetChatbot(ctx context.Context, id string) (*model.Chatbo
 
 	data, err := s.client.Get(ctx, key).Bytes()
 	if err == redis.Nil {
-		return nil, ErrChatbotNotFound
+		return nil, errs.ErrNotFound
 	}
 	if err != nil {
 		return nil, fmt.Errorf("get chatbot: %w", err)
@@ -67,32 +112,137 @@ func (s *RedisStorage) GetChatbot(ctx context.Context, id string) (*model.Chatbo
 	return &chatbot, nil
 }
 
-// GetChatbots 获取所有聊天机器人（Redis不适合，需要从主存储获取）
+// GetChatbotBySlug 按slug获取聊天机器人
+func (s *RedisStorage) GetChatbotBySlug(ctx context.Context, slug string) (*model.Chatbot, error) {
+	id, err := s.client.Get(ctx, fmt.Sprintf("chatbot:slug:%s", slug)).Result()
+	if err == redis.Nil {
+		return nil, errs.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get chatbot slug: %w", err)
+	}
+
+	chatbot, err := s.GetChatbot(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// slug映射可能已过时（机器人改名或删除后未清理）
+	if chatbot.Slug != slug {
+		return nil, errs.ErrNotFound
+	}
+
+	return chatbot, nil
+}
+
+// GetChatbots 获取所有聊天机器人（基于ID集合，跳过已过期的缓存）
 func (s *RedisStorage) GetChatbots(ctx context.Context) ([]*model.Chatbot, error) {
-	// Redis不适合存储列表，应该从MySQL获取
-	// 这里返回空，实际应该从主存储（MySQL）获取
-	return []*model.Chatbot{}, nil
+	ids, err := s.client.SMembers(ctx, chatbotIndexKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("smembers chatbots: %w", err)
+	}
+
+	chatbots := make([]*model.Chatbot, 0, len(ids))
+	for _, id := range ids {
+		chatbot, err := s.GetChatbot(ctx, id)
+		if errors.Is(err, errs.ErrNotFound) {
+			// 缓存已过期，顺便清理索引
+			s.client.SRem(ctx, chatbotIndexKey, id)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		chatbots = append(chatbots, chatbot)
+	}
+
+	// 集合无序，与数据库实现保持一致：按创建时间倒序
+	query := model.ChatbotQuery{Sort: model.ChatbotSortCreated, Desc: true}
+	sort.Slice(chatbots, func(i, j int) bool { return query.Less(chatbots[i], chatbots[j]) })
+
+	return chatbots, nil
 }
 
-// DeleteChatbot 删除聊天机器人（从缓存）
+// ListChatbots 分页查询聊天机器人（读取全部后在内存中过滤排序）
+func (s *RedisStorage) ListChatbots(ctx context.Context, q model.ChatbotQuery) (*model.ChatbotPage, error) {
+	chatbots, err := s.GetChatbots(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return model.NewChatbotPage(q, chatbots), nil
+}
+
+// DeleteChatbot 删除聊天机器人（从缓存），同时删除其对话记录和人设版本
 func (s *RedisStorage) DeleteChatbot(ctx context.Context, id string) error {
 	key := fmt.Sprintf("chatbot:%s", id)
-	return s.client.Del(ctx, key).Err()
+	convKey := fmt.Sprintf("conversations:%s", id)
+
+	members, err := s.client.ZRange(ctx, convKey, 0, -1).Result()
+	if err != nil {
+		return fmt.Errorf("zrange conversations: %w", err)
+	}
+
+	keys := make([]string, 0, 3*len(members)+4)
+	keys = append(keys, convKey,
+		fmt.Sprintf("persona_versions:%s", id),
+		fmt.Sprintf("persona_version:seq:%s", id),
+		fmt.Sprintf("feedback:%s", id),
+	)
+	for _, member := range members {
+		keys = append(keys, fmt.Sprintf("conversation:%s:%s", id, member), "conversation_owner:"+member, "feedback_owner:"+member)
+	}
+	if chatbot, err := s.GetChatbot(ctx, id); err == nil && chatbot.Slug != "" {
+		keys = append(keys, fmt.Sprintf("chatbot:slug:%s", chatbot.Slug))
+	}
+
+	pipe := s.client.TxPipeline()
+	delChatbot := pipe.Del(ctx, key)
+	pipe.Del(ctx, keys...)
+	pipe.SRem(ctx, chatbotIndexKey, id)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("delete chatbot: %w", err)
+	}
+
+	if delChatbot.Val() == 0 {
+		return errs.ErrNotFound
+	}
+
+	return nil
 }
 
 // SaveConversation 保存对话记录（添加到有序集合）
 func (s *RedisStorage) SaveConversation(ctx context.Context, conv *model.Conversation) error {
 	key := fmt.Sprintf("conversations:%s", conv.ChatbotID)
 
+	// 分配自增ID
+	id, err := s.client.Incr(ctx, conversationSeq).Result()
+	if err != nil {
+		return fmt.Errorf("incr conversation id: %w", err)
+	}
+	conv.ID = id
+	if conv.CreatedAt.IsZero() {
+		conv.CreatedAt = time.Now()
+	}
+
 	data, err := json.Marshal(conv)
 	if err != nil {
 		return fmt.Errorf("marshal conversation: %w", err)
 	}
 
-	// 使用时间戳作为分数，对话ID作为成员
-	score := float64(conv.CreatedAt.Unix())
+	// 使用对话ID作为分数和成员，保证与写入顺序一致
+	score := float64(conv.ID)
 	member := fmt.Sprintf("%d", conv.ID)
 
+	// 先保存对话内容，再加入有序集合，避免读到不存在的内容
+	// 同时记录对话所属的聊天机器人，供按ID读取，过期时间与内容一致
+	contentKey := fmt.Sprintf("conversation:%s:%d", conv.ChatbotID, conv.ID)
+	pipe := s.client.TxPipeline()
+	pipe.Set(ctx, contentKey, data, conversationTTL)
+	pipe.Set(ctx, fmt.Sprintf("conversation_owner:%d", conv.ID), conv.ChatbotID, conversationTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("set conversation content: %w", err)
+	}
+
 	// 添加到有序集合
 	if err := s.client.ZAdd(ctx, key, redis.Z{
 		Score:  score,
@@ -101,50 +251,384 @@ func (s *RedisStorage) SaveConversation(ctx context.Context, conv *model.Convers
 		return fmt.Errorf("zadd conversation: %w", err)
 	}
 
-	// 保存对话内容
-	contentKey := fmt.Sprintf("conversation:%s:%d", conv.ChatbotID, conv.ID)
-	if err := s.client.Set(ctx, contentKey, data, 7*24*time.Hour).Err(); err != nil {
-		return fmt.Errorf("set conversation content: %w", err)
-	}
-
 	// 限制有序集合大小（保留最近1000条）
 	s.client.ZRemRangeByRank(ctx, key, 0, -1001)
 
 	return nil
 }
 
-// GetConversationHistory 获取对话历史
+// DeleteConversations 删除对话内容、有序集合成员和反馈
+func (s *RedisStorage) DeleteConversations(ctx context.Context, chatbotID string, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	members := make([]string, len(ids))
+	zmembers := make([]interface{}, len(ids))
+	keys := make([]string, 0, 3*len(ids))
+	for i, id := range ids {
+		members[i] = strconv.FormatInt(id, 10)
+		zmembers[i] = members[i]
+		keys = append(keys, fmt.Sprintf("conversation:%s:%d", chatbotID, id))
+	}
+
+	// 只删除属于该聊天机器人的记录的归属键
+	owners, err := s.client.MGet(ctx, prefixed("conversation_owner:", members)...).Result()
+	if err != nil {
+		return fmt.Errorf("get conversation owners: %w", err)
+	}
+	for i, owner := range owners {
+		if owner == chatbotID {
+			keys = append(keys, "conversation_owner:"+members[i], "feedback_owner:"+members[i])
+		}
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.ZRem(ctx, fmt.Sprintf("conversations:%s", chatbotID), zmembers...)
+	pipe.HDel(ctx, fmt.Sprintf("feedback:%s", chatbotID), members...)
+	pipe.Del(ctx, keys...)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("delete conversations: %w", err)
+	}
+	return nil
+}
+
+// prefixed 为每个成员加上前缀
+func prefixed(prefix string, members []string) []string {
+	keys := make([]string, len(members))
+	for i, member := range members {
+		keys[i] = prefix + member
+	}
+	return keys
+}
+
+// GetConversationHistory 获取当前分支的对话历史
 func (s *RedisStorage) GetConversationHistory(ctx context.Context, chatbotID string, limit int) ([]*model.Conversation, error) {
+	if limit <= 0 {
+		return []*model.Conversation{}, nil
+	}
+
 	key := fmt.Sprintf("conversations:%s", chatbotID)
 
-	// 从有序集合获取最近的对话ID
-	members, err := s.client.ZRevRange(ctx, key, 0, int64(limit-1)).Result()
+	// 最新的记录即当前分支的末端
+	members, err := s.client.ZRevRange(ctx, key, 0, 0).Result()
 	if err != nil {
 		return nil, fmt.Errorf("zrevrange: %w", err)
 	}
+	if len(members) == 0 {
+		return []*model.Conversation{}, nil
+	}
 
-	var conversations []*model.Conversation
-	for _, member := range members {
-		contentKey := fmt.Sprintf("conversation:%s:%s", chatbotID, member)
-		data, err := s.client.Get(ctx, contentKey).Bytes()
+	conversations, err := s.loadConversations(ctx, chatbotID, members)
+	if err != nil || len(conversations) == 0 {
+		return conversations, err
+	}
+
+	return s.walkPath(ctx, conversations[0], limit)
+}
+
+// GetConversation 获取对话记录
+func (s *RedisStorage) GetConversation(ctx context.Context, id int64) (*model.Conversation, error) {
+	chatbotID, err := s.client.Get(ctx, fmt.Sprintf("conversation_owner:%d", id)).Result()
+	if err == redis.Nil {
+		return nil, errs.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get conversation owner: %w", err)
+	}
+
+	conversations, err := s.loadConversations(ctx, chatbotID, []string{strconv.FormatInt(id, 10)})
+	if err != nil {
+		return nil, err
+	}
+	if len(conversations) == 0 {
+		return nil, errs.ErrNotFound
+	}
+
+	return conversations[0], nil
+}
+
+// GetConversationPath 获取以id为终点的对话路径
+func (s *RedisStorage) GetConversationPath(ctx context.Context, id int64, limit int) ([]*model.Conversation, error) {
+	conv, err := s.GetConversation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.walkPath(ctx, conv, limit)
+}
+
+// walkPathScript 在服务端沿parent_id向上读取对话内容，一次往返取回整条路径
+//
+// ARGV：内容键前缀、起点ID、最多读取的条数；返回从起点向上的原始JSON，遇到已过期的父节点时停止。
+// 键名在脚本内拼出，不兼容Redis Cluster，与本存储的其他键一样假定单实例部署。
+var walkPathScript = redis.NewScript(`
+local prefix, id, limit = ARGV[1], ARGV[2], tonumber(ARGV[3])
+local result = {}
+while id ~= "0" and #result < limit do
+	local data = redis.call("GET", prefix .. id)
+	if not data then
+		break
+	end
+	result[#result + 1] = data
+	local parent = cjson.decode(data)["parent_id"]
+	if type(parent) ~= "number" then
+		break
+	end
+	id = string.format("%d", parent)
+end
+return result
+`)
+
+// walkPath 从leaf沿父节点向上读取最近limit轮对话，按时间正序返回；父节点已过期时路径到此为止
+func (s *RedisStorage) walkPath(ctx context.Context, leaf *model.Conversation, limit int) ([]*model.Conversation, error) {
+	conversations := make([]*model.Conversation, 0)
+	if limit <= 0 {
+		return conversations, nil
+	}
+	conversations = append(conversations, leaf)
+
+	if leaf.ParentID != 0 && limit > 1 {
+		values, err := walkPathScript.Run(ctx, s.client, nil,
+			fmt.Sprintf("conversation:%s:", leaf.ChatbotID), leaf.ParentID, limit-1).StringSlice()
 		if err != nil {
+			return nil, fmt.Errorf("walk conversation path: %w", err)
+		}
+		for _, data := range values {
+			var conv model.Conversation
+			if err := json.Unmarshal([]byte(data), &conv); err != nil {
+				return nil, fmt.Errorf("unmarshal conversation: %w", err)
+			}
+			conversations = append(conversations, &conv)
+		}
+	}
+
+	// 反转顺序，使最早的对话在前
+	for i, j := 0, len(conversations)-1; i < j; i, j = i+1, j-1 {
+		conversations[i], conversations[j] = conversations[j], conversations[i]
+	}
+
+	return conversations, nil
+}
+
+// GetConversationBranches 获取与id同一父节点的全部对话记录（读取全部后在内存中过滤）
+func (s *RedisStorage) GetConversationBranches(ctx context.Context, id int64) ([]*model.Conversation, error) {
+	target, err := s.GetConversation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// 子节点的ID一定大于父节点
+	min := "-inf"
+	if target.ParentID > 0 {
+		min = fmt.Sprintf("(%d", target.ParentID)
+	}
+	members, err := s.client.ZRangeByScore(ctx, fmt.Sprintf("conversations:%s", target.ChatbotID), &redis.ZRangeBy{Min: min, Max: "+inf"}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("zrangebyscore: %w", err)
+	}
+
+	convs, err := s.loadConversations(ctx, target.ChatbotID, members)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*model.Conversation, 0)
+	for _, conv := range convs {
+		if conv.ParentID == target.ParentID {
+			result = append(result, conv)
+		}
+	}
+
+	return result, nil
+}
+
+// QueryConversationHistory 分页查询对话历史
+func (s *RedisStorage) QueryConversationHistory(ctx context.Context, q model.HistoryQuery) (*model.HistoryPage, error) {
+	result := make([]*model.Conversation, 0)
+	if q.Limit <= 0 {
+		return model.NewHistoryPage(q, result), nil
+	}
+
+	key := fmt.Sprintf("conversations:%s", q.ChatbotID)
+
+	// 分数即对话ID，游标条件直接转换为分数区间
+	min, max := "-inf", "+inf"
+	if q.After > 0 {
+		min = fmt.Sprintf("(%d", q.After)
+	}
+	if q.Before > 0 {
+		max = fmt.Sprintf("(%d", q.Before)
+	}
+
+	// 时间和关键词条件需要读取内容后过滤，分批扫描直到多取到一条
+	const batch = 100
+	for offset := int64(0); len(result) <= q.Limit; offset += batch {
+		opt := &redis.ZRangeBy{Min: min, Max: max, Offset: offset, Count: batch}
+
+		var members []string
+		var err error
+		if q.Forward() {
+			members, err = s.client.ZRangeByScore(ctx, key, opt).Result()
+		} else {
+			members, err = s.client.ZRevRangeByScore(ctx, key, opt).Result()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("zrangebyscore: %w", err)
+		}
+
+		convs, err := s.loadConversations(ctx, q.ChatbotID, members)
+		if err != nil {
+			return nil, err
+		}
+		for _, conv := range convs {
+			if q.Match(conv) {
+				result = append(result, conv)
+				if len(result) > q.Limit {
+					break
+				}
+			}
+		}
+
+		if len(members) < batch {
+			break
+		}
+	}
+
+	return model.NewHistoryPage(q, result), nil
+}
+
+// loadConversations 按成员ID批量读取对话内容，跳过已过期的对话
+func (s *RedisStorage) loadConversations(ctx context.Context, chatbotID string, members []string) ([]*model.Conversation, error) {
+	conversations := make([]*model.Conversation, 0, len(members))
+	if len(members) == 0 {
+		return conversations, nil
+	}
+
+	keys := make([]string, len(members))
+	for i, member := range members {
+		keys[i] = fmt.Sprintf("conversation:%s:%s", chatbotID, member)
+	}
+
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("mget conversations: %w", err)
+	}
+
+	for _, value := range values {
+		data, ok := value.(string)
+		if !ok {
 			continue // 跳过已过期的对话
 		}
 
 		var conv model.Conversation
-		if err := json.Unmarshal(data, &conv); err != nil {
+		if err := json.Unmarshal([]byte(data), &conv); err != nil {
 			continue
 		}
 
 		conversations = append(conversations, &conv)
 	}
 
-	// 反转顺序，使最早的对话在前
-	for i, j := 0, len(conversations)-1; i < j; i, j = i+1, j-1 {
-		conversations[i], conversations[j] = conversations[j], conversations[i]
+	return conversations, nil
+}
+
+// SavePersonaVersion 保存人设版本，分配版本号并设为聊天机器人的当前版本
+//
+// 版本内容保存在哈希 persona_versions:<chatbot_id> 中，字段为版本号，不设过期时间。
+func (s *RedisStorage) SavePersonaVersion(ctx context.Context, v *model.PersonaVersion) error {
+	chatbotKey := fmt.Sprintf("chatbot:%s", v.ChatbotID)
+	if _, err := s.GetChatbot(ctx, v.ChatbotID); err != nil {
+		return err
 	}
 
-	return conversations, nil
+	version, err := s.client.Incr(ctx, fmt.Sprintf("persona_version:seq:%s", v.ChatbotID)).Result()
+	if err != nil {
+		return fmt.Errorf("incr persona version: %w", err)
+	}
+	v.Version = int(version)
+	if v.CreatedAt.IsZero() {
+		v.CreatedAt = time.Now()
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("marshal persona version: %w", err)
+	}
+	if err := s.client.HSet(ctx, fmt.Sprintf("persona_versions:%s", v.ChatbotID), strconv.Itoa(v.Version), data).Err(); err != nil {
+		return fmt.Errorf("save persona version: %w", err)
+	}
+
+	// 乐观锁更新聊天机器人的当前版本，避免覆盖并发写入
+	err = s.client.Watch(ctx, func(tx *redis.Tx) error {
+		raw, err := tx.Get(ctx, chatbotKey).Bytes()
+		if err == redis.Nil {
+			return errs.ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		var chatbot model.Chatbot
+		if err := json.Unmarshal(raw, &chatbot); err != nil {
+			return fmt.Errorf("unmarshal chatbot: %w", err)
+		}
+		if chatbot.PersonaVersion >= v.Version {
+			return nil
+		}
+		chatbot.PersonaVersion = v.Version
+
+		data, err := json.Marshal(&chatbot)
+		if err != nil {
+			return fmt.Errorf("marshal chatbot: %w", err)
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.SetArgs(ctx, chatbotKey, data, redis.SetArgs{KeepTTL: true})
+			return nil
+		})
+		return err
+	}, chatbotKey)
+	if err != nil {
+		return fmt.Errorf("update chatbot persona version: %w", err)
+	}
+
+	return nil
+}
+
+// GetPersonaVersion 获取指定人设版本
+func (s *RedisStorage) GetPersonaVersion(ctx context.Context, chatbotID string, version int) (*model.PersonaVersion, error) {
+	data, err := s.client.HGet(ctx, fmt.Sprintf("persona_versions:%s", chatbotID), strconv.Itoa(version)).Bytes()
+	if err == redis.Nil {
+		return nil, errs.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get persona version: %w", err)
+	}
+
+	var v model.PersonaVersion
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("unmarshal persona version: %w", err)
+	}
+
+	return &v, nil
+}
+
+// ListPersonaVersions 获取聊天机器人的全部人设版本，最新版本在前
+func (s *RedisStorage) ListPersonaVersions(ctx context.Context, chatbotID string) ([]*model.PersonaVersion, error) {
+	values, err := s.client.HVals(ctx, fmt.Sprintf("persona_versions:%s", chatbotID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("hvals persona versions: %w", err)
+	}
+
+	versions := make([]*model.PersonaVersion, 0, len(values))
+	for _, value := range values {
+		var v model.PersonaVersion
+		if err := json.Unmarshal([]byte(value), &v); err != nil {
+			return nil, fmt.Errorf("unmarshal persona version: %w", err)
+		}
+		versions = append(versions, &v)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Version > versions[j].Version })
+
+	return versions, nil
 }
 
 // SetSession 设置会话状态
@@ -194,6 +678,437 @@ func (s *RedisStorage) RateLimit(ctx context.Context, key string, limit int, win
 	return count <= int64(limit), nil
 }
 
+// SaveFeedback 保存反馈（按聊天机器人存入哈希，字段为对话ID），同一对话已有反馈时覆盖
+func (s *RedisStorage) SaveFeedback(ctx context.Context, f *model.Feedback) error {
+	now := time.Now()
+	if f.CreatedAt.IsZero() {
+		f.CreatedAt = now
+	}
+	if f.UpdatedAt.IsZero() {
+		f.UpdatedAt = now
+	}
+
+	data, err := json.Marshal(f)
+	if err != nil {
+		return fmt.Errorf("marshal feedback: %w", err)
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.HSet(ctx, fmt.Sprintf("feedback:%s", f.ChatbotID), strconv.FormatInt(f.ConversationID, 10), data)
+	pipe.Set(ctx, fmt.Sprintf("feedback_owner:%d", f.ConversationID), f.ChatbotID, 0)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("save feedback: %w", err)
+	}
+
+	return nil
+}
+
+// GetFeedback 获取对话的反馈
+func (s *RedisStorage) GetFeedback(ctx context.Context, conversationID int64) (*model.Feedback, error) {
+	chatbotID, err := s.client.Get(ctx, fmt.Sprintf("feedback_owner:%d", conversationID)).Result()
+	if err == redis.Nil {
+		return nil, errs.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get feedback owner: %w", err)
+	}
+
+	data, err := s.client.HGet(ctx, fmt.Sprintf("feedback:%s", chatbotID), strconv.FormatInt(conversationID, 10)).Bytes()
+	if err == redis.Nil {
+		return nil, errs.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get feedback: %w", err)
+	}
+
+	var f model.Feedback
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("unmarshal feedback: %w", err)
+	}
+	return &f, nil
+}
+
+// ListFeedback 查询聊天机器人的反馈（读取全部后在内存中过滤），按对话ID升序
+func (s *RedisStorage) ListFeedback(ctx context.Context, q model.FeedbackQuery) ([]*model.Feedback, error) {
+	values, err := s.client.HVals(ctx, fmt.Sprintf("feedback:%s", q.ChatbotID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("hvals feedback: %w", err)
+	}
+
+	result := make([]*model.Feedback, 0, len(values))
+	for _, data := range values {
+		var f model.Feedback
+		if err := json.Unmarshal([]byte(data), &f); err != nil {
+			return nil, fmt.Errorf("unmarshal feedback: %w", err)
+		}
+		if q.Match(&f) {
+			result = append(result, &f)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ConversationID < result[j].ConversationID })
+
+	return result, nil
+}
+
+// SaveKnowledgeBase 保存知识库（存入哈希，不设过期时间），同名知识库已存在时覆盖描述
+func (s *RedisStorage) SaveKnowledgeBase(ctx context.Context, kb *model.KnowledgeBase) error {
+	existing, err := s.GetKnowledgeBase(ctx, kb.Name)
+	switch {
+	case err == nil:
+		kb.CreatedAt = existing.CreatedAt
+	case !errors.Is(err, errs.ErrNotFound):
+		return err
+	case kb.CreatedAt.IsZero():
+		kb.CreatedAt = time.Now()
+	}
+
+	data, err := json.Marshal(kb)
+	if err != nil {
+		return fmt.Errorf("marshal knowledge base: %w", err)
+	}
+	if err := s.client.HSet(ctx, knowledgeBaseKey, kb.Name, data).Err(); err != nil {
+		return fmt.Errorf("save knowledge base: %w", err)
+	}
+
+	return nil
+}
+
+// GetKnowledgeBase 获取知识库
+func (s *RedisStorage) GetKnowledgeBase(ctx context.Context, name string) (*model.KnowledgeBase, error) {
+	data, err := s.client.HGet(ctx, knowledgeBaseKey, name).Bytes()
+	if err == redis.Nil {
+		return nil, errs.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get knowledge base: %w", err)
+	}
+
+	var kb model.KnowledgeBase
+	if err := json.Unmarshal(data, &kb); err != nil {
+		return nil, fmt.Errorf("unmarshal knowledge base: %w", err)
+	}
+	return &kb, nil
+}
+
+// ListKnowledgeBases 获取全部知识库，按名称升序
+func (s *RedisStorage) ListKnowledgeBases(ctx context.Context) ([]*model.KnowledgeBase, error) {
+	values, err := s.client.HGetAll(ctx, knowledgeBaseKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("list knowledge bases: %w", err)
+	}
+
+	kbs := make([]*model.KnowledgeBase, 0, len(values))
+	for _, data := range values {
+		var kb model.KnowledgeBase
+		if err := json.Unmarshal([]byte(data), &kb); err != nil {
+			return nil, fmt.Errorf("unmarshal knowledge base: %w", err)
+		}
+		kbs = append(kbs, &kb)
+	}
+	sort.Slice(kbs, func(i, j int) bool { return kbs[i].Name < kbs[j].Name })
+
+	return kbs, nil
+}
+
+// SaveKnowledgeChunks 批量保存知识分块（存入哈希并按ID加入全部、知识库和文档的有序集合，不设过期时间）
+func (s *RedisStorage) SaveKnowledgeChunks(ctx context.Context, chunks []*model.KnowledgeChunk) error {
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	// 一次分配整批ID
+	last, err := s.client.IncrBy(ctx, chunkSeq, int64(len(chunks))).Result()
+	if err != nil {
+		return fmt.Errorf("incr knowledge chunk id: %w", err)
+	}
+
+	now := time.Now()
+	pipe := s.client.TxPipeline()
+	for i, chunk := range chunks {
+		chunk.ID = last - int64(len(chunks)) + int64(i) + 1
+		if chunk.CreatedAt.IsZero() {
+			chunk.CreatedAt = now
+		}
+		chunk.UpdatedAt = chunk.CreatedAt
+		data, err := json.Marshal(chunk)
+		if err != nil {
+			return fmt.Errorf("marshal knowledge chunk: %w", err)
+		}
+		member := strconv.FormatInt(chunk.ID, 10)
+		pipe.HSet(ctx, chunkKey, member, data)
+		z := redis.Z{Score: float64(chunk.ID), Member: member}
+		pipe.ZAdd(ctx, chunkIndexKey, z)
+		pipe.ZAdd(ctx, chunkKBIndexKey(chunk.KnowledgeBase), z)
+		if chunk.DocumentID != "" {
+			pipe.ZAdd(ctx, chunkDocIndexKey(chunk.DocumentID), z)
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("save knowledge chunks: %w", err)
+	}
+
+	return nil
+}
+
+// ListKnowledgeChunks 按ID升序获取ID大于afterID的最多limit个分块
+func (s *RedisStorage) ListKnowledgeChunks(ctx context.Context, afterID int64, limit int) ([]*model.KnowledgeChunk, error) {
+	ids, err := s.client.ZRangeByScore(ctx, chunkIndexKey, &redis.ZRangeBy{
+		Min:   "(" + strconv.FormatInt(afterID, 10),
+		Max:   "+inf",
+		Count: int64(limit),
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("list knowledge chunk ids: %w", err)
+	}
+	return s.getChunks(ctx, ids)
+}
+
+// getChunks 按ID读取分块，保持ID的顺序，已删除的分块跳过
+func (s *RedisStorage) getChunks(ctx context.Context, ids []string) ([]*model.KnowledgeChunk, error) {
+	if len(ids) == 0 {
+		return []*model.KnowledgeChunk{}, nil
+	}
+
+	values, err := s.client.HMGet(ctx, chunkKey, ids...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("get knowledge chunks: %w", err)
+	}
+
+	chunks := make([]*model.KnowledgeChunk, 0, len(values))
+	for _, v := range values {
+		data, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var chunk model.KnowledgeChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("unmarshal knowledge chunk: %w", err)
+		}
+		chunks = append(chunks, &chunk)
+	}
+	return chunks, nil
+}
+
+// CountKnowledgeChunks 获取知识分块总数
+func (s *RedisStorage) CountKnowledgeChunks(ctx context.Context) (int, error) {
+	count, err := s.client.ZCard(ctx, chunkIndexKey).Result()
+	if err != nil {
+		return 0, fmt.Errorf("count knowledge chunks: %w", err)
+	}
+	return int(count), nil
+}
+
+// QueryKnowledgeChunks 分页查询知识分块，按ID升序
+//
+// 按文档、知识库或全部分块的ID有序集合分页；还有该集合不能覆盖的过滤条件时，
+// 按ID顺序逐批读取集合中的分块并过滤。
+func (s *RedisStorage) QueryKnowledgeChunks(ctx context.Context, q model.KnowledgeQuery) (*model.KnowledgePage, error) {
+	index := chunkIndexKey
+	filtered := q.Source != ""
+	switch {
+	case q.DocumentID != "":
+		index = chunkDocIndexKey(q.DocumentID)
+		filtered = filtered || q.KnowledgeBase != ""
+	case q.KnowledgeBase != "":
+		index = chunkKBIndexKey(q.KnowledgeBase)
+	}
+	if filtered {
+		return s.filterChunks(ctx, index, q)
+	}
+
+	page := &model.KnowledgePage{Items: []*model.KnowledgeChunk{}, Offset: q.Offset, Limit: q.Limit}
+	pipe := s.client.TxPipeline()
+	total := pipe.ZCard(ctx, index)
+	var ids *redis.StringSliceCmd
+	if q.Limit > 0 {
+		ids = pipe.ZRangeByScore(ctx, index, &redis.ZRangeBy{
+			Min:    "-inf",
+			Max:    "+inf",
+			Offset: int64(q.Offset),
+			Count:  int64(q.Limit),
+		})
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("query knowledge chunk ids: %w", err)
+	}
+	page.Total = int(total.Val())
+	if ids == nil {
+		return page, nil
+	}
+
+	chunks, err := s.getChunks(ctx, ids.Val())
+	if err != nil {
+		return nil, err
+	}
+	page.Items = chunks
+	return page, nil
+}
+
+// filterChunks 按ID顺序逐批读取有序集合index中的分块，返回满足查询条件的一页
+func (s *RedisStorage) filterChunks(ctx context.Context, index string, q model.KnowledgeQuery) (*model.KnowledgePage, error) {
+	page := &model.KnowledgePage{Items: []*model.KnowledgeChunk{}, Offset: q.Offset, Limit: q.Limit}
+	after := "-inf"
+	for {
+		ids, err := s.client.ZRangeByScore(ctx, index, &redis.ZRangeBy{
+			Min:   after,
+			Max:   "+inf",
+			Count: chunkScanBatch,
+		}).Result()
+		if err != nil {
+			return nil, fmt.Errorf("query knowledge chunk ids: %w", err)
+		}
+		if len(ids) == 0 {
+			return page, nil
+		}
+		after = "(" + ids[len(ids)-1]
+
+		chunks, err := s.getChunks(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, chunk := range chunks {
+			if !q.Match(chunk) {
+				continue
+			}
+			if page.Total >= q.Offset && len(page.Items) < q.Limit {
+				page.Items = append(page.Items, chunk)
+			}
+			page.Total++
+		}
+	}
+}
+
+// GetKnowledgeChunk 获取知识分块
+func (s *RedisStorage) GetKnowledgeChunk(ctx context.Context, id int64) (*model.KnowledgeChunk, error) {
+	data, err := s.client.HGet(ctx, chunkKey, strconv.FormatInt(id, 10)).Result()
+	if err == redis.Nil {
+		return nil, errs.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get knowledge chunk: %w", err)
+	}
+
+	var chunk model.KnowledgeChunk
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return nil, fmt.Errorf("unmarshal knowledge chunk: %w", err)
+	}
+	return &chunk, nil
+}
+
+// UpdateKnowledgeChunk 修改分块的内容、来源、分类和元数据
+func (s *RedisStorage) UpdateKnowledgeChunk(ctx context.Context, chunk *model.KnowledgeChunk) error {
+	stored, err := s.GetKnowledgeChunk(ctx, chunk.ID)
+	if err != nil {
+		return err
+	}
+
+	chunk.UpdatedAt = time.Now()
+	stored.Content = chunk.Content
+	stored.Source = chunk.Source
+	stored.Category = chunk.Category
+	stored.Metadata = chunk.Metadata
+	stored.UpdatedAt = chunk.UpdatedAt
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return fmt.Errorf("marshal knowledge chunk: %w", err)
+	}
+	if err := s.client.HSet(ctx, chunkKey, strconv.FormatInt(chunk.ID, 10), data).Err(); err != nil {
+		return fmt.Errorf("update knowledge chunk: %w", err)
+	}
+	return nil
+}
+
+// DeleteKnowledgeChunks 删除知识分块，不存在的ID忽略
+//
+// 先读取分块得到所属的知识库和文档，再从对应的有序集合中移除。
+func (s *RedisStorage) DeleteKnowledgeChunks(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	members := make([]string, len(ids))
+	for i, id := range ids {
+		members[i] = strconv.FormatInt(id, 10)
+	}
+	chunks, err := s.getChunks(ctx, members)
+	if err != nil {
+		return err
+	}
+
+	// 各有序集合中要移除的成员
+	indexes := map[string][]interface{}{chunkIndexKey: make([]interface{}, len(members))}
+	for i, member := range members {
+		indexes[chunkIndexKey][i] = member
+	}
+	for _, chunk := range chunks {
+		member := strconv.FormatInt(chunk.ID, 10)
+		key := chunkKBIndexKey(chunk.KnowledgeBase)
+		indexes[key] = append(indexes[key], member)
+		if chunk.DocumentID != "" {
+			key = chunkDocIndexKey(chunk.DocumentID)
+			indexes[key] = append(indexes[key], member)
+		}
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.HDel(ctx, chunkKey, members...)
+	for key, zmembers := range indexes {
+		pipe.ZRem(ctx, key, zmembers...)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("delete knowledge chunks: %w", err)
+	}
+	return nil
+}
+
+// SaveReindexJob 保存重建索引任务（存入哈希，不设过期时间）
+func (s *RedisStorage) SaveReindexJob(ctx context.Context, job *model.ReindexJob) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("marshal reindex job: %w", err)
+	}
+	if err := s.client.HSet(ctx, reindexJobKey, job.ID, data).Err(); err != nil {
+		return fmt.Errorf("save reindex job: %w", err)
+	}
+	return nil
+}
+
+// GetReindexJob 获取重建索引任务
+func (s *RedisStorage) GetReindexJob(ctx context.Context, id string) (*model.ReindexJob, error) {
+	data, err := s.client.HGet(ctx, reindexJobKey, id).Bytes()
+	if err == redis.Nil {
+		return nil, errs.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get reindex job: %w", err)
+	}
+
+	var job model.ReindexJob
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("unmarshal reindex job: %w", err)
+	}
+	return &job, nil
+}
+
+// ListReindexJobs 获取全部重建索引任务，最新创建的在前
+func (s *RedisStorage) ListReindexJobs(ctx context.Context) ([]*model.ReindexJob, error) {
+	values, err := s.client.HVals(ctx, reindexJobKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("list reindex jobs: %w", err)
+	}
+
+	jobs := make([]*model.ReindexJob, 0, len(values))
+	for _, data := range values {
+		var job model.ReindexJob
+		if err := json.Unmarshal([]byte(data), &job); err != nil {
+			return nil, fmt.Errorf("unmarshal reindex job: %w", err)
+		}
+		jobs = append(jobs, &job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.After(jobs[j].CreatedAt) })
+
+	return jobs, nil
+}
+
 // Close 关闭Redis连接
 func (s *RedisStorage) Close() error {
 	return s.client.Close()
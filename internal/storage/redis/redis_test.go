@@ -0,0 +1,36 @@
+package redis_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"eino/internal/storage"
+	"eino/internal/storage/redis"
+	"eino/internal/storage/storagetest"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// 测试会清空 EINO_TEST_REDIS_ADDR 的 15 号库
+func TestRedisStorageContract(t *testing.T) {
+	addr := os.Getenv("EINO_TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("EINO_TEST_REDIS_ADDR not set")
+	}
+	const db = 15
+
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		client := goredis.NewClient(&goredis.Options{Addr: addr, DB: db})
+		defer client.Close()
+		if err := client.FlushDB(context.Background()).Err(); err != nil {
+			t.Fatalf("reset redis: %v", err)
+		}
+
+		s, err := redis.NewRedisStorage(addr, "", db)
+		if err != nil {
+			t.Fatalf("NewRedisStorage: %v", err)
+		}
+		return s
+	})
+}
@@ -0,0 +1,1244 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"eino/internal/model"
+	"eino/internal/storage/errs"
+	"eino/internal/storage/migrate"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteStorage SQLite存储实现（嵌入式，适合单机部署和本地开发）
+type SQLiteStorage struct {
+	db *sql.DB
+}
+
+// NewSQLiteStorage 创建SQLite存储实例
+//
+// 表结构由 internal/storage/migrate 维护，需先执行迁移（或开启 auto_migrate）；
+// 存在未执行的迁移时返回错误，避免在缺表的数据库上运行到第一次查询才失败
+func NewSQLiteStorage(path string) (*SQLiteStorage, error) {
+	db, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkSchema(db, path); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteStorage{db: db}, nil
+}
+
+// checkSchema 检查内嵌的迁移是否都已执行
+func checkSchema(db *sql.DB, path string) error {
+	m, err := migrate.New(db, migrate.DialectSQLite)
+	if err != nil {
+		return err
+	}
+	pending, err := m.Pending(context.Background())
+	if err != nil {
+		return fmt.Errorf("check sqlite schema: %w", err)
+	}
+	if len(pending) > 0 {
+		first := pending[0]
+		return fmt.Errorf("sqlite database %s has %d pending migrations starting at %d_%s: "+
+			"run \"server migrate up\" or set storage.auto_migrate to true", path, len(pending), first.Version, first.Name)
+	}
+	return nil
+}
+
+// Open 打开SQLite数据库连接，供存储实现和迁移共用
+func Open(path string) (*sql.DB, error) {
+	if path != ":memory:" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, fmt.Errorf("create sqlite directory: %w", err)
+		}
+	}
+
+	// 开启WAL模式、外键约束（级联删除依赖此项）以及忙等待
+	dsn := fmt.Sprintf("file:%s?_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite database: %w", err)
+	}
+
+	// SQLite只允许单个写入者，使用单连接避免锁冲突
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("ping sqlite: %w", err)
+	}
+
+	return db, nil
+}
+
+// chatbotColumns 聊天机器人查询列，与scanChatbot保持一致
+const chatbotColumns = "id, slug, name, personality, background, prompt_template, prompt_format, options, system_prompt, persona_version, created_at, updated_at"
+
+// rowScanner sql.Row与sql.Rows的公共接口
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanChatbot 扫描一行聊天机器人数据
+func scanChatbot(row rowScanner) (*model.Chatbot, error) {
+	var chatbot model.Chatbot
+	var slug, promptTemplate, promptFormat, options sql.NullString
+	if err := row.Scan(
+		&chatbot.ID,
+		&slug,
+		&chatbot.Name,
+		&chatbot.Personality,
+		&chatbot.Background,
+		&promptTemplate,
+		&promptFormat,
+		&options,
+		&chatbot.SystemPrompt,
+		&chatbot.PersonaVersion,
+		&chatbot.CreatedAt,
+		&chatbot.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	chatbot.Slug = slug.String
+	chatbot.PromptTemplate = promptTemplate.String
+	chatbot.PromptFormat = promptFormat.String
+	if options.String != "" {
+		if err := json.Unmarshal([]byte(options.String), &chatbot.Options); err != nil {
+			return nil, fmt.Errorf("unmarshal chatbot options: %w", err)
+		}
+	}
+
+	return &chatbot, nil
+}
+
+// SaveChatbot 保存聊天机器人
+func (s *SQLiteStorage) SaveChatbot(ctx context.Context, chatbot *model.Chatbot) error {
+	if chatbot.UpdatedAt.IsZero() {
+		chatbot.UpdatedAt = time.Now()
+	}
+
+	options, err := json.Marshal(chatbot.Options)
+	if err != nil {
+		return fmt.Errorf("marshal chatbot options: %w", err)
+	}
+
+	query := `
+		INSERT INTO chatbots (id, slug, name, personality, background, prompt_template, prompt_format, options, system_prompt, persona_version, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			slug = excluded.slug,
+			name = excluded.name,
+			personality = excluded.personality,
+			background = excluded.background,
+			prompt_template = excluded.prompt_template,
+			prompt_format = excluded.prompt_format,
+			options = excluded.options,
+			system_prompt = excluded.system_prompt,
+			persona_version = excluded.persona_version,
+			updated_at = excluded.updated_at
+	`
+
+	// 空slug存为NULL，避免违反唯一索引
+	var slug sql.NullString
+	if chatbot.Slug != "" {
+		slug = sql.NullString{String: chatbot.Slug, Valid: true}
+	}
+
+	_, err = s.db.ExecContext(ctx, query,
+		chatbot.ID,
+		slug,
+		chatbot.Name,
+		chatbot.Personality,
+		chatbot.Background,
+		chatbot.PromptTemplate,
+		chatbot.PromptFormat,
+		string(options),
+		chatbot.SystemPrompt,
+		chatbot.PersonaVersion,
+		chatbot.CreatedAt,
+		chatbot.UpdatedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("save chatbot: %w", err)
+	}
+
+	return nil
+}
+
+// GetChatbot 获取聊天机器人
+func (s *SQLiteStorage) GetChatbot(ctx context.Context, id string) (*model.Chatbot, error) {
+	query := `SELECT ` + chatbotColumns + ` FROM chatbots WHERE id = ?`
+
+	chatbot, err := scanChatbot(s.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, errs.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get chatbot: %w", err)
+	}
+
+	return chatbot, nil
+}
+
+// GetChatbotBySlug 按slug获取聊天机器人
+func (s *SQLiteStorage) GetChatbotBySlug(ctx context.Context, slug string) (*model.Chatbot, error) {
+	query := `SELECT ` + chatbotColumns + ` FROM chatbots WHERE slug = ?`
+
+	chatbot, err := scanChatbot(s.db.QueryRowContext(ctx, query, slug))
+	if err == sql.ErrNoRows {
+		return nil, errs.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get chatbot by slug: %w", err)
+	}
+
+	return chatbot, nil
+}
+
+// GetChatbots 获取所有聊天机器人
+func (s *SQLiteStorage) GetChatbots(ctx context.Context) ([]*model.Chatbot, error) {
+	query := `SELECT ` + chatbotColumns + ` FROM chatbots ORDER BY created_at DESC, id DESC`
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("get chatbots: %w", err)
+	}
+	defer rows.Close()
+
+	chatbots := make([]*model.Chatbot, 0)
+	for rows.Next() {
+		chatbot, err := scanChatbot(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan chatbot: %w", err)
+		}
+		chatbots = append(chatbots, chatbot)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return chatbots, nil
+}
+
+// chatbotSortColumns 排序字段到列名的映射
+var chatbotSortColumns = map[string]string{
+	model.ChatbotSortCreated: "created_at",
+	model.ChatbotSortUpdated: "updated_at",
+	model.ChatbotSortName:    "name",
+}
+
+// ListChatbots 分页查询聊天机器人
+func (s *SQLiteStorage) ListChatbots(ctx context.Context, q model.ChatbotQuery) (*model.ChatbotPage, error) {
+	where := ""
+	var args []interface{}
+	if q.Search != "" {
+		where = "WHERE instr(lower(name), ?) > 0"
+		args = append(args, strings.ToLower(q.Search))
+	}
+
+	page := &model.ChatbotPage{Chatbots: []*model.Chatbot{}, Offset: q.Offset, Limit: q.Limit}
+	countQuery := "SELECT COUNT(*) FROM chatbots " + where
+	if err := s.db.QueryRowContext(ctx, countQuery, args...).Scan(&page.Total); err != nil {
+		return nil, fmt.Errorf("count chatbots: %w", err)
+	}
+	if q.Limit <= 0 || q.Offset >= page.Total {
+		return page, nil
+	}
+
+	column, ok := chatbotSortColumns[q.Sort]
+	if !ok {
+		column = chatbotSortColumns[model.ChatbotSortCreated]
+	}
+	order := "ASC"
+	if q.Desc {
+		order = "DESC"
+	}
+
+	// 以ID作为第二排序字段，保证分页顺序稳定
+	query := fmt.Sprintf(`
+		SELECT `+chatbotColumns+`
+		FROM chatbots
+		%s
+		ORDER BY %s %s, id %s
+		LIMIT ? OFFSET ?
+	`, where, column, order, order)
+
+	rows, err := s.db.QueryContext(ctx, query, append(args, q.Limit, q.Offset)...)
+	if err != nil {
+		return nil, fmt.Errorf("list chatbots: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		chatbot, err := scanChatbot(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan chatbot: %w", err)
+		}
+		page.Chatbots = append(page.Chatbots, chatbot)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return page, nil
+}
+
+// DeleteChatbot 删除聊天机器人（对话记录和人设版本通过外键级联删除）
+func (s *SQLiteStorage) DeleteChatbot(ctx context.Context, id string) error {
+	query := `DELETE FROM chatbots WHERE id = ?`
+
+	result, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("delete chatbot: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return errs.ErrNotFound
+	}
+
+	return nil
+}
+
+// conversationColumns 对话记录查询列，与scanConversation保持一致
+const conversationColumns = "id, chatbot_id, parent_id, user_message, bot_message, persona_version, metadata, created_at"
+
+// scanConversation 扫描一行对话记录
+func scanConversation(row rowScanner) (*model.Conversation, error) {
+	var conv model.Conversation
+	var metadata sql.NullString
+	if err := row.Scan(
+		&conv.ID,
+		&conv.ChatbotID,
+		&conv.ParentID,
+		&conv.UserMessage,
+		&conv.BotMessage,
+		&conv.PersonaVersion,
+		&metadata,
+		&conv.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if metadata.String != "" {
+		if err := json.Unmarshal([]byte(metadata.String), &conv.Metadata); err != nil {
+			return nil, fmt.Errorf("unmarshal conversation metadata: %w", err)
+		}
+	}
+
+	return &conv, nil
+}
+
+// SaveConversation 保存对话记录
+func (s *SQLiteStorage) SaveConversation(ctx context.Context, conv *model.Conversation) error {
+	if conv.CreatedAt.IsZero() {
+		conv.CreatedAt = time.Now()
+	}
+
+	query := `
+		INSERT INTO conversations (chatbot_id, parent_id, user_message, bot_message, persona_version, metadata, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+
+	// 没有附加信息时存为NULL
+	var metadata sql.NullString
+	if conv.Metadata != nil {
+		data, err := json.Marshal(conv.Metadata)
+		if err != nil {
+			return fmt.Errorf("marshal conversation metadata: %w", err)
+		}
+		metadata = sql.NullString{String: string(data), Valid: true}
+	}
+
+	result, err := s.db.ExecContext(ctx, query,
+		conv.ChatbotID,
+		conv.ParentID,
+		conv.UserMessage,
+		conv.BotMessage,
+		conv.PersonaVersion,
+		metadata,
+		conv.CreatedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("save conversation: %w", err)
+	}
+
+	// 获取插入的ID
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("get last insert id: %w", err)
+	}
+
+	conv.ID = id
+	return nil
+}
+
+// DeleteConversations 删除对话记录，反馈由外键级联删除
+func (s *SQLiteStorage) DeleteConversations(ctx context.Context, chatbotID string, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	args := make([]interface{}, 0, len(ids)+1)
+	args = append(args, chatbotID)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM conversations WHERE chatbot_id = ? AND id IN (`+placeholders+`)`, args...); err != nil {
+		return fmt.Errorf("delete conversations: %w", err)
+	}
+	return nil
+}
+
+// GetConversationHistory 获取当前分支的对话历史
+func (s *SQLiteStorage) GetConversationHistory(ctx context.Context, chatbotID string, limit int) ([]*model.Conversation, error) {
+	if limit <= 0 {
+		return []*model.Conversation{}, nil
+	}
+
+	// 最新的记录即当前分支的末端
+	var leaf int64
+	err := s.db.QueryRowContext(ctx, `SELECT id FROM conversations WHERE chatbot_id = ? ORDER BY id DESC LIMIT 1`, chatbotID).Scan(&leaf)
+	if err == sql.ErrNoRows {
+		return []*model.Conversation{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get latest conversation: %w", err)
+	}
+
+	return s.GetConversationPath(ctx, leaf, limit)
+}
+
+// GetConversation 获取对话记录
+func (s *SQLiteStorage) GetConversation(ctx context.Context, id int64) (*model.Conversation, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+conversationColumns+` FROM conversations WHERE id = ?`, id)
+	conv, err := scanConversation(row)
+	if err == sql.ErrNoRows {
+		return nil, errs.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get conversation: %w", err)
+	}
+	return conv, nil
+}
+
+// conversationPathQuery 从指定记录沿parent_id递归向上，最多取depth参数条，最早的在前；中途缺失的父节点视为路径起点
+const conversationPathQuery = `
+	WITH RECURSIVE path AS (
+		SELECT ` + conversationColumns + `, 1 AS depth
+		FROM conversations
+		WHERE id = ?
+		UNION ALL
+		SELECT c.id, c.chatbot_id, c.parent_id, c.user_message, c.bot_message, c.persona_version, c.metadata, c.created_at, path.depth + 1
+		FROM conversations c
+		JOIN path ON c.id = path.parent_id
+		WHERE path.depth < ?
+	)
+	SELECT ` + conversationColumns + `
+	FROM path
+	ORDER BY depth DESC
+`
+
+// GetConversationPath 获取以id为终点的对话路径
+//
+// 用递归CTE一次查出整条路径，不再逐条查询父节点。
+func (s *SQLiteStorage) GetConversationPath(ctx context.Context, id int64, limit int) ([]*model.Conversation, error) {
+	conversations := make([]*model.Conversation, 0)
+	if limit <= 0 {
+		return conversations, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx, conversationPathQuery, id, limit)
+	if err != nil {
+		return nil, fmt.Errorf("get conversation path: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		conv, err := scanConversation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan conversation: %w", err)
+		}
+		conversations = append(conversations, conv)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	// 终点不存在时返回错误
+	if len(conversations) == 0 && id != 0 {
+		return nil, errs.ErrNotFound
+	}
+
+	return conversations, nil
+}
+
+// GetConversationBranches 获取与id同一父节点的全部对话记录
+func (s *SQLiteStorage) GetConversationBranches(ctx context.Context, id int64) ([]*model.Conversation, error) {
+	target, err := s.GetConversation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT ` + conversationColumns + `
+		FROM conversations
+		WHERE chatbot_id = ? AND parent_id = ?
+		ORDER BY id ASC
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, target.ChatbotID, target.ParentID)
+	if err != nil {
+		return nil, fmt.Errorf("get conversation branches: %w", err)
+	}
+	defer rows.Close()
+
+	conversations := make([]*model.Conversation, 0)
+	for rows.Next() {
+		conv, err := scanConversation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan conversation: %w", err)
+		}
+		conversations = append(conversations, conv)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return conversations, nil
+}
+
+// QueryConversationHistory 分页查询对话历史
+func (s *SQLiteStorage) QueryConversationHistory(ctx context.Context, q model.HistoryQuery) (*model.HistoryPage, error) {
+	if q.Limit <= 0 {
+		return model.NewHistoryPage(q, nil), nil
+	}
+
+	conds := []string{"chatbot_id = ?"}
+	args := []interface{}{q.ChatbotID}
+	if q.Before > 0 {
+		conds = append(conds, "id < ?")
+		args = append(args, q.Before)
+	}
+	if q.After > 0 {
+		conds = append(conds, "id > ?")
+		args = append(args, q.After)
+	}
+	if !q.From.IsZero() {
+		conds = append(conds, "created_at >= ?")
+		args = append(args, q.From)
+	}
+	if !q.To.IsZero() {
+		conds = append(conds, "created_at < ?")
+		args = append(args, q.To)
+	}
+	if q.Search != "" {
+		conds = append(conds, "(instr(lower(user_message), ?) > 0 OR instr(lower(bot_message), ?) > 0)")
+		keyword := strings.ToLower(q.Search)
+		args = append(args, keyword, keyword)
+	}
+
+	order := "DESC"
+	if q.Forward() {
+		order = "ASC"
+	}
+
+	// 多取一条用于判断是否还有下一页
+	query := fmt.Sprintf(`
+		SELECT `+conversationColumns+`
+		FROM conversations
+		WHERE %s
+		ORDER BY id %s
+		LIMIT ?
+	`, strings.Join(conds, " AND "), order)
+	args = append(args, q.Limit+1)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query conversation history: %w", err)
+	}
+	defer rows.Close()
+
+	conversations := make([]*model.Conversation, 0)
+	for rows.Next() {
+		conv, err := scanConversation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan conversation: %w", err)
+		}
+		conversations = append(conversations, conv)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return model.NewHistoryPage(q, conversations), nil
+}
+
+// personaVersionColumns 人设版本查询列，与scanPersonaVersion保持一致
+const personaVersionColumns = "chatbot_id, version, personality, background, prompt_template, prompt_format, system_prompt, options, comment, created_at"
+
+// scanPersonaVersion 扫描一行人设版本
+func scanPersonaVersion(row rowScanner) (*model.PersonaVersion, error) {
+	var v model.PersonaVersion
+	var promptTemplate, promptFormat, options, comment sql.NullString
+	if err := row.Scan(
+		&v.ChatbotID,
+		&v.Version,
+		&v.Personality,
+		&v.Background,
+		&promptTemplate,
+		&promptFormat,
+		&v.SystemPrompt,
+		&options,
+		&comment,
+		&v.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	v.PromptTemplate = promptTemplate.String
+	v.PromptFormat = promptFormat.String
+	v.Comment = comment.String
+	if options.String != "" {
+		v.Options = &model.GenerationOptions{}
+		if err := json.Unmarshal([]byte(options.String), v.Options); err != nil {
+			return nil, fmt.Errorf("unmarshal persona version options: %w", err)
+		}
+	}
+	return &v, nil
+}
+
+// SavePersonaVersion 保存人设版本，分配版本号并设为聊天机器人的当前版本
+func (s *SQLiteStorage) SavePersonaVersion(ctx context.Context, v *model.PersonaVersion) error {
+	if v.CreatedAt.IsZero() {
+		v.CreatedAt = time.Now()
+	}
+	// 生成参数为nil时写入NULL，表示未记录
+	var options sql.NullString
+	if v.Options != nil {
+		data, err := json.Marshal(v.Options)
+		if err != nil {
+			return fmt.Errorf("marshal persona version options: %w", err)
+		}
+		options = sql.NullString{String: string(data), Valid: true}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// SQLite只有一个连接，事务内读取最大版本号即可串行分配
+	var current int
+	err = tx.QueryRowContext(ctx, `SELECT persona_version FROM chatbots WHERE id = ?`, v.ChatbotID).Scan(&current)
+	if err == sql.ErrNoRows {
+		return errs.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("get chatbot: %w", err)
+	}
+
+	var latest int
+	if err := tx.QueryRowContext(ctx,
+		`SELECT COALESCE(MAX(version), 0) FROM persona_versions WHERE chatbot_id = ?`, v.ChatbotID,
+	).Scan(&latest); err != nil {
+		return fmt.Errorf("get latest persona version: %w", err)
+	}
+	v.Version = latest + 1
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO persona_versions (`+personaVersionColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		v.ChatbotID,
+		v.Version,
+		v.Personality,
+		v.Background,
+		v.PromptTemplate,
+		v.PromptFormat,
+		v.SystemPrompt,
+		options,
+		v.Comment,
+		v.CreatedAt,
+	); err != nil {
+		return fmt.Errorf("save persona version: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE chatbots SET persona_version = ? WHERE id = ?`, v.Version, v.ChatbotID,
+	); err != nil {
+		return fmt.Errorf("update chatbot persona version: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit persona version: %w", err)
+	}
+
+	return nil
+}
+
+// GetPersonaVersion 获取指定人设版本
+func (s *SQLiteStorage) GetPersonaVersion(ctx context.Context, chatbotID string, version int) (*model.PersonaVersion, error) {
+	query := `SELECT ` + personaVersionColumns + ` FROM persona_versions WHERE chatbot_id = ? AND version = ?`
+
+	v, err := scanPersonaVersion(s.db.QueryRowContext(ctx, query, chatbotID, version))
+	if err == sql.ErrNoRows {
+		return nil, errs.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get persona version: %w", err)
+	}
+
+	return v, nil
+}
+
+// ListPersonaVersions 获取聊天机器人的全部人设版本，最新版本在前
+func (s *SQLiteStorage) ListPersonaVersions(ctx context.Context, chatbotID string) ([]*model.PersonaVersion, error) {
+	query := `SELECT ` + personaVersionColumns + ` FROM persona_versions WHERE chatbot_id = ? ORDER BY version DESC`
+
+	rows, err := s.db.QueryContext(ctx, query, chatbotID)
+	if err != nil {
+		return nil, fmt.Errorf("list persona versions: %w", err)
+	}
+	defer rows.Close()
+
+	versions := make([]*model.PersonaVersion, 0)
+	for rows.Next() {
+		v, err := scanPersonaVersion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan persona version: %w", err)
+		}
+		versions = append(versions, v)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return versions, nil
+}
+
+// feedbackColumns 反馈查询列，与scanFeedback保持一致
+const feedbackColumns = "conversation_id, chatbot_id, rating, tags, comment, created_at, updated_at"
+
+// scanFeedback 扫描一行反馈数据
+func scanFeedback(row rowScanner) (*model.Feedback, error) {
+	var f model.Feedback
+	var tags, comment sql.NullString
+	if err := row.Scan(
+		&f.ConversationID,
+		&f.ChatbotID,
+		&f.Rating,
+		&tags,
+		&comment,
+		&f.CreatedAt,
+		&f.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	f.Comment = comment.String
+	if tags.String != "" {
+		if err := json.Unmarshal([]byte(tags.String), &f.Tags); err != nil {
+			return nil, fmt.Errorf("unmarshal feedback tags: %w", err)
+		}
+	}
+
+	return &f, nil
+}
+
+// SaveFeedback 保存反馈，同一对话已有反馈时覆盖（保留首次提交时间）
+func (s *SQLiteStorage) SaveFeedback(ctx context.Context, f *model.Feedback) error {
+	now := time.Now()
+	if f.CreatedAt.IsZero() {
+		f.CreatedAt = now
+	}
+	if f.UpdatedAt.IsZero() {
+		f.UpdatedAt = now
+	}
+
+	tags, err := json.Marshal(f.Tags)
+	if err != nil {
+		return fmt.Errorf("marshal feedback tags: %w", err)
+	}
+
+	query := `
+		INSERT INTO feedback (` + feedbackColumns + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(conversation_id) DO UPDATE SET
+			rating = excluded.rating,
+			tags = excluded.tags,
+			comment = excluded.comment,
+			updated_at = excluded.updated_at
+	`
+
+	_, err = s.db.ExecContext(ctx, query,
+		f.ConversationID,
+		f.ChatbotID,
+		f.Rating,
+		string(tags),
+		f.Comment,
+		f.CreatedAt,
+		f.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("save feedback: %w", err)
+	}
+
+	return nil
+}
+
+// GetFeedback 获取对话的反馈
+func (s *SQLiteStorage) GetFeedback(ctx context.Context, conversationID int64) (*model.Feedback, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+feedbackColumns+` FROM feedback WHERE conversation_id = ?`, conversationID)
+	f, err := scanFeedback(row)
+	if err == sql.ErrNoRows {
+		return nil, errs.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get feedback: %w", err)
+	}
+	return f, nil
+}
+
+// ListFeedback 查询聊天机器人的反馈，按对话ID升序
+func (s *SQLiteStorage) ListFeedback(ctx context.Context, q model.FeedbackQuery) ([]*model.Feedback, error) {
+	conds := []string{"chatbot_id = ?"}
+	args := []interface{}{q.ChatbotID}
+	if !q.From.IsZero() {
+		conds = append(conds, "created_at >= ?")
+		args = append(args, q.From)
+	}
+	if !q.To.IsZero() {
+		conds = append(conds, "created_at < ?")
+		args = append(args, q.To)
+	}
+
+	query := `SELECT ` + feedbackColumns + ` FROM feedback WHERE ` + strings.Join(conds, " AND ") + ` ORDER BY conversation_id ASC`
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list feedback: %w", err)
+	}
+	defer rows.Close()
+
+	feedback := make([]*model.Feedback, 0)
+	for rows.Next() {
+		f, err := scanFeedback(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan feedback: %w", err)
+		}
+		feedback = append(feedback, f)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return feedback, nil
+}
+
+// knowledgeBaseColumns 知识库查询列，与scanKnowledgeBase保持一致
+const knowledgeBaseColumns = "name, description, created_at"
+
+// scanKnowledgeBase 扫描一行知识库数据
+func scanKnowledgeBase(row rowScanner) (*model.KnowledgeBase, error) {
+	var kb model.KnowledgeBase
+	var description sql.NullString
+	if err := row.Scan(&kb.Name, &description, &kb.CreatedAt); err != nil {
+		return nil, err
+	}
+	kb.Description = description.String
+	return &kb, nil
+}
+
+// SaveKnowledgeBase 保存知识库，同名知识库已存在时覆盖描述（保留创建时间）
+func (s *SQLiteStorage) SaveKnowledgeBase(ctx context.Context, kb *model.KnowledgeBase) error {
+	if kb.CreatedAt.IsZero() {
+		kb.CreatedAt = time.Now()
+	}
+
+	query := `
+		INSERT INTO knowledge_bases (` + knowledgeBaseColumns + `)
+		VALUES (?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET description = excluded.description
+	`
+
+	if _, err := s.db.ExecContext(ctx, query, kb.Name, kb.Description, kb.CreatedAt); err != nil {
+		return fmt.Errorf("save knowledge base: %w", err)
+	}
+
+	return nil
+}
+
+// GetKnowledgeBase 获取知识库
+func (s *SQLiteStorage) GetKnowledgeBase(ctx context.Context, name string) (*model.KnowledgeBase, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+knowledgeBaseColumns+` FROM knowledge_bases WHERE name = ?`, name)
+	kb, err := scanKnowledgeBase(row)
+	if err == sql.ErrNoRows {
+		return nil, errs.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get knowledge base: %w", err)
+	}
+	return kb, nil
+}
+
+// ListKnowledgeBases 获取全部知识库，按名称升序
+func (s *SQLiteStorage) ListKnowledgeBases(ctx context.Context) ([]*model.KnowledgeBase, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+knowledgeBaseColumns+` FROM knowledge_bases ORDER BY name ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("list knowledge bases: %w", err)
+	}
+	defer rows.Close()
+
+	kbs := make([]*model.KnowledgeBase, 0)
+	for rows.Next() {
+		kb, err := scanKnowledgeBase(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan knowledge base: %w", err)
+		}
+		kbs = append(kbs, kb)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return kbs, nil
+}
+
+// knowledgeChunkColumns 知识分块查询列，与scanKnowledgeChunk保持一致
+const knowledgeChunkColumns = "id, knowledge_base, document_id, content, source, category, metadata, created_at, updated_at"
+
+// scanKnowledgeChunk 扫描一行知识分块数据，早期版本保存的分块没有修改时间，使用创建时间
+func scanKnowledgeChunk(row rowScanner) (*model.KnowledgeChunk, error) {
+	var chunk model.KnowledgeChunk
+	var source, category, metadata sql.NullString
+	var updatedAt sql.NullTime
+	if err := row.Scan(&chunk.ID, &chunk.KnowledgeBase, &chunk.DocumentID, &chunk.Content, &source, &category, &metadata, &chunk.CreatedAt, &updatedAt); err != nil {
+		return nil, err
+	}
+	chunk.UpdatedAt = chunk.CreatedAt
+	if updatedAt.Valid {
+		chunk.UpdatedAt = updatedAt.Time
+	}
+	chunk.Source = source.String
+	chunk.Category = category.String
+	if metadata.String != "" {
+		chunk.Metadata = json.RawMessage(metadata.String)
+	}
+	return &chunk, nil
+}
+
+// SaveKnowledgeChunks 在一个事务中批量保存知识分块，ID由数据库自增分配
+func (s *SQLiteStorage) SaveKnowledgeChunks(ctx context.Context, chunks []*model.KnowledgeChunk) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO knowledge_base (knowledge_base, document_id, content, source, category, metadata, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return fmt.Errorf("prepare knowledge chunk insert: %w", err)
+	}
+	defer stmt.Close()
+
+	now := time.Now()
+	for _, chunk := range chunks {
+		if chunk.CreatedAt.IsZero() {
+			chunk.CreatedAt = now
+		}
+		chunk.UpdatedAt = chunk.CreatedAt
+
+		result, err := stmt.ExecContext(ctx, chunk.KnowledgeBase, chunk.DocumentID, chunk.Content, chunk.Source, chunk.Category,
+			chunkMetadata(chunk), chunk.CreatedAt, chunk.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("save knowledge chunk: %w", err)
+		}
+		if chunk.ID, err = result.LastInsertId(); err != nil {
+			return fmt.Errorf("get knowledge chunk id: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit knowledge chunks: %w", err)
+	}
+	return nil
+}
+
+// ListKnowledgeChunks 按ID升序获取ID大于afterID的最多limit个分块
+func (s *SQLiteStorage) ListKnowledgeChunks(ctx context.Context, afterID int64, limit int) ([]*model.KnowledgeChunk, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+knowledgeChunkColumns+` FROM knowledge_base WHERE id > ? ORDER BY id ASC LIMIT ?`, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list knowledge chunks: %w", err)
+	}
+	defer rows.Close()
+
+	chunks := make([]*model.KnowledgeChunk, 0)
+	for rows.Next() {
+		chunk, err := scanKnowledgeChunk(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan knowledge chunk: %w", err)
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return chunks, nil
+}
+
+// CountKnowledgeChunks 获取知识分块总数
+func (s *SQLiteStorage) CountKnowledgeChunks(ctx context.Context) (int, error) {
+	var count int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM knowledge_base`).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count knowledge chunks: %w", err)
+	}
+	return count, nil
+}
+
+// chunkMetadata 分块元数据列的值，没有元数据时为NULL
+func chunkMetadata(chunk *model.KnowledgeChunk) sql.NullString {
+	if len(chunk.Metadata) == 0 {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: string(chunk.Metadata), Valid: true}
+}
+
+// QueryKnowledgeChunks 分页查询知识分块，按ID升序
+func (s *SQLiteStorage) QueryKnowledgeChunks(ctx context.Context, q model.KnowledgeQuery) (*model.KnowledgePage, error) {
+	var conditions []string
+	var args []interface{}
+	if q.KnowledgeBase != "" {
+		conditions = append(conditions, "knowledge_base = ?")
+		args = append(args, q.KnowledgeBase)
+	}
+	if q.DocumentID != "" {
+		conditions = append(conditions, "document_id = ?")
+		args = append(args, q.DocumentID)
+	}
+	if q.Source != "" {
+		conditions = append(conditions, "source = ?")
+		args = append(args, q.Source)
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	page := &model.KnowledgePage{Items: []*model.KnowledgeChunk{}, Offset: q.Offset, Limit: q.Limit}
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM knowledge_base "+where, args...).Scan(&page.Total); err != nil {
+		return nil, fmt.Errorf("count knowledge chunks: %w", err)
+	}
+	if q.Limit <= 0 || q.Offset >= page.Total {
+		return page, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+knowledgeChunkColumns+` FROM knowledge_base `+where+` ORDER BY id ASC LIMIT ? OFFSET ?`,
+		append(args, q.Limit, q.Offset)...)
+	if err != nil {
+		return nil, fmt.Errorf("query knowledge chunks: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		chunk, err := scanKnowledgeChunk(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan knowledge chunk: %w", err)
+		}
+		page.Items = append(page.Items, chunk)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return page, nil
+}
+
+// GetKnowledgeChunk 获取知识分块
+func (s *SQLiteStorage) GetKnowledgeChunk(ctx context.Context, id int64) (*model.KnowledgeChunk, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+knowledgeChunkColumns+` FROM knowledge_base WHERE id = ?`, id)
+	chunk, err := scanKnowledgeChunk(row)
+	if err == sql.ErrNoRows {
+		return nil, errs.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get knowledge chunk: %w", err)
+	}
+	return chunk, nil
+}
+
+// UpdateKnowledgeChunk 修改分块的内容、来源、分类和元数据
+func (s *SQLiteStorage) UpdateKnowledgeChunk(ctx context.Context, chunk *model.KnowledgeChunk) error {
+	chunk.UpdatedAt = time.Now()
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE knowledge_base SET content = ?, source = ?, category = ?, metadata = ?, updated_at = ?
+		WHERE id = ?
+	`, chunk.Content, chunk.Source, chunk.Category, chunkMetadata(chunk), chunk.UpdatedAt, chunk.ID)
+	if err != nil {
+		return fmt.Errorf("update knowledge chunk: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected: %w", err)
+	}
+	if n == 0 {
+		return errs.ErrNotFound
+	}
+	return nil
+}
+
+// DeleteKnowledgeChunks 删除知识分块，不存在的ID忽略
+func (s *SQLiteStorage) DeleteKnowledgeChunks(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM knowledge_base WHERE id IN (`+placeholders+`)`, args...); err != nil {
+		return fmt.Errorf("delete knowledge chunks: %w", err)
+	}
+	return nil
+}
+
+// reindexJobColumns 重建索引任务查询列，与scanReindexJob保持一致
+const reindexJobColumns = "id, status, collection, embedding_model, embedding_dim, total_chunks, embedded_chunks, last_chunk_id, error, created_at, updated_at, finished_at"
+
+// scanReindexJob 扫描一行重建索引任务数据
+func scanReindexJob(row rowScanner) (*model.ReindexJob, error) {
+	var job model.ReindexJob
+	var jobErr sql.NullString
+	var finishedAt sql.NullTime
+	if err := row.Scan(&job.ID, &job.Status, &job.Collection, &job.EmbeddingModel, &job.EmbeddingDim,
+		&job.TotalChunks, &job.EmbeddedChunks, &job.LastChunkID, &jobErr,
+		&job.CreatedAt, &job.UpdatedAt, &finishedAt); err != nil {
+		return nil, err
+	}
+	job.Error = jobErr.String
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	return &job, nil
+}
+
+// SaveReindexJob 保存重建索引任务，同ID任务已存在时覆盖
+func (s *SQLiteStorage) SaveReindexJob(ctx context.Context, job *model.ReindexJob) error {
+	query := `
+		INSERT INTO reindex_jobs (` + reindexJobColumns + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			status = excluded.status,
+			collection = excluded.collection,
+			embedding_model = excluded.embedding_model,
+			embedding_dim = excluded.embedding_dim,
+			total_chunks = excluded.total_chunks,
+			embedded_chunks = excluded.embedded_chunks,
+			last_chunk_id = excluded.last_chunk_id,
+			error = excluded.error,
+			updated_at = excluded.updated_at,
+			finished_at = excluded.finished_at
+	`
+
+	var finishedAt sql.NullTime
+	if job.FinishedAt != nil {
+		finishedAt = sql.NullTime{Time: *job.FinishedAt, Valid: true}
+	}
+	if _, err := s.db.ExecContext(ctx, query,
+		job.ID,
+		job.Status,
+		job.Collection,
+		job.EmbeddingModel,
+		job.EmbeddingDim,
+		job.TotalChunks,
+		job.EmbeddedChunks,
+		job.LastChunkID,
+		job.Error,
+		job.CreatedAt,
+		job.UpdatedAt,
+		finishedAt,
+	); err != nil {
+		return fmt.Errorf("save reindex job: %w", err)
+	}
+
+	return nil
+}
+
+// GetReindexJob 获取重建索引任务
+func (s *SQLiteStorage) GetReindexJob(ctx context.Context, id string) (*model.ReindexJob, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+reindexJobColumns+` FROM reindex_jobs WHERE id = ?`, id)
+	job, err := scanReindexJob(row)
+	if err == sql.ErrNoRows {
+		return nil, errs.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get reindex job: %w", err)
+	}
+	return job, nil
+}
+
+// ListReindexJobs 获取全部重建索引任务，最新创建的在前
+func (s *SQLiteStorage) ListReindexJobs(ctx context.Context) ([]*model.ReindexJob, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+reindexJobColumns+` FROM reindex_jobs ORDER BY created_at DESC, id ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("list reindex jobs: %w", err)
+	}
+	defer rows.Close()
+
+	jobs := make([]*model.ReindexJob, 0)
+	for rows.Next() {
+		job, err := scanReindexJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan reindex job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return jobs, nil
+}
+
+// Close 关闭数据库连接
+func (s *SQLiteStorage) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,50 @@
+package sqlite_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"eino/internal/config"
+	"eino/internal/storage"
+	"eino/internal/storage/storagetest"
+)
+
+func TestSQLiteStorageContract(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		s, err := storage.NewStorage(config.StorageConfig{
+			Type:        "sqlite",
+			AutoMigrate: true,
+			SQLite:      config.SQLiteConfig{Path: filepath.Join(t.TempDir(), "eino.db")},
+		})
+		if err != nil {
+			t.Fatalf("NewStorage: %v", err)
+		}
+		return s
+	})
+}
+
+func TestSQLiteStorageRequiresMigrations(t *testing.T) {
+	cfg := config.StorageConfig{
+		Type:   "sqlite",
+		SQLite: config.SQLiteConfig{Path: filepath.Join(t.TempDir(), "eino.db")},
+	}
+
+	if _, err := storage.NewStorage(cfg); err == nil || !strings.Contains(err.Error(), "server migrate up") {
+		t.Fatalf("NewStorage on unmigrated database: err = %v, want pending migrations error", err)
+	}
+
+	cfg.AutoMigrate = true
+	s, err := storage.NewStorage(cfg)
+	if err != nil {
+		t.Fatalf("NewStorage with auto_migrate: %v", err)
+	}
+	s.Close()
+
+	cfg.AutoMigrate = false
+	s, err = storage.NewStorage(cfg)
+	if err != nil {
+		t.Fatalf("NewStorage on migrated database: %v", err)
+	}
+	s.Close()
+}
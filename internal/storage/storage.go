@@ -2,25 +2,81 @@ package storage
 
 import (
 	"context"
+	"database/sql"
 	"eino/internal/config"
 	"eino/internal/model"
 	"eino/internal/storage/memory"
+	"eino/internal/storage/migrate"
 	"eino/internal/storage/mysql"
 	"eino/internal/storage/redis"
+	"eino/internal/storage/sqlite"
 	"fmt"
+	"log"
 )
 
 // Storage 存储接口
 type Storage interface {
 	// Chatbot相关
+	// SaveChatbot 按调用方给定的UpdatedAt保存（由服务层维护），为零值时取当前时间并回写
 	SaveChatbot(ctx context.Context, chatbot *model.Chatbot) error
 	GetChatbot(ctx context.Context, id string) (*model.Chatbot, error)
+	GetChatbotBySlug(ctx context.Context, slug string) (*model.Chatbot, error)
 	GetChatbots(ctx context.Context) ([]*model.Chatbot, error)
+	ListChatbots(ctx context.Context, query model.ChatbotQuery) (*model.ChatbotPage, error)
 	DeleteChatbot(ctx context.Context, id string) error
 
+	// 人设版本相关
+	SavePersonaVersion(ctx context.Context, v *model.PersonaVersion) error
+	GetPersonaVersion(ctx context.Context, chatbotID string, version int) (*model.PersonaVersion, error)
+	ListPersonaVersions(ctx context.Context, chatbotID string) ([]*model.PersonaVersion, error)
+
 	// Conversation相关
 	SaveConversation(ctx context.Context, conv *model.Conversation) error
+	// GetConversationHistory 获取当前分支（最新一条记录所在路径）的最近limit轮对话，最早的在前
 	GetConversationHistory(ctx context.Context, chatbotID string, limit int) ([]*model.Conversation, error)
+	GetConversation(ctx context.Context, id int64) (*model.Conversation, error)
+	// GetConversationPath 获取以id为终点的路径上最近limit轮对话，最早的在前
+	GetConversationPath(ctx context.Context, id int64, limit int) ([]*model.Conversation, error)
+	// GetConversationBranches 获取与id同一父节点的全部记录（含自身），按ID升序
+	GetConversationBranches(ctx context.Context, id int64) ([]*model.Conversation, error)
+	QueryConversationHistory(ctx context.Context, query model.HistoryQuery) (*model.HistoryPage, error)
+	// DeleteConversations 删除聊天机器人的对话记录及其反馈，不存在或不属于该聊天机器人的ID忽略
+	DeleteConversations(ctx context.Context, chatbotID string, ids []int64) error
+
+	// 反馈相关
+	// SaveFeedback 保存反馈，同一对话已有反馈时覆盖
+	SaveFeedback(ctx context.Context, feedback *model.Feedback) error
+	GetFeedback(ctx context.Context, conversationID int64) (*model.Feedback, error)
+	// ListFeedback 查询聊天机器人的反馈，按对话ID升序
+	ListFeedback(ctx context.Context, query model.FeedbackQuery) ([]*model.Feedback, error)
+
+	// 知识库相关
+	// SaveKnowledgeBase 保存知识库，同名知识库已存在时覆盖描述
+	SaveKnowledgeBase(ctx context.Context, kb *model.KnowledgeBase) error
+	GetKnowledgeBase(ctx context.Context, name string) (*model.KnowledgeBase, error)
+	// ListKnowledgeBases 获取全部知识库，按名称升序
+	ListKnowledgeBases(ctx context.Context) ([]*model.KnowledgeBase, error)
+
+	// 知识分块相关
+	// SaveKnowledgeChunks 批量保存知识分块，写入后设置ID和创建时间
+	SaveKnowledgeChunks(ctx context.Context, chunks []*model.KnowledgeChunk) error
+	// ListKnowledgeChunks 按ID升序获取ID大于afterID的最多limit个分块
+	ListKnowledgeChunks(ctx context.Context, afterID int64, limit int) ([]*model.KnowledgeChunk, error)
+	CountKnowledgeChunks(ctx context.Context) (int, error)
+	// QueryKnowledgeChunks 分页查询知识分块，按ID升序
+	QueryKnowledgeChunks(ctx context.Context, query model.KnowledgeQuery) (*model.KnowledgePage, error)
+	GetKnowledgeChunk(ctx context.Context, id int64) (*model.KnowledgeChunk, error)
+	// UpdateKnowledgeChunk 修改分块的内容、来源、分类和元数据，并设置修改时间
+	UpdateKnowledgeChunk(ctx context.Context, chunk *model.KnowledgeChunk) error
+	// DeleteKnowledgeChunks 删除知识分块，不存在的ID忽略
+	DeleteKnowledgeChunks(ctx context.Context, ids []int64) error
+
+	// 重建索引任务相关
+	// SaveReindexJob 保存重建索引任务，同ID任务已存在时覆盖
+	SaveReindexJob(ctx context.Context, job *model.ReindexJob) error
+	GetReindexJob(ctx context.Context, id string) (*model.ReindexJob, error)
+	// ListReindexJobs 获取全部重建索引任务，最新创建的在前
+	ListReindexJobs(ctx context.Context) ([]*model.ReindexJob, error)
 
 	// 关闭连接
 	Close() error
@@ -28,22 +84,94 @@ type Storage interface {
 
 // NewStorage 创建存储实例
 func NewStorage(cfg config.StorageConfig) (Storage, error) {
+	if cfg.AutoMigrate {
+		if err := autoMigrate(cfg); err != nil {
+			return nil, err
+		}
+	}
+
 	switch cfg.Type {
 	case "memory":
 		return memory.NewMemoryStorage(), nil
 	case "mysql":
-		dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-			cfg.MySQL.User,
-			cfg.MySQL.Password,
-			cfg.MySQL.Host,
-			cfg.MySQL.Port,
-			cfg.MySQL.Database,
-		)
-		return mysql.NewMySQLStorage(dsn)
+		return mysql.NewMySQLStorage(mysqlDSN(cfg.MySQL))
 	case "redis":
 		addr := fmt.Sprintf("%s:%d", cfg.Redis.Host, cfg.Redis.Port)
 		return redis.NewRedisStorage(addr, cfg.Redis.Password, cfg.Redis.DB)
+	case "sqlite":
+		return sqlite.NewSQLiteStorage(cfg.SQLite.Path)
 	default:
 		return memory.NewMemoryStorage(), nil
 	}
 }
+
+// Migrator 绑定数据库连接的迁移执行器
+type Migrator struct {
+	*migrate.Migrator
+	db *sql.DB
+}
+
+// Close 关闭迁移使用的数据库连接
+func (m *Migrator) Close() error {
+	return m.db.Close()
+}
+
+// NewMigrator 为SQL类存储（mysql, sqlite）创建迁移执行器
+func NewMigrator(cfg config.StorageConfig) (*Migrator, error) {
+	var db *sql.DB
+	var err error
+
+	switch cfg.Type {
+	case "mysql":
+		db, err = mysql.Open(mysqlDSN(cfg.MySQL))
+	case "sqlite":
+		db, err = sqlite.Open(cfg.SQLite.Path)
+	default:
+		return nil, fmt.Errorf("storage type %q does not support migrations", cfg.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := migrate.New(db, cfg.Type)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Migrator{Migrator: m, db: db}, nil
+}
+
+// autoMigrate 启动时执行未完成的迁移，非SQL类存储直接跳过
+func autoMigrate(cfg config.StorageConfig) error {
+	if cfg.Type != "mysql" && cfg.Type != "sqlite" {
+		return nil
+	}
+
+	m, err := NewMigrator(cfg)
+	if err != nil {
+		return fmt.Errorf("create migrator: %w", err)
+	}
+	defer m.Close()
+
+	applied, err := m.Up(context.Background())
+	if err != nil {
+		return fmt.Errorf("auto migrate: %w", err)
+	}
+	for _, mig := range applied {
+		log.Printf("Applied migration %d_%s", mig.Version, mig.Name)
+	}
+
+	return nil
+}
+
+// mysqlDSN 构建MySQL连接串
+func mysqlDSN(cfg config.MySQLConfig) string {
+	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+		cfg.User,
+		cfg.Password,
+		cfg.Host,
+		cfg.Port,
+		cfg.Database,
+	)
+}
@@ -0,0 +1,1216 @@
+// Package storagetest 提供 storage.Storage 的通用契约测试
+//
+// 任何存储实现都应通过该测试套件，以保证各后端在ID分配、历史记录顺序、
+// 未找到错误和级联删除等方面行为一致。使用方式：
+//
+//	func TestContract(t *testing.T) {
+//		storagetest.Run(t, func(t *testing.T) storage.Storage {
+//			return memory.NewMemoryStorage()
+//		})
+//	}
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"eino/internal/model"
+	"eino/internal/storage"
+)
+
+// Factory 创建一个空的存储实例，套件会在子测试结束时关闭它
+type Factory func(t *testing.T) storage.Storage
+
+// Run 运行全部契约测试
+func Run(t *testing.T, newStorage Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s storage.Storage)
+	}{
+		{"ChatbotCRUD", testChatbotCRUD},
+		{"ChatbotUpdatedAt", testChatbotUpdatedAt},
+		{"ChatbotNotFound", testChatbotNotFound},
+		{"ChatbotReturnsCopy", testChatbotReturnsCopy},
+		{"ChatbotBySlug", testChatbotBySlug},
+		{"ListChatbots", testListChatbots},
+		{"ConversationIDs", testConversationIDs},
+		{"ConversationMetadata", testConversationMetadata},
+		{"HistoryOrdering", testHistoryOrdering},
+		{"HistoryLimit", testHistoryLimit},
+		{"HistoryIsolation", testHistoryIsolation},
+		{"ConversationBranches", testConversationBranches},
+		{"DeleteConversations", testDeleteConversations},
+		{"HistoryPagination", testHistoryPagination},
+		{"HistorySearch", testHistorySearch},
+		{"HistoryDateRange", testHistoryDateRange},
+		{"PersonaVersions", testPersonaVersions},
+		{"Feedback", testFeedback},
+		{"KnowledgeBases", testKnowledgeBases},
+		{"KnowledgeChunks", testKnowledgeChunks},
+		{"ReindexJobs", testReindexJobs},
+		{"CascadeDelete", testCascadeDelete},
+		{"ConcurrentWrites", testConcurrentWrites},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStorage(t)
+			t.Cleanup(func() { s.Close() })
+			tt.fn(t, s)
+		})
+	}
+}
+
+// newChatbot 构造测试用聊天机器人
+func newChatbot(id string) *model.Chatbot {
+	now := time.Now().Truncate(time.Second)
+	return &model.Chatbot{
+		ID:           id,
+		Name:         "bot-" + id,
+		Personality:  "personality-" + id,
+		Background:   "background-" + id,
+		SystemPrompt: "prompt-" + id,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+}
+
+// mustSaveChatbot 保存聊天机器人，失败时终止测试
+func mustSaveChatbot(t *testing.T, s storage.Storage, id string) *model.Chatbot {
+	t.Helper()
+	chatbot := newChatbot(id)
+	if err := s.SaveChatbot(context.Background(), chatbot); err != nil {
+		t.Fatalf("SaveChatbot(%s): %v", id, err)
+	}
+	return chatbot
+}
+
+// mustSaveConversation 在当前分支末尾保存一轮对话，失败时终止测试
+func mustSaveConversation(t *testing.T, s storage.Storage, chatbotID string, n int) *model.Conversation {
+	t.Helper()
+	conv := &model.Conversation{
+		ChatbotID:   chatbotID,
+		UserMessage: fmt.Sprintf("user-%d", n),
+		BotMessage:  fmt.Sprintf("bot-%d", n),
+	}
+	if history := mustHistory(t, s, chatbotID, 1); len(history) > 0 {
+		conv.ParentID = history[0].ID
+	}
+	if err := s.SaveConversation(context.Background(), conv); err != nil {
+		t.Fatalf("SaveConversation(%s, %d): %v", chatbotID, n, err)
+	}
+	return conv
+}
+
+// mustHistory 获取对话历史，失败时终止测试
+func mustHistory(t *testing.T, s storage.Storage, chatbotID string, limit int) []*model.Conversation {
+	t.Helper()
+	history, err := s.GetConversationHistory(context.Background(), chatbotID, limit)
+	if err != nil {
+		t.Fatalf("GetConversationHistory(%s, %d): %v", chatbotID, limit, err)
+	}
+	return history
+}
+
+func testChatbotCRUD(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	want := mustSaveChatbot(t, s, "crud")
+
+	got, err := s.GetChatbot(ctx, want.ID)
+	if err != nil {
+		t.Fatalf("GetChatbot: %v", err)
+	}
+	if got.ID != want.ID || got.Name != want.Name || got.Personality != want.Personality ||
+		got.Background != want.Background || got.SystemPrompt != want.SystemPrompt {
+		t.Fatalf("GetChatbot = %+v, want %+v", got, want)
+	}
+
+	// 再次保存同一ID视为更新
+	want.Name = "renamed"
+	if err := s.SaveChatbot(ctx, want); err != nil {
+		t.Fatalf("SaveChatbot(update): %v", err)
+	}
+	got, err = s.GetChatbot(ctx, want.ID)
+	if err != nil {
+		t.Fatalf("GetChatbot after update: %v", err)
+	}
+	if got.Name != "renamed" {
+		t.Fatalf("Name after update = %q, want %q", got.Name, "renamed")
+	}
+
+	mustSaveChatbot(t, s, "crud-2")
+	list, err := s.GetChatbots(ctx)
+	if err != nil {
+		t.Fatalf("GetChatbots: %v", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("GetChatbots returned %d chatbots, want 2", len(list))
+	}
+
+	if err := s.DeleteChatbot(ctx, want.ID); err != nil {
+		t.Fatalf("DeleteChatbot: %v", err)
+	}
+	if _, err := s.GetChatbot(ctx, want.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("GetChatbot after delete: err = %v, want ErrNotFound", err)
+	}
+	list, err = s.GetChatbots(ctx)
+	if err != nil {
+		t.Fatalf("GetChatbots after delete: %v", err)
+	}
+	if len(list) != 1 || list[0].ID != "crud-2" {
+		t.Fatalf("GetChatbots after delete = %v, want [crud-2]", list)
+	}
+}
+
+// testChatbotUpdatedAt 各后端都按调用方给定的UpdatedAt保存，零值时取当前时间
+func testChatbotUpdatedAt(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	chatbot := newChatbot("updated")
+	given := chatbot.CreatedAt.Add(-time.Hour)
+	chatbot.UpdatedAt = given
+	if err := s.SaveChatbot(ctx, chatbot); err != nil {
+		t.Fatalf("SaveChatbot: %v", err)
+	}
+	got, err := s.GetChatbot(ctx, chatbot.ID)
+	if err != nil {
+		t.Fatalf("GetChatbot: %v", err)
+	}
+	if !got.UpdatedAt.Equal(given) {
+		t.Fatalf("UpdatedAt = %v, want %v", got.UpdatedAt, given)
+	}
+
+	before := time.Now().Truncate(time.Second)
+	chatbot.UpdatedAt = time.Time{}
+	if err := s.SaveChatbot(ctx, chatbot); err != nil {
+		t.Fatalf("SaveChatbot(zero UpdatedAt): %v", err)
+	}
+	if chatbot.UpdatedAt.Before(before) {
+		t.Fatalf("UpdatedAt written back = %v, want >= %v", chatbot.UpdatedAt, before)
+	}
+	got, err = s.GetChatbot(ctx, chatbot.ID)
+	if err != nil {
+		t.Fatalf("GetChatbot after zero UpdatedAt: %v", err)
+	}
+	if got.UpdatedAt.Before(before) {
+		t.Fatalf("UpdatedAt = %v, want >= %v", got.UpdatedAt, before)
+	}
+}
+
+func testChatbotNotFound(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	if _, err := s.GetChatbot(ctx, "missing"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("GetChatbot(missing): err = %v, want ErrNotFound", err)
+	}
+	if err := s.DeleteChatbot(ctx, "missing"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("DeleteChatbot(missing): err = %v, want ErrNotFound", err)
+	}
+
+	history := mustHistory(t, s, "missing", 10)
+	if len(history) != 0 {
+		t.Fatalf("history of missing chatbot has %d entries, want 0", len(history))
+	}
+}
+
+func testChatbotReturnsCopy(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	saved := mustSaveChatbot(t, s, "copy")
+
+	// 修改调用方持有的对象不应影响存储内容
+	saved.Name = "mutated-after-save"
+	got, err := s.GetChatbot(ctx, "copy")
+	if err != nil {
+		t.Fatalf("GetChatbot: %v", err)
+	}
+	if got.Name != "bot-copy" {
+		t.Fatalf("Name = %q after mutating saved object, want %q", got.Name, "bot-copy")
+	}
+
+	got.Name = "mutated-after-get"
+	again, err := s.GetChatbot(ctx, "copy")
+	if err != nil {
+		t.Fatalf("GetChatbot: %v", err)
+	}
+	if again.Name != "bot-copy" {
+		t.Fatalf("Name = %q after mutating returned object, want %q", again.Name, "bot-copy")
+	}
+}
+
+func testChatbotBySlug(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	temperature := float32(0.3)
+
+	chatbot := newChatbot("slugged")
+	chatbot.Slug = "pirate"
+	chatbot.PromptTemplate = "You are {name}"
+	chatbot.PromptFormat = model.PromptFormatFString
+	chatbot.Options = model.GenerationOptions{Temperature: &temperature}
+	if err := s.SaveChatbot(ctx, chatbot); err != nil {
+		t.Fatalf("SaveChatbot: %v", err)
+	}
+	mustSaveChatbot(t, s, "plain")
+
+	got, err := s.GetChatbotBySlug(ctx, "pirate")
+	if err != nil {
+		t.Fatalf("GetChatbotBySlug: %v", err)
+	}
+	if got.ID != "slugged" || got.PromptTemplate != chatbot.PromptTemplate || got.PromptFormat != chatbot.PromptFormat {
+		t.Fatalf("GetChatbotBySlug = %+v, want %+v", got, chatbot)
+	}
+	if got.Options.Temperature == nil || *got.Options.Temperature != temperature || got.Options.MaxTokens != nil {
+		t.Fatalf("Options = %+v, want temperature %v only", got.Options, temperature)
+	}
+
+	if _, err := s.GetChatbotBySlug(ctx, "missing"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("GetChatbotBySlug(missing): err = %v, want ErrNotFound", err)
+	}
+
+	if err := s.DeleteChatbot(ctx, "slugged"); err != nil {
+		t.Fatalf("DeleteChatbot: %v", err)
+	}
+	if _, err := s.GetChatbotBySlug(ctx, "pirate"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("GetChatbotBySlug after delete: err = %v, want ErrNotFound", err)
+	}
+}
+
+// chatbotIDs 返回分页结果中的聊天机器人ID
+func chatbotIDs(page *model.ChatbotPage) []string {
+	ids := make([]string, len(page.Chatbots))
+	for i, chatbot := range page.Chatbots {
+		ids[i] = chatbot.ID
+	}
+	return ids
+}
+
+func testListChatbots(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	base := time.Now().Truncate(time.Second).Add(-time.Hour)
+
+	// created顺序：c1..c5；updated顺序与之相反；c4与c5创建时间相同
+	bots := []struct {
+		id, name string
+		created  time.Duration
+		updated  time.Duration
+	}{
+		{"c1", "delta", 1 * time.Second, 50 * time.Second},
+		{"c2", "alpha", 2 * time.Second, 40 * time.Second},
+		{"c3", "echo", 3 * time.Second, 30 * time.Second},
+		{"c4", "bravo", 4 * time.Second, 20 * time.Second},
+		{"c5", "charlie bot", 4 * time.Second, 10 * time.Second},
+	}
+	for _, b := range bots {
+		chatbot := newChatbot(b.id)
+		chatbot.Name = b.name
+		chatbot.CreatedAt = base.Add(b.created)
+		chatbot.UpdatedAt = base.Add(b.updated)
+		if err := s.SaveChatbot(ctx, chatbot); err != nil {
+			t.Fatalf("SaveChatbot(%s): %v", b.id, err)
+		}
+	}
+
+	list := func(q model.ChatbotQuery) *model.ChatbotPage {
+		t.Helper()
+		page, err := s.ListChatbots(ctx, q)
+		if err != nil {
+			t.Fatalf("ListChatbots(%+v): %v", q, err)
+		}
+		return page
+	}
+	assertChatbots := func(page *model.ChatbotPage, total int, want ...string) {
+		t.Helper()
+		if page.Total != total {
+			t.Fatalf("Total = %d, want %d", page.Total, total)
+		}
+		if got := chatbotIDs(page); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("chatbots = %v, want %v", got, want)
+		}
+	}
+
+	// 创建时间相同时按ID排序，保证顺序稳定
+	assertChatbots(list(model.ChatbotQuery{Limit: 10, Sort: model.ChatbotSortCreated, Desc: true}), 5, "c5", "c4", "c3", "c2", "c1")
+	assertChatbots(list(model.ChatbotQuery{Limit: 10, Sort: model.ChatbotSortCreated}), 5, "c1", "c2", "c3", "c4", "c5")
+	assertChatbots(list(model.ChatbotQuery{Limit: 10, Sort: model.ChatbotSortUpdated, Desc: true}), 5, "c1", "c2", "c3", "c4", "c5")
+	assertChatbots(list(model.ChatbotQuery{Limit: 10, Sort: model.ChatbotSortName}), 5, "c2", "c4", "c5", "c1", "c3")
+
+	// 分页
+	assertChatbots(list(model.ChatbotQuery{Offset: 1, Limit: 2, Sort: model.ChatbotSortName}), 5, "c4", "c5")
+	assertChatbots(list(model.ChatbotQuery{Offset: 4, Limit: 2, Sort: model.ChatbotSortName}), 5, "c3")
+	assertChatbots(list(model.ChatbotQuery{Offset: 10, Limit: 2, Sort: model.ChatbotSortName}), 5)
+
+	// 名称搜索忽略大小写，总数为匹配数
+	assertChatbots(list(model.ChatbotQuery{Limit: 10, Search: "A", Sort: model.ChatbotSortName}), 4, "c2", "c4", "c5", "c1")
+	assertChatbots(list(model.ChatbotQuery{Limit: 1, Search: "bot", Sort: model.ChatbotSortName}), 1, "c5")
+	assertChatbots(list(model.ChatbotQuery{Limit: 10, Search: "zulu"}), 0)
+
+	// GetChatbots按创建时间倒序
+	all, err := s.GetChatbots(ctx)
+	if err != nil {
+		t.Fatalf("GetChatbots: %v", err)
+	}
+	got := make([]string, len(all))
+	for i, chatbot := range all {
+		got[i] = chatbot.ID
+	}
+	if want := []string{"c5", "c4", "c3", "c2", "c1"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("GetChatbots = %v, want %v", got, want)
+	}
+}
+
+func testConversationIDs(t *testing.T, s storage.Storage) {
+	mustSaveChatbot(t, s, "ids")
+
+	var lastID int64
+	for i := 0; i < 3; i++ {
+		conv := mustSaveConversation(t, s, "ids", i)
+		if conv.ID <= lastID {
+			t.Fatalf("conversation %d got ID %d, want > %d", i, conv.ID, lastID)
+		}
+		if conv.CreatedAt.IsZero() {
+			t.Fatalf("conversation %d has zero CreatedAt", i)
+		}
+		lastID = conv.ID
+	}
+}
+
+func testConversationMetadata(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	mustSaveChatbot(t, s, "meta")
+
+	plain := mustSaveConversation(t, s, "meta", 0)
+	guarded := &model.Conversation{
+		ChatbotID:   "meta",
+		ParentID:    plain.ID,
+		UserMessage: "u",
+		BotMessage:  "b",
+		Metadata: &model.ConversationMetadata{Guard: &model.GuardVerdict{
+			Passed:     false,
+			Mode:       model.GuardModeRules,
+			Attempts:   2,
+			Violations: []string{"disclaimer"},
+		}},
+	}
+	if err := s.SaveConversation(ctx, guarded); err != nil {
+		t.Fatalf("SaveConversation: %v", err)
+	}
+
+	history := mustHistory(t, s, "meta", 10)
+	if len(history) != 2 || history[0].ID != plain.ID {
+		t.Fatalf("history = %d entries, want 2", len(history))
+	}
+	if history[0].Metadata != nil {
+		t.Fatalf("plain conversation metadata = %+v, want nil", history[0].Metadata)
+	}
+	got := history[1].Metadata
+	if got == nil || got.Guard == nil || got.Guard.Attempts != 2 || len(got.Guard.Violations) != 1 {
+		t.Fatalf("guarded conversation metadata = %+v", got)
+	}
+}
+
+func testHistoryOrdering(t *testing.T, s storage.Storage) {
+	mustSaveChatbot(t, s, "order")
+
+	var saved []*model.Conversation
+	for i := 0; i < 5; i++ {
+		saved = append(saved, mustSaveConversation(t, s, "order", i))
+	}
+
+	// 历史记录按写入顺序返回，最早的在前
+	history := mustHistory(t, s, "order", 10)
+	if len(history) != len(saved) {
+		t.Fatalf("history has %d entries, want %d", len(history), len(saved))
+	}
+	for i, conv := range history {
+		if conv.ID != saved[i].ID || conv.UserMessage != saved[i].UserMessage || conv.BotMessage != saved[i].BotMessage {
+			t.Fatalf("history[%d] = %+v, want %+v", i, conv, saved[i])
+		}
+		if conv.ChatbotID != "order" {
+			t.Fatalf("history[%d].ChatbotID = %q, want %q", i, conv.ChatbotID, "order")
+		}
+	}
+}
+
+func testHistoryLimit(t *testing.T, s storage.Storage) {
+	mustSaveChatbot(t, s, "limit")
+
+	var saved []*model.Conversation
+	for i := 0; i < 5; i++ {
+		saved = append(saved, mustSaveConversation(t, s, "limit", i))
+	}
+
+	// 只返回最近的limit条，仍按时间正序
+	history := mustHistory(t, s, "limit", 3)
+	if len(history) != 3 {
+		t.Fatalf("history has %d entries, want 3", len(history))
+	}
+	for i, conv := range history {
+		if want := saved[i+2]; conv.ID != want.ID {
+			t.Fatalf("history[%d].ID = %d, want %d", i, conv.ID, want.ID)
+		}
+	}
+
+	if history := mustHistory(t, s, "limit", 5); len(history) != 5 {
+		t.Fatalf("history with exact limit has %d entries, want 5", len(history))
+	}
+	if history := mustHistory(t, s, "limit", 0); len(history) != 0 {
+		t.Fatalf("history with zero limit has %d entries, want 0", len(history))
+	}
+}
+
+func testHistoryIsolation(t *testing.T, s storage.Storage) {
+	mustSaveChatbot(t, s, "a")
+	mustSaveChatbot(t, s, "b")
+
+	mustSaveConversation(t, s, "a", 0)
+	mustSaveConversation(t, s, "b", 0)
+	mustSaveConversation(t, s, "a", 1)
+
+	if history := mustHistory(t, s, "a", 10); len(history) != 2 {
+		t.Fatalf("history of a has %d entries, want 2", len(history))
+	}
+	history := mustHistory(t, s, "b", 10)
+	if len(history) != 1 || history[0].ChatbotID != "b" {
+		t.Fatalf("history of b = %v, want one entry of b", history)
+	}
+}
+
+func testConversationBranches(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	mustSaveChatbot(t, s, "tree")
+
+	first := mustSaveConversation(t, s, "tree", 0)
+	second := mustSaveConversation(t, s, "tree", 1)
+	third := mustSaveConversation(t, s, "tree", 2)
+
+	// 在first之后编辑消息，新分支成为当前分支
+	edited := &model.Conversation{ChatbotID: "tree", ParentID: first.ID, UserMessage: "edited", BotMessage: "reply"}
+	if err := s.SaveConversation(ctx, edited); err != nil {
+		t.Fatalf("SaveConversation: %v", err)
+	}
+	history := mustHistory(t, s, "tree", 10)
+	assertIDs(t, historyIDs(history), first.ID, edited.ID)
+	if history[1].ParentID != first.ID {
+		t.Fatalf("edited ParentID = %d, want %d", history[1].ParentID, first.ID)
+	}
+
+	// 原分支仍可按路径读取
+	path, err := s.GetConversationPath(ctx, third.ID, 10)
+	if err != nil {
+		t.Fatalf("GetConversationPath: %v", err)
+	}
+	assertIDs(t, historyIDs(path), first.ID, second.ID, third.ID)
+	path, err = s.GetConversationPath(ctx, third.ID, 2)
+	if err != nil {
+		t.Fatalf("GetConversationPath with limit: %v", err)
+	}
+	assertIDs(t, historyIDs(path), second.ID, third.ID)
+
+	branches, err := s.GetConversationBranches(ctx, edited.ID)
+	if err != nil {
+		t.Fatalf("GetConversationBranches: %v", err)
+	}
+	assertIDs(t, historyIDs(branches), second.ID, edited.ID)
+	roots, err := s.GetConversationBranches(ctx, first.ID)
+	if err != nil {
+		t.Fatalf("GetConversationBranches of root: %v", err)
+	}
+	assertIDs(t, historyIDs(roots), first.ID)
+
+	got, err := s.GetConversation(ctx, second.ID)
+	if err != nil {
+		t.Fatalf("GetConversation: %v", err)
+	}
+	if got.ChatbotID != "tree" || got.ParentID != first.ID || got.UserMessage != second.UserMessage {
+		t.Fatalf("GetConversation = %+v, want %+v", got, second)
+	}
+	if _, err := s.GetConversation(ctx, third.ID+100); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("GetConversation(missing) error = %v, want ErrNotFound", err)
+	}
+	if _, err := s.GetConversationPath(ctx, third.ID+100, 10); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("GetConversationPath(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func testDeleteConversations(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	mustSaveChatbot(t, s, "trim")
+	mustSaveChatbot(t, s, "other")
+
+	first := mustSaveConversation(t, s, "trim", 0)
+	second := mustSaveConversation(t, s, "trim", 1)
+	third := mustSaveConversation(t, s, "trim", 2)
+	other := mustSaveConversation(t, s, "other", 0)
+	if err := s.SaveFeedback(ctx, &model.Feedback{ConversationID: third.ID, ChatbotID: "trim", Rating: model.RatingUp}); err != nil {
+		t.Fatalf("SaveFeedback: %v", err)
+	}
+
+	// 中间的记录被删除后，路径在缺失的父节点处截止
+	if err := s.DeleteConversations(ctx, "trim", []int64{second.ID}); err != nil {
+		t.Fatalf("DeleteConversations(second): %v", err)
+	}
+	path, err := s.GetConversationPath(ctx, third.ID, 10)
+	if err != nil {
+		t.Fatalf("GetConversationPath after delete: %v", err)
+	}
+	assertIDs(t, historyIDs(path), third.ID)
+
+	// 其他聊天机器人的记录和不存在的ID忽略
+	if err := s.DeleteConversations(ctx, "trim", []int64{second.ID, third.ID, other.ID, other.ID + 100}); err != nil {
+		t.Fatalf("DeleteConversations: %v", err)
+	}
+	assertIDs(t, historyIDs(mustHistory(t, s, "trim", 10)), first.ID)
+	if _, err := s.GetConversation(ctx, third.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("GetConversation after delete error = %v, want ErrNotFound", err)
+	}
+	if _, err := s.GetFeedback(ctx, third.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("GetFeedback after delete error = %v, want ErrNotFound", err)
+	}
+	assertIDs(t, historyIDs(mustHistory(t, s, "other", 10)), other.ID)
+
+	if err := s.DeleteConversations(ctx, "trim", nil); err != nil {
+		t.Fatalf("DeleteConversations(nil): %v", err)
+	}
+}
+
+// historyIDs 返回对话记录的ID
+func historyIDs(convs []*model.Conversation) []int64 {
+	ids := make([]int64, len(convs))
+	for i, conv := range convs {
+		ids[i] = conv.ID
+	}
+	return ids
+}
+
+// mustQuery 分页查询对话历史，失败时终止测试
+func mustQuery(t *testing.T, s storage.Storage, q model.HistoryQuery) *model.HistoryPage {
+	t.Helper()
+	page, err := s.QueryConversationHistory(context.Background(), q)
+	if err != nil {
+		t.Fatalf("QueryConversationHistory(%+v): %v", q, err)
+	}
+	return page
+}
+
+// pageIDs 返回分页结果中的对话ID
+func pageIDs(page *model.HistoryPage) []int64 {
+	return historyIDs(page.Conversations)
+}
+
+// assertIDs 校验ID序列
+func assertIDs(t *testing.T, got []int64, want ...int64) {
+	t.Helper()
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("IDs = %v, want %v", got, want)
+	}
+}
+
+func testHistoryPagination(t *testing.T, s storage.Storage) {
+	mustSaveChatbot(t, s, "page")
+	mustSaveChatbot(t, s, "other")
+
+	var ids []int64
+	for i := 0; i < 7; i++ {
+		ids = append(ids, mustSaveConversation(t, s, "page", i).ID)
+		mustSaveConversation(t, s, "other", i)
+	}
+
+	// 从最新记录向前翻页
+	page := mustQuery(t, s, model.HistoryQuery{ChatbotID: "page", Limit: 3})
+	assertIDs(t, pageIDs(page), ids[4:7]...)
+	if page.NextCursor != ids[4] {
+		t.Fatalf("NextCursor = %d, want %d", page.NextCursor, ids[4])
+	}
+	page = mustQuery(t, s, model.HistoryQuery{ChatbotID: "page", Limit: 3, Before: page.NextCursor})
+	assertIDs(t, pageIDs(page), ids[1:4]...)
+	page = mustQuery(t, s, model.HistoryQuery{ChatbotID: "page", Limit: 3, Before: page.NextCursor})
+	assertIDs(t, pageIDs(page), ids[0])
+	if page.NextCursor != 0 {
+		t.Fatalf("NextCursor on last page = %d, want 0", page.NextCursor)
+	}
+
+	// 从最早记录向后翻页
+	page = mustQuery(t, s, model.HistoryQuery{ChatbotID: "page", Limit: 3, FromOldest: true})
+	assertIDs(t, pageIDs(page), ids[0:3]...)
+	if page.NextCursor != ids[2] {
+		t.Fatalf("oldest-first NextCursor = %d, want %d", page.NextCursor, ids[2])
+	}
+
+	// 从指定游标向后翻页
+	page = mustQuery(t, s, model.HistoryQuery{ChatbotID: "page", Limit: 3, After: ids[0]})
+	assertIDs(t, pageIDs(page), ids[1:4]...)
+	if page.NextCursor != ids[3] {
+		t.Fatalf("forward NextCursor = %d, want %d", page.NextCursor, ids[3])
+	}
+	page = mustQuery(t, s, model.HistoryQuery{ChatbotID: "page", Limit: 3, After: page.NextCursor})
+	assertIDs(t, pageIDs(page), ids[4:7]...)
+	if page.NextCursor != 0 {
+		t.Fatalf("forward NextCursor on last page = %d, want 0", page.NextCursor)
+	}
+
+	// before与after同时给出时取区间
+	page = mustQuery(t, s, model.HistoryQuery{ChatbotID: "page", Limit: 10, After: ids[1], Before: ids[4]})
+	assertIDs(t, pageIDs(page), ids[2:4]...)
+
+	if page := mustQuery(t, s, model.HistoryQuery{ChatbotID: "missing", Limit: 3}); len(page.Conversations) != 0 {
+		t.Fatalf("query of missing chatbot returned %d entries, want 0", len(page.Conversations))
+	}
+}
+
+func testHistorySearch(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	mustSaveChatbot(t, s, "search")
+
+	messages := [][2]string{
+		{"tell me about golang", "Go is a language"},
+		{"what is rust", "a systems language"},
+		{"weather today", "sunny, good for GOLANG meetups"},
+		{"你好，机器人", "你好，有什么可以帮你"},
+	}
+	var ids []int64
+	for _, m := range messages {
+		conv := &model.Conversation{ChatbotID: "search", UserMessage: m[0], BotMessage: m[1]}
+		if err := s.SaveConversation(ctx, conv); err != nil {
+			t.Fatalf("SaveConversation: %v", err)
+		}
+		ids = append(ids, conv.ID)
+	}
+
+	// 同时匹配用户消息和回复，忽略大小写
+	page := mustQuery(t, s, model.HistoryQuery{ChatbotID: "search", Limit: 10, Search: "golang"})
+	assertIDs(t, pageIDs(page), ids[0], ids[2])
+
+	page = mustQuery(t, s, model.HistoryQuery{ChatbotID: "search", Limit: 1, Search: "golang"})
+	assertIDs(t, pageIDs(page), ids[2])
+	if page.NextCursor != ids[2] {
+		t.Fatalf("NextCursor = %d, want %d", page.NextCursor, ids[2])
+	}
+
+	page = mustQuery(t, s, model.HistoryQuery{ChatbotID: "search", Limit: 10, Search: "机器人"})
+	assertIDs(t, pageIDs(page), ids[3])
+
+	page = mustQuery(t, s, model.HistoryQuery{ChatbotID: "search", Limit: 10, Search: "python"})
+	assertIDs(t, pageIDs(page))
+}
+
+func testHistoryDateRange(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	mustSaveChatbot(t, s, "dates")
+
+	day := func(d int) time.Time { return time.Date(2024, time.January, d, 0, 0, 0, 0, time.Local) }
+	var ids []int64
+	for d := 1; d <= 3; d++ {
+		conv := &model.Conversation{
+			ChatbotID:   "dates",
+			UserMessage: fmt.Sprintf("user-%d", d),
+			BotMessage:  fmt.Sprintf("bot-%d", d),
+			CreatedAt:   day(d).Add(12 * time.Hour),
+		}
+		if err := s.SaveConversation(ctx, conv); err != nil {
+			t.Fatalf("SaveConversation: %v", err)
+		}
+		ids = append(ids, conv.ID)
+	}
+
+	page := mustQuery(t, s, model.HistoryQuery{ChatbotID: "dates", Limit: 10, From: day(2), To: day(3)})
+	assertIDs(t, pageIDs(page), ids[1])
+
+	page = mustQuery(t, s, model.HistoryQuery{ChatbotID: "dates", Limit: 10, From: day(2)})
+	assertIDs(t, pageIDs(page), ids[1], ids[2])
+
+	page = mustQuery(t, s, model.HistoryQuery{ChatbotID: "dates", Limit: 10, To: day(2)})
+	assertIDs(t, pageIDs(page), ids[0])
+}
+
+func testPersonaVersions(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	chatbot := mustSaveChatbot(t, s, "versioned")
+
+	if err := s.SavePersonaVersion(ctx, &model.PersonaVersion{ChatbotID: "missing"}); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("SavePersonaVersion(missing) error = %v, want ErrNotFound", err)
+	}
+
+	chatbot.PromptFormat = model.PromptFormatFString
+	maxTokens := 256
+	chatbot.Options.MaxTokens = &maxTokens
+	for i := 1; i <= 3; i++ {
+		chatbot.Personality = fmt.Sprintf("personality-%d", i)
+		v := model.NewPersonaVersion(chatbot, fmt.Sprintf("edit %d", i))
+		if err := s.SavePersonaVersion(ctx, v); err != nil {
+			t.Fatalf("SavePersonaVersion(%d): %v", i, err)
+		}
+		if v.Version != i {
+			t.Fatalf("version = %d, want %d", v.Version, i)
+		}
+	}
+
+	got, err := s.GetChatbot(ctx, "versioned")
+	if err != nil {
+		t.Fatalf("GetChatbot: %v", err)
+	}
+	if got.PersonaVersion != 3 {
+		t.Fatalf("chatbot persona_version = %d, want 3", got.PersonaVersion)
+	}
+
+	v, err := s.GetPersonaVersion(ctx, "versioned", 2)
+	if err != nil {
+		t.Fatalf("GetPersonaVersion: %v", err)
+	}
+	if v.Personality != "personality-2" || v.Comment != "edit 2" || v.SystemPrompt != chatbot.SystemPrompt || v.PromptFormat != chatbot.PromptFormat {
+		t.Fatalf("version 2 = %+v", v)
+	}
+	if v.Options == nil || v.Options.MaxTokens == nil || *v.Options.MaxTokens != 256 {
+		t.Fatalf("version 2 options = %+v, want max_tokens 256", v.Options)
+	}
+	if _, err := s.GetPersonaVersion(ctx, "versioned", 4); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("GetPersonaVersion(4) error = %v, want ErrNotFound", err)
+	}
+
+	versions, err := s.ListPersonaVersions(ctx, "versioned")
+	if err != nil {
+		t.Fatalf("ListPersonaVersions: %v", err)
+	}
+	if len(versions) != 3 || versions[0].Version != 3 || versions[2].Version != 1 {
+		t.Fatalf("ListPersonaVersions returned %d versions, want 3 newest first", len(versions))
+	}
+
+	// 对话记录保留生成时的人设版本
+	conv := &model.Conversation{ChatbotID: "versioned", UserMessage: "u", BotMessage: "b", PersonaVersion: 2}
+	if err := s.SaveConversation(ctx, conv); err != nil {
+		t.Fatalf("SaveConversation: %v", err)
+	}
+	if history := mustHistory(t, s, "versioned", 1); history[0].PersonaVersion != 2 {
+		t.Fatalf("conversation persona_version = %d, want 2", history[0].PersonaVersion)
+	}
+
+	// 删除后重新创建，版本号从1开始
+	if err := s.DeleteChatbot(ctx, "versioned"); err != nil {
+		t.Fatalf("DeleteChatbot: %v", err)
+	}
+	if versions, err := s.ListPersonaVersions(ctx, "versioned"); err != nil || len(versions) != 0 {
+		t.Fatalf("ListPersonaVersions after delete = %d, %v; want 0", len(versions), err)
+	}
+	chatbot = mustSaveChatbot(t, s, "versioned")
+	v = model.NewPersonaVersion(chatbot, "")
+	if err := s.SavePersonaVersion(ctx, v); err != nil {
+		t.Fatalf("SavePersonaVersion after recreate: %v", err)
+	}
+	if v.Version != 1 {
+		t.Fatalf("version after recreate = %d, want 1", v.Version)
+	}
+}
+
+func testFeedback(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	mustSaveChatbot(t, s, "rated")
+	mustSaveChatbot(t, s, "other")
+
+	first := mustSaveConversation(t, s, "rated", 0)
+	second := mustSaveConversation(t, s, "rated", 1)
+	other := mustSaveConversation(t, s, "other", 0)
+
+	if _, err := s.GetFeedback(ctx, first.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("GetFeedback before save error = %v, want ErrNotFound", err)
+	}
+
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for i, f := range []*model.Feedback{
+		{ConversationID: second.ID, ChatbotID: "rated", Rating: model.RatingDown, CreatedAt: base.Add(time.Minute)},
+		{ConversationID: first.ID, ChatbotID: "rated", Rating: model.RatingUp, Tags: []string{"helpful", "in-character"}, Comment: "nice", CreatedAt: base},
+		{ConversationID: other.ID, ChatbotID: "other", Rating: model.RatingUp, CreatedAt: base},
+	} {
+		if err := s.SaveFeedback(ctx, f); err != nil {
+			t.Fatalf("SaveFeedback(%d): %v", i, err)
+		}
+	}
+
+	got, err := s.GetFeedback(ctx, first.ID)
+	if err != nil {
+		t.Fatalf("GetFeedback: %v", err)
+	}
+	if got.Rating != model.RatingUp || got.Comment != "nice" || fmt.Sprint(got.Tags) != "[helpful in-character]" || got.ChatbotID != "rated" {
+		t.Fatalf("GetFeedback = %+v", got)
+	}
+
+	// 重复提交覆盖原反馈
+	if err := s.SaveFeedback(ctx, &model.Feedback{ConversationID: first.ID, ChatbotID: "rated", Rating: model.RatingDown, CreatedAt: base}); err != nil {
+		t.Fatalf("SaveFeedback overwrite: %v", err)
+	}
+	got, err = s.GetFeedback(ctx, first.ID)
+	if err != nil {
+		t.Fatalf("GetFeedback after overwrite: %v", err)
+	}
+	if got.Rating != model.RatingDown || got.Comment != "" || len(got.Tags) != 0 {
+		t.Fatalf("overwritten feedback = %+v", got)
+	}
+
+	list, err := s.ListFeedback(ctx, model.FeedbackQuery{ChatbotID: "rated"})
+	if err != nil {
+		t.Fatalf("ListFeedback: %v", err)
+	}
+	if len(list) != 2 || list[0].ConversationID != first.ID || list[1].ConversationID != second.ID {
+		t.Fatalf("ListFeedback = %+v, want feedback of %d and %d", list, first.ID, second.ID)
+	}
+	list, err = s.ListFeedback(ctx, model.FeedbackQuery{ChatbotID: "rated", From: base.Add(30 * time.Second)})
+	if err != nil {
+		t.Fatalf("ListFeedback with range: %v", err)
+	}
+	if len(list) != 1 || list[0].ConversationID != second.ID {
+		t.Fatalf("ListFeedback with range = %+v, want feedback of %d", list, second.ID)
+	}
+
+	if err := s.DeleteChatbot(ctx, "rated"); err != nil {
+		t.Fatalf("DeleteChatbot: %v", err)
+	}
+	if _, err := s.GetFeedback(ctx, first.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("GetFeedback after delete error = %v, want ErrNotFound", err)
+	}
+	if list, err := s.ListFeedback(ctx, model.FeedbackQuery{ChatbotID: "other"}); err != nil || len(list) != 1 {
+		t.Fatalf("ListFeedback of other chatbot = %v, %v; want 1 entry", list, err)
+	}
+}
+
+func testKnowledgeBases(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	if _, err := s.GetKnowledgeBase(ctx, "hr"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("GetKnowledgeBase before save error = %v, want ErrNotFound", err)
+	}
+	list, err := s.ListKnowledgeBases(ctx)
+	if err != nil {
+		t.Fatalf("ListKnowledgeBases on empty storage: %v", err)
+	}
+	if len(list) != 0 {
+		t.Fatalf("ListKnowledgeBases on empty storage = %+v, want empty", list)
+	}
+
+	created := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for _, kb := range []*model.KnowledgeBase{
+		{Name: "pirate-lore", Description: "航海传说", CreatedAt: created},
+		{Name: "hr", Description: "员工手册", CreatedAt: created},
+	} {
+		if err := s.SaveKnowledgeBase(ctx, kb); err != nil {
+			t.Fatalf("SaveKnowledgeBase(%s): %v", kb.Name, err)
+		}
+	}
+
+	// 覆盖描述，保留创建时间
+	if err := s.SaveKnowledgeBase(ctx, &model.KnowledgeBase{Name: "hr", Description: "人事制度"}); err != nil {
+		t.Fatalf("SaveKnowledgeBase overwrite: %v", err)
+	}
+	got, err := s.GetKnowledgeBase(ctx, "hr")
+	if err != nil {
+		t.Fatalf("GetKnowledgeBase: %v", err)
+	}
+	if got.Description != "人事制度" || !got.CreatedAt.Equal(created) {
+		t.Fatalf("GetKnowledgeBase = %+v, want description 人事制度 created at %v", got, created)
+	}
+
+	list, err = s.ListKnowledgeBases(ctx)
+	if err != nil {
+		t.Fatalf("ListKnowledgeBases: %v", err)
+	}
+	if len(list) != 2 || list[0].Name != "hr" || list[1].Name != "pirate-lore" {
+		t.Fatalf("ListKnowledgeBases = %+v, want hr, pirate-lore", list)
+	}
+}
+
+func testKnowledgeChunks(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	if n, err := s.CountKnowledgeChunks(ctx); err != nil || n != 0 {
+		t.Fatalf("CountKnowledgeChunks on empty storage = %d, %v, want 0", n, err)
+	}
+
+	chunks := []*model.KnowledgeChunk{
+		{KnowledgeBase: "default", DocumentID: "doc-1", Content: "第一段", Source: "guide.md", Metadata: []byte(`{"chunk_index":0}`)},
+		{KnowledgeBase: "default", DocumentID: "doc-1", Content: "第二段", Source: "guide.md", Metadata: []byte(`{"chunk_index":1}`)},
+		{KnowledgeBase: "hr", DocumentID: "doc-2", Content: "年假二十天", Category: "policy"},
+	}
+	if err := s.SaveKnowledgeChunks(ctx, chunks); err != nil {
+		t.Fatalf("SaveKnowledgeChunks: %v", err)
+	}
+	for i, chunk := range chunks {
+		if chunk.ID == 0 || chunk.CreatedAt.IsZero() {
+			t.Fatalf("chunk %d not assigned ID and CreatedAt: %+v", i, chunk)
+		}
+		if i > 0 && chunk.ID <= chunks[i-1].ID {
+			t.Fatalf("chunk IDs not increasing: %d after %d", chunk.ID, chunks[i-1].ID)
+		}
+	}
+
+	if n, err := s.CountKnowledgeChunks(ctx); err != nil || n != 3 {
+		t.Fatalf("CountKnowledgeChunks = %d, %v, want 3", n, err)
+	}
+
+	// 按ID游标分页
+	page, err := s.ListKnowledgeChunks(ctx, 0, 2)
+	if err != nil {
+		t.Fatalf("ListKnowledgeChunks: %v", err)
+	}
+	if len(page) != 2 || page[0].ID != chunks[0].ID || page[1].ID != chunks[1].ID {
+		t.Fatalf("ListKnowledgeChunks first page = %+v", page)
+	}
+	if page[0].Content != "第一段" || page[0].Source != "guide.md" || page[0].KnowledgeBase != "default" {
+		t.Fatalf("ListKnowledgeChunks chunk = %+v", page[0])
+	}
+	if string(page[1].Metadata) != `{"chunk_index":1}` {
+		t.Fatalf("ListKnowledgeChunks metadata = %s", page[1].Metadata)
+	}
+
+	page, err = s.ListKnowledgeChunks(ctx, page[1].ID, 2)
+	if err != nil {
+		t.Fatalf("ListKnowledgeChunks second page: %v", err)
+	}
+	if len(page) != 1 || page[0].KnowledgeBase != "hr" || page[0].Category != "policy" || page[0].Metadata != nil {
+		t.Fatalf("ListKnowledgeChunks second page = %+v", page)
+	}
+
+	page, err = s.ListKnowledgeChunks(ctx, chunks[2].ID, 2)
+	if err != nil {
+		t.Fatalf("ListKnowledgeChunks past end: %v", err)
+	}
+	if len(page) != 0 {
+		t.Fatalf("ListKnowledgeChunks past end = %+v, want empty", page)
+	}
+
+	// 按条件分页查询
+	result, err := s.QueryKnowledgeChunks(ctx, model.KnowledgeQuery{DocumentID: "doc-1", Offset: 1, Limit: 10})
+	if err != nil {
+		t.Fatalf("QueryKnowledgeChunks: %v", err)
+	}
+	if result.Total != 2 || len(result.Items) != 1 || result.Items[0].ID != chunks[1].ID || result.Items[0].DocumentID != "doc-1" {
+		t.Fatalf("QueryKnowledgeChunks by document = %+v", result)
+	}
+	result, err = s.QueryKnowledgeChunks(ctx, model.KnowledgeQuery{KnowledgeBase: "hr", Limit: 10})
+	if err != nil {
+		t.Fatalf("QueryKnowledgeChunks: %v", err)
+	}
+	if result.Total != 1 || len(result.Items) != 1 || result.Items[0].Content != "年假二十天" {
+		t.Fatalf("QueryKnowledgeChunks by knowledge base = %+v", result)
+	}
+	result, err = s.QueryKnowledgeChunks(ctx, model.KnowledgeQuery{Source: "guide.md", Limit: 1})
+	if err != nil {
+		t.Fatalf("QueryKnowledgeChunks: %v", err)
+	}
+	if result.Total != 2 || len(result.Items) != 1 || result.Items[0].ID != chunks[0].ID {
+		t.Fatalf("QueryKnowledgeChunks by source = %+v", result)
+	}
+	result, err = s.QueryKnowledgeChunks(ctx, model.KnowledgeQuery{KnowledgeBase: "hr", DocumentID: "doc-1", Limit: 10})
+	if err != nil {
+		t.Fatalf("QueryKnowledgeChunks: %v", err)
+	}
+	if result.Total != 0 || len(result.Items) != 0 {
+		t.Fatalf("QueryKnowledgeChunks by knowledge base and document = %+v, want empty", result)
+	}
+	result, err = s.QueryKnowledgeChunks(ctx, model.KnowledgeQuery{Offset: 2, Limit: 10})
+	if err != nil {
+		t.Fatalf("QueryKnowledgeChunks: %v", err)
+	}
+	if result.Total != 3 || len(result.Items) != 1 || result.Items[0].ID != chunks[2].ID {
+		t.Fatalf("QueryKnowledgeChunks without filters = %+v", result)
+	}
+
+	// 获取和修改
+	if _, err := s.GetKnowledgeChunk(ctx, chunks[2].ID+100); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("GetKnowledgeChunk missing error = %v, want ErrNotFound", err)
+	}
+	updated := *chunks[0]
+	updated.Content = "第一段（修订）"
+	updated.Category = "guide"
+	updated.Metadata = []byte(`{"chunk_index":0,"edited":true}`)
+	if err := s.UpdateKnowledgeChunk(ctx, &updated); err != nil {
+		t.Fatalf("UpdateKnowledgeChunk: %v", err)
+	}
+	got, err := s.GetKnowledgeChunk(ctx, chunks[0].ID)
+	if err != nil {
+		t.Fatalf("GetKnowledgeChunk: %v", err)
+	}
+	if got.Content != "第一段（修订）" || got.Category != "guide" || got.Source != "guide.md" || got.DocumentID != "doc-1" {
+		t.Fatalf("GetKnowledgeChunk after update = %+v", got)
+	}
+	if string(got.Metadata) != `{"chunk_index":0,"edited":true}` || got.UpdatedAt.Before(got.CreatedAt) {
+		t.Fatalf("GetKnowledgeChunk after update = %+v", got)
+	}
+	missing := updated
+	missing.ID = chunks[2].ID + 100
+	if err := s.UpdateKnowledgeChunk(ctx, &missing); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("UpdateKnowledgeChunk missing error = %v, want ErrNotFound", err)
+	}
+
+	// 删除，不存在的ID忽略
+	if err := s.DeleteKnowledgeChunks(ctx, []int64{chunks[0].ID, chunks[2].ID + 100}); err != nil {
+		t.Fatalf("DeleteKnowledgeChunks: %v", err)
+	}
+	if _, err := s.GetKnowledgeChunk(ctx, chunks[0].ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("GetKnowledgeChunk after delete error = %v, want ErrNotFound", err)
+	}
+	if n, err := s.CountKnowledgeChunks(ctx); err != nil || n != 2 {
+		t.Fatalf("CountKnowledgeChunks after delete = %d, %v, want 2", n, err)
+	}
+	page, err = s.ListKnowledgeChunks(ctx, 0, 10)
+	if err != nil || len(page) != 2 || page[0].ID != chunks[1].ID {
+		t.Fatalf("ListKnowledgeChunks after delete = %+v, %v", page, err)
+	}
+	result, err = s.QueryKnowledgeChunks(ctx, model.KnowledgeQuery{DocumentID: "doc-1", Limit: 10})
+	if err != nil || result.Total != 1 || len(result.Items) != 1 || result.Items[0].ID != chunks[1].ID {
+		t.Fatalf("QueryKnowledgeChunks by document after delete = %+v, %v", result, err)
+	}
+	result, err = s.QueryKnowledgeChunks(ctx, model.KnowledgeQuery{KnowledgeBase: "default", Limit: 10})
+	if err != nil || result.Total != 1 || len(result.Items) != 1 {
+		t.Fatalf("QueryKnowledgeChunks by knowledge base after delete = %+v, %v", result, err)
+	}
+}
+
+func testReindexJobs(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	if _, err := s.GetReindexJob(ctx, "missing"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("GetReindexJob missing error = %v, want ErrNotFound", err)
+	}
+
+	created := time.Now().Add(-time.Hour).Truncate(time.Second)
+	older := &model.ReindexJob{
+		ID:             "job-1",
+		Status:         model.ReindexStatusCompleted,
+		Collection:     "knowledge_base_1",
+		EmbeddingModel: "nomic-embed-text",
+		EmbeddingDim:   768,
+		CreatedAt:      created,
+		UpdatedAt:      created,
+	}
+	newer := &model.ReindexJob{
+		ID:             "job-2",
+		Status:         model.ReindexStatusRunning,
+		Collection:     "knowledge_base_2",
+		EmbeddingModel: "bge-m3",
+		EmbeddingDim:   1024,
+		TotalChunks:    10,
+		CreatedAt:      created.Add(time.Minute),
+		UpdatedAt:      created.Add(time.Minute),
+	}
+	for _, job := range []*model.ReindexJob{older, newer} {
+		if err := s.SaveReindexJob(ctx, job); err != nil {
+			t.Fatalf("SaveReindexJob(%s): %v", job.ID, err)
+		}
+	}
+
+	// 更新进度并结束任务
+	finished := created.Add(2 * time.Minute)
+	newer.Status = model.ReindexStatusFailed
+	newer.EmbeddedChunks = 4
+	newer.LastChunkID = 42
+	newer.Error = "embedding failed"
+	newer.UpdatedAt = finished
+	newer.FinishedAt = &finished
+	if err := s.SaveReindexJob(ctx, newer); err != nil {
+		t.Fatalf("SaveReindexJob update: %v", err)
+	}
+
+	got, err := s.GetReindexJob(ctx, "job-2")
+	if err != nil {
+		t.Fatalf("GetReindexJob: %v", err)
+	}
+	if got.Status != model.ReindexStatusFailed || got.EmbeddedChunks != 4 || got.LastChunkID != 42 ||
+		got.Error != "embedding failed" || got.EmbeddingDim != 1024 || got.TotalChunks != 10 {
+		t.Fatalf("GetReindexJob = %+v", got)
+	}
+	if got.FinishedAt == nil || !got.FinishedAt.Equal(finished) || !got.CreatedAt.Equal(newer.CreatedAt) {
+		t.Fatalf("GetReindexJob times = created %v finished %v", got.CreatedAt, got.FinishedAt)
+	}
+
+	got, err = s.GetReindexJob(ctx, "job-1")
+	if err != nil {
+		t.Fatalf("GetReindexJob: %v", err)
+	}
+	if got.FinishedAt != nil {
+		t.Fatalf("GetReindexJob FinishedAt = %v, want nil", got.FinishedAt)
+	}
+
+	list, err := s.ListReindexJobs(ctx)
+	if err != nil {
+		t.Fatalf("ListReindexJobs: %v", err)
+	}
+	if len(list) != 2 || list[0].ID != "job-2" || list[1].ID != "job-1" {
+		t.Fatalf("ListReindexJobs = %+v, want job-2, job-1", list)
+	}
+}
+
+func testCascadeDelete(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	mustSaveChatbot(t, s, "cascade")
+	mustSaveChatbot(t, s, "keep")
+	var last *model.Conversation
+	for i := 0; i < 3; i++ {
+		last = mustSaveConversation(t, s, "cascade", i)
+	}
+	mustSaveConversation(t, s, "keep", 0)
+
+	if err := s.DeleteChatbot(ctx, "cascade"); err != nil {
+		t.Fatalf("DeleteChatbot: %v", err)
+	}
+	if history := mustHistory(t, s, "cascade", 10); len(history) != 0 {
+		t.Fatalf("history after delete has %d entries, want 0", len(history))
+	}
+	if _, err := s.GetConversation(ctx, last.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("GetConversation after delete error = %v, want ErrNotFound", err)
+	}
+
+	// 重新创建同ID的机器人不应看到旧记录
+	mustSaveChatbot(t, s, "cascade")
+	if history := mustHistory(t, s, "cascade", 10); len(history) != 0 {
+		t.Fatalf("history of recreated chatbot has %d entries, want 0", len(history))
+	}
+
+	if history := mustHistory(t, s, "keep", 10); len(history) != 1 {
+		t.Fatalf("history of other chatbot has %d entries, want 1", len(history))
+	}
+}
+
+func testConcurrentWrites(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	mustSaveChatbot(t, s, "concurrent")
+
+	const workers, perWorker = 8, 10
+	var wg sync.WaitGroup
+	errCh := make(chan error, workers*perWorker)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				conv := &model.Conversation{
+					ChatbotID:   "concurrent",
+					UserMessage: fmt.Sprintf("user-%d-%d", w, i),
+					BotMessage:  fmt.Sprintf("bot-%d-%d", w, i),
+				}
+				if err := s.SaveConversation(ctx, conv); err != nil {
+					errCh <- err
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		t.Fatalf("concurrent SaveConversation: %v", err)
+	}
+
+	// 并发写入的记录互不相连，按完整列表校验
+	history := mustQuery(t, s, model.HistoryQuery{ChatbotID: "concurrent", Limit: workers * perWorker * 2, FromOldest: true}).Conversations
+	if len(history) != workers*perWorker {
+		t.Fatalf("history has %d entries, want %d", len(history), workers*perWorker)
+	}
+	seen := make(map[int64]bool, len(history))
+	for i, conv := range history {
+		if seen[conv.ID] {
+			t.Fatalf("duplicate conversation ID %d", conv.ID)
+		}
+		seen[conv.ID] = true
+		if i > 0 && conv.ID <= history[i-1].ID {
+			t.Fatalf("history not ordered: ID %d after %d", conv.ID, history[i-1].ID)
+		}
+	}
+}
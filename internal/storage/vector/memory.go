@@ -0,0 +1,435 @@
+package vector
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// MemoryStore 内存向量存储，暴力搜索全部向量，适合开发、测试和小规模知识库
+//
+// 设置快照路径后，启动时从快照恢复数据，运行中按间隔将有变更的数据写入快照，关闭时再写入一次。
+type MemoryStore struct {
+	metric       Metric
+	snapshotPath string
+
+	mu          sync.RWMutex
+	collections map[string]*memoryCollection
+	aliases     map[string]string // 别名 -> 集合名称
+	dirty       bool              // 上次快照后数据有变更
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// memorySnapshot 快照文件内容
+type memorySnapshot struct {
+	Collections map[string]*memoryCollection
+	Aliases     map[string]string
+}
+
+// memoryCollection 内存中的一个集合，字段导出以便快照编码
+type memoryCollection struct {
+	EmbeddingModel string
+	Dim            int
+	RowIDs         bool // 主键由调用方指定，写入相同主键时覆盖
+	NextID         int64
+	Records        []memoryRecord
+
+	index map[int64]int // 主键 -> Records下标，RowIDs为true时使用，按需重建
+}
+
+// memoryRecord 集合中的一条向量
+type memoryRecord struct {
+	ID            int64
+	KnowledgeBase string
+	Content       string
+	Metadata      []byte
+	Embedding     []float32
+	Norm          float64
+}
+
+// NewMemoryStore 创建内存向量存储
+//
+// snapshotPath为空时不持久化；interval大于0时按间隔写入快照，否则只在关闭时写入。
+func NewMemoryStore(metric Metric, snapshotPath string, interval time.Duration) (*MemoryStore, error) {
+	s := &MemoryStore{
+		metric:       metric,
+		snapshotPath: snapshotPath,
+		collections:  make(map[string]*memoryCollection),
+		aliases:      make(map[string]string),
+	}
+	if snapshotPath != "" {
+		if err := s.load(); err != nil {
+			return nil, err
+		}
+	}
+	if snapshotPath != "" && interval > 0 {
+		s.stop = make(chan struct{})
+		s.done = make(chan struct{})
+		go s.snapshotLoop(interval)
+	}
+	return s, nil
+}
+
+// resolve 将别名解析为集合名称，调用方需持有锁
+func (s *MemoryStore) resolve(name string) string {
+	if target, ok := s.aliases[name]; ok {
+		return target
+	}
+	return name
+}
+
+// CreateCollection 创建集合（如果不存在），已存在的集合维度必须一致
+func (s *MemoryStore) CreateCollection(ctx context.Context, collectionName string, info CollectionInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if coll, ok := s.collections[s.resolve(collectionName)]; ok {
+		if coll.Dim != info.Dim {
+			return fmt.Errorf("collection %s has dimension %d, expected %d", collectionName, coll.Dim, info.Dim)
+		}
+		return nil
+	}
+	s.collections[collectionName] = &memoryCollection{
+		EmbeddingModel: info.EmbeddingModel,
+		Dim:            info.Dim,
+		RowIDs:         info.RowIDs,
+		NextID:         1,
+	}
+	s.dirty = true
+	return nil
+}
+
+// DescribeCollection 获取集合元数据，collectionName可以是别名；集合不存在时返回ErrCollectionNotFound
+func (s *MemoryStore) DescribeCollection(ctx context.Context, collectionName string) (*CollectionInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	name := s.resolve(collectionName)
+	coll, ok := s.collections[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrCollectionNotFound, collectionName)
+	}
+	return &CollectionInfo{Collection: name, EmbeddingModel: coll.EmbeddingModel, Dim: coll.Dim, RowIDs: coll.RowIDs}, nil
+}
+
+// DropCollection 删除集合及指向它的别名，集合不存在时不做任何事
+func (s *MemoryStore) DropCollection(ctx context.Context, collectionName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.collections[collectionName]; !ok {
+		return nil
+	}
+	delete(s.collections, collectionName)
+	for alias, target := range s.aliases {
+		if target == collectionName {
+			delete(s.aliases, alias)
+		}
+	}
+	s.dirty = true
+	return nil
+}
+
+// SetAlias 将别名指向集合，别名已存在时原子地切换到新集合
+func (s *MemoryStore) SetAlias(ctx context.Context, alias, collectionName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.collections[collectionName]; !ok {
+		return fmt.Errorf("set alias: %w: %s", ErrCollectionNotFound, collectionName)
+	}
+	if _, ok := s.collections[alias]; ok {
+		return fmt.Errorf("set alias: %s is a collection", alias)
+	}
+	s.aliases[alias] = collectionName
+	s.dirty = true
+	return nil
+}
+
+// Insert 批量写入向量数据，RowIDs集合中主键已存在时覆盖
+func (s *MemoryStore) Insert(ctx context.Context, collectionName string, entities []Entity) error {
+	if len(entities) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	coll, ok := s.collections[s.resolve(collectionName)]
+	if !ok {
+		return fmt.Errorf("insert vector: %w: %s", ErrCollectionNotFound, collectionName)
+	}
+	for i, e := range entities {
+		if len(e.Embedding) != coll.Dim {
+			return fmt.Errorf("insert vector: embedding %d has dimension %d, collection %s expects %d", i, len(e.Embedding), collectionName, coll.Dim)
+		}
+		if coll.RowIDs && e.ID <= 0 {
+			return fmt.Errorf("insert vector: entity %d has no id", i)
+		}
+	}
+
+	if coll.RowIDs && coll.index == nil {
+		coll.index = make(map[int64]int, len(coll.Records))
+		for i, r := range coll.Records {
+			coll.index[r.ID] = i
+		}
+	}
+	for _, e := range entities {
+		record := memoryRecord{
+			ID:            e.ID,
+			KnowledgeBase: e.KnowledgeBase,
+			Content:       e.Content,
+			Metadata:      slices.Clone(e.Metadata),
+			Embedding:     slices.Clone(e.Embedding),
+			Norm:          norm(e.Embedding),
+		}
+		if !coll.RowIDs {
+			record.ID = coll.NextID
+			coll.NextID++
+			coll.Records = append(coll.Records, record)
+			continue
+		}
+		if i, ok := coll.index[record.ID]; ok {
+			coll.Records[i] = record
+			continue
+		}
+		coll.index[record.ID] = len(coll.Records)
+		coll.Records = append(coll.Records, record)
+	}
+	s.dirty = true
+	return nil
+}
+
+// Delete 按主键删除向量，不存在的主键忽略
+func (s *MemoryStore) Delete(ctx context.Context, collectionName string, ids []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	coll, ok := s.collections[s.resolve(collectionName)]
+	if !ok {
+		return fmt.Errorf("delete vectors: %w: %s", ErrCollectionNotFound, collectionName)
+	}
+	deleted := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		deleted[id] = true
+	}
+	n := len(coll.Records)
+	coll.Records = slices.DeleteFunc(coll.Records, func(r memoryRecord) bool { return deleted[r.ID] })
+	if len(coll.Records) != n {
+		coll.index = nil
+		s.dirty = true
+	}
+	return nil
+}
+
+// Search 在指定知识库中搜索相似向量，返回按距离从近到远排列的结果
+func (s *MemoryStore) Search(ctx context.Context, collectionName string, queryVector []float32, kbs []string, topK int) ([]SearchResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	coll, ok := s.collections[s.resolve(collectionName)]
+	if !ok {
+		return nil, fmt.Errorf("search vectors: %w: %s", ErrCollectionNotFound, collectionName)
+	}
+	if len(queryVector) != coll.Dim {
+		return nil, fmt.Errorf("search vectors: query has dimension %d, collection %s expects %d", len(queryVector), collectionName, coll.Dim)
+	}
+
+	type hit struct {
+		index    int
+		distance float32
+	}
+	hits := make([]hit, 0)
+	for i, r := range coll.Records {
+		if !slices.Contains(kbs, r.KnowledgeBase) {
+			continue
+		}
+		hits = append(hits, hit{index: i, distance: s.metric.distance(queryVector, r.Embedding, r.Norm)})
+	}
+	slices.SortStableFunc(hits, func(a, b hit) int {
+		switch {
+		case a.distance < b.distance:
+			return -1
+		case a.distance > b.distance:
+			return 1
+		}
+		return 0
+	})
+	if len(hits) > topK {
+		hits = hits[:topK]
+	}
+
+	results := make([]SearchResult, len(hits))
+	for i, h := range hits {
+		r := coll.Records[h.index]
+		results[i] = SearchResult{
+			Entity:   Entity{ID: r.ID, KnowledgeBase: r.KnowledgeBase, Content: r.Content, Metadata: r.Metadata},
+			Distance: h.distance,
+		}
+	}
+	return results, nil
+}
+
+// Distances 计算查询向量与指定主键的向量之间的距离，不存在的主键不返回
+func (s *MemoryStore) Distances(ctx context.Context, collectionName string, queryVector []float32, ids []int64) (map[int64]float32, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	coll, ok := s.collections[s.resolve(collectionName)]
+	if !ok {
+		return nil, fmt.Errorf("vector distances: %w: %s", ErrCollectionNotFound, collectionName)
+	}
+	if len(queryVector) != coll.Dim {
+		return nil, fmt.Errorf("vector distances: query has dimension %d, collection %s expects %d", len(queryVector), collectionName, coll.Dim)
+	}
+
+	wanted := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	distances := make(map[int64]float32, len(ids))
+	for _, r := range coll.Records {
+		if wanted[r.ID] {
+			distances[r.ID] = s.metric.distance(queryVector, r.Embedding, r.Norm)
+		}
+	}
+	return distances, nil
+}
+
+// Scan 按写入顺序遍历集合中的全部数据，返回的实体不含向量；fn返回错误时停止遍历
+func (s *MemoryStore) Scan(ctx context.Context, collectionName string, fn func(Entity) error) error {
+	s.mu.RLock()
+	coll, ok := s.collections[s.resolve(collectionName)]
+	if !ok {
+		s.mu.RUnlock()
+		return fmt.Errorf("scan collection: %w: %s", ErrCollectionNotFound, collectionName)
+	}
+	// 复制记录后释放锁，fn中可以写入其他集合
+	records := slices.Clone(coll.Records)
+	s.mu.RUnlock()
+
+	for _, r := range records {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(Entity{ID: r.ID, KnowledgeBase: r.KnowledgeBase, Content: r.Content, Metadata: r.Metadata}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Snapshot 将全部集合和别名写入快照文件，未设置快照路径时不做任何事
+//
+// 先写临时文件再重命名，写入中途失败不会破坏已有快照。
+func (s *MemoryStore) Snapshot() error {
+	if s.snapshotPath == "" {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.snapshotPath), 0o755); err != nil {
+		return fmt.Errorf("create snapshot directory: %w", err)
+	}
+	f, err := os.CreateTemp(filepath.Dir(s.snapshotPath), filepath.Base(s.snapshotPath)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create snapshot: %w", err)
+	}
+	snapshot := memorySnapshot{Collections: s.collections, Aliases: s.aliases}
+	if err := gob.NewEncoder(f).Encode(&snapshot); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return fmt.Errorf("encode snapshot: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	if err := os.Rename(f.Name(), s.snapshotPath); err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+
+	s.dirty = false
+	return nil
+}
+
+// load 从快照文件恢复集合和别名，文件不存在时从空存储开始
+func (s *MemoryStore) load() error {
+	data, err := os.ReadFile(s.snapshotPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read snapshot: %w", err)
+	}
+
+	var snapshot memorySnapshot
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&snapshot); err != nil {
+		// 早期版本的快照只有集合，没有别名
+		if legacyErr := gob.NewDecoder(bytes.NewReader(data)).Decode(&snapshot.Collections); legacyErr != nil {
+			return fmt.Errorf("decode snapshot %s: %w", s.snapshotPath, err)
+		}
+	}
+	if snapshot.Collections != nil {
+		s.collections = snapshot.Collections
+	}
+	if snapshot.Aliases != nil {
+		s.aliases = snapshot.Aliases
+	}
+	return nil
+}
+
+// snapshotLoop 按间隔写入有变更的数据
+func (s *MemoryStore) snapshotLoop(interval time.Duration) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.mu.RLock()
+			dirty := s.dirty
+			s.mu.RUnlock()
+			if !dirty {
+				continue
+			}
+			if err := s.Snapshot(); err != nil {
+				log.Printf("Warning: failed to snapshot vector store: %v", err)
+			}
+		}
+	}
+}
+
+// Close 停止定时快照并写入最终快照
+func (s *MemoryStore) Close() error {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+		s.stop = nil
+	}
+
+	s.mu.RLock()
+	dirty := s.dirty
+	s.mu.RUnlock()
+	if !dirty {
+		return nil
+	}
+	return s.Snapshot()
+}
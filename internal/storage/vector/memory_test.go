@@ -0,0 +1,262 @@
+package vector_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"eino/internal/storage/vector"
+)
+
+func newStore(t *testing.T, metric vector.Metric, path string) *vector.MemoryStore {
+	t.Helper()
+	s, err := vector.NewMemoryStore(metric, path, 0)
+	if err != nil {
+		t.Fatalf("NewMemoryStore: %v", err)
+	}
+	return s
+}
+
+func insert(t *testing.T, s *vector.MemoryStore) {
+	t.Helper()
+	ctx := context.Background()
+	if err := s.CreateCollection(ctx, "kb", vector.CollectionInfo{EmbeddingModel: "test", Dim: 2}); err != nil {
+		t.Fatalf("CreateCollection: %v", err)
+	}
+	err := s.Insert(ctx, "kb", []vector.Entity{
+		{KnowledgeBase: "default", Content: "east", Embedding: []float32{1, 0}},
+		{KnowledgeBase: "default", Content: "far-east", Embedding: []float32{10, 0}},
+		{KnowledgeBase: "default", Content: "north", Embedding: []float32{0, 1}},
+		{KnowledgeBase: "hr", Content: "hr-east", Embedding: []float32{1, 0.1}},
+	})
+	if err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+}
+
+func resultContents(results []vector.SearchResult) []string {
+	out := make([]string, len(results))
+	for i, r := range results {
+		out[i] = r.Content
+	}
+	return out
+}
+
+func resultDistances(results []vector.SearchResult) []float32 {
+	out := make([]float32, len(results))
+	for i, r := range results {
+		out[i] = r.Distance
+	}
+	return out
+}
+
+func TestMemoryStoreSearch(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("L2", func(t *testing.T) {
+		s := newStore(t, vector.MetricL2, "")
+		insert(t, s)
+
+		results, err := s.Search(ctx, "kb", []float32{2, 0}, []string{"default"}, 2)
+		if err != nil {
+			t.Fatalf("Search: %v", err)
+		}
+		contents, distances := resultContents(results), resultDistances(results)
+		if !slices.Equal(contents, []string{"east", "north"}) {
+			t.Fatalf("contents = %v", contents)
+		}
+		if distances[0] != 1 || distances[1] != 5 {
+			t.Fatalf("distances = %v", distances)
+		}
+	})
+
+	t.Run("Cosine", func(t *testing.T) {
+		s := newStore(t, vector.MetricCosine, "")
+		insert(t, s)
+
+		results, err := s.Search(ctx, "kb", []float32{2, 0}, []string{"default"}, 3)
+		if err != nil {
+			t.Fatalf("Search: %v", err)
+		}
+		contents, distances := resultContents(results), resultDistances(results)
+		// 方向相同的向量距离为0，与模长无关
+		if !slices.Equal(contents, []string{"east", "far-east", "north"}) {
+			t.Fatalf("contents = %v", contents)
+		}
+		if distances[0] > 1e-6 || distances[1] > 1e-6 || distances[2] < 0.999 {
+			t.Fatalf("distances = %v", distances)
+		}
+	})
+
+	t.Run("KnowledgeBaseFilter", func(t *testing.T) {
+		s := newStore(t, vector.MetricL2, "")
+		insert(t, s)
+
+		results, err := s.Search(ctx, "kb", []float32{1, 0}, []string{"hr"}, 10)
+		if err != nil {
+			t.Fatalf("Search: %v", err)
+		}
+		if len(results) != 1 || results[0].Content != "hr-east" || results[0].KnowledgeBase != "hr" || results[0].ID == 0 {
+			t.Fatalf("results = %+v", results)
+		}
+
+		results, err = s.Search(ctx, "kb", []float32{1, 0}, []string{"missing"}, 10)
+		if err != nil {
+			t.Fatalf("Search: %v", err)
+		}
+		if len(results) != 0 {
+			t.Fatalf("results = %+v, want empty", results)
+		}
+	})
+
+	t.Run("Distances", func(t *testing.T) {
+		s := newStore(t, vector.MetricL2, "")
+		ctx := context.Background()
+		if err := s.CreateCollection(ctx, "rows", vector.CollectionInfo{EmbeddingModel: "test", Dim: 2, RowIDs: true}); err != nil {
+			t.Fatalf("CreateCollection: %v", err)
+		}
+		err := s.Insert(ctx, "rows", []vector.Entity{
+			{ID: 1, KnowledgeBase: "default", Content: "east", Embedding: []float32{1, 0}},
+			{ID: 2, KnowledgeBase: "default", Content: "north", Embedding: []float32{0, 1}},
+		})
+		if err != nil {
+			t.Fatalf("Insert: %v", err)
+		}
+
+		// 不存在的主键不返回
+		distances, err := s.Distances(ctx, "rows", []float32{2, 0}, []int64{2, 3})
+		if err != nil {
+			t.Fatalf("Distances: %v", err)
+		}
+		if len(distances) != 1 || distances[2] != 5 {
+			t.Fatalf("distances = %v, want map[2:5]", distances)
+		}
+	})
+
+	t.Run("DimensionMismatch", func(t *testing.T) {
+		s := newStore(t, vector.MetricL2, "")
+		insert(t, s)
+
+		if err := s.CreateCollection(ctx, "kb", vector.CollectionInfo{EmbeddingModel: "test", Dim: 3}); err == nil {
+			t.Fatal("CreateCollection with another dimension succeeded")
+		}
+		if err := s.Insert(ctx, "kb", []vector.Entity{{KnowledgeBase: "default", Embedding: []float32{1, 2, 3}}}); err == nil {
+			t.Fatal("Insert with wrong dimension succeeded")
+		}
+		if _, err := s.Search(ctx, "kb", []float32{1}, []string{"default"}, 1); err == nil {
+			t.Fatal("Search with wrong dimension succeeded")
+		}
+	})
+}
+
+func TestMemoryStoreCollections(t *testing.T) {
+	ctx := context.Background()
+	s := newStore(t, vector.MetricL2, "")
+
+	if _, err := s.DescribeCollection(ctx, "kb"); !errors.Is(err, vector.ErrCollectionNotFound) {
+		t.Fatalf("DescribeCollection missing: err = %v, want ErrCollectionNotFound", err)
+	}
+	insert(t, s)
+
+	info, err := s.DescribeCollection(ctx, "kb")
+	if err != nil {
+		t.Fatalf("DescribeCollection: %v", err)
+	}
+	if info.EmbeddingModel != "test" || info.Dim != 2 {
+		t.Fatalf("info = %+v", info)
+	}
+
+	var contents []string
+	err = s.Scan(ctx, "kb", func(e vector.Entity) error {
+		if e.Embedding != nil {
+			t.Errorf("Scan returned embedding for %q", e.Content)
+		}
+		contents = append(contents, e.KnowledgeBase+"/"+e.Content)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if !slices.Equal(contents, []string{"default/east", "default/far-east", "default/north", "hr/hr-east"}) {
+		t.Fatalf("Scan contents = %v", contents)
+	}
+
+	if err := s.SetAlias(ctx, "kb", "kb"); err == nil {
+		t.Fatal("SetAlias onto an existing collection succeeded")
+	}
+	if err := s.SetAlias(ctx, "alias", "missing"); !errors.Is(err, vector.ErrCollectionNotFound) {
+		t.Fatalf("SetAlias to missing collection: err = %v, want ErrCollectionNotFound", err)
+	}
+	if err := s.SetAlias(ctx, "alias", "kb"); err != nil {
+		t.Fatalf("SetAlias: %v", err)
+	}
+	info, err = s.DescribeCollection(ctx, "alias")
+	if err != nil || info.Collection != "kb" {
+		t.Fatalf("DescribeCollection alias = %+v, %v", info, err)
+	}
+
+	// 切换别名后读写都作用于新集合
+	if err := s.CreateCollection(ctx, "other", vector.CollectionInfo{Dim: 2, RowIDs: true}); err != nil {
+		t.Fatalf("CreateCollection: %v", err)
+	}
+	if err := s.Insert(ctx, "other", []vector.Entity{{KnowledgeBase: "default", Content: "south", Embedding: []float32{0, -1}}}); err == nil {
+		t.Fatal("Insert without id into a RowIDs collection succeeded")
+	}
+	if err := s.SetAlias(ctx, "alias", "other"); err != nil {
+		t.Fatalf("SetAlias switch: %v", err)
+	}
+	err = s.Insert(ctx, "alias", []vector.Entity{
+		{ID: 7, KnowledgeBase: "default", Content: "south", Embedding: []float32{0, -1}},
+		{ID: 7, KnowledgeBase: "default", Content: "south-v2", Embedding: []float32{0, -1}},
+	})
+	if err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	if results, err := s.Search(ctx, "alias", []float32{0, -1}, []string{"default"}, 10); err != nil || !slices.Equal(resultContents(results), []string{"south-v2"}) {
+		t.Fatalf("Search via alias = %+v, %v", results, err)
+	}
+	if err := s.Delete(ctx, "alias", []int64{7, 8}); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if results, err := s.Search(ctx, "alias", []float32{0, -1}, []string{"default"}, 10); err != nil || len(results) != 0 {
+		t.Fatalf("Search after delete = %+v, %v", results, err)
+	}
+
+	if err := s.DropCollection(ctx, "other"); err != nil {
+		t.Fatalf("DropCollection: %v", err)
+	}
+	if _, err := s.DescribeCollection(ctx, "alias"); !errors.Is(err, vector.ErrCollectionNotFound) {
+		t.Fatalf("DescribeCollection after drop: err = %v, want ErrCollectionNotFound", err)
+	}
+}
+
+func TestMemoryStoreSnapshot(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "vectors", "snapshot.gob")
+
+	s := newStore(t, vector.MetricL2, path)
+	insert(t, s)
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	restored := newStore(t, vector.MetricL2, path)
+	defer restored.Close()
+	results, err := restored.Search(ctx, "kb", []float32{0, 1}, []string{"default", "hr"}, 1)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if !slices.Equal(resultContents(results), []string{"north"}) {
+		t.Fatalf("results = %+v", results)
+	}
+
+	// 恢复后可以继续写入并再次快照
+	if err := restored.Insert(ctx, "kb", []vector.Entity{{KnowledgeBase: "default", Content: "south", Embedding: []float32{0, -1}}}); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	if err := restored.Snapshot(); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+}
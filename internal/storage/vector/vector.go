@@ -0,0 +1,88 @@
+// Package vector 定义知识库向量存储共用的数据结构，并提供不依赖外部服务的内存向量存储。
+package vector
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrCollectionNotFound 集合不存在
+var ErrCollectionNotFound = errors.New("collection not found")
+
+// CollectionInfo 集合元数据：生成向量使用的嵌入模型和向量维度
+type CollectionInfo struct {
+	Collection     string // 集合名称，按别名查询时为别名指向的集合
+	EmbeddingModel string // 早期版本创建的集合没有记录模型，为空
+	Dim            int
+	// RowIDs 主键为knowledge_base表的行ID，写入相同主键时覆盖；
+	// 早期版本创建的集合由向量库自动分配主键，为false
+	RowIDs bool
+}
+
+// Entity 写入向量库的一条知识
+type Entity struct {
+	ID            int64  // 主键，即knowledge_base表的行ID；自动分配主键的集合忽略该字段
+	KnowledgeBase string // 所属知识库
+	Content       string
+	Metadata      []byte // JSON元数据，可以为空
+	Embedding     []float32
+}
+
+// SearchResult 一条搜索结果，Entity不含向量
+type SearchResult struct {
+	Entity
+	Distance float32 // 与查询向量的距离，越小越相似
+}
+
+// Metric 向量距离度量
+type Metric string
+
+// 支持的距离度量，搜索结果都按距离从近到远排列
+const (
+	MetricL2     Metric = "l2"     // 欧氏距离的平方，与Milvus的L2一致
+	MetricCosine Metric = "cosine" // 余弦距离：1 - 余弦相似度
+)
+
+// ParseMetric 解析距离度量，为空时使用L2
+func ParseMetric(s string) (Metric, error) {
+	switch Metric(s) {
+	case "", MetricL2:
+		return MetricL2, nil
+	case MetricCosine:
+		return MetricCosine, nil
+	}
+	return "", fmt.Errorf("unknown vector metric %q: use l2 or cosine", s)
+}
+
+// distance 计算两个向量的距离，norm为b的模长（仅余弦距离使用）
+func (m Metric) distance(a, b []float32, norm float64) float32 {
+	switch m {
+	case MetricCosine:
+		var dot, na float64
+		for i := range a {
+			dot += float64(a[i]) * float64(b[i])
+			na += float64(a[i]) * float64(a[i])
+		}
+		if na == 0 || norm == 0 {
+			return 1
+		}
+		return float32(1 - dot/(math.Sqrt(na)*norm))
+	default:
+		var sum float64
+		for i := range a {
+			d := float64(a[i]) - float64(b[i])
+			sum += d * d
+		}
+		return float32(sum)
+	}
+}
+
+// norm 计算向量的模长
+func norm(v []float32) float64 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	return math.Sqrt(sum)
+}
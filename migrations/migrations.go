@@ -0,0 +1,12 @@
+// Package migrations 内嵌数据库迁移脚本
+//
+// 每种数据库方言一个子目录，文件命名为 <版本号>_<名称>.up.sql / .down.sql，
+// 由 internal/storage/migrate 按版本号顺序执行。
+package migrations
+
+import "embed"
+
+// FS 内嵌的迁移脚本
+//
+//go:embed mysql/*.sql sqlite/*.sql
+var FS embed.FS
@@ -1,8 +1,3 @@
--- 创建数据库（如果不存在）
-CREATE DATABASE IF NOT EXISTS eino_chatbot CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
-
-USE eino_chatbot;
-
 -- 聊天机器人表
 CREATE TABLE IF NOT EXISTS chatbots (
     id VARCHAR(36) PRIMARY KEY,
@@ -0,0 +1,3 @@
+ALTER TABLE conversations
+    DROP INDEX ft_messages,
+    DROP INDEX idx_chatbot_id_id;
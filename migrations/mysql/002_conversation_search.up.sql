@@ -0,0 +1,4 @@
+-- 对话历史分页（按机器人+ID游标）与全文搜索（ngram分词，支持中文）
+ALTER TABLE conversations
+    ADD INDEX idx_chatbot_id_id (chatbot_id, id),
+    ADD FULLTEXT INDEX ft_messages (user_message, bot_message) WITH PARSER ngram;
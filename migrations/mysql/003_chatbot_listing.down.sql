@@ -0,0 +1,3 @@
+ALTER TABLE chatbots
+    DROP INDEX idx_name,
+    DROP INDEX idx_updated_at;
@@ -0,0 +1,4 @@
+-- 聊天机器人列表按更新时间、名称排序
+ALTER TABLE chatbots
+    ADD INDEX idx_updated_at (updated_at),
+    ADD INDEX idx_name (name);
@@ -0,0 +1,5 @@
+ALTER TABLE chatbots
+    DROP INDEX uk_slug,
+    DROP COLUMN options,
+    DROP COLUMN prompt_template,
+    DROP COLUMN slug;
@@ -0,0 +1,6 @@
+-- 人设包：稳定slug、提示词模板和生成参数
+ALTER TABLE chatbots
+    ADD COLUMN slug VARCHAR(64) NULL AFTER id,
+    ADD COLUMN prompt_template TEXT AFTER background,
+    ADD COLUMN options TEXT AFTER prompt_template,
+    ADD UNIQUE INDEX uk_slug (slug);
@@ -0,0 +1,3 @@
+ALTER TABLE conversations DROP COLUMN persona_version;
+ALTER TABLE chatbots DROP COLUMN persona_version;
+DROP TABLE IF EXISTS persona_versions;
@@ -0,0 +1,2 @@
+ALTER TABLE persona_versions DROP COLUMN prompt_format;
+ALTER TABLE chatbots DROP COLUMN prompt_format;
@@ -0,0 +1 @@
+ALTER TABLE conversations DROP INDEX idx_chatbot_parent, DROP COLUMN parent_id;
@@ -0,0 +1,9 @@
+-- 对话树：每轮对话记录上一轮的ID，0表示对话起点
+ALTER TABLE conversations
+    ADD COLUMN parent_id BIGINT NOT NULL DEFAULT 0 AFTER chatbot_id,
+    ADD INDEX idx_chatbot_parent (chatbot_id, parent_id);
+
+-- 已有对话按时间顺序串成一条分支
+UPDATE conversations c
+JOIN (SELECT id, LAG(id, 1, 0) OVER (PARTITION BY chatbot_id ORDER BY id) AS prev FROM conversations) p ON p.id = c.id
+SET c.parent_id = p.prev;
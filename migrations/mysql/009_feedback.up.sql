@@ -0,0 +1,13 @@
+-- 回复反馈，每轮对话最多一条
+CREATE TABLE IF NOT EXISTS feedback (
+    conversation_id BIGINT PRIMARY KEY,
+    chatbot_id VARCHAR(36) NOT NULL,
+    rating TINYINT NOT NULL,
+    tags TEXT,
+    comment TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_chatbot_created (chatbot_id, created_at),
+    FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE,
+    FOREIGN KEY (chatbot_id) REFERENCES chatbots(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
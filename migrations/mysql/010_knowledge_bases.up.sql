@@ -0,0 +1,6 @@
+-- 命名知识库，聊天机器人通过 options.retrieval.knowledge_bases 绑定
+CREATE TABLE IF NOT EXISTS knowledge_bases (
+    name VARCHAR(64) PRIMARY KEY,
+    description TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS reindex_jobs;
+ALTER TABLE knowledge_base
+    DROP INDEX idx_knowledge_base,
+    DROP COLUMN metadata,
+    DROP COLUMN knowledge_base;
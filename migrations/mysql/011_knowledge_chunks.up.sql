@@ -0,0 +1,21 @@
+-- knowledge_base表保存知识分块，作为向量库的数据来源，向量库中的主键与行ID相同
+ALTER TABLE knowledge_base
+    ADD COLUMN knowledge_base VARCHAR(64) NOT NULL DEFAULT 'default' AFTER id,
+    ADD COLUMN metadata TEXT NULL AFTER category,
+    ADD INDEX idx_knowledge_base (knowledge_base);
+
+-- 重建索引任务，服务重启后继续执行未结束的任务
+CREATE TABLE IF NOT EXISTS reindex_jobs (
+    id VARCHAR(36) PRIMARY KEY,
+    status VARCHAR(20) NOT NULL,
+    collection VARCHAR(255) NOT NULL,
+    embedding_model VARCHAR(255) NOT NULL,
+    embedding_dim INT NOT NULL,
+    total_chunks INT NOT NULL DEFAULT 0,
+    embedded_chunks INT NOT NULL DEFAULT 0,
+    last_chunk_id BIGINT NOT NULL DEFAULT 0,
+    error TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,4 @@
+ALTER TABLE knowledge_base
+    DROP INDEX idx_document_id,
+    DROP COLUMN updated_at,
+    DROP COLUMN document_id;
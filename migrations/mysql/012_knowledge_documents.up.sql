@@ -0,0 +1,5 @@
+-- 知识分块记录所属文档和修改时间，按文档列出或删除分块
+ALTER TABLE knowledge_base
+    ADD COLUMN document_id VARCHAR(64) NOT NULL DEFAULT '' AFTER knowledge_base,
+    ADD COLUMN updated_at TIMESTAMP NULL AFTER created_at,
+    ADD INDEX idx_document_id (document_id);
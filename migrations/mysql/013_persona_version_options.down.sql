@@ -0,0 +1 @@
+ALTER TABLE persona_versions DROP COLUMN options;
@@ -0,0 +1,5 @@
+-- 人设版本记录生成参数；已有版本只有各聊天机器人的当前版本能确定生成参数，其余保持NULL
+ALTER TABLE persona_versions ADD COLUMN options TEXT NULL AFTER system_prompt;
+UPDATE persona_versions v
+    JOIN chatbots c ON c.id = v.chatbot_id AND c.persona_version = v.version
+    SET v.options = c.options;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS knowledge_base;
+DROP TABLE IF EXISTS conversations;
+DROP TABLE IF EXISTS chatbots;
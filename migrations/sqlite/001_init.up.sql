@@ -0,0 +1,34 @@
+-- 聊天机器人表
+CREATE TABLE IF NOT EXISTS chatbots (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    personality TEXT,
+    background TEXT,
+    system_prompt TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_chatbots_created_at ON chatbots (created_at);
+
+-- 对话记录表
+CREATE TABLE IF NOT EXISTS conversations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    chatbot_id TEXT NOT NULL,
+    user_message TEXT NOT NULL,
+    bot_message TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (chatbot_id) REFERENCES chatbots(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_conversations_chatbot_id ON conversations (chatbot_id);
+CREATE INDEX IF NOT EXISTS idx_conversations_created_at ON conversations (created_at);
+
+-- 知识库表（可选，用于管理知识库元数据）
+CREATE TABLE IF NOT EXISTS knowledge_base (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    content TEXT NOT NULL,
+    source TEXT,
+    category TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_knowledge_base_category ON knowledge_base (category);
+CREATE INDEX IF NOT EXISTS idx_knowledge_base_created_at ON knowledge_base (created_at);
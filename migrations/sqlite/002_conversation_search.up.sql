@@ -0,0 +1,2 @@
+-- 对话历史分页（按机器人+ID游标）
+CREATE INDEX IF NOT EXISTS idx_conversations_chatbot_id_id ON conversations (chatbot_id, id);
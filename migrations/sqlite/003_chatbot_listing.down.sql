@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_chatbots_name;
+DROP INDEX IF EXISTS idx_chatbots_updated_at;
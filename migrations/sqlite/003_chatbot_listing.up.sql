@@ -0,0 +1,3 @@
+-- 聊天机器人列表按更新时间、名称排序
+CREATE INDEX IF NOT EXISTS idx_chatbots_updated_at ON chatbots (updated_at);
+CREATE INDEX IF NOT EXISTS idx_chatbots_name ON chatbots (name);
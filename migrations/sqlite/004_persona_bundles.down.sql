@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS uk_chatbots_slug;
+ALTER TABLE chatbots DROP COLUMN options;
+ALTER TABLE chatbots DROP COLUMN prompt_template;
+ALTER TABLE chatbots DROP COLUMN slug;
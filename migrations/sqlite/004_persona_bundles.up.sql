@@ -0,0 +1,5 @@
+-- 人设包：稳定slug、提示词模板和生成参数
+ALTER TABLE chatbots ADD COLUMN slug TEXT;
+ALTER TABLE chatbots ADD COLUMN prompt_template TEXT;
+ALTER TABLE chatbots ADD COLUMN options TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS uk_chatbots_slug ON chatbots (slug);
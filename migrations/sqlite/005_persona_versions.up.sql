@@ -0,0 +1,21 @@
+-- 人设版本历史
+CREATE TABLE IF NOT EXISTS persona_versions (
+    chatbot_id TEXT NOT NULL,
+    version INTEGER NOT NULL,
+    personality TEXT,
+    background TEXT,
+    prompt_template TEXT,
+    system_prompt TEXT,
+    comment TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (chatbot_id, version),
+    FOREIGN KEY (chatbot_id) REFERENCES chatbots(id) ON DELETE CASCADE
+);
+
+ALTER TABLE chatbots ADD COLUMN persona_version INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE conversations ADD COLUMN persona_version INTEGER NOT NULL DEFAULT 0;
+
+-- 为已有聊天机器人生成初始版本（已有对话的版本未知，保持0）
+INSERT INTO persona_versions (chatbot_id, version, personality, background, prompt_template, system_prompt, comment, created_at)
+SELECT id, 1, personality, background, prompt_template, system_prompt, 'initial', updated_at FROM chatbots;
+UPDATE chatbots SET persona_version = 1;
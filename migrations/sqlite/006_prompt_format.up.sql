@@ -0,0 +1,3 @@
+-- 系统提示词模板格式：go（默认）, fstring
+ALTER TABLE chatbots ADD COLUMN prompt_format TEXT;
+ALTER TABLE persona_versions ADD COLUMN prompt_format TEXT;
@@ -0,0 +1,2 @@
+-- 对话附加信息（JSON），如人设一致性检查结果
+ALTER TABLE conversations ADD COLUMN metadata TEXT;
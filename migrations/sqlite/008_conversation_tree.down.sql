@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_conversations_chatbot_parent;
+ALTER TABLE conversations DROP COLUMN parent_id;
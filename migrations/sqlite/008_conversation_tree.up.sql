@@ -0,0 +1,8 @@
+-- 对话树：每轮对话记录上一轮的ID，0表示对话起点
+ALTER TABLE conversations ADD COLUMN parent_id INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_conversations_chatbot_parent ON conversations (chatbot_id, parent_id);
+
+-- 已有对话按时间顺序串成一条分支
+UPDATE conversations SET parent_id = p.prev
+FROM (SELECT id, LAG(id, 1, 0) OVER (PARTITION BY chatbot_id ORDER BY id) AS prev FROM conversations) AS p
+WHERE p.id = conversations.id;
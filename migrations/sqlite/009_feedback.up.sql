@@ -0,0 +1,13 @@
+-- 回复反馈，每轮对话最多一条
+CREATE TABLE IF NOT EXISTS feedback (
+    conversation_id INTEGER PRIMARY KEY,
+    chatbot_id TEXT NOT NULL,
+    rating INTEGER NOT NULL,
+    tags TEXT,
+    comment TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE,
+    FOREIGN KEY (chatbot_id) REFERENCES chatbots(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_feedback_chatbot_created ON feedback (chatbot_id, created_at);
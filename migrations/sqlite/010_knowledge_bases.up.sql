@@ -0,0 +1,6 @@
+-- 命名知识库，聊天机器人通过 options.retrieval.knowledge_bases 绑定
+CREATE TABLE IF NOT EXISTS knowledge_bases (
+    name TEXT PRIMARY KEY,
+    description TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS reindex_jobs;
+DROP INDEX IF EXISTS idx_knowledge_base_knowledge_base;
+ALTER TABLE knowledge_base DROP COLUMN metadata;
+ALTER TABLE knowledge_base DROP COLUMN knowledge_base;
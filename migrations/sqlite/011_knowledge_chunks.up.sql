@@ -0,0 +1,20 @@
+-- knowledge_base表保存知识分块，作为向量库的数据来源，向量库中的主键与行ID相同
+ALTER TABLE knowledge_base ADD COLUMN knowledge_base TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE knowledge_base ADD COLUMN metadata TEXT;
+CREATE INDEX IF NOT EXISTS idx_knowledge_base_knowledge_base ON knowledge_base (knowledge_base);
+
+-- 重建索引任务，服务重启后继续执行未结束的任务
+CREATE TABLE IF NOT EXISTS reindex_jobs (
+    id TEXT PRIMARY KEY,
+    status TEXT NOT NULL,
+    collection TEXT NOT NULL,
+    embedding_model TEXT NOT NULL,
+    embedding_dim INTEGER NOT NULL,
+    total_chunks INTEGER NOT NULL DEFAULT 0,
+    embedded_chunks INTEGER NOT NULL DEFAULT 0,
+    last_chunk_id INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP
+);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_knowledge_base_document_id;
+ALTER TABLE knowledge_base DROP COLUMN updated_at;
+ALTER TABLE knowledge_base DROP COLUMN document_id;
@@ -0,0 +1,4 @@
+-- 知识分块记录所属文档和修改时间，按文档列出或删除分块
+ALTER TABLE knowledge_base ADD COLUMN document_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE knowledge_base ADD COLUMN updated_at TIMESTAMP;
+CREATE INDEX IF NOT EXISTS idx_knowledge_base_document_id ON knowledge_base (document_id);
@@ -0,0 +1,6 @@
+-- 人设版本记录生成参数；已有版本只有各聊天机器人的当前版本能确定生成参数，其余保持NULL
+ALTER TABLE persona_versions ADD COLUMN options TEXT;
+UPDATE persona_versions SET options = (
+    SELECT c.options FROM chatbots c
+    WHERE c.id = persona_versions.chatbot_id AND c.persona_version = persona_versions.version
+);